@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+)
+
+// auditCmd shows the audit trail of a product, an actor or both.
+func auditCmd(args []string) error {
+	var sf storeFlags
+	var filter audit.Filter
+
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&filter.ProductID, "product", "", "show the changes to the product with this id")
+	fs.StringVar(&filter.Actor, "actor", "", "show the changes made by this actor")
+	fs.Parse(args)
+
+	if err := filter.Validate(); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	sink, err := newAuditSink(sf.backend)
+	if err != nil {
+		return err
+	}
+
+	l, ok := sink.(audit.Log)
+	if !ok {
+		return fmt.Errorf("the audit sink can't be queried")
+	}
+
+	entries, err := l.Query(filter)
+	if err != nil {
+		return err
+	}
+
+	switch sf.output {
+	case "json":
+		return printJSON(catalog.AuditResponse{Data: entries})
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTOR\tSOURCE IP\tOPERATION\tPRODUCT\tFIELDS")
+		for _, e := range entries {
+			fields := ""
+			for idx, c := range e.Changes {
+				if idx > 0 {
+					fields += ","
+				}
+				fields += c.Field
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Actor, e.SourceIP, e.Operation, e.ProductID, fields)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", sf.output)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// difference describes how a single product in a snapshot differs from the
+// same product in the data store.
+type difference struct {
+	// Change is either added (only in the snapshot), removed (only in the data store)
+	// or changed (in both, but with different values)
+	Change string `json:"change"`
+
+	// ID is the ID of the product
+	ID string `json:"id"`
+
+	// Name is the name of the product
+	Name string `json:"name"`
+
+	// Fields are the names of the fields that have different values
+	Fields []string `json:"fields,omitempty"`
+}
+
+// diffCmd compares a catalog snapshot with the products in the data store.
+func diffCmd(args []string) error {
+	var sf storeFlags
+	var ff fileFlags
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	sf.register(fs)
+	ff.register(fs, "-", "the snapshot to compare, - reads from stdin")
+	fs.Parse(args)
+
+	snapshot, err := readSnapshot(ff)
+	if err != nil {
+		return err
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]datastore.Product)
+	err = db.ForEachProduct(func(p datastore.Product) error {
+		stored[p.Item.ID] = p
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	diffs := make([]difference, 0)
+	for _, p := range snapshot {
+		current, ok := stored[p.Item.ID]
+		if !ok {
+			diffs = append(diffs, difference{Change: "added", ID: p.Item.ID, Name: p.Item.Name})
+			continue
+		}
+		delete(stored, p.Item.ID)
+
+		if fields := changedFields(current, p); len(fields) > 0 {
+			diffs = append(diffs, difference{Change: "changed", ID: p.Item.ID, Name: p.Item.Name, Fields: fields})
+		}
+	}
+	for _, p := range stored {
+		diffs = append(diffs, difference{Change: "removed", ID: p.Item.ID, Name: p.Item.Name})
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].ID < diffs[j].ID
+	})
+
+	if sf.output == "json" {
+		if err := printJSON(diffs); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CHANGE\tID\tNAME\tFIELDS")
+		for _, d := range diffs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Change, d.ID, d.Name, strings.Join(d.Fields, ","))
+		}
+		w.Flush()
+	}
+
+	if len(diffs) > 0 {
+		return errDifferences
+	}
+
+	return nil
+}
+
+// changedFields returns the JSON names of the fields that differ between a and b.
+func changedFields(pa datastore.Product, pb datastore.Product) []string {
+	fields := make([]string, 0)
+	for _, c := range datastore.Diff(&pa, &pb) {
+		fields = append(fields, c.Field)
+	}
+	return fields
+}
@@ -0,0 +1,272 @@
+// Command catalogctl is the admin tool for the catalog of the ACME Serverless Fitness Shop.
+// It seeds, imports, exports and inspects the catalog in any of the supported data stores.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/catalogio"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+)
+
+const usage = `catalogctl is the admin tool for the catalog of the ACME Serverless Fitness Shop.
+
+Usage:
+  catalogctl <command> [flags]
+
+Commands:
+  seed         Add the sample products of the ACME Serverless Fitness Shop
+  import       Add all products from a catalog snapshot
+  export       Write all products to a catalog snapshot
+  get          Show a single product
+  list         Show all products
+  status       Move a single product to draft, published or archived
+  schedule     Set the period in which a single product is available
+  delete       Permanently remove a single product
+  revisions    Show the revision history of a single product
+  rollback     Restore a single product as it was in an earlier revision
+  audit        Show the audit trail of a product or an actor
+  validate     Check a catalog snapshot, or the products in the data store, for errors
+  diff         Compare a catalog snapshot with the products in the data store
+  migrate      Copy all products from one data store to another
+  webhooks     Show all webhook subscriptions
+  subscribe    Subscribe an endpoint to the changes of the catalog
+  unsubscribe  Remove a webhook subscription
+  deliveries   Show the delivery log of a webhook subscription
+
+Run "catalogctl <command> -h" for the flags of a command.
+
+The data store is configured with the same environment variables as the services,
+TABLE and REGION for DynamoDB and MONGO_* for MongoDB. A backend named file:<path>
+uses a catalog snapshot on the local disk as data store. Changes are recorded in the
+audit trail selected with AUDIT_SINK, which defaults to the data store, and published
+as change events like the services do, as set with EVENTS_PUBLISHER and
+EVENTS_DELIVERY.
+`
+
+// command is a single subcommand of catalogctl.
+type command func(args []string) error
+
+var commands = map[string]command{
+	"seed":        seedCmd,
+	"import":      importCmd,
+	"export":      exportCmd,
+	"get":         getCmd,
+	"list":        listCmd,
+	"status":      statusCmd,
+	"schedule":    scheduleCmd,
+	"delete":      deleteCmd,
+	"revisions":   revisionsCmd,
+	"rollback":    rollbackCmd,
+	"audit":       auditCmd,
+	"validate":    validateCmd,
+	"diff":        diffCmd,
+	"migrate":     migrateCmd,
+	"webhooks":    webhooksCmd,
+	"subscribe":   subscribeCmd,
+	"unsubscribe": unsubscribeCmd,
+	"deliveries":  deliveriesCmd,
+}
+
+// publishers are the publishers of the data stores that were opened, which are closed
+// before catalogctl exits so the events they buffer are sent.
+var publishers []bus.Publisher
+
+// errDifferences is returned by commands that completed successfully, but need
+// to report a non-zero exit code (like diff finding differences).
+var errDifferences = fmt.Errorf("differences found")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	err := cmd(os.Args[2:])
+	closePublishers()
+	if err != nil {
+		if err != errDifferences {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		}
+		os.Exit(1)
+	}
+}
+
+// closePublishers closes the publishers of the data stores that were opened.
+func closePublishers() {
+	for _, p := range publishers {
+		if err := p.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "error disconnecting from the message bus: %s\n", err.Error())
+		}
+	}
+	publishers = nil
+}
+
+// storeFlags are the flags shared by all commands that connect to a data store.
+type storeFlags struct {
+	backend string
+	output  string
+}
+
+// register adds the shared flags to the flagset.
+func (s *storeFlags) register(fs *flag.FlagSet) {
+	backend := os.Getenv("CATALOG_BACKEND")
+	if backend == "" {
+		backend = "dynamodb"
+	}
+
+	fs.StringVar(&s.backend, "backend", backend, "the data store to use (dynamodb, mongodb or file:<path>), can also be set with $CATALOG_BACKEND")
+	fs.StringVar(&s.output, "output", "table", "the output format (table or json)")
+}
+
+// store returns the datastore manager for the selected backend.
+func (s *storeFlags) store() (datastore.Manager, error) {
+	return newStore(s.backend)
+}
+
+// newStore returns the datastore manager for the named backend. Changes made through
+// the manager are recorded in the audit trail and the revisions as made by catalogctl
+// and the current user, and published like the services publish them: to
+// EVENTS_PUBLISHER, the webhook subscriptions and, in MongoDB, the log of the live feed,
+// or through the outbox when EVENTS_DELIVERY is outbox. The file:<path> backend doesn't
+// have webhooks or an outbox, so its changes are only published to EVENTS_PUBLISHER.
+func newStore(backend string) (datastore.Manager, error) {
+	events, err := bus.Open(os.Getenv("EVENTS_PUBLISHER"))
+	if err != nil {
+		return nil, fmt.Errorf("error configuring events publisher: %s", err.Error())
+	}
+
+	delivery, err := bus.DeliveryFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring events delivery: %s", err.Error())
+	}
+
+	var db datastore.Manager
+	publisher := events
+
+	switch {
+	case backend == "dynamodb" && delivery == bus.DeliveryOutbox:
+		db = dynamodb.NewWithOutbox()
+	case backend == "dynamodb":
+		db = dynamodb.New()
+		publisher = bus.Multi(events, webhook.NewDispatcher(dynamodb.NewWebhooks()))
+	case backend == "mongodb" && delivery == bus.DeliveryOutbox:
+		db = mongodb.NewWithOutbox()
+	case backend == "mongodb":
+		size, err := feedSize()
+		if err != nil {
+			return nil, err
+		}
+		db = mongodb.New()
+		publisher = bus.Multi(events, webhook.NewDispatcher(mongodb.NewWebhooks()), mongodb.NewFeedLog(size))
+	case strings.HasPrefix(backend, "file:"):
+		db, err = file.New(strings.TrimPrefix(backend, "file:"))
+	default:
+		err = fmt.Errorf("unknown backend %q, must be dynamodb, mongodb or file:<path>", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The events in the outbox are published by the relay of the services
+	if delivery != bus.DeliveryOutbox || strings.HasPrefix(backend, "file:") {
+		db = bus.Wrap(db, publisher, bus.SourceFromEnv())
+	}
+	publishers = append(publishers, publisher)
+
+	sink, err := newAuditSink(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Wrap(db, sink, actor(), ""), nil
+}
+
+// feedSize returns the number of changes the live feed keeps, which is
+// PRODUCTS_STREAM_BUFFER or else 1000, like in the services.
+func feedSize() (int, error) {
+	n := os.Getenv("PRODUCTS_STREAM_BUFFER")
+	if n == "" {
+		return 1000, nil
+	}
+
+	i, err := strconv.Atoi(n)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing PRODUCTS_STREAM_BUFFER: %s", err.Error())
+	}
+	return i, nil
+}
+
+// newAuditSink returns the sink for the audit trail configured with the AUDIT_SINK
+// environment variable, which defaults to the audit log of the named backend. The
+// file:<path> backend keeps its audit log in <path>.audit.
+func newAuditSink(backend string) (audit.Sink, error) {
+	var l audit.Log
+
+	switch {
+	case backend == "dynamodb":
+		l = dynamodb.NewAuditLog()
+	case backend == "mongodb":
+		l = mongodb.NewAuditLog()
+	case strings.HasPrefix(backend, "file:"):
+		l = audit.NewFile(strings.TrimPrefix(backend, "file:") + ".audit")
+	default:
+		return nil, fmt.Errorf("unknown backend %q, must be dynamodb, mongodb or file:<path>", backend)
+	}
+
+	return audit.Open(os.Getenv("AUDIT_SINK"), l)
+}
+
+// actor returns the name under which catalogctl records its changes.
+func actor() string {
+	if u, err := user.Current(); err == nil {
+		return fmt.Sprintf("catalogctl:%s", u.Username)
+	}
+	return "catalogctl"
+}
+
+// fileFlags are the flags shared by all commands that read or write a catalog snapshot.
+type fileFlags struct {
+	file   string
+	format string
+}
+
+// register adds the shared flags to the flagset.
+func (f *fileFlags) register(fs *flag.FlagSet, file string, usage string) {
+	fs.StringVar(&f.file, "file", file, usage)
+	fs.StringVar(&f.format, "format", "", "the format of the snapshot (json, ndjson or csv), defaults to the file extension or json")
+}
+
+// snapshotFormat returns the format set with the format flag, or derives it
+// from the extension of the file.
+func (f *fileFlags) snapshotFormat() (catalogio.Format, error) {
+	if f.format != "" {
+		return catalogio.ParseFormat(f.format)
+	}
+
+	if f.file != "-" {
+		if format, err := catalogio.ParseFormat(strings.TrimPrefix(filepath.Ext(f.file), ".")); err == nil {
+			return format, nil
+		}
+	}
+
+	return catalogio.JSON, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// run runs the command with the arguments and returns what it wrote to stdout. The
+// publishers of the data stores it opened are closed like in main.
+func run(t *testing.T, args []string) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		out <- buf.String()
+	}()
+
+	err = commands[args[0]](args[1:])
+	closePublishers()
+	w.Close()
+
+	return <-out, err
+}
+
+// setenv sets the environment variable until the test ends.
+func setenv(t *testing.T, key string, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// TestCommands runs the commands one after the other against a data store in a snapshot
+// file, and checks what they print and which change events they publish.
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalogctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	setenv(t, "CATALOG_BACKEND", "file:"+filepath.Join(dir, "catalog.ndjson"))
+	setenv(t, "AUDIT_SINK", "")
+	setenv(t, "EVENTS_PUBLISHER", "stdout")
+	setenv(t, "EVENTS_DELIVERY", "")
+
+	mat := sampleProducts[0].ID
+	snapshot := filepath.Join(dir, "snapshot.json")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    bool
+		wantOutput []string
+		wantEvents map[string]int
+	}{
+		{
+			name:       "seed",
+			args:       []string{"seed"},
+			wantOutput: []string{"Yoga Mat", "Water Bottle"},
+			wantEvents: map[string]int{bus.TypeProductCreated: len(sampleProducts)},
+		},
+		{
+			name:       "list",
+			args:       []string{"list", "-output", "json"},
+			wantOutput: []string{`"name": "Yoga Mat"`, `"status": "published"`},
+		},
+		{
+			name:       "get",
+			args:       []string{"get", mat},
+			wantOutput: []string{"Limited Edition Mat"},
+		},
+		{
+			name:    "get unknown product",
+			args:    []string{"get", "unknown"},
+			wantErr: true,
+		},
+		{
+			name:       "status",
+			args:       []string{"status", mat, "draft"},
+			wantOutput: []string{"moved from published to draft"},
+			wantEvents: map[string]int{bus.TypeProductUpdated: 1},
+		},
+		{
+			name:       "schedule",
+			args:       []string{"schedule", "-publish-at", "2030-01-01T00:00:00Z", mat},
+			wantOutput: []string{"available from 2030-01-01T00:00:00Z"},
+			wantEvents: map[string]int{bus.TypeProductUpdated: 1},
+		},
+		{
+			name:       "revisions",
+			args:       []string{"revisions", "-output", "json", mat},
+			wantOutput: []string{`"version": 3`, `"operation": "window"`},
+		},
+		{
+			name:       "rollback",
+			args:       []string{"rollback", mat, "1"},
+			wantOutput: []string{"restored from version 1 as version 4"},
+			wantEvents: map[string]int{bus.TypeProductUpdated: 1},
+		},
+		{
+			name: "export",
+			args: []string{"export", "-file", snapshot},
+		},
+		{
+			name:       "delete",
+			args:       []string{"delete", mat},
+			wantOutput: []string{"deleted product " + mat},
+			wantEvents: map[string]int{bus.TypeProductDeleted: 1},
+		},
+		{
+			name:       "diff with a deleted product",
+			args:       []string{"diff", "-file", snapshot},
+			wantErr:    true,
+			wantOutput: []string{"added", "Yoga Mat"},
+		},
+		{
+			name:       "import",
+			args:       []string{"import", "-file", snapshot},
+			wantOutput: []string{fmt.Sprintf("imported %d products", len(sampleProducts))},
+			wantEvents: map[string]int{bus.TypeProductCreated: 1, bus.TypeProductUpdated: len(sampleProducts) - 1},
+		},
+		{
+			name: "diff after import",
+			args: []string{"diff", "-file", snapshot},
+		},
+		{
+			name:       "validate",
+			args:       []string{"validate"},
+			wantOutput: []string{fmt.Sprintf("all %d products are valid", len(sampleProducts))},
+		},
+		{
+			name:       "audit",
+			args:       []string{"audit", "-output", "json", "-product", mat},
+			wantOutput: []string{`"actor": "catalogctl`, `"operation": "delete"`},
+		},
+	}
+
+	types := []string{bus.TypeProductCreated, bus.TypeProductUpdated, bus.TypeProductDeleted}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error = %v, want error %t\n%s", tt.name, err, tt.wantErr, out)
+		}
+		for _, want := range tt.wantOutput {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: the output doesn't contain %q\n%s", tt.name, want, out)
+			}
+		}
+		for _, typ := range types {
+			if got := strings.Count(out, fmt.Sprintf(`"type":%q`, typ)); got != tt.wantEvents[typ] {
+				t.Errorf("%s: published %d %s events, want %d", tt.name, got, typ, tt.wantEvents[typ])
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/retgits/acme-serverless-catalog/internal/migrate"
+)
+
+// migrateReport is the JSON output of the migrate command.
+type migrateReport struct {
+	Result migrate.Result   `json:"result"`
+	Source *migrate.Summary `json:"source,omitempty"`
+	Target *migrate.Summary `json:"target,omitempty"`
+	Match  *bool            `json:"match,omitempty"`
+}
+
+// migrateCmd copies all products from one data store to another and compares the
+// checksums of both data stores afterwards.
+func migrateCmd(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "the data store to copy from (dynamodb, mongodb or file:<path>)")
+	to := fs.String("to", "", "the data store to copy to (dynamodb, mongodb or file:<path>)")
+	concurrency := fs.Int("concurrency", 4, "the number of products written to the target at the same time")
+	checkpoint := fs.String("checkpoint", "", "the file that tracks copied products, to resume an interrupted migration")
+	dryRun := fs.Bool("dry-run", false, "read all products from the source without writing to the target")
+	verify := fs.Bool("verify", true, "compare the checksums of both data stores after the migration")
+	output := fs.String("output", "table", "the output format (table or json)")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return fmt.Errorf("migrate needs both -from and -to")
+	}
+	if *from == *to {
+		return fmt.Errorf("the source and target data store are the same")
+	}
+
+	source, err := newStore(*from)
+	if err != nil {
+		return err
+	}
+
+	target, err := newStore(*to)
+	if err != nil {
+		return err
+	}
+
+	opts := migrate.Options{
+		Concurrency: *concurrency,
+		DryRun:      *dryRun,
+	}
+
+	if *checkpoint != "" && !*dryRun {
+		cp, err := migrate.OpenCheckpoint(*checkpoint)
+		if err != nil {
+			return err
+		}
+		defer cp.Close()
+
+		if cp.Len() > 0 {
+			fmt.Fprintf(os.Stderr, "resuming migration, %d products were copied before\n", cp.Len())
+		}
+		opts.Checkpoint = cp
+	}
+
+	report := migrateReport{}
+	report.Result, err = migrate.Run(source, target, opts)
+	if err != nil {
+		printMigrateReport(*output, report)
+		return err
+	}
+
+	if *verify && !*dryRun {
+		src, err := migrate.Checksum(source)
+		if err != nil {
+			return fmt.Errorf("error calculating the checksum of the source: %s", err.Error())
+		}
+
+		tgt, err := migrate.Checksum(target)
+		if err != nil {
+			return fmt.Errorf("error calculating the checksum of the target: %s", err.Error())
+		}
+
+		match := src == tgt
+		report.Source = &src
+		report.Target = &tgt
+		report.Match = &match
+	}
+
+	if err := printMigrateReport(*output, report); err != nil {
+		return err
+	}
+
+	if report.Match != nil && !*report.Match {
+		return errDifferences
+	}
+
+	return nil
+}
+
+// printMigrateReport writes the outcome of the migration to stdout.
+func printMigrateReport(output string, report migrateReport) error {
+	if output == "json" {
+		return printJSON(report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Copied\t%d\n", report.Result.Copied)
+	fmt.Fprintf(w, "Skipped\t%d\n", report.Result.Skipped)
+	fmt.Fprintf(w, "Failed\t%d\n", len(report.Result.Failed))
+
+	if report.Match != nil {
+		fmt.Fprintf(w, "Source\t%d products, checksum %s\n", report.Source.Count, report.Source.Checksum)
+		fmt.Fprintf(w, "Target\t%d products, checksum %s\n", report.Target.Count, report.Target.Checksum)
+		if *report.Match {
+			fmt.Fprintln(w, "Result\tboth data stores hold identical catalogs")
+		} else {
+			fmt.Fprintln(w, "Result\tthe catalogs are different")
+		}
+	}
+
+	ids := make([]string, 0, len(report.Result.Failed))
+	for id := range report.Result.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(w, "Failed %s\t%s\n", id, report.Result.Failed[id])
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// printProducts writes the products to stdout as a table or as JSON.
+func printProducts(output string, products []datastore.Product) error {
+	switch output {
+	case "json":
+		return printJSON(catalog.ProductsResponse{Data: products})
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPRICE\tTAGS\tSTATUS\tAVAILABLE")
+		for _, prod := range products {
+			p := prod.Item
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\n", p.ID, p.Name, p.Price, strings.Join(p.Tags, ","), prod.Status, formatWindow(prod.Window))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", output)
+	}
+}
+
+// printProduct writes all details of a single product to stdout as a table or as JSON.
+func printProduct(output string, prod datastore.Product) error {
+	switch output {
+	case "json":
+		return printJSON(prod)
+	case "table":
+		p := prod.Item
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\t%s\n", p.ID)
+		fmt.Fprintf(w, "Name\t%s\n", p.Name)
+		fmt.Fprintf(w, "Short description\t%s\n", p.ShortDescription)
+		fmt.Fprintf(w, "Description\t%s\n", p.Description)
+		fmt.Fprintf(w, "Image 1\t%s\n", p.ImageURL1)
+		fmt.Fprintf(w, "Image 2\t%s\n", p.ImageURL2)
+		fmt.Fprintf(w, "Image 3\t%s\n", p.ImageURL3)
+		fmt.Fprintf(w, "Price\t%.2f\n", p.Price)
+		fmt.Fprintf(w, "Tags\t%s\n", strings.Join(p.Tags, ","))
+		fmt.Fprintf(w, "Status\t%s\n", prod.Status)
+		fmt.Fprintf(w, "Available\t%s\n", formatWindow(prod.Window))
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", output)
+	}
+}
+
+// formatWindow describes the availability window of a product.
+func formatWindow(w datastore.Window) string {
+	switch {
+	case w.PublishAt == nil && w.UnpublishAt == nil:
+		return "always"
+	case w.UnpublishAt == nil:
+		return fmt.Sprintf("from %s", w.PublishAt.Format(time.RFC3339))
+	case w.PublishAt == nil:
+		return fmt.Sprintf("until %s", w.UnpublishAt.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("from %s until %s", w.PublishAt.Format(time.RFC3339), w.UnpublishAt.Format(time.RFC3339))
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// getCmd shows a single product.
+func getCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl get [flags] <product id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("get needs exactly one product id")
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	p, err := db.GetProduct(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return printProduct(sf.output, p)
+}
+
+// listCmd shows all products.
+func listCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	sf.register(fs)
+	fs.Parse(args)
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	products, err := db.GetProducts()
+	if err != nil {
+		return err
+	}
+
+	return printProducts(sf.output, products)
+}
+
+// deleteCmd permanently removes a single product.
+func deleteCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl delete [flags] <product id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("delete needs exactly one product id")
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.DeleteProduct(fs.Arg(0)); err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted product %s\n", fs.Arg(0))
+	return nil
+}
+
+// statusCmd moves a single product to a new lifecycle status.
+func statusCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl status [flags] <product id> <draft|published|archived>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("status needs a product id and a status")
+	}
+
+	status, err := datastore.ParseStatus(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	p, err := db.GetProduct(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.SetProductStatus(p.Item.ID, status); err != nil {
+		return err
+	}
+
+	fmt.Printf("product %s moved from %s to %s\n", p.Item.ID, p.Status, status)
+	return nil
+}
+
+// scheduleCmd sets the availability window of a single product.
+func scheduleCmd(args []string) error {
+	var sf storeFlags
+	var publishAt, unpublishAt string
+
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&publishAt, "publish-at", "", "the moment the product goes live as an RFC3339 timestamp, empty to make it available right away")
+	fs.StringVar(&unpublishAt, "unpublish-at", "", "the moment the product is taken off the storefront as an RFC3339 timestamp, empty to keep it available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl schedule [flags] <product id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("schedule needs exactly one product id")
+	}
+
+	var window datastore.Window
+	var err error
+
+	if window.PublishAt, err = parseTime("publish-at", publishAt); err != nil {
+		return err
+	}
+	if window.UnpublishAt, err = parseTime("unpublish-at", unpublishAt); err != nil {
+		return err
+	}
+	if err := window.Validate(); err != nil {
+		return err
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.SetProductWindow(fs.Arg(0), window); err != nil {
+		return err
+	}
+
+	fmt.Printf("product %s is available %s\n", fs.Arg(0), formatWindow(window))
+	return nil
+}
+
+// parseTime parses the RFC3339 timestamp of the named flag, an empty value means
+// the window is open on that side.
+func parseTime(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+	return &t, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// revisionsCmd shows the revision history of a single product.
+func revisionsCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("revisions", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl revisions [flags] <product id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("revisions needs exactly one product id")
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	revs, err := db.GetRevisions(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return printRevisions(sf.output, revs)
+}
+
+// rollbackCmd restores a single product as it was in an earlier revision.
+func rollbackCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl rollback [flags] <product id> <version>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("rollback needs a product id and a version")
+	}
+
+	version, err := strconv.Atoi(fs.Arg(1))
+	if err != nil || version < 1 {
+		return fmt.Errorf("invalid version %q", fs.Arg(1))
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	rev, err := db.RollbackProduct(fs.Arg(0), version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("product %s restored from version %d as version %d\n", rev.ProductID, rev.RestoredFrom, rev.Version)
+	return nil
+}
+
+// printRevisions writes the revisions to stdout as a table or as JSON.
+func printRevisions(output string, revs []datastore.Revision) error {
+	switch output {
+	case "json":
+		return printJSON(catalog.RevisionsResponse{Data: revs})
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tCREATED\tACTOR\tOPERATION\tNAME\tPRICE\tSTATUS")
+		for _, rev := range revs {
+			op := string(rev.Operation)
+			if rev.RestoredFrom > 0 {
+				op = fmt.Sprintf("%s (v%d)", op, rev.RestoredFrom)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.2f\t%s\n", rev.Version, rev.CreatedAt.Format(time.RFC3339), rev.Actor, op, rev.Product.Item.Name, rev.Product.Item.Price, rev.Product.Status)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", output)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// sampleProducts are the products of the ACME Serverless Fitness Shop demo catalog.
+// They have fixed IDs so seeding the same data store twice doesn't create duplicates.
+var sampleProducts = []acmeserverless.CatalogItem{
+	{
+		ID:               "5c61f497e5fdadefe84ff9b9",
+		Name:             "Yoga Mat",
+		ShortDescription: "Limited Edition Mat",
+		Description:      "Limited edition yoga mat",
+		ImageURL1:        "/static/images/yogamat_square.jpg",
+		ImageURL2:        "/static/images/yogamat_thumb2.jpg",
+		ImageURL3:        "/static/images/yogamat_thumb3.jpg",
+		Price:            62.5,
+		Tags:             []string{"mat"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9ba",
+		Name:             "Water Bottle",
+		ShortDescription: "Best water bottle ever",
+		Description:      "For all those athletes out there, a perfect bottle to enrich you",
+		ImageURL1:        "/static/images/bottle_square.jpg",
+		ImageURL2:        "/static/images/bottle_thumb2.jpg",
+		ImageURL3:        "/static/images/bottle_thumb3.jpg",
+		Price:            34.99,
+		Tags:             []string{"bottle"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9bb",
+		Name:             "Fit Bike",
+		ShortDescription: "Get Light on this bike",
+		Description:      "Ride like the wind with your very own ultra light weight bike",
+		ImageURL1:        "/static/images/bicycle_square.jpg",
+		ImageURL2:        "/static/images/bicycle_thumb2.jpg",
+		ImageURL3:        "/static/images/bicycle_thumb3.jpg",
+		Price:            499.99,
+		Tags:             []string{"bicycle"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9bc",
+		Name:             "Basket Ball",
+		ShortDescription: "World's roundest basketball",
+		Description:      "Perfectly round basketball for a perfect game",
+		ImageURL1:        "/static/images/basketball_square.jpg",
+		ImageURL2:        "/static/images/basketball_thumb2.jpg",
+		ImageURL3:        "/static/images/basketball_thumb3.jpg",
+		Price:            110.75,
+		Tags:             []string{"basketball"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9bd",
+		Name:             "Smart Watch",
+		ShortDescription: "The watch that makes you smarter",
+		Description:      "Track your fitness, heart rate and sleep, and never miss a call",
+		ImageURL1:        "/static/images/smartwatch_square.jpg",
+		ImageURL2:        "/static/images/smartwatch_thumb2.jpg",
+		ImageURL3:        "/static/images/smartwatch_thumb3.jpg",
+		Price:            399.59,
+		Tags:             []string{"watch"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9be",
+		Name:             "Red Pants",
+		ShortDescription: "Because who doesn't need red pants",
+		Description:      "Awesome red pants, to be seen by everyone in the gym",
+		ImageURL1:        "/static/images/redpants_square.jpg",
+		ImageURL2:        "/static/images/redpants_thumb2.jpg",
+		ImageURL3:        "/static/images/redpants_thumb3.jpg",
+		Price:            99,
+		Tags:             []string{"clothing"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9bf",
+		Name:             "Running shoes",
+		ShortDescription: "Shoes that make you run faster",
+		Description:      "Lightweight running shoes with all the cushioning you need",
+		ImageURL1:        "/static/images/shoes_square.jpg",
+		ImageURL2:        "/static/images/shoes_thumb2.jpg",
+		ImageURL3:        "/static/images/shoes_thumb3.jpg",
+		Price:            120,
+		Tags:             []string{"running"},
+	},
+	{
+		ID:               "5c61f497e5fdadefe84ff9c0",
+		Name:             "Weights",
+		ShortDescription: "Get ripped without going to the gym",
+		Description:      "A set of adjustable dumbbells for your home workout",
+		ImageURL1:        "/static/images/weights_square.jpg",
+		ImageURL2:        "/static/images/weights_thumb2.jpg",
+		ImageURL3:        "/static/images/weights_thumb3.jpg",
+		Price:            49.99,
+		Tags:             []string{"weight"},
+	},
+}
+
+// seedCmd adds the sample products to the data store as published products.
+func seedCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	sf.register(fs)
+	fs.Parse(args)
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	products := make([]datastore.Product, 0, len(sampleProducts))
+	for _, p := range sampleProducts {
+		prod := datastore.Product{Item: p, Status: datastore.StatusPublished}
+		if _, err := db.AddProduct(prod); err != nil {
+			return fmt.Errorf("error seeding product %q: %s", p.Name, err.Error())
+		}
+		products = append(products, prod)
+	}
+
+	return printProducts(sf.output, products)
+}
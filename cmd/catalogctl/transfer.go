@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/catalogio"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// importCmd adds all products from a catalog snapshot to the data store. Products
+// without an ID get a new one, products with an existing ID are replaced.
+func importCmd(args []string) error {
+	var sf storeFlags
+	var ff fileFlags
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	sf.register(fs)
+	ff.register(fs, "-", "the snapshot to import, - reads from stdin")
+	dryRun := fs.Bool("dry-run", false, "validate the snapshot without writing to the data store")
+	fs.Parse(args)
+
+	products, err := readSnapshot(ff)
+	if err != nil {
+		return err
+	}
+
+	if problems := validateProducts(products, false); len(problems) > 0 {
+		printProblems(problems)
+		return fmt.Errorf("the snapshot has %d problems, nothing was imported", len(problems))
+	}
+
+	if *dryRun {
+		fmt.Printf("%d products are valid and can be imported\n", len(products))
+		return nil
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if p.Item.ID == "" {
+			p.Item.ID = uuid.Must(uuid.NewV4()).String()
+		}
+		if _, err := db.AddProduct(p); err != nil {
+			return fmt.Errorf("error importing product %q: %s", p.Item.Name, err.Error())
+		}
+	}
+
+	fmt.Printf("imported %d products\n", len(products))
+	return nil
+}
+
+// exportCmd writes all products in the data store to a catalog snapshot.
+func exportCmd(args []string) error {
+	var sf storeFlags
+	var ff fileFlags
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	sf.register(fs)
+	ff.register(fs, "-", "the file to write the snapshot to, - writes to stdout")
+	fs.Parse(args)
+
+	format, err := ff.snapshotFormat()
+	if err != nil {
+		return err
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if ff.file != "-" {
+		f, err := os.Create(ff.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc, err := catalogio.NewEncoder(w, format)
+	if err != nil {
+		return err
+	}
+
+	if err := db.ForEachProduct(enc.Encode); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// readSnapshot reads all products from the snapshot selected by the file flags.
+func readSnapshot(ff fileFlags) ([]datastore.Product, error) {
+	format, err := ff.snapshotFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = os.Stdin
+	if ff.file != "-" {
+		f, err := os.Open(ff.file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dec, err := catalogio.NewDecoder(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]datastore.Product, 0)
+	for {
+		p, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading product %d of the snapshot: %s", len(products)+1, err.Error())
+		}
+		products = append(products, p)
+	}
+
+	return products, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// problem describes why a product in the catalog isn't valid.
+type problem struct {
+	// Position is the 1-based position of the product in the snapshot or data store
+	Position int `json:"position"`
+
+	// ID is the ID of the product, if it has one
+	ID string `json:"id"`
+
+	// Message describes the problem
+	Message string `json:"message"`
+}
+
+// validateCmd checks a catalog snapshot, or if no file is given all products in
+// the data store, for errors.
+func validateCmd(args []string) error {
+	var sf storeFlags
+	var ff fileFlags
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	sf.register(fs)
+	ff.register(fs, "", "the snapshot to validate, leave empty to validate the data store")
+	fs.Parse(args)
+
+	// Products in the data store must have an ID, while products in a
+	// snapshot get one when they're imported
+	requireIDs := ff.file == ""
+
+	products, err := loadProducts(sf, ff)
+	if err != nil {
+		return err
+	}
+
+	problems := validateProducts(products, requireIDs)
+
+	if sf.output == "json" {
+		if err := printJSON(problems); err != nil {
+			return err
+		}
+	} else if len(problems) > 0 {
+		printProblems(problems)
+	} else {
+		fmt.Printf("all %d products are valid\n", len(products))
+	}
+
+	if len(problems) > 0 {
+		return errDifferences
+	}
+
+	return nil
+}
+
+// loadProducts reads all products from the snapshot, or from the data store if no
+// snapshot file is set.
+func loadProducts(sf storeFlags, ff fileFlags) ([]datastore.Product, error) {
+	if ff.file != "" {
+		return readSnapshot(ff)
+	}
+
+	db, err := sf.store()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetProducts()
+}
+
+// validateProducts returns the problems of all products. If requireIDs is set,
+// products without an ID are reported as well.
+func validateProducts(products []datastore.Product, requireIDs bool) []problem {
+	problems := make([]problem, 0)
+	seen := make(map[string]int)
+
+	for idx, prod := range products {
+		p := prod.Item
+		add := func(format string, a ...interface{}) {
+			problems = append(problems, problem{Position: idx + 1, ID: p.ID, Message: fmt.Sprintf(format, a...)})
+		}
+
+		if p.ID == "" && requireIDs {
+			add("id is empty")
+		}
+		if p.ID != "" {
+			if first, ok := seen[p.ID]; ok {
+				add("id is also used by product %d", first)
+			} else {
+				seen[p.ID] = idx + 1
+			}
+		}
+		if strings.TrimSpace(p.Name) == "" {
+			add("name is empty")
+		}
+		if p.Price < 0 {
+			add("price %.2f is negative", p.Price)
+		}
+		if p.ImageURL1 == "" {
+			add("imageUrl1 is empty")
+		}
+	}
+
+	return problems
+}
+
+// printProblems writes the problems to stderr as a table.
+func printProblems(problems []problem) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POSITION\tID\tPROBLEM")
+	for _, p := range problems {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", p.Position, p.ID, p.Message)
+	}
+	w.Flush()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+)
+
+// webhooksCmd shows all webhook subscriptions.
+func webhooksCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("webhooks", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl webhooks [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	store, err := newWebhookStore(sf.backend)
+	if err != nil {
+		return err
+	}
+
+	subs, err := store.Subscriptions(context.Background())
+	if err != nil {
+		return err
+	}
+
+	switch sf.output {
+	case "json":
+		return printJSON(catalog.WebhooksResponse{Data: subs})
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tURL\tEVENTS\tCREATED\tCREATED BY")
+		for _, s := range subs {
+			events := "all"
+			if len(s.Events) > 0 {
+				events = strings.Join(s.Events, ",")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.URL, events, s.CreatedAt.Format(time.RFC3339), s.CreatedBy)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", sf.output)
+	}
+}
+
+// subscribeCmd subscribes an endpoint to the changes of the catalog.
+func subscribeCmd(args []string) error {
+	var sf storeFlags
+	var events, secret string
+
+	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&events, "events", "", "a comma separated list of the events to deliver, like ProductCreated,ProductDeleted, defaults to all events")
+	fs.StringVar(&secret, "secret", "", "the secret the deliveries are signed with, defaults to a random secret")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl subscribe [flags] <url>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("subscribe needs exactly one url")
+	}
+
+	var types []string
+	for _, e := range strings.Split(events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			types = append(types, e)
+		}
+	}
+
+	s, err := webhook.NewSubscription(fs.Arg(0), types, secret, actor())
+	if err != nil {
+		return err
+	}
+
+	store, err := newWebhookStore(sf.backend)
+	if err != nil {
+		return err
+	}
+
+	if err := store.AddSubscription(context.Background(), s); err != nil {
+		return err
+	}
+
+	if sf.output == "json" {
+		return printJSON(catalog.WebhookResponse{Subscription: s, Secret: s.Secret})
+	}
+
+	fmt.Printf("subscription %s created, the deliveries are signed with %s\n", s.ID, s.Secret)
+	return nil
+}
+
+// unsubscribeCmd removes a webhook subscription.
+func unsubscribeCmd(args []string) error {
+	var sf storeFlags
+
+	fs := flag.NewFlagSet("unsubscribe", flag.ExitOnError)
+	sf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl unsubscribe [flags] <subscription id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("unsubscribe needs exactly one subscription id")
+	}
+
+	store, err := newWebhookStore(sf.backend)
+	if err != nil {
+		return err
+	}
+
+	if err := store.RemoveSubscription(context.Background(), fs.Arg(0)); err != nil {
+		return err
+	}
+
+	fmt.Printf("subscription %s removed\n", fs.Arg(0))
+	return nil
+}
+
+// deliveriesCmd shows the delivery log of a webhook subscription.
+func deliveriesCmd(args []string) error {
+	var sf storeFlags
+	var limit int
+
+	fs := flag.NewFlagSet("deliveries", flag.ExitOnError)
+	sf.register(fs)
+	fs.IntVar(&limit, "limit", 50, "the number of deliveries to show, newest first")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalogctl deliveries [flags] <subscription id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("deliveries needs exactly one subscription id")
+	}
+	if limit < 1 {
+		return fmt.Errorf("limit must be at least 1")
+	}
+
+	store, err := newWebhookStore(sf.backend)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := store.Deliveries(context.Background(), fs.Arg(0), limit)
+	if err != nil {
+		return err
+	}
+
+	switch sf.output {
+	case "json":
+		return printJSON(catalog.DeliveriesResponse{Data: deliveries})
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tEVENT\tSTATUS\tATTEMPTS\tCODE\tERROR")
+		for _, d := range deliveries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", d.ID, d.CreatedAt.Format(time.RFC3339), d.EventType, d.Status, d.Attempts, d.StatusCode, d.Error)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output %q, must be table or json", sf.output)
+	}
+}
+
+// newWebhookStore returns the webhook store of the named backend. Catalog snapshots
+// can't hold webhook subscriptions.
+func newWebhookStore(backend string) (webhook.Store, error) {
+	switch backend {
+	case "dynamodb":
+		return dynamodb.NewWebhooks(), nil
+	case "mongodb":
+		return mongodb.NewWebhooks(), nil
+	default:
+		return nil, fmt.Errorf("backend %q doesn't support webhooks, must be dynamodb or mongodb", backend)
+	}
+}
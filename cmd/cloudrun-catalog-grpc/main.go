@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/process"
+	"github.com/retgits/acme-serverless-catalog/internal/rpc"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+	"github.com/retgits/acme-serverless-catalog/pkg/catalogpb"
+	"google.golang.org/grpc"
+)
+
+const (
+	servicename = "catalog-grpc"
+)
+
+func main() {
+	// Write the logs as JSON, including the lines of the standard logger
+	logger, err := logging.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring logging: %s", err.Error())
+	}
+	logging.SetDefault(logger)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.LevelInfo))
+
+	// Get the version or set a default to "dev"
+	version := os.Getenv("VERSION")
+	if version == "" {
+		version = "dev"
+	}
+
+	// Get the server port or set it to 8080
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// Get the service name
+	service := os.Getenv("K_SERVICE")
+	if service == "" {
+		service = servicename
+	}
+
+	// Initialize a connection to Sentry to capture errors
+	if err := sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  service,
+		Release:     version,
+		Environment: os.Getenv("STAGE"),
+	}); err != nil {
+		log.Fatalf("error configuring sentry: %s", err.Error())
+	}
+
+	// Export the traces as configured in the OTEL_* environment variables
+	traceCfg, err := tracing.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring tracing: %s", err.Error())
+	}
+	if err := tracing.Init(traceCfg); err != nil {
+		log.Fatalf("error configuring tracing: %s", err.Error())
+	}
+
+	// Create the authenticator that checks the callers that change the catalog
+	authenticator, err := auth.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+
+	// Publish the changes to the catalog, so other services can react to them
+	events, err := bus.Open(os.Getenv("EVENTS_PUBLISHER"))
+	if err != nil {
+		log.Fatalf("error configuring events publisher: %s", err.Error())
+	}
+
+	// Get the number of changes the watchers can catch up on when they reconnect or set it
+	// to 1000
+	feedSize := 1000
+	if n := os.Getenv("PRODUCTS_STREAM_BUFFER"); n != "" {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			log.Fatalf("error parsing PRODUCTS_STREAM_BUFFER: %s", err.Error())
+		}
+		feedSize = i
+	}
+
+	// Queue the events for the webhook subscriptions too, which are sent by the HTTP
+	// service, and add them to the log of the live feed, which every instance of both
+	// services pushes to the clients that watch the products
+	dispatcher := webhook.NewDispatcher(mongodb.NewWebhooks())
+	feedLog := mongodb.NewFeedLog(feedSize)
+	feed := bus.NewBroadcaster(feedSize)
+	publisher := bus.Multi(events, dispatcher, feedLog)
+
+	// Publish the events right after the change, or from the outbox that is written in the
+	// same transaction as the change
+	delivery, err := bus.DeliveryFromEnv()
+	if err != nil {
+		log.Fatalf("error configuring events delivery: %s", err.Error())
+	}
+
+	// Create an instance of the datastore manager, which traces its calls as part of the
+	// call and publishes an event for every change
+	source := bus.SourceFromEnv()
+	var db datastore.Manager
+	var relay *bus.Relay
+	if delivery == bus.DeliveryOutbox {
+		db = tracing.Instrument(mongodb.NewWithOutbox(), "mongodb")
+		relay = bus.NewRelay(mongodb.NewOutbox(), map[string]bus.Publisher{"events": events, "webhooks": dispatcher, "feed": feedLog}, source)
+	} else {
+		db = bus.Wrap(tracing.Instrument(mongodb.New(), "mongodb"), publisher, source)
+	}
+
+	// Create the sink for the audit trail
+	sink, err := audit.Open(os.Getenv("AUDIT_SINK"), mongodb.NewAuditLog())
+	if err != nil {
+		log.Fatalf("error configuring audit sink: %s", err.Error())
+	}
+
+	// Get the time in-flight calls get to finish on shutdown or set it to 5 seconds
+	shutdownTimeout := 5 * time.Second
+	if t := os.Getenv("SHUTDOWN_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			log.Fatalf("error parsing SHUTDOWN_TIMEOUT: %s", err.Error())
+		}
+		shutdownTimeout = d
+	}
+
+	// Get the interval at which the outbox is drained or set it to a second
+	relayInterval := time.Second
+	if i := os.Getenv("EVENTS_RELAY_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing EVENTS_RELAY_INTERVAL: %s", err.Error())
+		}
+		relayInterval = d
+	}
+
+	// Get the interval at which the log of the live feed is read again when it fails or
+	// set it to 5 seconds
+	feedRetry := 5 * time.Second
+	if i := os.Getenv("STREAM_RETRY_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing STREAM_RETRY_INTERVAL: %s", err.Error())
+		}
+		feedRetry = d
+	}
+
+	// Every call gets a request ID and a span, before it's handled
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(), tracing.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(), tracing.StreamServerInterceptor()),
+	)
+	catalogpb.RegisterCatalogServiceServer(server, rpc.NewServer(db, sink, authenticator, feed))
+
+	// Publish the events in the outbox and follow the log of the live feed in the
+	// background
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		process.RunRelay(relayInterval, relay, stop)
+	}()
+	go func() {
+		defer background.Done()
+		process.RunFeed(feed, feedLog, feedRetry, stop)
+	}()
+
+	// Start the server
+	logger.Info("successfully started server", "service", servicename, "port", port)
+	err = serve(server, fmt.Sprintf(":%s", port), shutdownTimeout, func() {
+		// End the watchers, which would otherwise keep their streams open
+		feed.Close()
+	})
+
+	// Stop the background jobs before the connection to the data store is closed
+	close(stop)
+	background.Wait()
+
+	if ferr := process.Flush(process.FlushTimeout(shutdownTimeout), nil, publisher); ferr != nil {
+		logger.Error("error flushing", "error", ferr)
+	}
+	if err != nil {
+		log.Fatalf("error running %s server: %s", servicename, err.Error())
+	}
+	logger.Info("successfully stopped server", "service", servicename)
+}
+
+// serve handles calls until the process receives SIGTERM or SIGINT. It then calls
+// draining, stops accepting connections and waits up to timeout for the calls in flight
+// to finish, after which they're cancelled.
+func serve(server *grpc.Server, addr string, timeout time.Duration, draining func()) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(lis)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		logging.Default().Info("draining calls", "signal", sig.String())
+	}
+	draining()
+
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		server.Stop()
+		return fmt.Errorf("calls still in flight after %s", timeout)
+	}
+}
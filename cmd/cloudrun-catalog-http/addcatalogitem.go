@@ -5,21 +5,22 @@ import (
 
 	"github.com/gofrs/uuid"
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
 	"github.com/valyala/fasthttp"
 )
 
 // AddCatalogItem ...
 func AddCatalogItem(ctx *fasthttp.RequestCtx) {
 	// Update the product with an ID
-	prod, err := acmeserverless.UnmarshalCatalogItem(string(ctx.Request.Body()))
+	prod, err := catalog.UnmarshalProduct(string(ctx.Request.Body()))
 	if err != nil {
-		ErrorHandler(ctx, "AddCatalogItem", "UnmarshalCatalogItem", err)
+		ErrorHandler(ctx, "AddCatalogItem", "UnmarshalProduct", err)
 		return
 	}
-	prod.ID = uuid.Must(uuid.NewV4()).String()
+	prod.Item.ID = uuid.Must(uuid.NewV4()).String()
 
 	// Store a new product in the catalog
-	err = db.AddProduct(prod)
+	_, err = auditedDB(ctx).AddProduct(prod)
 	if err != nil {
 		ErrorHandler(ctx, "AddCatalogItem", "AddProduct", err)
 		return
@@ -27,7 +28,7 @@ func AddCatalogItem(ctx *fasthttp.RequestCtx) {
 
 	status := acmeserverless.CreateCatalogItemResponse{
 		Message:    "Product created successfully!",
-		ResourceID: prod,
+		ResourceID: prod.Item,
 		Status:     http.StatusOK,
 	}
 
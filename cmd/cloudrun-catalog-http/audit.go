@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetAuditTrail returns the entries of the audit trail for the product in the productId
+// query parameter, the actor in the actor query parameter, or both.
+func GetAuditTrail(ctx *fasthttp.RequestCtx) {
+	l, ok := auditSink.(audit.Log)
+	if !ok {
+		ErrorHandler(ctx, "GetAuditTrail", "Query", fmt.Errorf("the audit sink can't be queried"))
+		return
+	}
+
+	entries, err := l.Query(audit.Filter{
+		ProductID: string(ctx.QueryArgs().Peek("productId")),
+		Actor:     string(ctx.QueryArgs().Peek("actor")),
+	})
+	if err != nil {
+		ErrorHandler(ctx, "GetAuditTrail", "Query", err)
+		return
+	}
+
+	res := catalog.AuditResponse{
+		Data: entries,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetAuditTrail", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// auditedDB returns the datastore manager that records the changes made during the
+// request in the audit trail.
+func auditedDB(ctx *fasthttp.RequestCtx) datastore.Manager {
+	return audit.Wrap(requestDB(ctx), auditSink, requestActor(ctx), sourceIP(ctx))
+}
+
+// requestActor returns the identity of the caller, as verified by the auth middleware.
+func requestActor(ctx *fasthttp.RequestCtx) string {
+	if p, ok := auth.PrincipalFromRequest(ctx); ok && p.Name != "" {
+		return p.Name
+	}
+	return audit.Anonymous
+}
+
+// sourceIP returns the IP address of the caller. The front end of Cloud Run appends the
+// address it received the request from to the X-Forwarded-For header, so only the last
+// entry can be trusted, the ones before it are sent by the client.
+func sourceIP(ctx *fasthttp.RequestCtx) string {
+	xff := string(ctx.Request.Header.Peek("X-Forwarded-For"))
+	if i := strings.LastIndex(xff, ","); i >= 0 {
+		xff = xff[i+1:]
+	}
+	if ip := strings.TrimSpace(xff); ip != "" {
+		return ip
+	}
+	return ctx.RemoteIP().String()
+}
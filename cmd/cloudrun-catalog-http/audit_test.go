@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSourceIP(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("169.254.1.1"), Port: 43210}
+
+	tests := []struct {
+		name string
+		xff  string
+		want string
+	}{
+		{name: "no header", want: "169.254.1.1"},
+		{name: "one address", xff: "203.0.113.7", want: "203.0.113.7"},
+		{name: "spoofed address before the real one", xff: "10.0.0.1, 198.51.100.2 , 203.0.113.7", want: "203.0.113.7"},
+		{name: "empty last entry", xff: "203.0.113.7, ", want: "169.254.1.1"},
+	}
+
+	for _, tt := range tests {
+		var req fasthttp.Request
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+
+		var ctx fasthttp.RequestCtx
+		ctx.Init(&req, remote, nil)
+
+		if got := sourceIP(&ctx); got != tt.want {
+			t.Errorf("%s: sourceIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/valyala/fasthttp"
+)
+
+// BatchGetCatalogItems returns the products with the IDs in the request body, together
+// with the IDs of the products that don't exist.
+func BatchGetCatalogItems(ctx *fasthttp.RequestCtx) {
+	req, err := catalog.UnmarshalBatchGetRequest(string(ctx.Request.Body()))
+	if err != nil {
+		ErrorHandler(ctx, "BatchGetCatalogItems", "UnmarshalBatchGetRequest", err)
+		return
+	}
+
+	getCatalogItemsByIDs(ctx, "BatchGetCatalogItems", req.IDs)
+}
+
+// getCatalogItemsByIDs writes the products with the given IDs, together with the IDs
+// of the products that don't exist, as the response.
+func getCatalogItemsByIDs(ctx *fasthttp.RequestCtx, function string, ids []string) {
+	ids, err := catalog.CleanIDs(ids)
+	if err != nil {
+		ErrorHandler(ctx, function, "CleanIDs", err)
+		return
+	}
+
+	// Get the requested products from the catalog
+	products, err := requestDB(ctx).GetProductsByIDs(ids)
+	if err != nil {
+		ErrorHandler(ctx, function, "GetProductsByIDs", err)
+		return
+	}
+
+	res := catalog.NewBatchGetResponse(ids, products, time.Now())
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, function, "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/pkg/catalogclient"
+)
+
+// TestCatalogClient runs the client of the catalog against the handlers of the API.
+func TestCatalogClient(t *testing.T) {
+	url, _ := testServer(t)
+	ctx := context.Background()
+
+	// The server waits for the idle connections when it shuts down, so they're closed
+	// before that
+	client := &http.Client{Transport: &http.Transport{}}
+	t.Cleanup(client.CloseIdleConnections)
+
+	admin, err := catalogclient.New(catalogclient.Config{BaseURL: url, HTTPClient: client, APIKey: testAPIKey, MinBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := catalogclient.New(catalogclient.Config{BaseURL: url, HTTPClient: client, MinBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := public.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Watch() = %v", err)
+	}
+	defer watcher.Close()
+
+	// Creating products needs the write role
+	if _, err := public.CreateProduct(ctx, catalogclient.Product{}); !catalogclient.IsUnauthorized(err) {
+		t.Errorf("CreateProduct() without credentials = %v, want a 401", err)
+	}
+
+	var ids []string
+	for i, name := range []string{"Red bottle", "Blue bottle", "Yoga mat"} {
+		item, err := admin.CreateProduct(ctx, catalogclient.Product{CatalogItem: acmeserverless.CatalogItem{
+			Name:  name,
+			Price: float32(10 * (i + 1)),
+			Tags:  []string{"sale"},
+		}, Status: catalogclient.StatusPublished})
+		if err != nil {
+			t.Fatalf("CreateProduct() = %v", err)
+		}
+		if item.ID == "" || item.Name != name {
+			t.Fatalf("CreateProduct() = %+v, want the product with its ID", item)
+		}
+		ids = append(ids, item.ID)
+	}
+
+	change, err := watcher.Next()
+	if err != nil || change.Type != catalogclient.ChangeProduct || change.ProductID != ids[0] || change.EventID == "" {
+		t.Errorf("Next() = %+v, %v, want the first product", change, err)
+	}
+
+	item, err := public.GetProduct(ctx, ids[1])
+	if err != nil || item.Name != "Blue bottle" {
+		t.Errorf("GetProduct() = %+v, %v, want the blue bottle", item, err)
+	}
+	if _, err := public.GetProduct(ctx, "unknown"); !catalogclient.IsNotFound(err) {
+		t.Errorf("GetProduct() of an unknown product = %v, want not found", err)
+	}
+
+	// The iterator pages through the GraphQL connection
+	it := public.Products(ctx, catalogclient.ProductQuery{PageSize: 2})
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if it.Err() != nil || n != 3 || it.TotalCount() != 3 {
+		t.Errorf("Products() = %d products, total %d, %v, want 3", n, it.TotalCount(), it.Err())
+	}
+
+	it = public.Products(ctx, catalogclient.ProductQuery{Search: "bottle", PageSize: 1})
+	n = 0
+	for it.Next() {
+		n++
+	}
+	if it.Err() != nil || n != 2 {
+		t.Errorf("Products() searching bottle = %d products, %v, want 2", n, it.Err())
+	}
+
+	// A product that is taken off the storefront is removed from the feed and the lists
+	if _, err := admin.SetProductStatus(ctx, ids[0], catalogclient.StatusDraft); err != nil {
+		t.Fatalf("SetProductStatus() = %v", err)
+	}
+	for {
+		change, err := watcher.Next()
+		if err != nil {
+			t.Fatalf("Next() = %v", err)
+		}
+		if change.Type == catalogclient.ChangeRemove {
+			if change.ProductID != ids[0] {
+				t.Errorf("Next() = %+v, want %s removed", change, ids[0])
+			}
+			break
+		}
+	}
+
+	res, err := public.BatchGetProducts(ctx, ids)
+	if err != nil || len(res.Items) != 2 || len(res.Missing) != 1 || res.Missing[0] != ids[0] {
+		t.Errorf("BatchGetProducts() = %+v, %v, want the draft missing", res, err)
+	}
+
+	revisions, err := admin.ProductRevisions(ctx, ids[0])
+	if err != nil || len(revisions) != 2 {
+		t.Errorf("ProductRevisions() = %+v, %v, want 2 revisions", revisions, err)
+	}
+
+	// GraphQL errors are reported as such
+	var out struct{}
+	err = public.GraphQL(ctx, `query { products { color } }`, nil, &out)
+	if _, ok := err.(catalogclient.GraphQLErrors); !ok {
+		t.Errorf("GraphQL() with an unknown field = %v, want GraphQL errors", err)
+	}
+}
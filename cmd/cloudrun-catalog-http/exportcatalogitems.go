@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	sentryfasthttp "github.com/getsentry/sentry-go/fasthttp"
+	"github.com/retgits/acme-serverless-catalog/internal/catalogio"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/valyala/fasthttp"
+)
+
+// ExportCatalogItems streams all products from the catalog in the format requested
+// by the format query parameter (json, ndjson or csv).
+func ExportCatalogItems(ctx *fasthttp.RequestCtx) {
+	format, err := catalogio.ParseFormat(string(ctx.QueryArgs().Peek("format")))
+	if err != nil {
+		ErrorHandler(ctx, "ExportCatalogItems", "ParseFormat", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType(format.ContentType())
+	ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"catalog.%s\"", format.Extension()))
+
+	// The request can't be used once the handler returns, so the manager that is
+	// traced with it, and the context the errors are reported with, are created up front
+	store := requestDB(ctx)
+	c := requestContext(ctx)
+	hub := sentryfasthttp.GetHubFromContext(ctx)
+	exportError := func(method string, err error) {
+		reportExportError(hub, c, method, err)
+	}
+
+	// The body is written after the handler returns, so errors that happen while
+	// streaming can't change the status code anymore and are only reported
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		enc, err := catalogio.NewEncoder(w, format)
+		if err != nil {
+			exportError("NewEncoder", err)
+			return
+		}
+
+		// The snapshot contains products in every status, so it can be used to
+		// seed another environment
+		if err := store.ForEachProduct(enc.Encode); err != nil {
+			exportError("ForEachProduct", err)
+		}
+
+		if err := enc.Close(); err != nil {
+			exportError("Close", err)
+		}
+	})
+}
+
+// reportExportError logs the errors that occur while streaming the export and sends
+// them to sentry.
+func reportExportError(hub *sentry.Hub, c context.Context, method string, err error) {
+	logging.FromContext(c).Error("error streaming export", "function", "ExportCatalogItems", "method", method, "error", err)
+	captureException(hub, c, fmt.Errorf("error in ExportCatalogItems::%s %s", method, err.Error()))
+}
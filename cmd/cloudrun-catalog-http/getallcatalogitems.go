@@ -2,22 +2,31 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
 	"github.com/valyala/fasthttp"
 )
 
 // GetAllCatalogItems ...
 func GetAllCatalogItems(ctx *fasthttp.RequestCtx) {
+	// Only get the requested products when a list of IDs is passed in
+	if ctx.QueryArgs().Has("ids") {
+		getCatalogItemsByIDs(ctx, "GetAllCatalogItems", catalog.ParseIDs(string(ctx.QueryArgs().Peek("ids"))))
+		return
+	}
+
 	// Get all products from the catalog
-	products, err := db.GetProducts()
+	products, err := requestDB(ctx).GetProducts()
 	if err != nil {
 		ErrorHandler(ctx, "GetAllCatalogItems", "GetProducts", err)
 		return
 	}
 
+	// Only published products are shown in the catalog
 	res := acmeserverless.AllCatalogItemsResponse{
-		Data: products,
+		Data: catalog.ListedItems(products, time.Now()),
 	}
 
 	payload, err := res.Marshal()
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -12,13 +14,19 @@ func GetCatalogItemDetails(ctx *fasthttp.RequestCtx) {
 	productID := ctx.UserValue("id").(string)
 
 	// Get all products from the catalog
-	prod, err := db.GetProduct(productID)
+	prod, err := requestDB(ctx).GetProduct(productID)
 	if err != nil {
 		ErrorHandler(ctx, "GetCatalogItemDetails", "GetProduct", err)
 		return
 	}
 
-	payload, err := prod.Marshal()
+	// Drafts are treated as if they don't exist yet
+	if !prod.Visible(time.Now()) {
+		ErrorHandler(ctx, "GetCatalogItemDetails", "Visible", fmt.Errorf("Unable to find product with id %s", productID))
+		return
+	}
+
+	payload, err := prod.Item.Marshal()
 	if err != nil {
 		ErrorHandler(ctx, "GetCatalogItemDetails", "Marshal", err)
 		return
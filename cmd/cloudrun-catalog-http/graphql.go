@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/graph"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/valyala/fasthttp"
+)
+
+// GraphQL runs a GraphQL request against the catalog. Queries can be sent with GET, in the
+// query, operationName and variables query parameters, and queries and mutations with POST
+// as JSON. Reading the storefront is public, the mutations and the fields that show the
+// products which aren't on the storefront need the write role.
+func GraphQL(ctx *fasthttp.RequestCtx) {
+	var req graph.Request
+	var err error
+
+	if ctx.IsGet() {
+		args := ctx.QueryArgs()
+		req, err = graph.QueryRequest(string(args.Peek("query")), string(args.Peek("operationName")), string(args.Peek("variables")))
+	} else {
+		req, err = graph.UnmarshalRequest(ctx.Request.Body())
+	}
+	if err != nil {
+		ErrorHandler(ctx, "GraphQL", "UnmarshalRequest", err)
+		return
+	}
+
+	// Callers that hold the write role change the catalog in their own name, everyone
+	// else can only read it
+	session := &graph.Session{DB: requestDB(ctx)}
+	p, _, err := auth.Authorize(authenticator, auth.RoleWrite, auth.Credentials{
+		Authorization: string(ctx.Request.Header.Peek("Authorization")),
+		APIKey:        string(ctx.Request.Header.Peek("X-API-Key")),
+	})
+	if err != nil {
+		session.Denied = err
+	} else {
+		session.Writer = audit.Wrap(requestDB(ctx), auditSink, p.Name, sourceIP(ctx))
+	}
+
+	res := graph.Do(requestContext(ctx), session, req)
+	if len(res.Errors) > 0 {
+		logging.FromRequest(ctx).Warn("graphql request returned errors", "function", "GraphQL", "operation", req.OperationName, "errors", len(res.Errors), "error", res.Errors[0].Message)
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GraphQL", "Marshal", err)
+		return
+	}
+
+	// Requests that can't be executed at all are rejected, errors of single fields are
+	// returned next to the data
+	ctx.SetStatusCode(http.StatusOK)
+	if !res.Executed() {
+		ctx.SetStatusCode(http.StatusBadRequest)
+	}
+	ctx.SetContentType("application/json")
+	ctx.Write(payload)
+}
+
+// GetGraphQLSchema returns the schema of the GraphQL API in the schema definition language.
+func GetGraphQLSchema(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType("text/plain; charset=utf-8")
+	ctx.WriteString(graph.SDL())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/health"
+	"github.com/valyala/fasthttp"
+)
+
+// readyTimeout is how long a component can take to respond to a readiness check
+const readyTimeout = 2 * time.Second
+
+// readinessChecks are the components checked by the readiness probe
+var readinessChecks []health.Check
+
+// Liveness reports that the process is up and can handle requests.
+func Liveness(ctx *fasthttp.RequestCtx) {
+	writeReport(ctx, health.Live())
+}
+
+// Readiness reports whether the data store and the other components the service depends
+// on can be reached. It responds with 503 Service Unavailable when one of them is down.
+func Readiness(ctx *fasthttp.RequestCtx) {
+	writeReport(ctx, health.Ready(readinessChecks, readyTimeout))
+}
+
+// writeReport writes the report as the response
+func writeReport(ctx *fasthttp.RequestCtx, report health.Report) {
+	payload, err := report.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "writeReport", "Marshal", err)
+		return
+	}
+
+	// Probes shouldn't get an old result from a cache
+	ctx.Response.Header.Set("Cache-Control", "no-store")
+	ctx.SetContentType("application/json")
+
+	if report.Status == health.StatusUp {
+		ctx.SetStatusCode(http.StatusOK)
+	} else {
+		ctx.SetStatusCode(http.StatusServiceUnavailable)
+	}
+	ctx.Write(payload)
+}
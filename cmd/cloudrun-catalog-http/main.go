@@ -1,18 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/fasthttp/router"
 	"github.com/getsentry/sentry-go"
 	sentryfasthttp "github.com/getsentry/sentry-go/fasthttp"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/cors"
 	"github.com/retgits/acme-serverless-catalog/internal/datastore"
 	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
-	gcrwavefront "github.com/retgits/gcr-wavefront"
+	"github.com/retgits/acme-serverless-catalog/internal/health"
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/metrics"
+	"github.com/retgits/acme-serverless-catalog/internal/process"
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
 	"github.com/valyala/fasthttp"
 )
 
@@ -21,27 +35,71 @@ const (
 )
 
 var (
-	db datastore.Manager
+	db            datastore.Manager
+	auditSink     audit.Sink
+	authenticator auth.Authenticator
 )
 
-// CORSHandler sets CORS headers for the preflight request
-func CORSHandler(ctx *fasthttp.RequestCtx) {
-	ctx.Response.Header.Add("Access-Control-Allow-Credentials", "true")
-	ctx.Response.Header.Add("Access-Control-Allow-Headers", "Authorization")
-	ctx.Response.Header.Add("Access-Control-Allow-Methods", "GET, POST")
-	ctx.Response.Header.Add("Access-Control-Allow-Origin", "*")
-	ctx.Response.Header.Add("Access-Control-Max-Age", "3600")
-	ctx.Response.SetStatusCode(http.StatusNoContent)
-}
-
-// ErrorHandler takes the activity where the error occured and the error object and sends a message to sentry.
+// ErrorHandler takes the activity where the error occured and the error object, logs it and sends a message to sentry.
 func ErrorHandler(ctx *fasthttp.RequestCtx, function string, method string, err error) {
-	sentry.CaptureException(fmt.Errorf("error in %s::%s %s", function, method, err.Error()))
+	logging.FromRequest(ctx).Error("error handling request", "function", function, "method", method, "error", err)
+	captureException(sentryfasthttp.GetHubFromContext(ctx), requestContext(ctx), fmt.Errorf("error in %s::%s %s", function, method, err.Error()))
 	ctx.SetStatusCode(http.StatusBadRequest)
 	ctx.SetBodyString(err.Error())
 }
 
+// captureException sends the error to sentry, tagged with the request ID in c. The hub of
+// the request is used when there is one, so the event has the details of the request.
+func captureException(hub *sentry.Hub, c context.Context, err error) {
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+
+	hub.WithScope(func(scope *sentry.Scope) {
+		if id := logging.RequestIDFromContext(c); id != "" {
+			scope.SetTag("request_id", id)
+		}
+		hub.CaptureException(err)
+	})
+}
+
+// requestContext returns the context that holds the span, the logger and the request ID
+// of the request. Unlike the request itself, it can still be used after the handler returns.
+func requestContext(ctx *fasthttp.RequestCtx) context.Context {
+	c := tracing.ContextFromRequest(ctx)
+	if id := logging.RequestIDFromRequest(ctx); id != "" {
+		c = logging.WithRequestID(c, id)
+	}
+	return c
+}
+
+// requestDB returns the datastore manager that runs its operations as part of the request,
+// so they're cancelled, traced and logged with it.
+func requestDB(ctx *fasthttp.RequestCtx) datastore.Manager {
+	return db.WithContext(requestContext(ctx))
+}
+
+// tagRequest returns a handler that tags the sentry events of the request with its request
+// ID, including the panics recovered by sentry, and calls next.
+func tagRequest(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if hub := sentryfasthttp.GetHubFromContext(ctx); hub != nil {
+			hub.Scope().SetTag("request_id", logging.RequestIDFromRequest(ctx))
+		}
+		next(ctx)
+	}
+}
+
 func main() {
+	// Write the logs as JSON, including the lines of the standard logger
+	logger, err := logging.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring logging: %s", err.Error())
+	}
+	logging.SetDefault(logger)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.LevelInfo))
+
 	// Get the version or set a default to "dev"
 	version := os.Getenv("VERSION")
 	if version == "" {
@@ -51,7 +109,13 @@ func main() {
 	// Get the Wavefront server URL or set it to debug
 	wfServer := os.Getenv("WAVEFRONT_URL")
 	if wfServer == "" {
-		wfServer = gcrwavefront.DebugServerName
+		wfServer = metrics.WavefrontDebugServer
+	}
+
+	// Get the metrics backends or send the metrics to both Wavefront and Prometheus
+	backends := os.Getenv("METRICS_BACKENDS")
+	if backends == "" {
+		backends = "wavefront,prometheus"
 	}
 
 	// Get the server port or set it to 8080
@@ -82,36 +146,263 @@ func main() {
 	// Create an instance of sentryfasthttp
 	sentryHandler := sentryfasthttp.New(sentryfasthttp.Options{})
 
-	// Configure the Wavefront wrapper
-	cfg := gcrwavefront.WavefrontConfig{
+	// Configure the metrics backends
+	recorder, prom, err := newRecorder(backends, metrics.WavefrontConfig{
 		Server:        wfServer,
 		Token:         os.Getenv("WAVEFRONT_TOKEN"),
-		BatchSize:     10000,
-		MaxBufferSize: 50000,
 		FlushInterval: 1,
 		Source:        "acmeserverless",
 		MetricPrefix:  fmt.Sprintf("acmeserverless.gcr.%s", servicename),
-		PointTags:     make(map[string]string),
+	})
+	if err != nil {
+		log.Fatalf("error configuring metrics: %s", err.Error())
+	}
+
+	// Export the traces as configured in the OTEL_* environment variables
+	traceCfg, err := tracing.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring tracing: %s", err.Error())
+	}
+	if err := tracing.Init(traceCfg); err != nil {
+		log.Fatalf("error configuring tracing: %s", err.Error())
+	}
+
+	// Create the authenticator that checks the callers of the write endpoints
+	authenticator, err = auth.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+
+	router := newRouter(recorder, prom, sentryHandler)
+
+	// Apply the same CORS policy to preflight requests and responses
+	policy, err := cors.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring CORS: %s", err.Error())
+	}
+
+	// Publish the changes to the catalog, so other services can react to them
+	events, err := bus.Open(os.Getenv("EVENTS_PUBLISHER"))
+	if err != nil {
+		log.Fatalf("error configuring events publisher: %s", err.Error())
+	}
+
+	// Get the number of changes the live feed keeps for clients that reconnect or set it
+	// to 1000
+	feedSize := 1000
+	if n := os.Getenv("PRODUCTS_STREAM_BUFFER"); n != "" {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			log.Fatalf("error parsing PRODUCTS_STREAM_BUFFER: %s", err.Error())
+		}
+		feedSize = i
+	}
+
+	// Get the interval at which idle clients of the live feed get a heartbeat or set it to
+	// 15 seconds
+	if i := os.Getenv("PRODUCTS_STREAM_HEARTBEAT"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing PRODUCTS_STREAM_HEARTBEAT: %s", err.Error())
+		}
+		feedHeartbeat = d
+	}
+
+	// Queue the events for the webhook subscriptions too, which are sent in the background,
+	// and add them to the log of the live feed, which every instance pushes to its clients
+	webhookStore = mongodb.NewWebhooks()
+	dispatcher := webhook.NewDispatcher(webhookStore)
+	feedLog := mongodb.NewFeedLog(feedSize)
+	feed = bus.NewBroadcaster(feedSize)
+	publisher := bus.Multi(events, dispatcher, feedLog)
+
+	// Publish the events right after the change, or from the outbox that is written in the
+	// same transaction as the change
+	delivery, err := bus.DeliveryFromEnv()
+	if err != nil {
+		log.Fatalf("error configuring events delivery: %s", err.Error())
+	}
+
+	// Create an instance of the datastore manager, which records the latency of its calls,
+	// traces them as part of the request and publishes an event for every change
+	source := bus.SourceFromEnv()
+	var relay *bus.Relay
+	if delivery == bus.DeliveryOutbox {
+		db = metrics.Instrument(tracing.Instrument(mongodb.NewWithOutbox(), "mongodb"), "mongodb", recorder)
+		relay = bus.NewRelay(mongodb.NewOutbox(), map[string]bus.Publisher{"events": events, "webhooks": dispatcher, "feed": feedLog}, source)
+	} else {
+		db = bus.Wrap(metrics.Instrument(tracing.Instrument(mongodb.New(), "mongodb"), "mongodb", recorder), publisher, source)
+	}
+
+	// Create the sink for the audit trail
+	sink, err := audit.Open(os.Getenv("AUDIT_SINK"), mongodb.NewAuditLog())
+	if err != nil {
+		log.Fatalf("error configuring audit sink: %s", err.Error())
 	}
+	auditSink = sink
 
-	if err := cfg.ConfigureSender(); err != nil {
-		log.Fatalf("error configuring wavefront: %s", err.Error())
+	// The service is ready when the data store, the webhook subscriptions and, if they can
+	// be checked, the audit trail and the events publisher can be reached
+	readinessChecks = []health.Check{{Name: "datastore", Pinger: db}}
+	if p, ok := auditSink.(health.Pinger); ok {
+		readinessChecks = append(readinessChecks, health.Check{Name: "audit", Pinger: p})
+	}
+	if p, ok := events.(health.Pinger); ok {
+		readinessChecks = append(readinessChecks, health.Check{Name: "events", Pinger: p})
+	}
+	if p, ok := webhookStore.(health.Pinger); ok {
+		readinessChecks = append(readinessChecks, health.Check{Name: "webhooks", Pinger: p})
+	}
+
+	// Get the interval of the publishing scheduler or set it to a minute
+	interval := time.Minute
+	if i := os.Getenv("SCHEDULE_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing SCHEDULE_INTERVAL: %s", err.Error())
+		}
+		interval = d
 	}
 
-	// Wrap the sentryHandler with the Wavefront middleware to make sure all events
-	// are sent to sentry before sending data to Wavefront
-	router := router.New()
-	router.GlobalOPTIONS = CORSHandler
+	// Get the time in-flight requests get to finish on shutdown or set it to 5 seconds,
+	// which leaves time to flush within the 10 seconds Cloud Run waits after SIGTERM
+	shutdownTimeout := 5 * time.Second
+	if t := os.Getenv("SHUTDOWN_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			log.Fatalf("error parsing SHUTDOWN_TIMEOUT: %s", err.Error())
+		}
+		shutdownTimeout = d
+	}
 
-	// Add routes to the router
-	router.POST("/product", cfg.WrapFastHTTPRequest(sentryHandler.Handle(AddCatalogItem)))
-	router.GET("/products/{id}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetCatalogItemDetails)))
-	router.GET("/products", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetAllCatalogItems)))
+	// Get the interval at which the outbox is drained or set it to a second
+	relayInterval := time.Second
+	if i := os.Getenv("EVENTS_RELAY_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing EVENTS_RELAY_INTERVAL: %s", err.Error())
+		}
+		relayInterval = d
+	}
 
-	// Create an instance of the datastore manager
-	db = mongodb.New()
+	// Get the interval at which the products in the catalog are counted or set it to a minute
+	metricsInterval := time.Minute
+	if i := os.Getenv("METRICS_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing METRICS_INTERVAL: %s", err.Error())
+		}
+		metricsInterval = d
+	}
+
+	// Get the interval at which the webhook deliveries that are due are sent or set it to
+	// 5 seconds
+	webhooksInterval := 5 * time.Second
+	if i := os.Getenv("WEBHOOKS_DELIVERY_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing WEBHOOKS_DELIVERY_INTERVAL: %s", err.Error())
+		}
+		webhooksInterval = d
+	}
+
+	// Watch the catalog collection, so the changes made by any writer are applied to the
+	// caches, the search index and the other targets, if that's enabled
+	var newWatcher func(name string) *mongodb.Watcher
+	var targets []stream.Target
+	if w := os.Getenv("STREAM_WATCH"); w != "" {
+		enabled, err := strconv.ParseBool(w)
+		if err != nil {
+			log.Fatalf("error parsing STREAM_WATCH: %s", err.Error())
+		}
+		if enabled {
+			if targets, err = stream.FromEnv(); err != nil {
+				log.Fatalf("error configuring stream targets: %s", err.Error())
+			}
+			newWatcher = func(name string) *mongodb.Watcher {
+				return mongodb.NewWatcher(service + "/" + name)
+			}
+		}
+	}
+
+	// Get the interval at which a failed change or change stream is retried or set it to
+	// 5 seconds
+	watchRetry := 5 * time.Second
+	if i := os.Getenv("STREAM_RETRY_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing STREAM_RETRY_INTERVAL: %s", err.Error())
+		}
+		watchRetry = d
+	}
+
+	// Get the time after which the lease of an instance that stopped renewing it expires,
+	// so another instance takes over its background job, or set it to 30 seconds
+	leaseTTL := 30 * time.Second
+	if i := os.Getenv("LEASE_TTL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			log.Fatalf("error parsing LEASE_TTL: %s", err.Error())
+		}
+		leaseTTL = d
+	}
+
+	// The jobs that must run on a single instance only run on the one holding their lease
+	leases := mongodb.NewLeases()
+	holder := lease.NewHolder()
+
+	// Announce products that go live or are taken off the storefront, keep track of
+	// the size of the catalog, publish the events in the outbox and apply the changes
+	// in the change stream, send the webhooks and follow the log of the live feed
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(6)
+	go func() {
+		defer background.Done()
+		runScheduler(interval, schedule.NewBusEmitter(context.Background(), publisher, source), mongodb.NewWatermark(service), leases, holder, leaseTTL, stop)
+	}()
+	go func() {
+		defer background.Done()
+		runCatalogMetrics(metricsInterval, recorder, stop)
+	}()
+	go func() {
+		defer background.Done()
+		process.RunRelay(relayInterval, relay, stop)
+	}()
+	go func() {
+		defer background.Done()
+		runWatcher(newWatcher, targets, watchRetry, leases, holder, leaseTTL, stop)
+	}()
+	go func() {
+		defer background.Done()
+		runWebhooks(webhooksInterval, dispatcher, stop)
+	}()
+	go func() {
+		defer background.Done()
+		process.RunFeed(feed, feedLog, watchRetry, stop)
+	}()
+
+	// Every response, including the probes and errors of the router, gets a request ID
+	server := &fasthttp.Server{
+		Handler: cors.Handler(policy, logging.Middleware(router.Handler)),
+	}
 
 	// Start the server
-	log.Printf("successfully started %s server", servicename)
-	log.Fatal(fasthttp.ListenAndServe(fmt.Sprintf(":%s", port), router.Handler))
+	logger.Info("successfully started server", "service", servicename, "port", port)
+	err = listenAndServe(server, fmt.Sprintf(":%s", port), shutdownTimeout, func() {
+		// End the live feeds, which would otherwise keep their connections open
+		feed.Close()
+	})
+
+	// Stop the background jobs before the connection to the data store is closed
+	close(stop)
+	background.Wait()
+
+	if ferr := process.Flush(process.FlushTimeout(shutdownTimeout), recorder, publisher); ferr != nil {
+		logger.Error("error flushing", "error", ferr)
+	}
+	if err != nil {
+		log.Fatalf("error running %s server: %s", servicename, err.Error())
+	}
+	logger.Info("successfully stopped server", "service", servicename)
 }
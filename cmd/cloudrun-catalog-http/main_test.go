@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sentryfasthttp "github.com/getsentry/sentry-go/fasthttp"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/cors"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/metrics"
+	"github.com/retgits/acme-serverless-catalog/internal/process"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// testSource is the source of the change events of the test server
+	testSource = "/acme/catalog/test"
+
+	// testAPIKey is the API key that holds the write role on the test server
+	testAPIKey = "s3cr3t"
+)
+
+// testServer runs the routes of the API on a local port until the test ends and returns
+// its URL and the publisher that receives the change events. The catalog is a snapshot
+// file and the webhooks are kept in memory. The globals of the handlers are replaced,
+// so tests that use it can't run in parallel.
+func testServer(t *testing.T) (string, bus.Publisher) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	store, err := file.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := auth.ParseAPIKeys("ops:" + testAPIKey + ":" + auth.RoleWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhookStore = webhook.NewMemory()
+	feed = bus.NewBroadcaster(100)
+	feedLog := bus.NewMemoryLog(100)
+	publisher := bus.Multi(bus.NewMemory(), webhook.NewDispatcher(webhookStore), feedLog)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		process.RunFeed(feed, feedLog, time.Second, stop)
+	}()
+
+	db = bus.Wrap(store, publisher, testSource)
+	auditSink = audit.NewFile(filepath.Join(dir, "audit.ndjson"))
+	authenticator = keys
+
+	r := newRouter(metrics.Multi(), nil, sentryfasthttp.New(sentryfasthttp.Options{}))
+	server := &fasthttp.Server{
+		Handler: cors.Handler(cors.DefaultPolicy(), logging.Middleware(r.Handler)),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+
+	t.Cleanup(func() {
+		// End the live feeds first, the server waits for open connections
+		feed.Close()
+		server.Shutdown()
+		close(stop)
+		<-done
+	})
+
+	return "http://" + ln.Addr().String(), publisher
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/metrics"
+)
+
+// newRecorder creates the recorder for the comma separated list of metrics backends,
+// which can be wavefront and prometheus. The Prometheus recorder is returned separately,
+// so its metrics can be served, and is nil when Prometheus isn't one of the backends.
+func newRecorder(backends string, wf metrics.WavefrontConfig) (metrics.Recorder, *metrics.Prometheus, error) {
+	var recorders []metrics.Recorder
+	var prom *metrics.Prometheus
+
+	for _, backend := range strings.Split(backends, ",") {
+		switch strings.TrimSpace(backend) {
+		case "":
+		case "wavefront":
+			w, err := metrics.NewWavefront(wf)
+			if err != nil {
+				return nil, nil, err
+			}
+			recorders = append(recorders, w)
+		case "prometheus":
+			prom = metrics.NewPrometheus()
+			recorders = append(recorders, prom)
+		default:
+			return nil, nil, fmt.Errorf("unknown metrics backend %q, must be wavefront or prometheus", backend)
+		}
+	}
+
+	return metrics.Multi(recorders...), prom, nil
+}
+
+// runCatalogMetrics counts the products in the catalog every interval, until stop is
+// closed. An interval of zero or less disables the count.
+func runCatalogMetrics(interval time.Duration, recorder metrics.Recorder, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := metrics.RecordProducts(db, recorder); err != nil {
+			logging.Default().Error("error counting products", "function", "runCatalogMetrics", "error", err)
+			sentry.CaptureException(fmt.Errorf("error in runCatalogMetrics::RecordProducts %s", err.Error()))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
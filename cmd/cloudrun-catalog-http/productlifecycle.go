@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetAllProducts returns all products in the catalog together with their status, so
+// merchandisers can see drafts and archived products as well. The status query
+// parameter limits the result to products in that status.
+func GetAllProducts(ctx *fasthttp.RequestCtx) {
+	var filter datastore.Status
+	if ctx.QueryArgs().Has("status") {
+		status, err := datastore.ParseStatus(string(ctx.QueryArgs().Peek("status")))
+		if err != nil {
+			ErrorHandler(ctx, "GetAllProducts", "ParseStatus", err)
+			return
+		}
+		filter = status
+	}
+
+	products, err := requestDB(ctx).GetProducts()
+	if err != nil {
+		ErrorHandler(ctx, "GetAllProducts", "GetProducts", err)
+		return
+	}
+
+	res := catalog.ProductsResponse{
+		Data: make([]datastore.Product, 0, len(products)),
+	}
+	for _, p := range products {
+		if filter == "" || p.Status == filter {
+			res.Data = append(res.Data, p)
+		}
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetAllProducts", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// GetProductDetails returns a single product, in any status, together with its status.
+func GetProductDetails(ctx *fasthttp.RequestCtx) {
+	productID := ctx.UserValue("id").(string)
+
+	prod, err := requestDB(ctx).GetProduct(productID)
+	if err != nil {
+		ErrorHandler(ctx, "GetProductDetails", "GetProduct", err)
+		return
+	}
+
+	payload, err := prod.MarshalJSON()
+	if err != nil {
+		ErrorHandler(ctx, "GetProductDetails", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// UpdateProductStatus moves a product to the status in the request body, if the
+// lifecycle allows that transition.
+func UpdateProductStatus(ctx *fasthttp.RequestCtx) {
+	req, err := catalog.UnmarshalStatusRequest(string(ctx.Request.Body()))
+	if err != nil {
+		ErrorHandler(ctx, "UpdateProductStatus", "UnmarshalStatusRequest", err)
+		return
+	}
+
+	setProductStatus(ctx, "UpdateProductStatus", ctx.UserValue("id").(string), req.Status)
+}
+
+// UpdateProductAvailability changes the period in which a product is available on the
+// storefront and returns the updated product.
+func UpdateProductAvailability(ctx *fasthttp.RequestCtx) {
+	productID := ctx.UserValue("id").(string)
+
+	window, err := catalog.UnmarshalWindowRequest(string(ctx.Request.Body()))
+	if err != nil {
+		ErrorHandler(ctx, "UpdateProductAvailability", "UnmarshalWindowRequest", err)
+		return
+	}
+
+	if _, err := auditedDB(ctx).SetProductWindow(productID, window); err != nil {
+		ErrorHandler(ctx, "UpdateProductAvailability", "SetProductWindow", err)
+		return
+	}
+
+	prod, err := requestDB(ctx).GetProduct(productID)
+	if err != nil {
+		ErrorHandler(ctx, "UpdateProductAvailability", "GetProduct", err)
+		return
+	}
+
+	payload, err := prod.MarshalJSON()
+	if err != nil {
+		ErrorHandler(ctx, "UpdateProductAvailability", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// ArchiveCatalogItem removes a product from the storefront by archiving it. The product
+// is kept in the catalog, so orders that contain it can still show its details.
+func ArchiveCatalogItem(ctx *fasthttp.RequestCtx) {
+	setProductStatus(ctx, "ArchiveCatalogItem", ctx.UserValue("id").(string), datastore.StatusArchived)
+}
+
+// setProductStatus moves a product to a new status and writes the old and new status
+// as the response.
+func setProductStatus(ctx *fasthttp.RequestCtx, function string, productID string, status datastore.Status) {
+	prod, err := requestDB(ctx).GetProduct(productID)
+	if err != nil {
+		ErrorHandler(ctx, function, "GetProduct", err)
+		return
+	}
+
+	// The data store checks that the lifecycle allows the transition, against the
+	// product as it is when it's written
+	if _, err := auditedDB(ctx).SetProductStatus(productID, status); err != nil {
+		ErrorHandler(ctx, function, "SetProductStatus", err)
+		return
+	}
+
+	res := catalog.StatusResponse{
+		ID:       productID,
+		Previous: prod.Status,
+		Status:   status,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, function, "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/valyala/fasthttp"
+)
+
+// GetProductRevisions returns every revision of a product, oldest first, so merchandisers
+// can see how the product changed over time.
+func GetProductRevisions(ctx *fasthttp.RequestCtx) {
+	revs, err := requestDB(ctx).GetRevisions(ctx.UserValue("id").(string))
+	if err != nil {
+		ErrorHandler(ctx, "GetProductRevisions", "GetRevisions", err)
+		return
+	}
+
+	res := catalog.RevisionsResponse{
+		Data: revs,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetProductRevisions", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// RollbackProduct restores a product as it was in the revision in the request body and
+// returns the new revision that records the rollback.
+func RollbackProduct(ctx *fasthttp.RequestCtx) {
+	req, err := catalog.UnmarshalRollbackRequest(string(ctx.Request.Body()))
+	if err != nil {
+		ErrorHandler(ctx, "RollbackProduct", "UnmarshalRollbackRequest", err)
+		return
+	}
+
+	rev, err := auditedDB(ctx).RollbackProduct(ctx.UserValue("id").(string), req.Version)
+	if err != nil {
+		ErrorHandler(ctx, "RollbackProduct", "RollbackProduct", err)
+		return
+	}
+
+	payload, err := json.Marshal(rev)
+	if err != nil {
+		ErrorHandler(ctx, "RollbackProduct", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
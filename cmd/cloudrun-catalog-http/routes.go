@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fasthttp/router"
+	sentryfasthttp "github.com/getsentry/sentry-go/fasthttp"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/metrics"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+	"github.com/valyala/fasthttp"
+)
+
+// newRouter creates the router with all routes of the API, which record their requests
+// with recorder and report their errors with sentryHandler. The metrics are served for
+// Prometheus to scrape when prom isn't nil.
+func newRouter(recorder metrics.Recorder, prom *metrics.Prometheus, sentryHandler *sentryfasthttp.Handler) *router.Router {
+	r := router.New()
+
+	// Wrap the sentryHandler with the tracing and metrics middleware to make sure all
+	// events are sent to sentry before the request is recorded
+	handle := func(method string, path string, h fasthttp.RequestHandler) {
+		r.Handle(method, path, metrics.Middleware(recorder, path, tracing.Middleware(path, sentryHandler.Handle(tagRequest(h)))))
+	}
+
+	// Changes to the catalog, and views that show products which aren't on the
+	// storefront, need the write role
+	protected := func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return auth.RequireRole(authenticator, auth.RoleWrite, h)
+	}
+
+	// Add routes to the router
+	handle(http.MethodPost, "/product", protected(AddCatalogItem))
+	handle(http.MethodGet, "/products/{id}", GetCatalogItemDetails)
+	handle(http.MethodGet, "/products", GetAllCatalogItems)
+	handle(http.MethodGet, "/products/stream", StreamCatalogItems)
+	handle(http.MethodGet, "/products:export", protected(ExportCatalogItems))
+	handle(http.MethodPost, "/products:batchGet", BatchGetCatalogItems)
+	handle(http.MethodPut, "/products/{id}/status", protected(UpdateProductStatus))
+	handle(http.MethodPut, "/products/{id}/availability", protected(UpdateProductAvailability))
+	handle(http.MethodGet, "/products/{id}/revisions", protected(GetProductRevisions))
+	handle(http.MethodPost, "/products/{id}/rollback", protected(RollbackProduct))
+	handle(http.MethodDelete, "/products/{id}", protected(ArchiveCatalogItem))
+	handle(http.MethodGet, "/admin/products", protected(GetAllProducts))
+	handle(http.MethodGet, "/admin/products/{id}", protected(GetProductDetails))
+	handle(http.MethodGet, "/audit", protected(GetAuditTrail))
+	handle(http.MethodPost, "/webhooks", protected(CreateWebhook))
+	handle(http.MethodGet, "/webhooks", protected(GetWebhooks))
+	handle(http.MethodGet, "/webhooks/{id}", protected(GetWebhook))
+	handle(http.MethodDelete, "/webhooks/{id}", protected(DeleteWebhook))
+	handle(http.MethodGet, "/webhooks/{id}/deliveries", protected(GetWebhookDeliveries))
+	handle(http.MethodGet, "/graphql", GraphQL)
+	handle(http.MethodPost, "/graphql", GraphQL)
+	handle(http.MethodGet, "/graphql/schema", GetGraphQLSchema)
+
+	// Probes and scrapes aren't wrapped, so they don't show up in the metrics and traces
+	r.GET("/healthz", Liveness)
+	r.GET("/readyz", Readiness)
+	if prom != nil {
+		r.GET("/metrics", metrics.Handler(prom))
+	}
+
+	return r
+}
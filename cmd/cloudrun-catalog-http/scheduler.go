@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+)
+
+// scheduleLease is the name of the lease the instance that runs the scheduler holds
+const scheduleLease = "schedule"
+
+// runScheduler checks the catalog every interval for products whose availability window
+// started or ended since the watermark and emits an event for each of them, until stop is
+// closed. Only the instance that holds the schedule lease checks the catalog, and it
+// catches up from the watermark as soon as it takes the lease, so the boundaries that
+// passed while no instance held it are still announced. An interval of zero or less
+// disables the scheduler.
+func runScheduler(interval time.Duration, emitter schedule.Emitter, watermark schedule.Watermark, leases lease.Store, holder string, ttl time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		logging.Default().Info("publishing scheduler is disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	lease.Run(ctx, leases, scheduleLease, holder, ttl, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		now := time.Now()
+		for {
+			checkSchedule(ctx, emitter, watermark, now)
+
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+		}
+	})
+}
+
+// checkSchedule emits the events up to now. When it fails, the boundaries in this period
+// are checked again on the next tick.
+func checkSchedule(ctx context.Context, emitter schedule.Emitter, watermark schedule.Watermark, now time.Time) {
+	n, err := schedule.Check(ctx, db, emitter, watermark, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.Default().Error("error running schedule", "function", "runScheduler", "error", err)
+			sentry.CaptureException(fmt.Errorf("error in runScheduler::Check %s", err.Error()))
+		}
+		return
+	}
+
+	if n > 0 {
+		logging.Default().Info("emitted schedule events", "count", n)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+)
+
+// TestRunScheduler checks that the instance that takes the lease catches up from the
+// stored watermark right away, and that an instance without the lease doesn't emit.
+func TestRunScheduler(t *testing.T) {
+	tests := []struct {
+		name       string
+		heldBy     string
+		wantEvents int
+	}{
+		{name: "lease free", wantEvents: 1},
+		{name: "lease held by another instance", heldBy: "other"},
+	}
+
+	for _, tt := range tests {
+		testServer(t)
+
+		// The scheduler was down when the window of the product started
+		missed := time.Now().Add(-30 * time.Minute)
+		p := datastore.Product{
+			Item:   acmeserverless.CatalogItem{ID: "launch", Name: "Launch"},
+			Status: datastore.StatusPublished,
+			Window: datastore.Window{PublishAt: &missed},
+		}
+		if _, err := db.AddProduct(p); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		watermark := schedule.NewMemoryWatermark()
+		stored := time.Now().Add(-time.Hour)
+		watermark.Save(ctx, stored)
+
+		leases := lease.NewMemory()
+		if tt.heldBy != "" {
+			leases.Acquire(ctx, scheduleLease, tt.heldBy, time.Minute)
+		}
+
+		publisher := bus.NewMemory()
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			runScheduler(time.Hour, schedule.NewBusEmitter(ctx, publisher, testSource), watermark, leases, "test", time.Minute, stop)
+		}()
+
+		deadline := time.Now().Add(time.Second)
+		for len(publisher.Events()) < tt.wantEvents && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if tt.wantEvents == 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		close(stop)
+		<-done
+
+		events := publisher.Events()
+		if len(events) != tt.wantEvents {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(events), tt.wantEvents)
+		}
+		for _, e := range events {
+			if e.Type != bus.TypeProductPublished || e.Subject != "launch" || !e.Time.Equal(missed.UTC()) {
+				t.Errorf("%s: got event %+v, want the launch at %s", tt.name, e, missed)
+			}
+		}
+
+		got, _ := watermark.Load(ctx)
+		if moved := got.After(stored); moved != (tt.wantEvents > 0) {
+			t.Errorf("%s: the watermark is %s, stored was %s", tt.name, got, stored)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/valyala/fasthttp"
+)
+
+// listenAndServe serves requests until the process receives SIGTERM or SIGINT. It then
+// calls draining, stops accepting connections and waits up to timeout for the requests
+// in flight to finish.
+func listenAndServe(server *fasthttp.Server, addr string, timeout time.Duration, draining func()) error {
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	return serve(server, ln, signals, timeout, draining)
+}
+
+// serve serves the requests of the listener until a signal arrives, and then shuts the
+// server down like listenAndServe.
+func serve(server *fasthttp.Server, ln net.Listener, signals <-chan os.Signal, timeout time.Duration, draining func()) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		logging.Default().Info("draining requests", "signal", sig.String())
+	}
+	draining()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("requests still in flight after %s", timeout)
+	}
+}
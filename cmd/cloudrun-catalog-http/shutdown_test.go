@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestServe checks that the requests in flight when SIGTERM arrives finish, and that the
+// server gives up on them after the timeout.
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		timeout  time.Duration
+		wantErr  string
+	}{
+		{name: "request finishes", duration: 100 * time.Millisecond, timeout: time.Second},
+		{name: "request outlasts the timeout", duration: time.Second, timeout: 100 * time.Millisecond, wantErr: "still in flight"},
+	}
+
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		started := make(chan struct{})
+		server := &fasthttp.Server{
+			Handler: func(ctx *fasthttp.RequestCtx) {
+				close(started)
+				time.Sleep(tt.duration)
+				ctx.SetStatusCode(http.StatusOK)
+			},
+		}
+
+		signals := make(chan os.Signal, 1)
+		drained := false
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- serve(server, ln, signals, tt.timeout, func() { drained = true })
+		}()
+
+		responses := make(chan error, 1)
+		go func() {
+			res, err := http.Get("http://" + ln.Addr().String() + "/products")
+			if err == nil {
+				res.Body.Close()
+			}
+			responses <- err
+		}()
+
+		<-started
+		start := time.Now()
+		signals <- syscall.SIGTERM
+
+		err = <-stopped
+		if elapsed := time.Since(start); elapsed > tt.timeout+500*time.Millisecond {
+			t.Errorf("%s: serve() returned after %s, want within the timeout of %s", tt.name, elapsed, tt.timeout)
+		}
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: serve() = %v, want nil", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: serve() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if !drained {
+			t.Errorf("%s: draining wasn't called", tt.name)
+		}
+
+		// The request in flight gets its response, also after the timeout
+		if err := <-responses; err != nil {
+			t.Errorf("%s: the request in flight failed: %v", tt.name, err)
+		}
+	}
+}
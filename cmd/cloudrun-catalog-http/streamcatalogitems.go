@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// feed sends the changes made through any instance to the clients of the live feed
+	feed *bus.Broadcaster
+
+	// feedHeartbeat is how often a comment is sent to idle clients, so proxies don't
+	// close the connection
+	feedHeartbeat = 15 * time.Second
+)
+
+// StreamCatalogItems pushes the changes to the products on the storefront to the client
+// as Server-Sent Events, until the client disconnects or the server stops. The ID of every
+// event is the ID of the change event, and a client that reconnects with the
+// Last-Event-ID header first gets the changes it missed. When those changes aren't known
+// anymore, the client gets a reset event and should read the products again.
+func StreamCatalogItems(ctx *fasthttp.RequestCtx) {
+	logger := logging.FromRequest(ctx)
+	listener, missed, ok := feed.Subscribe(requestContext(ctx), string(ctx.Request.Header.Peek("Last-Event-ID")))
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer listener.Cancel()
+
+		// Clients wait a few seconds before they reconnect after the stream ends
+		fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+		if !ok {
+			writeFeedEvent(w, "", catalog.FeedReset, []byte("{}"))
+		}
+		for _, e := range missed {
+			sendFeedEvent(w, logger, e)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(feedHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, open := <-listener.Events():
+				if !open {
+					return
+				}
+				sendFeedEvent(w, logger, e)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// sendFeedEvent writes the message of the live feed for a change event. Events that
+// can't be read are logged and skipped.
+func sendFeedEvent(w *bufio.Writer, logger *logging.Logger, e bus.Event) {
+	event, data, err := catalog.FeedMessage(e, time.Now())
+	if err != nil {
+		logger.Error("error sending change to the live feed", "function", "StreamCatalogItems", "event_id", e.ID, "error", err)
+		return
+	}
+	writeFeedEvent(w, e.ID, event, data)
+}
+
+// writeFeedEvent writes a single Server-Sent Event. The data is a single line of JSON.
+func writeFeedEvent(w *bufio.Writer, id string, event string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+)
+
+// feedEvents reads the events of the live feed at url, as "<event> <data>", until the
+// test ends.
+func feedEvents(t *testing.T, url string) <-chan string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequest(http.MethodGet, url+"/products/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The headers are only sent once the handler returns, which it doesn't when the
+	// stream is buffered
+	client := &http.Client{Transport: &http.Transport{ResponseHeaderTimeout: 5 * time.Second}}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d", res.StatusCode)
+	}
+
+	events := make(chan string, 10)
+	go func() {
+		defer res.Body.Close()
+		defer close(events)
+
+		var event string
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				events <- event + " " + strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	return events
+}
+
+// nextEvent returns the next event of the feed, or fails the test if it doesn't arrive.
+func nextEvent(t *testing.T, events <-chan string) string {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("the live feed ended")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event on the live feed")
+		return ""
+	}
+}
+
+// TestStreamCatalogItems checks that the live feed is sent through all middleware while
+// it's open, and that a product whose availability window starts is pushed when the
+// scheduler reaches the start of the window.
+func TestStreamCatalogItems(t *testing.T) {
+	url, publisher := testServer(t)
+	events := feedEvents(t, url)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runScheduler(20*time.Millisecond, schedule.NewBusEmitter(context.Background(), publisher, testSource), schedule.NewMemoryWatermark(), lease.NewMemory(), "test", time.Minute, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	launch := time.Now().Add(200 * time.Millisecond)
+	p := datastore.Product{
+		Item:   acmeserverless.CatalogItem{ID: "launch", Name: "Launch"},
+		Status: datastore.StatusPublished,
+		Window: datastore.Window{PublishAt: &launch},
+	}
+	if _, err := db.AddProduct(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// The product isn't listed before its window starts
+	if got, want := nextEvent(t, events), `remove {"id":"launch"}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got := nextEvent(t, events)
+	if !strings.HasPrefix(got, `product {"id":"launch"`) {
+		t.Errorf("got %q, want the product when its window starts", got)
+	}
+	if time.Now().Before(launch) {
+		t.Errorf("the product was pushed before its window started")
+	}
+}
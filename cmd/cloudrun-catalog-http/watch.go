@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+)
+
+// watchLease is the name of the lease the instance that reads the change stream holds
+const watchLease = "watch"
+
+// runWatcher applies the changes in the change stream to the targets until stop is
+// closed. Every target has a watcher of its own, created with newWatcher, so it has its
+// own position in the change stream and a target that is slow or fails doesn't hold up
+// the others. The change stream is only read by the instance that holds the watch lease,
+// so the targets get every change once, and a watcher is started again every retry when
+// it fails. A nil newWatcher disables it.
+func runWatcher(newWatcher func(name string) *mongodb.Watcher, targets []stream.Target, retry time.Duration, leases lease.Store, holder string, ttl time.Duration, stop <-chan struct{}) {
+	if newWatcher == nil {
+		return
+	}
+
+	if retry <= 0 {
+		retry = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	// Targets with the same name, like two webhooks on one host, are numbered in the order
+	// they're configured, so their watchers don't share a position
+	seen := make(map[string]int)
+	watchers := make([]*mongodb.Watcher, len(targets))
+	var consumers sync.WaitGroup
+	for i, t := range targets {
+		name := t.Name()
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+
+		watchers[i] = newWatcher(name)
+		consumers.Add(1)
+		go func(t stream.Target, sub *mongodb.Subscription) {
+			defer consumers.Done()
+			consume(ctx, t, sub, retry)
+		}(t, watchers[i].Subscribe(t.Name()))
+	}
+
+	lease.Run(ctx, leases, watchLease, holder, ttl, func(ctx context.Context) {
+		var running sync.WaitGroup
+		for i, w := range watchers {
+			running.Add(1)
+			go func(w *mongodb.Watcher, name string) {
+				defer running.Done()
+				watch(ctx, w, name, retry)
+			}(w, targets[i].Name())
+		}
+		running.Wait()
+	})
+
+	for _, w := range watchers {
+		w.Close()
+	}
+	consumers.Wait()
+}
+
+// watch runs the watcher of the target with the name until ctx is done, and starts it
+// again every retry when it fails.
+func watch(ctx context.Context, w *mongodb.Watcher, name string, retry time.Duration) {
+	for {
+		err := w.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.Default().Error("error watching the catalog", "function", "runWatcher", "target", name, "error", err)
+			sentry.CaptureException(fmt.Errorf("error in runWatcher::Run %s", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// consume applies the changes of the subscription to the target. A change that can't be
+// applied is tried again every retry, so the changes are applied in order, until it
+// succeeds or ctx is done.
+func consume(ctx context.Context, t stream.Target, sub *mongodb.Subscription, retry time.Duration) {
+	for c := range sub.Changes() {
+		for {
+			err := t.Apply(ctx, c)
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+
+			logging.Default().Error("error applying change", "function", "consume", "target", t.Name(), "product_id", c.ProductID, "error", err)
+			sentry.CaptureException(fmt.Errorf("error in consume::%s %s", t.Name(), err.Error()))
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(retry):
+			}
+		}
+		sub.Done()
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// defaultDeliveriesLimit is the number of deliveries returned when the request
+	// doesn't have a limit
+	defaultDeliveriesLimit = 50
+
+	// maxDeliveriesLimit is the largest number of deliveries returned at once
+	maxDeliveriesLimit = 500
+)
+
+// webhookStore keeps the webhook subscriptions and their deliveries
+var webhookStore webhook.Store
+
+// CreateWebhook subscribes the endpoint in the request body to the changes of the
+// catalog and returns the subscription, including its secret, which isn't shown again.
+func CreateWebhook(ctx *fasthttp.RequestCtx) {
+	req, err := catalog.UnmarshalWebhookRequest(string(ctx.Request.Body()))
+	if err != nil {
+		ErrorHandler(ctx, "CreateWebhook", "UnmarshalWebhookRequest", err)
+		return
+	}
+
+	s, err := webhook.NewSubscription(req.URL, req.Events, req.Secret, requestActor(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "CreateWebhook", "NewSubscription", err)
+		return
+	}
+
+	if err := webhookStore.AddSubscription(requestContext(ctx), s); err != nil {
+		ErrorHandler(ctx, "CreateWebhook", "AddSubscription", err)
+		return
+	}
+
+	res := catalog.WebhookResponse{
+		Subscription: s,
+		Secret:       s.Secret,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "CreateWebhook", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// GetWebhooks returns all webhook subscriptions, without their secrets.
+func GetWebhooks(ctx *fasthttp.RequestCtx) {
+	subs, err := webhookStore.Subscriptions(requestContext(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhooks", "Subscriptions", err)
+		return
+	}
+
+	res := catalog.WebhooksResponse{
+		Data: subs,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhooks", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// GetWebhook returns a single webhook subscription, without its secret.
+func GetWebhook(ctx *fasthttp.RequestCtx) {
+	s, err := webhookStore.GetSubscription(requestContext(ctx), ctx.UserValue("id").(string))
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhook", "GetSubscription", err)
+		return
+	}
+
+	payload, err := json.Marshal(s)
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhook", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// DeleteWebhook removes a webhook subscription. The deliveries that are still pending are
+// marked dead the next time they're due, and the log of the subscription is kept.
+func DeleteWebhook(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	if err := webhookStore.RemoveSubscription(requestContext(ctx), id); err != nil {
+		ErrorHandler(ctx, "DeleteWebhook", "RemoveSubscription", err)
+		return
+	}
+
+	logging.FromRequest(ctx).Info("removed webhook subscription", "id", id, "actor", requestActor(ctx))
+	ctx.SetStatusCode(http.StatusOK)
+}
+
+// GetWebhookDeliveries returns the newest deliveries of a webhook subscription, as many as
+// the limit query parameter. When the status query parameter is set, only the deliveries
+// among them in that status are returned.
+func GetWebhookDeliveries(ctx *fasthttp.RequestCtx) {
+	limit := defaultDeliveriesLimit
+	if l := string(ctx.QueryArgs().Peek("limit")); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			ErrorHandler(ctx, "GetWebhookDeliveries", "ParseLimit", fmt.Errorf("limit must be between 1 and %d", maxDeliveriesLimit))
+			return
+		}
+		limit = n
+	}
+
+	var status webhook.Status
+	if s := string(ctx.QueryArgs().Peek("status")); s != "" {
+		st, err := webhook.ParseStatus(s)
+		if err != nil {
+			ErrorHandler(ctx, "GetWebhookDeliveries", "ParseStatus", err)
+			return
+		}
+		status = st
+	}
+
+	deliveries, err := webhookStore.Deliveries(requestContext(ctx), ctx.UserValue("id").(string), limit)
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhookDeliveries", "Deliveries", err)
+		return
+	}
+
+	res := catalog.DeliveriesResponse{
+		Data: make([]webhook.Delivery, 0, len(deliveries)),
+	}
+	for _, d := range deliveries {
+		if status == "" || d.Status == status {
+			res.Data = append(res.Data, d)
+		}
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetWebhookDeliveries", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
+
+// runWebhooks sends the webhook deliveries that are due every interval, until stop is
+// closed, and once more before it returns so the events of the last requests are sent.
+func runWebhooks(interval time.Duration, dispatcher *webhook.Dispatcher, stop <-chan struct{}) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			deliverWebhooks(dispatcher)
+			return
+		case <-ticker.C:
+			deliverWebhooks(dispatcher)
+		}
+	}
+}
+
+// deliverWebhooks sends the deliveries that are due. Deliveries that fail are retried by
+// a later run.
+func deliverWebhooks(dispatcher *webhook.Dispatcher) {
+	n, err := dispatcher.Deliver(context.Background())
+	if n > 0 {
+		logging.Default().Info("attempted webhook deliveries", "count", n)
+	}
+	if err != nil {
+		logging.Default().Error("error delivering webhooks", "function", "runWebhooks", "error", err)
+		sentry.CaptureException(fmt.Errorf("error in runWebhooks::Deliver %s", err.Error()))
+	}
+}
@@ -1,56 +1,81 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/getsentry/sentry-go"
 	acmeserverless "github.com/retgits/acme-serverless"
-	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
+// db is the data store, which is set up once per container
+var db datastore.Manager
+
 // handler handles the API Gateway events and returns an error if anything goes wrong.
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Initiialize a connection to Sentry to capture errors and traces
-	sentry.Init(sentry.ClientOptions{
-		Dsn: os.Getenv("SENTRY_DSN"),
-		Transport: &sentry.HTTPSyncTransport{
-			Timeout: time.Second * 3,
-		},
-		ServerName:  os.Getenv("FUNCTION_NAME"),
-		Release:     os.Getenv("VERSION"),
-		Environment: os.Getenv("STAGE"),
-	})
-
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Create the headers of the response, the CORS headers
+	// are added by the CORS policy.
+	headers := make(map[string]string)
+
+	dynamoStore := db.WithContext(ctx)
+
+	// Only get the requested products when a list of IDs is passed in
+	if ids, ok := request.QueryStringParameters["ids"]; ok {
+		return getProductsByIDs(ctx, dynamoStore, catalog.ParseIDs(ids), headers)
 	}
-	headers["Access-Control-Allow-Origin"] = "*"
 
 	// Get all products from the catalog
-	dynamoStore := dynamodb.New()
 	products, err := dynamoStore.GetProducts()
 	if err != nil {
-		return handleError("getting products", headers, err)
+		return handleError(ctx, "getting products", headers, err)
 	}
 
+	// Only published products are shown in the catalog
 	res := acmeserverless.AllCatalogItemsResponse{
-		Data: products,
+		Data: catalog.ListedItems(products, time.Now()),
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		return handleError(ctx, "marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
 	}
 
+	return response, nil
+}
+
+// getProductsByIDs returns the products with the given IDs, together with the IDs of the
+// products that don't exist.
+func getProductsByIDs(ctx context.Context, dynamoStore datastore.Manager, ids []string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	ids, err := catalog.CleanIDs(ids)
+	if err != nil {
+		return handleError(ctx, "parsing product ids", headers, err)
+	}
+
+	products, err := dynamoStore.GetProductsByIDs(ids)
+	if err != nil {
+		return handleError(ctx, "getting products", headers, err)
+	}
+
+	res := catalog.NewBatchGetResponse(ids, products, time.Now())
+
 	payload, err := res.Marshal()
 	if err != nil {
-		return handleError("marshalling response", headers, err)
+		return handleError(ctx, "marshalling response", headers, err)
 	}
 
 	response := events.APIGatewayProxyResponse{
@@ -62,12 +87,12 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 	return response, nil
 }
 
-// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// handleError takes the activity where the error occured and the error object, logs it and sends a message to sentry.
 // The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
-func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
+func handleError(ctx context.Context, area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	logging.FromContext(ctx).Error("error handling request", "area", area, "error", err)
+	bootstrap.CaptureException(ctx, fmt.Errorf("error %s: %s", area, err.Error()))
 	msg := fmt.Sprintf("error %s: %s", area, err.Error())
-	log.Println(msg)
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusBadRequest,
 		Body:       msg,
@@ -77,5 +102,13 @@ func handleError(area string, headers map[string]string, err error) (events.APIG
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	// Set up logging, Sentry, tracing, the CORS policy and the data store once per
+	// container instead of on every invocation
+	fn, err := bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+	db = fn.DB
+
+	lambda.Start(wflambda.Wrapper(fn.HTTP(handler)))
 }
@@ -1,54 +1,47 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/getsentry/sentry-go"
-	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
-// handler handles the API Gateway events and returns an error if anything goes wrong.
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Initiialize a connection to Sentry to capture errors and traces
-	sentry.Init(sentry.ClientOptions{
-		Dsn: os.Getenv("SENTRY_DSN"),
-		Transport: &sentry.HTTPSyncTransport{
-			Timeout: time.Second * 3,
-		},
-		ServerName:  os.Getenv("FUNCTION_NAME"),
-		Release:     os.Getenv("VERSION"),
-		Environment: os.Getenv("STAGE"),
-	})
+// db is the data store, which is set up once per container
+var db datastore.Manager
 
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
-	}
-	headers["Access-Control-Allow-Origin"] = "*"
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Create the headers of the response, the CORS headers
+	// are added by the CORS policy.
+	headers := make(map[string]string)
 
 	// Create the key attributes
 	productID := request.PathParameters["id"]
 
 	// Get a product based on the ID
-	dynamoStore := dynamodb.New()
+	dynamoStore := db.WithContext(ctx)
 	prod, err := dynamoStore.GetProduct(productID)
 	if err != nil {
-		return handleError("finding product", headers, err)
+		return handleError(ctx, "finding product", headers, err)
+	}
+
+	// Drafts are treated as if they don't exist yet
+	if !prod.Visible(time.Now()) {
+		return handleError(ctx, "finding product", headers, fmt.Errorf("Unable to find product with id %s", productID))
 	}
 
-	payload, err := prod.Marshal()
+	payload, err := prod.Item.Marshal()
 	if err != nil {
-		return handleError("marshalling response", headers, err)
+		return handleError(ctx, "marshalling response", headers, err)
 	}
 
 	response := events.APIGatewayProxyResponse{
@@ -60,12 +53,12 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 	return response, nil
 }
 
-// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// handleError takes the activity where the error occured and the error object, logs it and sends a message to sentry.
 // The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
-func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
+func handleError(ctx context.Context, area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	logging.FromContext(ctx).Error("error handling request", "area", area, "error", err)
+	bootstrap.CaptureException(ctx, fmt.Errorf("error %s: %s", area, err.Error()))
 	msg := fmt.Sprintf("error %s: %s", area, err.Error())
-	log.Println(msg)
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusBadRequest,
 		Body:       msg,
@@ -75,5 +68,13 @@ func handleError(area string, headers map[string]string, err error) (events.APIG
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	// Set up logging, Sentry, tracing, the CORS policy and the data store once per
+	// container instead of on every invocation
+	fn, err := bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+	db = fn.DB
+
+	lambda.Start(wflambda.Wrapper(fn.HTTP(handler)))
 }
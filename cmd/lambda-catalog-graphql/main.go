@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/graph"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+var (
+	// fn is the state shared by the invocations in the container
+	fn *bootstrap.Function
+
+	// db is the data store, which is set up once per container
+	db datastore.Manager
+
+	// sink records who changed the products in the audit trail
+	sink audit.Sink
+
+	// authenticator checks the callers that change the catalog
+	authenticator auth.Authenticator
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong. Queries can be
+// sent with GET, in the query string, and queries and mutations with POST as JSON. Reading the storefront
+// is public, the mutations and the fields that show the products which aren't on the storefront need the
+// write role.
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Create the headers of the response, the CORS headers
+	// are added by the CORS policy.
+	headers := make(map[string]string)
+
+	var req graph.Request
+	var err error
+	if request.HTTPMethod == http.MethodGet {
+		params := request.QueryStringParameters
+		req, err = graph.QueryRequest(params["query"], params["operationName"], params["variables"])
+	} else {
+		req, err = graph.UnmarshalRequest([]byte(request.Body))
+	}
+	if err != nil {
+		return handleError(ctx, "unmarshalling request", headers, err)
+	}
+
+	// Callers that hold the write role change the catalog in their own name, everyone
+	// else can only read it
+	session := &graph.Session{DB: db.WithContext(ctx)}
+	p, _, err := auth.Authorize(authenticator, auth.RoleWrite, auth.Credentials{
+		Authorization: apigateway.Header(request, "Authorization"),
+		APIKey:        apigateway.Header(request, "X-API-Key"),
+	})
+	if err != nil {
+		session.Denied = err
+	} else {
+		session.Writer = audit.Wrap(db.WithContext(ctx), sink, p.Name, request.RequestContext.Identity.SourceIP)
+	}
+
+	res := graph.Do(ctx, session, req)
+	if len(res.Errors) > 0 {
+		logging.FromContext(ctx).Warn("graphql request returned errors", "operation", req.OperationName, "errors", len(res.Errors), "error", res.Errors[0].Message)
+	}
+
+	// Publish the events of the changes, when they're recorded in the outbox
+	if session.Changed {
+		fn.PublishPending(ctx)
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		return handleError(ctx, "marshalling response", headers, err)
+	}
+
+	// Requests that can't be executed at all are rejected, errors of single fields are
+	// returned next to the data
+	status := http.StatusOK
+	if !res.Executed() {
+		status = http.StatusBadRequest
+	}
+	headers["Content-Type"] = "application/json"
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object, logs it and sends a message to sentry.
+// The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
+func handleError(ctx context.Context, area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	logging.FromContext(ctx).Error("error handling request", "area", area, "error", err)
+	bootstrap.CaptureException(ctx, fmt.Errorf("error %s: %s", area, err.Error()))
+	msg := fmt.Sprintf("error %s: %s", area, err.Error())
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       msg,
+		Headers:    headers,
+	}, nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	// Set up logging, Sentry, tracing, the CORS policy and the data store once per
+	// container instead of on every invocation
+	var err error
+	fn, err = bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+	db = fn.DB
+
+	// Record who changed the products in the audit trail
+	sink, err = audit.Open(os.Getenv("AUDIT_SINK"), dynamodb.NewAuditLog())
+	if err != nil {
+		log.Fatalf("error configuring audit sink: %s", err.Error())
+	}
+
+	// Check the callers that change the catalog, reading it doesn't need credentials
+	authenticator, err = auth.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+
+	lambda.Start(wflambda.Wrapper(fn.HTTP(handler)))
+}
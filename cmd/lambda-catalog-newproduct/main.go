@@ -1,66 +1,74 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/getsentry/sentry-go"
 	"github.com/gofrs/uuid"
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
 	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
-// handler handles the API Gateway events and returns an error if anything goes wrong.
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Initiialize a connection to Sentry to capture errors and traces
-	sentry.Init(sentry.ClientOptions{
-		Dsn: os.Getenv("SENTRY_DSN"),
-		Transport: &sentry.HTTPSyncTransport{
-			Timeout: time.Second * 3,
-		},
-		ServerName:  os.Getenv("FUNCTION_NAME"),
-		Release:     os.Getenv("VERSION"),
-		Environment: os.Getenv("STAGE"),
-	})
-
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
-	}
-	headers["Access-Control-Allow-Origin"] = "*"
+var (
+	// fn is the state shared by the invocations in the container
+	fn *bootstrap.Function
+
+	// db is the data store, which is set up once per container
+	db datastore.Manager
+
+	// sink records who created the products in the audit trail
+	sink audit.Sink
+)
+
+// handler handles the API Gateway events of callers with the write role and returns an error if anything goes wrong.
+func handler(ctx context.Context, principal auth.Principal, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Create the headers of the response, the CORS headers
+	// are added by the CORS policy.
+	headers := make(map[string]string)
 
 	// Update the product with an ID
-	prod, err := acmeserverless.UnmarshalCatalogItem(request.Body)
+	prod, err := catalog.UnmarshalProduct(request.Body)
 	if err != nil {
-		return handleError("unmarshalling product", headers, err)
+		return handleError(ctx, "unmarshalling product", headers, err)
 	}
-	prod.ID = uuid.Must(uuid.NewV4()).String()
+	prod.Item.ID = uuid.Must(uuid.NewV4()).String()
 
-	// Store a new product in the catalog
-	dynamoStore := dynamodb.New()
-	err = dynamoStore.AddProduct(prod)
+	// Store a new product in the catalog, on behalf of the caller that API Gateway
+	// identified when the credentials don't name one
+	actor := principal.Name
+	if actor == "" {
+		actor = audit.APIGatewayActor(request.RequestContext)
+	}
+	dynamoStore := audit.Wrap(db.WithContext(ctx), sink, actor, request.RequestContext.Identity.SourceIP)
+	_, err = dynamoStore.AddProduct(prod)
 	if err != nil {
-		return handleError("adding product", headers, err)
+		return handleError(ctx, "adding product", headers, err)
 	}
 
+	// Publish the event of the new product, when it's recorded in the outbox
+	fn.PublishPending(ctx)
+
 	status := acmeserverless.CreateCatalogItemResponse{
 		Message:    "Product created successfully!",
-		ResourceID: prod,
+		ResourceID: prod.Item,
 		Status:     http.StatusOK,
 	}
 
 	payload, err := status.Marshal()
 	if err != nil {
-		return handleError("marshalling response", headers, err)
+		return handleError(ctx, "marshalling response", headers, err)
 	}
 
 	response := events.APIGatewayProxyResponse{
@@ -72,12 +80,12 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 	return response, nil
 }
 
-// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// handleError takes the activity where the error occured and the error object, logs it and sends a message to sentry.
 // The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
-func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
+func handleError(ctx context.Context, area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	logging.FromContext(ctx).Error("error handling request", "area", area, "error", err)
+	bootstrap.CaptureException(ctx, fmt.Errorf("error %s: %s", area, err.Error()))
 	msg := fmt.Sprintf("error %s: %s", area, err.Error())
-	log.Println(msg)
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusBadRequest,
 		Body:       msg,
@@ -87,5 +95,26 @@ func handleError(area string, headers map[string]string, err error) (events.APIG
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	// Set up logging, Sentry, tracing, the CORS policy and the data store once per
+	// container instead of on every invocation
+	var err error
+	fn, err = bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+	db = fn.DB
+
+	// Record who created the products in the audit trail
+	sink, err = audit.Open(os.Getenv("AUDIT_SINK"), dynamodb.NewAuditLog())
+	if err != nil {
+		log.Fatalf("error configuring audit sink: %s", err.Error())
+	}
+
+	// Only callers with the write role can create products
+	authenticator, err := auth.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+
+	lambda.Start(wflambda.Wrapper(fn.HTTP(auth.RequireRoleLambda(authenticator, auth.RoleWrite, handler))))
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+var (
+	// fn is the state shared by the invocations in the container
+	fn *bootstrap.Function
+
+	// db is the data store, which is set up once per container
+	db datastore.Manager
+)
+
+// handler handles the scheduled CloudWatch events and publishes an event for every product
+// whose availability window started or ended since the previous invocation. It also sends
+// the webhook deliveries that are due.
+func handler(ctx context.Context, event events.CloudWatchEvent) error {
+	// Get the interval of the schedule or set it to a minute, it has to match the
+	// rate of the CloudWatch rule so no boundaries are missed or emitted twice
+	interval := time.Minute
+	if i := os.Getenv("SCHEDULE_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil {
+			return handleError(ctx, "parsing SCHEDULE_INTERVAL", err)
+		}
+		interval = d
+	}
+
+	// The time of the event is the moment the rule triggered, which makes the
+	// periods line up even when an invocation starts late
+	to := event.Time
+	if to.IsZero() {
+		to = time.Now()
+	}
+	to = to.Truncate(time.Second)
+
+	// Publish the events that were left in the outbox by invocations that failed
+	fn.PublishPending(ctx)
+
+	n, err := schedule.Run(db.WithContext(ctx), schedule.NewBusEmitter(ctx, fn.Publisher, fn.Source), to.Add(-interval), to)
+	if err != nil {
+		return handleError(ctx, "running schedule", err)
+	}
+
+	// Send the webhook deliveries that are due, including the ones the publisher queued
+	// for the schedule events above and for the events drained from the outbox
+	fn.DeliverWebhooks(ctx)
+
+	logging.FromContext(ctx).Info("emitted schedule events", "count", n)
+	return nil
+}
+
+// handleError takes the activity where the error occured and the error object, logs it and sends a message to sentry.
+// The error is returned so the invocation is marked as failed.
+func handleError(ctx context.Context, area string, err error) error {
+	logging.FromContext(ctx).Error("error running schedule", "area", area, "error", err)
+	msg := fmt.Errorf("error %s: %s", area, err.Error())
+	bootstrap.CaptureException(ctx, msg)
+	return msg
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	// Set up logging, Sentry, tracing and the data store once per container instead of
+	// on every invocation
+	var err error
+	fn, err = bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+	db = fn.DB
+
+	lambda.Start(wflambda.Wrapper(fn.Scheduled(handler)))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/retgits/acme-serverless-catalog/internal/bootstrap"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+var (
+	// fn is the state shared by the invocations in the container
+	fn *bootstrap.Function
+
+	// targets are the copies of the catalog that are kept up to date, which are set up
+	// once per container
+	targets []stream.Target
+
+	// reportFailures is true when the event source mapping has ReportBatchItemFailures in
+	// its function response types, so Lambda reads the failed records from the response
+	reportFailures bool
+)
+
+// handler handles the records of the DynamoDB stream of the catalog table and applies
+// every change to a product to the targets. The records are applied in order, and when a
+// record fails the later records of the same product are skipped, so a product never
+// goes back to an older version. The failed and skipped records are reported, so Lambda
+// retries them instead of the whole batch. When the event source mapping doesn't read the
+// reported records, the invocation fails instead, so Lambda retries the whole batch rather
+// than dropping the failed records.
+func handler(ctx context.Context, event events.DynamoDBEvent) (stream.BatchResponse, error) {
+	var res stream.BatchResponse
+	failed := make(map[string]bool)
+	applied := 0
+
+	for _, record := range event.Records {
+		c, ok, err := dynamodb.DecodeRecord(record)
+		if err != nil {
+			handleError(ctx, "decoding record", record, err)
+			res.Fail(record)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if failed[c.ProductID] {
+			logging.FromContext(ctx).Warn("skipping record after a failed record of the same product", "event_id", record.EventID, "product_id", c.ProductID)
+			res.Fail(record)
+			continue
+		}
+
+		if err := stream.Fanout(ctx, c, targets); err != nil {
+			handleError(ctx, "applying change", record, err)
+			failed[c.ProductID] = true
+			res.Fail(record)
+			continue
+		}
+		applied++
+	}
+
+	logging.FromContext(ctx).Info("applied changes", "count", applied, "failed", len(res.BatchItemFailures))
+	if len(res.BatchItemFailures) > 0 && !reportFailures {
+		return res, fmt.Errorf("%d of %d records failed", len(res.BatchItemFailures), len(event.Records))
+	}
+	return res, nil
+}
+
+// handleError takes the activity where the error occured, the record and the error object,
+// logs it and sends a message to sentry. The record is reported as failed by the caller.
+func handleError(ctx context.Context, area string, record events.DynamoDBEventRecord, err error) {
+	logging.FromContext(ctx).Error("error processing record", "area", area, "event_id", record.EventID, "sequence_number", record.Change.SequenceNumber, "error", err)
+	bootstrap.CaptureException(ctx, fmt.Errorf("error %s: %s", area, err.Error()))
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	// Set up logging, Sentry, tracing and the targets once per container instead of on
+	// every invocation
+	var err error
+	fn, err = bootstrap.New()
+	if err != nil {
+		log.Fatalf("error starting function: %s", err.Error())
+	}
+
+	targets, err = stream.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring targets: %s", err.Error())
+	}
+	if v := os.Getenv("STREAM_REPORT_BATCH_ITEM_FAILURES"); v != "" {
+		if reportFailures, err = strconv.ParseBool(v); err != nil {
+			log.Fatalf("error parsing STREAM_REPORT_BATCH_ITEM_FAILURES: %s", err.Error())
+		}
+	}
+	if len(targets) == 0 {
+		fn.Logger.Warn("no targets configured, changes are only decoded")
+	}
+
+	lambda.Start(wflambda.Wrapper(fn.Stream(handler)))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+)
+
+// failing is a target that fails the changes of one product
+type failing struct {
+	productID string
+	applied   []string
+}
+
+func (f *failing) Name() string {
+	return "failing"
+}
+
+func (f *failing) Apply(ctx context.Context, c stream.Change) error {
+	if c.ProductID == f.productID {
+		return fmt.Errorf("unavailable")
+	}
+	f.applied = append(f.applied, c.ID)
+	return nil
+}
+
+// record returns a stream record that creates a product
+func record(eventID string, sequenceNumber string, productID string) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   eventID,
+		EventName: "INSERT",
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequenceNumber,
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"PK":      events.NewStringAttribute("PRODUCT"),
+				"SK":      events.NewStringAttribute(productID),
+				"Payload": events.NewStringAttribute(fmt.Sprintf(`{"id":%q,"name":"Bottle","price":10}`, productID)),
+				"Version": events.NewNumberAttribute("1"),
+			},
+		},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	batch := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("e1", "100", "p1"),
+		record("e2", "200", "p2"),
+		record("e3", "300", "p1"),
+		{EventID: "e4", EventName: "INSERT", Change: events.DynamoDBStreamRecord{SequenceNumber: "400"}},
+	}}
+
+	tests := []struct {
+		name           string
+		reportFailures bool
+		wantErr        bool
+	}{
+		{name: "reported", reportFailures: true},
+		{name: "not reported", reportFailures: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		target := &failing{productID: "p1"}
+		targets = []stream.Target{target}
+		reportFailures = tt.reportFailures
+
+		res, err := handler(context.Background(), batch)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("handler() %s error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+
+		// The record of p1 fails, the later record of p1 is skipped and the record without
+		// a product is ignored
+		if len(res.BatchItemFailures) != 2 || res.BatchItemFailures[0].ItemIdentifier != "100" || res.BatchItemFailures[1].ItemIdentifier != "300" {
+			t.Errorf("handler() %s failures = %v, want 100 and 300", tt.name, res.BatchItemFailures)
+		}
+		if len(target.applied) != 1 || target.applied[0] != "e2" {
+			t.Errorf("handler() %s applied %v, want e2", tt.name, target.applied)
+		}
+	}
+
+	targets = []stream.Target{&failing{}}
+	reportFailures = false
+	if _, err := handler(context.Background(), batch); err != nil {
+		t.Errorf("handler() without failures = %v, want nil", err)
+	}
+}
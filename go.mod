@@ -5,11 +5,17 @@ replace github.com/wavefronthq/wavefront-lambda-go => github.com/retgits/wavefro
 go 1.14
 
 require (
+	cloud.google.com/go/pubsub v1.10.3
 	github.com/aws/aws-lambda-go v1.16.0
 	github.com/aws/aws-sdk-go v1.30.7
 	github.com/fasthttp/router v1.0.2
 	github.com/getsentry/sentry-go v0.5.1
 	github.com/gofrs/uuid v3.2.0+incompatible
+	github.com/golang/protobuf v1.5.2
+	github.com/graphql-go/graphql v0.7.9
+	github.com/nats-io/nats-server/v2 v2.1.9
+	github.com/nats-io/nats.go v1.10.0
+	github.com/prometheus/client_golang v1.10.0
 	github.com/pulumi/pulumi v1.14.1
 	github.com/pulumi/pulumi-aws/sdk/v2 v2.0.0
 	github.com/pulumi/pulumi/sdk/v2 v2.0.0
@@ -18,5 +24,14 @@ require (
 	github.com/retgits/pulumi-helpers/v2 v2.0.0
 	github.com/valyala/fasthttp v1.10.0
 	github.com/wavefronthq/wavefront-lambda-go v0.0.0-20190812171804-d9475d6695cc
+	github.com/wavefronthq/wavefront-sdk-go v0.9.5
 	go.mongodb.org/mongo-driver v1.4.0-beta1.0.20200416213727-891a5fc9374a
+	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/exporters/otlp v0.20.0
+	go.opentelemetry.io/otel/exporters/stdout v0.20.0
+	go.opentelemetry.io/otel/sdk v0.20.0
+	go.opentelemetry.io/otel/trace v0.20.0
+	google.golang.org/genproto v0.0.0-20210423144448-3a41ef94ed2b
+	google.golang.org/grpc v1.37.0
+	gopkg.in/square/go-jose.v2 v2.5.1
 )
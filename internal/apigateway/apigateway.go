@@ -0,0 +1,20 @@
+// Package apigateway contains the helpers the Lambda functions of the Catalog service share
+// to read the API Gateway events they're invoked with.
+package apigateway
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Header returns the value of the named header of the request. API Gateway keeps the
+// case of the header names the client sent, so they're compared case-insensitively.
+func Header(request events.APIGatewayProxyRequest, name string) string {
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package apigateway
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHeader(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"x-api-key":     "s3cr3t",
+			"Authorization": "Bearer token",
+		},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "X-API-Key", want: "s3cr3t"},
+		{name: "x-api-key", want: "s3cr3t"},
+		{name: "authorization", want: "Bearer token"},
+		{name: "Origin", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Header(request, tt.name); got != tt.want {
+			t.Errorf("Header(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIGatewayActor returns the identity of the caller from the request context of Amazon
+// API Gateway. Users authenticated by an authorizer are identified by their email address,
+// username or subject, in that order, and requests signed with IAM credentials by the ARN
+// of the IAM principal. Unidentified callers are Anonymous. It identifies the callers of
+// the Lambda functions when their token or API key doesn't.
+func APIGatewayActor(rc events.APIGatewayProxyRequestContext) string {
+	if claims, ok := rc.Authorizer["claims"].(map[string]interface{}); ok {
+		for _, name := range []string{"email", "cognito:username", "sub"} {
+			if v, ok := claims[name].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+
+	if p, ok := rc.Authorizer["principalId"].(string); ok && p != "" {
+		return p
+	}
+
+	switch {
+	case rc.Identity.UserArn != "":
+		return rc.Identity.UserArn
+	case rc.Identity.CognitoIdentityID != "":
+		return rc.Identity.CognitoIdentityID
+	case rc.Identity.Caller != "":
+		return rc.Identity.Caller
+	default:
+		return Anonymous
+	}
+}
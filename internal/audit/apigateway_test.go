@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestAPIGatewayActor(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   events.APIGatewayProxyRequestContext
+		want string
+	}{
+		{
+			name: "email claim",
+			rc: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"email": "jane@example.com", "sub": "1234"},
+			}},
+			want: "jane@example.com",
+		},
+		{
+			name: "username claim",
+			rc: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"email": "", "cognito:username": "jane", "sub": "1234"},
+			}},
+			want: "jane",
+		},
+		{
+			name: "subject claim",
+			rc: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "1234"},
+			}},
+			want: "1234",
+		},
+		{
+			name: "custom authorizer",
+			rc:   events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{"principalId": "ops"}},
+			want: "ops",
+		},
+		{
+			name: "IAM user",
+			rc:   events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{UserArn: "arn:aws:iam::123456789012:user/jane", Caller: "AIDA"}},
+			want: "arn:aws:iam::123456789012:user/jane",
+		},
+		{
+			name: "Cognito identity",
+			rc:   events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{CognitoIdentityID: "us-east-1:abcd"}},
+			want: "us-east-1:abcd",
+		},
+		{
+			name: "caller",
+			rc:   events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{Caller: "AIDA"}},
+			want: "AIDA",
+		},
+		{
+			name: "claims that aren't a map",
+			rc:   events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{"claims": "jane"}},
+			want: Anonymous,
+		},
+		{
+			name: "unidentified",
+			rc:   events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{SourceIP: "203.0.113.7"}},
+			want: Anonymous,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := APIGatewayActor(tt.rc); got != tt.want {
+			t.Errorf("%s: APIGatewayActor() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package audit keeps an append-only trail of every change to the catalog of the ACME
+// Serverless Fitness Shop, recording who made the change, when, from where and which
+// fields of the product changed. Entries are written to a Sink, which can be a data
+// store, a file or standard output.
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Anonymous is the actor of changes made by callers that couldn't be identified.
+const Anonymous = "anonymous"
+
+// Entry is a single change to a product in the audit trail.
+type Entry struct {
+	// ID is the unique identifier of the entry
+	ID string `json:"id"`
+
+	// Time is the moment the change was made
+	Time time.Time `json:"time"`
+
+	// Actor is who made the change
+	Actor string `json:"actor"`
+
+	// SourceIP is the IP address the change was made from, if it was made over the API
+	SourceIP string `json:"sourceIp,omitempty"`
+
+	// Operation is the kind of change
+	Operation datastore.Operation `json:"operation"`
+
+	// ProductID is the unique identifier of the product that changed
+	ProductID string `json:"productId"`
+
+	// Changes are the fields of the product that changed
+	Changes []datastore.Change `json:"changes"`
+}
+
+// NewEntry creates an entry for a change to a product made now.
+func NewEntry(actor string, sourceIP string, op datastore.Operation, productID string, changes []datastore.Change) Entry {
+	return Entry{
+		ID:        uuid.Must(uuid.NewV4()).String(),
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		SourceIP:  sourceIP,
+		Operation: op,
+		ProductID: productID,
+		Changes:   changes,
+	}
+}
+
+// Sink stores entries of the audit trail. Entries are only ever added, never changed
+// or removed.
+type Sink interface {
+	Write(e Entry) error
+}
+
+// Log is a Sink that can be queried for the entries it stored.
+type Log interface {
+	Sink
+
+	// Query returns the entries matching the filter, oldest first
+	Query(f Filter) ([]Entry, error)
+}
+
+// Filter selects entries of the audit trail by product, by actor or by both.
+type Filter struct {
+	// ProductID selects the changes to a single product
+	ProductID string
+
+	// Actor selects the changes made by a single actor
+	Actor string
+}
+
+// Validate returns an error if the filter doesn't select a product or an actor.
+func (f Filter) Validate() error {
+	if f.ProductID == "" && f.Actor == "" {
+		return fmt.Errorf("a product or an actor is required")
+	}
+	return nil
+}
+
+// Match returns true if the entry is selected by the filter.
+func (f Filter) Match(e Entry) bool {
+	return (f.ProductID == "" || e.ProductID == f.ProductID) && (f.Actor == "" || e.Actor == f.Actor)
+}
+
+// Open returns the sink described by spec, which is the value of the AUDIT_SINK
+// environment variable. An empty spec or "datastore" uses the log of the data store,
+// "stdout" writes the entries as JSON to standard output and "file:<path>" appends
+// them to a file.
+func Open(spec string, store Log) (Sink, error) {
+	switch {
+	case spec == "" || spec == "datastore":
+		return store, nil
+	case spec == "stdout":
+		return NewWriter(os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFile(strings.TrimPrefix(spec, "file:")), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q, must be datastore, stdout or file:<path>", spec)
+	}
+}
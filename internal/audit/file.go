@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// writer writes entries as JSON, one entry per line.
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter creates a sink that writes entries as JSON lines to w. The entries can't be
+// queried, so it's meant to ship the audit trail to a log collector.
+func NewWriter(w io.Writer) Sink {
+	return &writer{w: w}
+}
+
+// Write adds a single entry as a line of JSON
+func (s *writer) Write(e Entry) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(payload, '\n'))
+	return err
+}
+
+// file appends entries as JSON lines to a file on the local disk.
+type file struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile creates a log that appends entries to the file at path, which is created
+// when the first entry is written.
+func NewFile(path string) Log {
+	return &file{path: path}
+}
+
+// Write appends a single entry to the end of the file
+func (l *file) Write(e Entry) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(payload, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Ping checks that the directory of the file can be reached
+func (l *file) Ping() error {
+	_, err := os.Stat(filepath.Dir(l.path))
+	return err
+}
+
+// Query reads the file and returns the entries matching the filter
+func (l *file) Query(filter Filter) ([]Entry, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, 0)
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("error reading %s on line %d: %s", l.path, line, err.Error())
+		}
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// manager records an entry in the audit trail for every change made through the
+// Manager it wraps.
+type manager struct {
+	datastore.Manager
+	sink     Sink
+	actor    string
+	sourceIP string
+}
+
+// Wrap returns a Manager that writes an entry to the sink for every change made through db,
+// made by actor from sourceIP, with the fields its revision changed. The actor is
+// recorded in the revisions of the products as well.
+func Wrap(db datastore.Manager, sink Sink, actor string, sourceIP string) datastore.Manager {
+	if actor == "" {
+		actor = Anonymous
+	}
+
+	return manager{
+		Manager:  db.WithActor(actor),
+		sink:     sink,
+		actor:    actor,
+		sourceIP: sourceIP,
+	}
+}
+
+// WithActor returns a Manager that records actor in the audit trail and the revisions
+func (m manager) WithActor(actor string) datastore.Manager {
+	return Wrap(m.Manager, m.sink, actor, m.sourceIP)
+}
+
+// WithContext returns a Manager that runs its operations with ctx and keeps recording
+// the changes
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	return Wrap(m.Manager.WithContext(ctx), m.sink, m.actor, m.sourceIP)
+}
+
+// AddProduct stores a product and records the change
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	return m.record(m.Manager.AddProduct(p))
+}
+
+// SetProductStatus moves a product to a new status and records the change
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	return m.record(m.Manager.SetProductStatus(productID, status))
+}
+
+// UpdateProductItem replaces the catalog item of a product and records the change
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	return m.record(m.Manager.UpdateProductItem(item))
+}
+
+// SetProductWindow changes the availability window of a product and records the change
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	return m.record(m.Manager.SetProductWindow(productID, window))
+}
+
+// DeleteProduct removes a product and records the change
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	return m.record(m.Manager.DeleteProduct(productID))
+}
+
+// RollbackProduct restores an earlier revision of a product and records the change
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	return m.record(m.Manager.RollbackProduct(productID, version))
+}
+
+// record writes an entry with the fields the revision a change stored changed, unless the
+// change failed.
+func (m manager) record(rev datastore.Revision, err error) (datastore.Revision, error) {
+	if err != nil {
+		return rev, err
+	}
+
+	after := &rev.Product
+	if rev.Operation == datastore.OperationDelete {
+		after = nil
+	}
+
+	return rev, m.write(rev.Operation, rev.ProductID, rev.Previous, after)
+}
+
+// write adds an entry for the change to the sink.
+func (m manager) write(op datastore.Operation, productID string, before *datastore.Product, after *datastore.Product) error {
+	e := NewEntry(m.actor, m.sourceIP, op, productID, datastore.Diff(before, after))
+	if err := m.sink.Write(e); err != nil {
+		return fmt.Errorf("the product was changed, but the audit entry couldn't be written: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	filestore "github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+)
+
+// memory is a sink that keeps the entries in memory, or fails when err is set.
+type memory struct {
+	entries []Entry
+	err     error
+}
+
+func (s *memory) Write(e Entry) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// counting is a data store that counts the reads of products.
+type counting struct {
+	datastore.Manager
+	reads int
+}
+
+func (c *counting) GetProduct(productID string) (datastore.Product, error) {
+	c.reads++
+	return c.Manager.GetProduct(productID)
+}
+
+func (c *counting) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	c.reads++
+	return c.Manager.GetProductsByIDs(productIDs)
+}
+
+// testStore returns a data store in a snapshot file that is removed when the test ends.
+func testStore(t *testing.T) datastore.Manager {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := filestore.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWrap(t *testing.T) {
+	sink := &memory{}
+	store := &counting{Manager: testStore(t)}
+	db := Wrap(store, sink, "jane", "203.0.113.7")
+
+	p := datastore.Product{Item: acmeserverless.CatalogItem{ID: "1", Name: "Bottle", Price: 10}, Status: datastore.StatusDraft}
+	if _, err := db.AddProduct(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpdateProductItem(acmeserverless.CatalogItem{ID: "1", Name: "Water bottle", Price: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetProductStatus("1", datastore.StatusPublished); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetProductStatus("1", datastore.StatusPublished); err == nil {
+		t.Fatal("expected an error for a transition that isn't allowed")
+	}
+	if _, err := db.DeleteProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RollbackProduct("1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		operation datastore.Operation
+		changes   []datastore.Change
+	}{
+		{datastore.OperationCreate, []datastore.Change{
+			{Field: "name", After: "Bottle"},
+			{Field: "price", After: float32(10)},
+			{Field: "status", After: datastore.StatusDraft},
+		}},
+		{datastore.OperationUpdate, []datastore.Change{
+			{Field: "name", Before: "Bottle", After: "Water bottle"},
+		}},
+		{datastore.OperationStatus, []datastore.Change{
+			{Field: "status", Before: datastore.StatusDraft, After: datastore.StatusPublished},
+		}},
+		{datastore.OperationDelete, []datastore.Change{
+			{Field: "name", Before: "Water bottle"},
+			{Field: "price", Before: float32(10)},
+			{Field: "status", Before: datastore.StatusPublished},
+		}},
+		{datastore.OperationRollback, []datastore.Change{
+			{Field: "name", After: "Bottle"},
+			{Field: "price", After: float32(10)},
+			{Field: "status", After: datastore.StatusDraft},
+		}},
+	}
+
+	if len(sink.entries) != len(tests) {
+		t.Fatalf("got %d entries, want %d", len(sink.entries), len(tests))
+	}
+
+	for i, tt := range tests {
+		e := sink.entries[i]
+		if e.Operation != tt.operation || e.ProductID != "1" || e.Actor != "jane" || e.SourceIP != "203.0.113.7" {
+			t.Errorf("entry %d: got %+v", i, e)
+		}
+		if !reflect.DeepEqual(e.Changes, tt.changes) {
+			t.Errorf("entry %d: got changes %+v, want %+v", i, e.Changes, tt.changes)
+		}
+	}
+
+	// The entries are made from the revisions the writes return
+	if store.reads != 0 {
+		t.Errorf("the products were read %d times, want 0", store.reads)
+	}
+}
+
+func TestWrapActor(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     string
+		withActor string
+		want      string
+	}{
+		{name: "known caller", actor: "jane", want: "jane"},
+		{name: "unknown caller", actor: "", want: Anonymous},
+		{name: "actor set later", actor: "", withActor: "jane", want: "jane"},
+	}
+
+	for _, tt := range tests {
+		sink := &memory{}
+		db := Wrap(testStore(t), sink, tt.actor, "")
+		if tt.withActor != "" {
+			db = db.WithActor(tt.withActor)
+		}
+
+		rev, err := db.AddProduct(datastore.Product{Item: acmeserverless.CatalogItem{ID: "1"}, Status: datastore.StatusDraft})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sink.entries) != 1 || sink.entries[0].Actor != tt.want {
+			t.Errorf("%s: got entries %+v, want one by %s", tt.name, sink.entries, tt.want)
+		}
+		if rev.Actor != tt.want {
+			t.Errorf("%s: the revision was made by %q, want %q", tt.name, rev.Actor, tt.want)
+		}
+	}
+}
+
+// TestWrapSinkError checks that a change that was made is reported as failed when its
+// entry can't be written, so the trail doesn't silently miss changes.
+func TestWrapSinkError(t *testing.T) {
+	store := testStore(t)
+	db := Wrap(store, &memory{err: errors.New("disk full")}, "jane", "")
+
+	if _, err := db.AddProduct(datastore.Product{Item: acmeserverless.CatalogItem{ID: "1"}, Status: datastore.StatusDraft}); err == nil {
+		t.Fatal("expected an error when the entry can't be written")
+	}
+	if _, err := store.GetProduct("1"); err != nil {
+		t.Errorf("the product wasn't stored: %v", err)
+	}
+}
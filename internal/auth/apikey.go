@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// apiKey is a single static API key. Only the hash of the key is kept in memory.
+type apiKey struct {
+	name  string
+	hash  [sha256.Size]byte
+	roles []string
+}
+
+// APIKeys authenticates callers with static API keys, sent in the X-API-Key header or
+// in an Authorization header with the ApiKey scheme.
+type APIKeys struct {
+	keys []apiKey
+}
+
+// ParseAPIKeys creates the authenticator for the comma separated list of API keys in
+// spec. Each key is written as <name>:<key>:<role>|<role>, where the name identifies
+// the caller in the audit trail. Names and keys can't contain colons, roles can.
+func ParseAPIKeys(spec string) (*APIKeys, error) {
+	a := &APIKeys{}
+
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// The entry is reported by its position, any part of it can be the key
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key at position %d, must be <name>:<key>:<role>|<role>", i+1)
+		}
+
+		var roles []string
+		for _, r := range strings.Split(parts[2], "|") {
+			if r = strings.TrimSpace(r); r != "" {
+				roles = append(roles, r)
+			}
+		}
+
+		a.keys = append(a.keys, apiKey{
+			name:  parts[0],
+			hash:  sha256.Sum256([]byte(parts[1])),
+			roles: roles,
+		})
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the caller that owns the API key. Every key is compared, in
+// constant time, so the response time doesn't give away which keys exist.
+func (a *APIKeys) Authenticate(c Credentials) (Principal, error) {
+	key := c.APIKey
+	if key == "" {
+		key = scheme(c.Authorization, "ApiKey")
+	}
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(key))
+
+	var match *apiKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], a.keys[i].hash[:]) == 1 {
+			match = &a.keys[i]
+		}
+	}
+
+	if match == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	return Principal{
+		Name:  "apikey:" + match.name,
+		Roles: match.roles,
+	}, nil
+}
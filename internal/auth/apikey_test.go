@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		wantKeys int
+		wantErr  string
+	}{
+		{name: "empty", spec: ""},
+		{name: "single key", spec: "ops:s3cr3t:catalog:write", wantKeys: 1},
+		{name: "keys with spaces and empty entries", spec: " ops:s3cr3t:catalog:write|catalog:read , ,shop:0th3r:", wantKeys: 2},
+		{name: "key without name", spec: "ops:s3cr3t:catalog:write,s3cr3t", wantErr: "position 2"},
+		{name: "empty name", spec: ":s3cr3t:catalog:write", wantErr: "position 1"},
+		{name: "empty key", spec: "ops::catalog:write", wantErr: "position 1"},
+		{name: "without roles", spec: "ops:s3cr3t", wantErr: "position 1"},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseAPIKeys(tt.spec)
+		if tt.wantErr == "" {
+			if err != nil || len(a.keys) != tt.wantKeys {
+				t.Errorf("%s: ParseAPIKeys() = %v, %v, want %d keys", tt.name, a, err, tt.wantKeys)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: ParseAPIKeys() = %v, want an error about %s", tt.name, err, tt.wantErr)
+			continue
+		}
+		if strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("%s: the error %q contains the key", tt.name, err)
+		}
+	}
+}
+
+func TestAPIKeysAuthenticate(t *testing.T) {
+	a, err := ParseAPIKeys("ops:s3cr3t:catalog:write|catalog:read,shop:0th3r:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		creds     Credentials
+		wantName  string
+		wantRoles int
+		wantErr   error
+	}{
+		{name: "header", creds: Credentials{APIKey: "s3cr3t"}, wantName: "apikey:ops", wantRoles: 2},
+		{name: "authorization", creds: Credentials{Authorization: "ApiKey 0th3r"}, wantName: "apikey:shop"},
+		{name: "unknown key", creds: Credentials{APIKey: "wrong"}, wantErr: ErrInvalidCredentials},
+		{name: "bearer token", creds: Credentials{Authorization: "Bearer s3cr3t"}, wantErr: ErrNoCredentials},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		p, err := a.Authenticate(tt.creds)
+		if err != tt.wantErr || p.Name != tt.wantName || len(p.Roles) != tt.wantRoles {
+			t.Errorf("%s: Authenticate() = %+v, %v, want %s with %d roles, %v", tt.name, p, err, tt.wantName, tt.wantRoles, tt.wantErr)
+		}
+	}
+}
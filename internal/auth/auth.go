@@ -0,0 +1,134 @@
+// Package auth authenticates the callers of the catalog API of the ACME Serverless
+// Fitness Shop and checks that they hold the role needed for a request. Callers present
+// either a JWT bearer token, signed by a key from a JSON Web Key Set, or a static API
+// key. Reading the catalog is public, changing it requires RoleWrite.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// RoleWrite is the role needed to create, update and delete products and to see the
+// products and history that aren't visible on the storefront.
+const RoleWrite = "catalog:write"
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request doesn't carry
+	// credentials it understands.
+	ErrNoCredentials = errors.New("authentication required")
+
+	// ErrInvalidCredentials is returned by an Authenticator when the request carries
+	// credentials it understands, but that aren't valid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Name identifies the caller in the audit trail
+	Name string
+
+	// Roles are the roles granted to the caller
+	Roles []string
+}
+
+// HasRole returns true if the role is granted to the principal.
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials are the parts of a request that can authenticate the caller.
+type Credentials struct {
+	// Authorization is the value of the Authorization header
+	Authorization string
+
+	// APIKey is the value of the X-API-Key header
+	APIKey string
+}
+
+// Authenticator verifies the credentials of a request and returns the caller. It returns
+// ErrNoCredentials when the request doesn't carry credentials it understands, so that
+// authenticators can be chained.
+type Authenticator interface {
+	Authenticate(c Credentials) (Principal, error)
+}
+
+// chain tries a list of authenticators in order.
+type chain []Authenticator
+
+// Chain returns an authenticator that uses the first of the authenticators that
+// understands the credentials. Without any authenticators, every request is rejected.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+// Authenticate returns the result of the first authenticator that understands the
+// credentials
+func (c chain) Authenticate(creds Credentials) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(creds)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return p, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+// Authorize authenticates the credentials and checks that the caller holds the role.
+// It returns the HTTP status code to respond with when the request isn't allowed.
+func Authorize(a Authenticator, role string, c Credentials) (Principal, int, error) {
+	p, err := a.Authenticate(c)
+	if err != nil {
+		return Principal{}, http.StatusUnauthorized, err
+	}
+
+	if !p.HasRole(role) {
+		return p, http.StatusForbidden, errors.New("the role " + role + " is required")
+	}
+
+	return p, http.StatusOK, nil
+}
+
+// FromEnv creates the authenticator configured in the environment. AUTH_JWKS is the path
+// or URL of the JSON Web Key Set that signs bearer tokens, which are checked against
+// AUTH_ISSUER and AUTH_AUDIENCE when those are set. AUTH_API_KEYS lists the static API
+// keys. When neither is set, every request that needs a role is rejected.
+func FromEnv() (Authenticator, error) {
+	var authenticators []Authenticator
+
+	if source := os.Getenv("AUTH_JWKS"); source != "" {
+		keys, err := LoadKeySet(source)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, NewJWT(keys, os.Getenv("AUTH_ISSUER"), os.Getenv("AUTH_AUDIENCE")))
+	}
+
+	if spec := os.Getenv("AUTH_API_KEYS"); spec != "" {
+		keys, err := ParseAPIKeys(spec)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, keys)
+	}
+
+	return Chain(authenticators...), nil
+}
+
+// scheme returns the credentials of an Authorization header that uses the named scheme,
+// or an empty string if it uses another scheme.
+func scheme(header string, name string) string {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], name) {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// principalKey is the user value of a fasthttp request that holds the authenticated caller
+const principalKey = "auth.principal"
+
+// RequireRole returns a fasthttp handler that only calls next when the caller holds the
+// role. Callers without valid credentials get a 401 Unauthorized and callers without the
+// role a 403 Forbidden. The caller is available to next through PrincipalFromRequest.
+func RequireRole(a Authenticator, role string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		p, status, err := Authorize(a, role, Credentials{
+			Authorization: string(ctx.Request.Header.Peek("Authorization")),
+			APIKey:        string(ctx.Request.Header.Peek("X-API-Key")),
+		})
+		if err != nil {
+			if status == http.StatusUnauthorized {
+				ctx.Response.Header.Set("WWW-Authenticate", `Bearer realm="catalog"`)
+			}
+			ctx.SetStatusCode(status)
+			ctx.SetBodyString(err.Error())
+			return
+		}
+
+		ctx.SetUserValue(principalKey, p)
+		next(ctx)
+	}
+}
+
+// PrincipalFromRequest returns the caller that was authenticated by RequireRole.
+func PrincipalFromRequest(ctx *fasthttp.RequestCtx) (Principal, bool) {
+	p, ok := ctx.UserValue(principalKey).(Principal)
+	return p, ok
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizeGRPC authenticates the credentials in the metadata of an incoming gRPC call,
+// the authorization and x-api-key keys, and checks that the caller holds the role. Callers
+// without valid credentials get an Unauthenticated error and callers without the role a
+// PermissionDenied error.
+func AuthorizeGRPC(ctx context.Context, a Authenticator, role string) (Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	p, code, err := Authorize(a, role, Credentials{
+		Authorization: value(md, "authorization"),
+		APIKey:        value(md, "x-api-key"),
+	})
+	if err != nil {
+		if code == http.StatusUnauthorized {
+			return p, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return p, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return p, nil
+}
+
+// value returns the first value of the key in the metadata. The keys of gRPC metadata are
+// always in lower case.
+func value(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// keySetTTL is how long a key set fetched from a URL is used before it's fetched again
+	keySetTTL = time.Hour
+
+	// keySetMinRefresh is the minimum time between two fetches of a key set, so tokens
+	// with an unknown key ID can't be used to flood the identity provider
+	keySetMinRefresh = time.Minute
+)
+
+// KeySet holds the public keys that sign tokens.
+type KeySet interface {
+	// Key returns the key with the ID, or the only key in the set if the ID is empty
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// LoadKeySet returns the JSON Web Key Set at source, which is either a http(s) URL or
+// the path of a file. A key set from a URL is fetched again every hour and when a token
+// is signed by a key it doesn't know yet, so keys can be rotated.
+func LoadKeySet(source string) (KeySet, error) {
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		s := &remoteKeySet{
+			url:    source,
+			client: &http.Client{Timeout: 5 * time.Second},
+		}
+		return s, s.refresh()
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeySet(data)
+}
+
+// staticKeySet is a key set that doesn't change.
+type staticKeySet map[string]crypto.PublicKey
+
+// Key returns the key with the ID
+func (s staticKeySet) Key(kid string) (crypto.PublicKey, error) {
+	if kid == "" && len(s) == 1 {
+		for _, k := range s {
+			return k, nil
+		}
+	}
+
+	k, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return k, nil
+}
+
+// remoteKeySet is a key set that is fetched from a URL and cached.
+type remoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    staticKeySet
+	fetched time.Time
+}
+
+// Key returns the key with the ID, fetching the key set again when it's too old or
+// doesn't have the key
+func (s *remoteKeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.fetched) > keySetTTL {
+		if err := s.fetch(); err != nil && s.keys == nil {
+			return nil, err
+		}
+	}
+
+	k, err := s.keys.Key(kid)
+	if err != nil && time.Since(s.fetched) > keySetMinRefresh {
+		if err := s.fetch(); err != nil {
+			return nil, err
+		}
+		return s.keys.Key(kid)
+	}
+
+	return k, err
+}
+
+// refresh fetches the key set
+func (s *remoteKeySet) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetch()
+}
+
+// fetch gets the key set from the URL. The caller must hold the lock. The keys that
+// were fetched before are kept when the key set can't be fetched.
+func (s *remoteKeySet) fetch() error {
+	s.fetched = time.Now()
+
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return fmt.Errorf("error fetching key set: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching key set: %s returned %s", s.url, res.Status)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("error fetching key set: %s", err.Error())
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	return nil
+}
+
+// parseKeySet parses a JSON Web Key Set. Keys meant for encryption and keys of types
+// that can't verify tokens are skipped.
+func parseKeySet(data []byte) (staticKeySet, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error parsing key set: %s", err.Error())
+	}
+
+	keys := make(staticKeySet)
+	for _, raw := range set.Keys {
+		var k struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &k); err != nil {
+			return nil, fmt.Errorf("error parsing key set: %s", err.Error())
+		}
+		if (k.Use != "" && k.Use != "sig") || (k.Kty != "RSA" && k.Kty != "EC") {
+			continue
+		}
+
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("error parsing key %q: %s", k.Kid, err.Error())
+		}
+
+		// Only the public half of a key is used, also when the set has the private key
+		switch key := jwk.Key.(type) {
+		case *rsa.PublicKey:
+			keys[k.Kid] = key
+		case *ecdsa.PublicKey:
+			keys[k.Kid] = key
+		case *rsa.PrivateKey:
+			keys[k.Kid] = &key.PublicKey
+		case *ecdsa.PrivateKey:
+			keys[k.Kid] = &key.PublicKey
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the key set doesn't have any signing keys")
+	}
+
+	return keys, nil
+}
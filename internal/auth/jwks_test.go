@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// jwks returns the JSON Web Key Set of the keys
+func jwks(t *testing.T, keys ...jose.JSONWebKey) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+
+	rsaKey := jose.JSONWebKey{Key: &keys.rsa.PublicKey, KeyID: "rsa", Use: "sig", Algorithm: "RS256"}
+	ecKey := jose.JSONWebKey{Key: &keys.p256.PublicKey, KeyID: "p256"}
+	encKey := jose.JSONWebKey{Key: &keys.p384.PublicKey, KeyID: "enc", Use: "enc"}
+	privateKey := jose.JSONWebKey{Key: keys.p521, KeyID: "private"}
+	secretKey := jose.JSONWebKey{Key: []byte("s3cr3t"), KeyID: "secret"}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		kids    []string
+		wantErr bool
+	}{
+		{name: "signing keys", data: jwks(t, rsaKey, ecKey), kids: []string{"p256", "rsa"}},
+		{name: "encryption key", data: jwks(t, rsaKey, encKey), kids: []string{"rsa"}},
+		{name: "private key", data: jwks(t, privateKey), kids: []string{"private"}},
+		{name: "symmetric key", data: jwks(t, ecKey, secretKey), kids: []string{"p256"}},
+		{name: "unknown key type", data: []byte(`{"keys":[{"kty":"OKP","crv":"X448","kid":"x","x":"AA"},` + string(jwks(t, ecKey))[9:]), kids: []string{"p256"}},
+		{name: "point not on the curve", data: []byte(`{"keys":[{"kty":"EC","crv":"P-256","kid":"bad","x":"AQ","y":"AQ"}]}`), wantErr: true},
+		{name: "unknown curve", data: []byte(`{"keys":[{"kty":"EC","crv":"P-192","kid":"bad","x":"AQ","y":"AQ"}]}`), wantErr: true},
+		{name: "invalid modulus", data: []byte(`{"keys":[{"kty":"RSA","kid":"bad","n":"!!","e":"AQAB"}]}`), wantErr: true},
+		{name: "no signing keys", data: jwks(t, encKey, secretKey), wantErr: true},
+		{name: "empty", data: []byte(`{"keys":[]}`), wantErr: true},
+		{name: "not JSON", data: []byte(`<html>`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		set, err := parseKeySet(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseKeySet() = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		var kids []string
+		for kid, key := range set {
+			switch key.(type) {
+			case *rsa.PublicKey, *ecdsa.PublicKey:
+			default:
+				t.Errorf("%s: key %s is a %T, want a public key", tt.name, kid, key)
+			}
+			kids = append(kids, kid)
+		}
+		if len(kids) != len(tt.kids) {
+			t.Errorf("%s: parseKeySet() has keys %v, want %v", tt.name, kids, tt.kids)
+			continue
+		}
+		for _, kid := range tt.kids {
+			if _, err := set.Key(kid); err != nil {
+				t.Errorf("%s: Key(%s) = %v", tt.name, kid, err)
+			}
+		}
+	}
+}
+
+func TestStaticKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+
+	single := staticKeySet{"rsa": &keys.rsa.PublicKey}
+	if _, err := single.Key(""); err != nil {
+		t.Errorf("Key(\"\") of a single key = %v, want the key", err)
+	}
+
+	if _, err := keys.keySet().Key(""); err == nil {
+		t.Errorf("Key(\"\") of several keys = nil, want an error")
+	}
+}
+
+// keyServer serves a key set that can be replaced, and counts the requests
+type keyServer struct {
+	mu       sync.Mutex
+	data     []byte
+	status   int
+	requests int
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	w.Write(s.data)
+}
+
+func (s *keyServer) set(data []byte, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.status = data, status
+}
+
+func (s *keyServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+	old := jose.JSONWebKey{Key: &keys.rsa.PublicKey, KeyID: "2020-01"}
+	rotated := jose.JSONWebKey{Key: &keys.p256.PublicKey, KeyID: "2020-02"}
+
+	ks := &keyServer{data: jwks(t, old)}
+	server := httptest.NewServer(ks)
+	defer server.Close()
+
+	set, err := LoadKeySet(server.URL)
+	if err != nil {
+		t.Fatalf("LoadKeySet() = %v", err)
+	}
+	remote := set.(*remoteKeySet)
+	j := NewJWT(set, "", "")
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := map[string]interface{}{"sub": "user-1", "exp": exp}
+
+	verify := func(key interface{}, alg jose.SignatureAlgorithm, kid string) error {
+		_, _, err := j.verify(sign(t, key, alg, kid, claims), time.Now())
+		return err
+	}
+
+	if err := verify(keys.rsa, jose.RS256, "2020-01"); err != nil {
+		t.Fatalf("verify() with the first key = %v", err)
+	}
+
+	// The identity provider rotates its keys. The set was just fetched, so an unknown key
+	// doesn't fetch it again right away.
+	ks.set(jwks(t, old, rotated), 0)
+	if err := verify(keys.p256, jose.ES256, "2020-02"); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("verify() with the rotated key right after a fetch = %v, want an unknown key", err)
+	}
+	if n := ks.count(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+
+	// Once the minimum time between fetches passed, an unknown key fetches the set again
+	remote.mu.Lock()
+	remote.fetched = time.Now().Add(-keySetMinRefresh - time.Second)
+	remote.mu.Unlock()
+	if err := verify(keys.p256, jose.ES256, "2020-02"); err != nil {
+		t.Errorf("verify() with the rotated key = %v", err)
+	}
+	if err := verify(keys.rsa, jose.RS256, "2020-01"); err != nil {
+		t.Errorf("verify() with the first key after the rotation = %v", err)
+	}
+	if n := ks.count(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+
+	// Tokens with made up key IDs don't make the set be fetched on every request
+	for i := 0; i < 5; i++ {
+		verify(keys.rsa, jose.RS256, fmt.Sprintf("made-up-%d", i))
+	}
+	if n := ks.count(); n != 2 {
+		t.Errorf("%d requests after unknown keys, want 2", n)
+	}
+
+	// The old key is removed, which is picked up when the set expires
+	ks.set(jwks(t, rotated), 0)
+	remote.mu.Lock()
+	remote.fetched = time.Now().Add(-keySetTTL - time.Second)
+	remote.mu.Unlock()
+	if err := verify(keys.rsa, jose.RS256, "2020-01"); err == nil {
+		t.Errorf("verify() with a removed key = nil, want an error")
+	}
+	if n := ks.count(); n != 3 {
+		t.Errorf("%d requests after the set expired, want 3", n)
+	}
+
+	// When the set can't be fetched, the keys fetched before are still used
+	ks.set(nil, http.StatusServiceUnavailable)
+	remote.mu.Lock()
+	remote.fetched = time.Now().Add(-keySetTTL - time.Second)
+	remote.mu.Unlock()
+	if err := verify(keys.p256, jose.ES256, "2020-02"); err != nil {
+		t.Errorf("verify() while the set can't be fetched = %v, want the cached key", err)
+	}
+}
+
+func TestLoadKeySetError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := LoadKeySet(server.URL); err == nil {
+		t.Errorf("LoadKeySet() of a missing set = nil, want an error")
+	}
+	if _, err := LoadKeySet("/does/not/exist.json"); err == nil {
+		t.Errorf("LoadKeySet() of a missing file = nil, want an error")
+	}
+}
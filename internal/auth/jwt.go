@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// leeway is the clock skew allowed when checking the expiry of a token
+const leeway = time.Minute
+
+// algorithms are the signing algorithms tokens can use, with the size in bits of the
+// curve of the key for the ECDSA algorithms
+var algorithms = map[jose.SignatureAlgorithm]int{
+	jose.RS256: 0,
+	jose.RS384: 0,
+	jose.RS512: 0,
+	jose.PS256: 0,
+	jose.PS384: 0,
+	jose.PS512: 0,
+	jose.ES256: 256,
+	jose.ES384: 384,
+	jose.ES512: 521,
+}
+
+// JWT authenticates callers with bearer tokens that are JSON Web Tokens signed with
+// RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384 or ES512.
+type JWT struct {
+	keys     KeySet
+	issuer   string
+	audience string
+}
+
+// NewJWT creates the authenticator for tokens signed by a key in the key set. The issuer
+// and audience of the tokens are checked when they're not empty.
+func NewJWT(keys KeySet, issuer string, audience string) *JWT {
+	return &JWT{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// claims are the claims of a token used by the service, next to the registered claims
+type claims struct {
+	Email       string     `json:"email"`
+	Roles       stringList `json:"roles"`
+	Permissions stringList `json:"permissions"`
+	Scope       stringList `json:"scope"`
+	Scp         stringList `json:"scp"`
+}
+
+// stringList is a claim that is either a list of strings or a single string with values
+// separated by spaces.
+type stringList []string
+
+// UnmarshalJSON accepts both a list of strings and a single string
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = strings.Fields(s)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Authenticate verifies the bearer token in the Authorization header and returns the
+// caller it was issued to. The roles of the caller come from the roles, permissions,
+// scope and scp claims.
+func (j *JWT) Authenticate(c Credentials) (Principal, error) {
+	token := scheme(c.Authorization, "Bearer")
+	if token == "" || strings.Count(token, ".") != 2 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	std, cl, err := j.verify(token, time.Now())
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %s", ErrInvalidCredentials.Error(), err.Error())
+	}
+
+	p := Principal{
+		Name: std.Subject,
+	}
+	if cl.Email != "" {
+		p.Name = cl.Email
+	}
+
+	for _, roles := range []stringList{cl.Roles, cl.Permissions, cl.Scope, cl.Scp} {
+		p.Roles = append(p.Roles, roles...)
+	}
+
+	return p, nil
+}
+
+// verify checks the signature and the claims of the token
+func (j *JWT) verify(token string, now time.Time) (jwt.Claims, claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil || len(tok.Headers) != 1 {
+		return jwt.Claims{}, claims{}, fmt.Errorf("malformed token")
+	}
+	header := tok.Headers[0]
+
+	key, err := j.keys.Key(header.KeyID)
+	if err != nil {
+		return jwt.Claims{}, claims{}, err
+	}
+
+	if err := checkAlgorithm(jose.SignatureAlgorithm(header.Algorithm), key); err != nil {
+		return jwt.Claims{}, claims{}, err
+	}
+
+	var std jwt.Claims
+	var cl claims
+	if err := tok.Claims(key, &std, &cl); err != nil {
+		return jwt.Claims{}, claims{}, fmt.Errorf("invalid signature or claims")
+	}
+
+	if std.Expiry == nil {
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token doesn't expire")
+	}
+
+	expected := jwt.Expected{Issuer: j.issuer, Time: now}
+	if j.audience != "" {
+		expected.Audience = jwt.Audience{j.audience}
+	}
+
+	switch err := std.ValidateWithLeeway(expected, leeway); err {
+	case nil:
+	case jwt.ErrExpired:
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token expired")
+	case jwt.ErrNotValidYet, jwt.ErrIssuedInTheFuture:
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token isn't valid yet")
+	case jwt.ErrInvalidIssuer:
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token was issued by %q", std.Issuer)
+	case jwt.ErrInvalidAudience:
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token isn't meant for %q", j.audience)
+	default:
+		return jwt.Claims{}, claims{}, err
+	}
+
+	if std.Subject == "" {
+		return jwt.Claims{}, claims{}, fmt.Errorf("the token doesn't have a subject")
+	}
+
+	return std, cl, nil
+}
+
+// checkAlgorithm returns an error if tokens can't be signed with the algorithm, or if
+// the type or curve of the key doesn't match it, so a token can't pick a weaker algorithm
+// or verify with a key that isn't meant for it.
+func checkAlgorithm(alg jose.SignatureAlgorithm, key crypto.PublicKey) error {
+	bits, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if bits != 0 {
+			return fmt.Errorf("algorithm %q doesn't match the key", alg)
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().BitSize != bits {
+			return fmt.Errorf("algorithm %q doesn't match the key", alg)
+		}
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+
+	return nil
+}
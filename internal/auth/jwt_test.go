@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// testKeys are the private keys the tokens of the tests are signed with, by key ID
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	p256 *ecdsa.PrivateKey
+	p384 *ecdsa.PrivateKey
+	p521 *ecdsa.PrivateKey
+}
+
+// newTestKeys generates a key of every type
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	var k testKeys
+	var err error
+	if k.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if k.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if k.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if k.p521, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// keySet returns the public keys as a static key set
+func (k testKeys) keySet() staticKeySet {
+	return staticKeySet{
+		"rsa":  &k.rsa.PublicKey,
+		"p256": &k.p256.PublicKey,
+		"p384": &k.p384.PublicKey,
+		"p521": &k.p521.PublicKey,
+	}
+}
+
+// sign returns a token with the claims, signed with key and alg, and with kid in its header
+func sign(t *testing.T, key interface{}, alg jose.SignatureAlgorithm, kid string, claims interface{}) string {
+	t.Helper()
+
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader("kid", kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// unsigned returns a token with the header and claims and the given signature
+func unsigned(t *testing.T, header interface{}, claims interface{}, signature []byte) string {
+	t.Helper()
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(h) + "." + enc.EncodeToString(c) + "." + enc.EncodeToString(signature)
+}
+
+// resign replaces the signature of a token
+func resign(token string, signature func(sig []byte) []byte) string {
+	parts := strings.Split(token, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	return parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(signature(sig))
+}
+
+func TestJWTVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+
+	valid := map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://id.example.com/",
+		"aud": []string{"catalog", "orders"},
+		"exp": now.Add(time.Hour).Unix(),
+		"iat": now.Unix(),
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := make(map[string]interface{}, len(valid))
+		for k, v := range valid {
+			c[k] = v
+		}
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	es256 := sign(t, keys.p256, jose.ES256, "p256", valid)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	hmacKey, _ := json.Marshal(keys.rsa.PublicKey)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "RS256", token: sign(t, keys.rsa, jose.RS256, "rsa", valid)},
+		{name: "RS512", token: sign(t, keys.rsa, jose.RS512, "rsa", valid)},
+		{name: "PS256", token: sign(t, keys.rsa, jose.PS256, "rsa", valid)},
+		{name: "ES256", token: es256},
+		{name: "ES384", token: sign(t, keys.p384, jose.ES384, "p384", valid)},
+		{name: "ES512", token: sign(t, keys.p521, jose.ES512, "p521", valid)},
+		{name: "single audience", token: sign(t, keys.rsa, jose.RS256, "rsa", with("aud", "catalog"))},
+		{name: "expired within leeway", token: sign(t, keys.rsa, jose.RS256, "rsa", with("exp", now.Add(-30*time.Second).Unix()))},
+		{name: "not before within leeway", token: sign(t, keys.rsa, jose.RS256, "rsa", with("nbf", now.Add(30*time.Second).Unix()))},
+
+		// Algorithms and keys
+		{name: "alg none", token: unsigned(t, map[string]string{"alg": "none", "kid": "rsa"}, valid, nil), wantErr: `unsupported algorithm "none"`},
+		{name: "alg none without signature", token: strings.TrimSuffix(unsigned(t, map[string]string{"alg": "none", "kid": "rsa"}, valid, nil), "."), wantErr: "malformed token"},
+		{name: "HS256 with the public key as secret", token: sign(t, hmacKey, jose.HS256, "rsa", valid), wantErr: `unsupported algorithm "HS256"`},
+		{name: "RS256 header for an EC key", token: unsigned(t, map[string]string{"alg": "RS256", "kid": "p256"}, valid, make([]byte, 256)), wantErr: `algorithm "RS256" doesn't match the key`},
+		{name: "ES256 header for an RSA key", token: unsigned(t, map[string]string{"alg": "ES256", "kid": "rsa"}, valid, make([]byte, 64)), wantErr: `algorithm "ES256" doesn't match the key`},
+		{name: "ES256 with a P-384 key", token: unsigned(t, map[string]string{"alg": "ES256", "kid": "p384"}, valid, make([]byte, 64)), wantErr: `algorithm "ES256" doesn't match the key`},
+		{name: "ES384 with a P-256 key", token: unsigned(t, map[string]string{"alg": "ES384", "kid": "p256"}, valid, make([]byte, 96)), wantErr: `algorithm "ES384" doesn't match the key`},
+		{name: "unknown key", token: sign(t, keys.rsa, jose.RS256, "other", valid), wantErr: `unknown key "other"`},
+		{name: "no key ID", token: sign(t, keys.rsa, jose.RS256, "", valid), wantErr: `unknown key ""`},
+		{name: "key ID of a key of another curve", token: sign(t, keys.p384, jose.ES384, "p256", valid), wantErr: `algorithm "ES384" doesn't match the key`},
+		{name: "signed by another key", token: sign(t, other, jose.ES256, "p256", valid), wantErr: "invalid signature or claims"},
+
+		// Signatures
+		{name: "ES256 signature too short", token: resign(es256, func(sig []byte) []byte { return sig[:63] }), wantErr: "invalid signature or claims"},
+		{name: "ES256 signature too long", token: resign(es256, func(sig []byte) []byte { return append(sig, 0) }), wantErr: "invalid signature or claims"},
+		{name: "ES256 signature in ASN.1", token: resign(es256, func(sig []byte) []byte { return append([]byte{0x30, 0x44, 0x02, 0x20}, sig...)[:70] }), wantErr: "invalid signature or claims"},
+		{name: "ES256 empty signature", token: resign(es256, func(sig []byte) []byte { return nil }), wantErr: "invalid signature or claims"},
+		{name: "ES256 signature of other claims", token: strings.Split(sign(t, keys.p256, jose.ES256, "p256", with("sub", "admin")), ".")[0] + "." + strings.Split(es256, ".")[1] + "." + strings.Split(sign(t, keys.p256, jose.ES256, "p256", with("sub", "admin")), ".")[2], wantErr: "invalid signature or claims"},
+		{name: "RS256 signature flipped", token: resign(sign(t, keys.rsa, jose.RS256, "rsa", valid), func(sig []byte) []byte { sig[10] ^= 1; return sig }), wantErr: "invalid signature or claims"},
+		{name: "malformed token", token: "not.a.token", wantErr: "malformed token"},
+
+		// Claims
+		{name: "no expiry", token: sign(t, keys.rsa, jose.RS256, "rsa", with("exp", nil)), wantErr: "the token doesn't expire"},
+		{name: "expiry not a number", token: sign(t, keys.rsa, jose.RS256, "rsa", with("exp", "tomorrow")), wantErr: "invalid signature or claims"},
+		{name: "expired", token: sign(t, keys.rsa, jose.RS256, "rsa", with("exp", now.Add(-2*time.Minute).Unix())), wantErr: "the token expired"},
+		{name: "not valid yet", token: sign(t, keys.rsa, jose.RS256, "rsa", with("nbf", now.Add(2*time.Minute).Unix())), wantErr: "the token isn't valid yet"},
+		{name: "issued in the future", token: sign(t, keys.rsa, jose.RS256, "rsa", with("iat", now.Add(2*time.Minute).Unix())), wantErr: "the token isn't valid yet"},
+		{name: "other issuer", token: sign(t, keys.rsa, jose.RS256, "rsa", with("iss", "https://evil.example.com/")), wantErr: `the token was issued by "https://evil.example.com/"`},
+		{name: "no issuer", token: sign(t, keys.rsa, jose.RS256, "rsa", with("iss", nil)), wantErr: `the token was issued by ""`},
+		{name: "other audience", token: sign(t, keys.rsa, jose.RS256, "rsa", with("aud", "orders")), wantErr: `the token isn't meant for "catalog"`},
+		{name: "no audience", token: sign(t, keys.rsa, jose.RS256, "rsa", with("aud", nil)), wantErr: `the token isn't meant for "catalog"`},
+		{name: "no subject", token: sign(t, keys.rsa, jose.RS256, "rsa", with("sub", nil)), wantErr: "the token doesn't have a subject"},
+	}
+
+	j := NewJWT(keys.keySet(), "https://id.example.com/", "catalog")
+	for _, tt := range tests {
+		_, _, err := j.verify(tt.token, now)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: verify() = %v, want nil", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s: verify() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	keys := newTestKeys(t)
+	j := NewJWT(keys.keySet(), "", "")
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          Principal
+		wantErr       error
+	}{
+		{
+			name:          "roles from all claims",
+			authorization: "Bearer " + sign(t, keys.rsa, jose.RS256, "rsa", map[string]interface{}{"sub": "user-1", "exp": exp, "roles": []string{RoleWrite}, "permissions": "audit:read", "scope": "openid catalog:read", "scp": []string{"profile"}}),
+			want:          Principal{Name: "user-1", Roles: []string{RoleWrite, "audit:read", "openid", "catalog:read", "profile"}},
+		},
+		{
+			name:          "email",
+			authorization: "Bearer " + sign(t, keys.p256, jose.ES256, "p256", map[string]interface{}{"sub": "user-1", "email": "ops@example.com", "exp": exp}),
+			want:          Principal{Name: "ops@example.com"},
+		},
+		{name: "no header", authorization: "", wantErr: ErrNoCredentials},
+		{name: "other scheme", authorization: "Basic b3BzOnMzY3IzdA==", wantErr: ErrNoCredentials},
+		{name: "not a JWT", authorization: "Bearer s3cr3t", wantErr: ErrNoCredentials},
+		{name: "invalid", authorization: "Bearer " + sign(t, keys.rsa, jose.RS256, "rsa", map[string]interface{}{"sub": "user-1"}), wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		p, err := j.Authenticate(Credentials{Authorization: tt.authorization})
+		switch {
+		case tt.wantErr == nil && err != nil:
+			t.Errorf("%s: Authenticate() = %v", tt.name, err)
+		case tt.wantErr == ErrNoCredentials && err != ErrNoCredentials:
+			t.Errorf("%s: Authenticate() = %v, want %v", tt.name, err, ErrNoCredentials)
+		case tt.wantErr == ErrInvalidCredentials && (err == nil || !strings.HasPrefix(err.Error(), ErrInvalidCredentials.Error())):
+			t.Errorf("%s: Authenticate() = %v, want %v", tt.name, err, ErrInvalidCredentials)
+		}
+		if tt.wantErr != nil {
+			continue
+		}
+
+		if p.Name != tt.want.Name || strings.Join(p.Roles, " ") != strings.Join(tt.want.Roles, " ") {
+			t.Errorf("%s: Authenticate() = %+v, want %+v", tt.name, p, tt.want)
+		}
+	}
+}
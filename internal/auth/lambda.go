@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+)
+
+// LambdaHandler handles an API Gateway event on behalf of an authenticated caller.
+type LambdaHandler func(ctx context.Context, p Principal, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// RequireRoleLambda returns a Lambda handler that only calls next when the caller holds
+// the role. Callers without valid credentials get a 401 Unauthorized and callers without
+// the role a 403 Forbidden.
+func RequireRoleLambda(a Authenticator, role string, next LambdaHandler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		p, status, err := Authorize(a, role, Credentials{
+			Authorization: apigateway.Header(request, "Authorization"),
+			APIKey:        apigateway.Header(request, "X-API-Key"),
+		})
+		if err != nil {
+			headers := make(map[string]string)
+			if status == http.StatusUnauthorized {
+				headers["WWW-Authenticate"] = `Bearer realm="catalog"`
+			}
+			return events.APIGatewayProxyResponse{
+				StatusCode: status,
+				Body:       err.Error(),
+				Headers:    headers,
+			}, nil
+		}
+
+		return next(ctx, p, request)
+	}
+}
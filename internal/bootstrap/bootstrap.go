@@ -0,0 +1,284 @@
+// Package bootstrap sets up the AWS Lambda functions of the Catalog service of the ACME
+// Serverless Fitness Shop. Logging, Sentry, tracing, the CORS policy and the data store
+// are set up once per container, when the function starts, instead of on every
+// invocation, and every invocation gets its own Sentry hub that describes the request.
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/cors"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+)
+
+// sentryFlushTimeout is how long an invocation waits for its events to be sent to Sentry
+// before it returns, because the container can be frozen until the next invocation.
+const sentryFlushTimeout = 2 * time.Second
+
+// Function is the state that is shared by the invocations in the same container.
+type Function struct {
+	// DB is the data store, which publishes an event for every change and traces its
+	// calls as part of the invocation when it's used with the context of the invocation
+	DB datastore.Manager
+
+	// Logger is the logger of the container, the logger of an invocation is in its
+	// context
+	Logger *logging.Logger
+
+	// Publisher publishes events that aren't written with a change to a product, like
+	// the ones of the schedule, to EVENTS_PUBLISHER and the webhook subscriptions
+	Publisher bus.Publisher
+
+	// Source is the source of the events
+	Source string
+
+	policy   cors.Policy
+	relay    *bus.Relay
+	webhooks *webhook.Dispatcher
+}
+
+// HTTPHandler handles an API Gateway event.
+type HTTPHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// New sets up the function from the environment. The logs are written as JSON at the
+// level in LOG_LEVEL, errors are sent asynchronously to the Sentry DSN in SENTRY_DSN, the
+// traces are exported as configured in the OTEL_* variables, the CORS policy is read
+// from the CORS_* variables and the changes are published to EVENTS_PUBLISHER and queued
+// for the webhook subscriptions.
+func New() (*Function, error) {
+	// Write the logs as JSON, including the lines of the standard logger
+	logger, err := logging.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	logging.SetDefault(logger)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.LevelInfo))
+
+	// Initialize a connection to Sentry to capture errors, the events are sent in the
+	// background and flushed before each invocation ends
+	if err := sentry.Init(sentry.ClientOptions{
+		Dsn:         os.Getenv("SENTRY_DSN"),
+		Transport:   sentry.NewHTTPTransport(),
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	}); err != nil {
+		return nil, fmt.Errorf("error configuring sentry: %s", err.Error())
+	}
+
+	// Export the traces as configured in the OTEL_* environment variables
+	traceCfg, err := tracing.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring tracing: %s", err.Error())
+	}
+	if err := tracing.Init(traceCfg); err != nil {
+		return nil, fmt.Errorf("error configuring tracing: %s", err.Error())
+	}
+
+	// Apply the same CORS policy to preflight requests and responses
+	policy, err := cors.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring CORS: %s", err.Error())
+	}
+
+	// Publish the changes to the catalog, so other services can react to them
+	events, err := bus.Open(os.Getenv("EVENTS_PUBLISHER"))
+	if err != nil {
+		return nil, fmt.Errorf("error configuring events publisher: %s", err.Error())
+	}
+
+	// Publish the events right after the change, or from the outbox that is written in the
+	// same transaction as the change
+	delivery, err := bus.DeliveryFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring events delivery: %s", err.Error())
+	}
+
+	// Queue the events for the webhook subscriptions too, which are sent by the scheduled
+	// function
+	dispatcher := webhook.NewDispatcher(dynamodb.NewWebhooks())
+	publisher := bus.Multi(events, dispatcher)
+
+	f := &Function{
+		Logger:    logger,
+		Publisher: publisher,
+		Source:    bus.SourceFromEnv(),
+		policy:    policy,
+		webhooks:  dispatcher,
+	}
+
+	if delivery == bus.DeliveryOutbox {
+		f.DB = tracing.Instrument(dynamodb.NewWithOutbox(), "dynamodb")
+		f.relay = bus.NewRelay(dynamodb.NewOutbox(), map[string]bus.Publisher{"events": events, "webhooks": dispatcher}, f.Source)
+	} else {
+		f.DB = bus.Wrap(tracing.Instrument(dynamodb.New(), "dynamodb"), publisher, f.Source)
+	}
+
+	return f, nil
+}
+
+// PublishPending publishes the events in the outbox, when the events are delivered through
+// the outbox. Functions that change products call it before they return, so the events
+// are published right away, and the scheduled function calls it to publish the events
+// that were left behind by invocations that failed. Events that can't be published stay
+// in the outbox, so the error is only logged and sent to Sentry.
+func (f *Function) PublishPending(ctx context.Context) {
+	if f.relay == nil {
+		return
+	}
+
+	n, err := f.relay.Drain(ctx)
+	if n > 0 {
+		logging.FromContext(ctx).Info("published events from the outbox", "count", n)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error draining the outbox", "error", err)
+		CaptureException(ctx, fmt.Errorf("error draining the outbox: %s", err.Error()))
+	}
+}
+
+// DeliverWebhooks sends the webhook deliveries that are due. The scheduled function calls
+// it on every invocation, so the deliveries are sent, and retried, within the interval of
+// the schedule. Deliveries that fail are retried by a later invocation, so the error is
+// only logged and sent to Sentry.
+func (f *Function) DeliverWebhooks(ctx context.Context) {
+	n, err := f.webhooks.Deliver(ctx)
+	if n > 0 {
+		logging.FromContext(ctx).Info("attempted webhook deliveries", "count", n)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error delivering webhooks", "error", err)
+		CaptureException(ctx, fmt.Errorf("error delivering webhooks: %s", err.Error()))
+	}
+}
+
+// HTTP returns the Lambda handler of an API Gateway function. It applies the CORS policy,
+// gives the request a request ID, traces it and passes a Sentry hub that describes the
+// request to next in the context.
+func (f *Function) HTTP(next HTTPHandler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return cors.WrapLambda(f.policy, logging.WrapLambda(tracing.WrapLambda(withHub(next))))
+}
+
+// Scheduled returns the Lambda handler of a function that is triggered by a CloudWatch
+// rule. The invocation is traced, and the spans and the events sent to Sentry by next are
+// flushed before it ends.
+func (f *Function) Scheduled(next func(ctx context.Context, event events.CloudWatchEvent) error) func(context.Context, events.CloudWatchEvent) error {
+	return func(ctx context.Context, event events.CloudWatchEvent) error {
+		ctx = logging.NewContext(ctx, f.Logger.With("event_id", event.ID))
+
+		hub := newHub(ctx)
+		hub.Scope().SetTag("event_id", event.ID)
+		hub.Scope().SetExtra("event_time", event.Time)
+		defer flushHub(ctx, hub)
+
+		return run(sentry.SetHubOnContext(ctx, hub), "schedule "+event.DetailType, "timer", func(ctx context.Context) error {
+			return next(ctx, event)
+		})
+	}
+}
+
+// Stream returns the Lambda handler of a function that consumes a DynamoDB stream. The
+// invocation is traced, and the spans and the events sent to Sentry by next are flushed
+// before it ends.
+func (f *Function) Stream(next func(ctx context.Context, event events.DynamoDBEvent) (stream.BatchResponse, error)) func(context.Context, events.DynamoDBEvent) (stream.BatchResponse, error) {
+	return func(ctx context.Context, event events.DynamoDBEvent) (stream.BatchResponse, error) {
+		var first, last string
+		if n := len(event.Records); n > 0 {
+			first = event.Records[0].Change.SequenceNumber
+			last = event.Records[n-1].Change.SequenceNumber
+		}
+		ctx = logging.NewContext(ctx, f.Logger.With("records", len(event.Records)))
+
+		hub := newHub(ctx)
+		hub.Scope().SetExtra("records", len(event.Records))
+		hub.Scope().SetExtra("first_sequence_number", first)
+		hub.Scope().SetExtra("last_sequence_number", last)
+		defer flushHub(ctx, hub)
+
+		var res stream.BatchResponse
+		err := run(sentry.SetHubOnContext(ctx, hub), "process stream", "datasource", func(ctx context.Context) error {
+			var err error
+			res, err = next(ctx, event)
+			return err
+		})
+		return res, err
+	}
+}
+
+// newHub returns a Sentry hub for an invocation that isn't an API Gateway request
+func newHub(ctx context.Context) *sentry.Hub {
+	hub := sentry.CurrentHub().Clone()
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		hub.Scope().SetTag("aws_request_id", lc.AwsRequestID)
+	}
+	return hub
+}
+
+// run calls next in a span with the given name and trigger, and exports the spans before
+// it returns
+func run(ctx context.Context, name string, trigger string, next func(ctx context.Context) error) error {
+	spanCtx, span := tracing.Start(ctx, name, tracing.KindInternal)
+	span.SetAttribute("faas.trigger", trigger)
+	span.SetAttribute("faas.name", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+
+	err := next(spanCtx)
+	span.End(err)
+
+	if ferr := tracing.Flush(); ferr != nil {
+		logging.FromContext(ctx).Error("error exporting spans", "error", ferr)
+	}
+
+	return err
+}
+
+// CaptureException sends the error to Sentry, using the hub of the invocation in ctx so
+// the event describes the request.
+func CaptureException(ctx context.Context, err error) {
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub.CaptureException(err)
+}
+
+// withHub returns a handler that passes a Sentry hub, which describes the API Gateway
+// request, to next. Panics are sent to Sentry before they're passed on, and the events
+// of the invocation are flushed before it ends.
+func withHub(next HTTPHandler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		hub := sentry.CurrentHub().Clone()
+		describe(hub.Scope(), ctx, request)
+		ctx = sentry.SetHubOnContext(ctx, hub)
+
+		defer flushHub(ctx, hub)
+		defer func() {
+			if err := recover(); err != nil {
+				hub.RecoverWithContext(ctx, err)
+				panic(err)
+			}
+		}()
+
+		return next(ctx, request)
+	}
+}
+
+// flushHub waits for the events of the hub to be sent to Sentry
+func flushHub(ctx context.Context, hub *sentry.Hub) {
+	if !hub.Flush(sentryFlushTimeout) {
+		logging.FromContext(ctx).Warn("not all events could be sent to sentry")
+	}
+}
@@ -0,0 +1,99 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/cors"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// transport keeps the events sent to Sentry.
+type transport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transport) Configure(options sentry.ClientOptions) {}
+
+func (t *transport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *transport) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// TestHTTP checks that the request ID of an API Gateway request is in the context, the
+// logs and the response of the handler, and tags the errors sent to Sentry.
+func TestHTTP(t *testing.T) {
+	sent := &transport{}
+	if err := sentry.Init(sentry.ClientOptions{Transport: sent}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sentry.CurrentHub().BindClient(nil) })
+
+	// New makes the logger of the container the default logger
+	var buf bytes.Buffer
+	logger := logging.New(&buf, logging.LevelInfo)
+	old := logging.Default()
+	logging.SetDefault(logger)
+	t.Cleanup(func() { logging.SetDefault(old) })
+
+	f := &Function{Logger: logger, policy: cors.DefaultPolicy()}
+
+	var ctxID string
+	handler := f.HTTP(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctxID = logging.RequestIDFromContext(ctx)
+		logging.FromContext(ctx).Info("handled")
+		CaptureException(ctx, errors.New("error in get"))
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "X-Request-ID", headers: map[string]string{"X-Request-ID": "req-123"}, want: "req-123"},
+		{name: "API Gateway request ID", want: "gw-456"},
+	}
+
+	for _, tt := range tests {
+		sent.events = nil
+		buf.Reset()
+
+		res, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodGet,
+			Path:           "/products",
+			Headers:        tt.headers,
+			RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gw-456"},
+		})
+		if err != nil {
+			t.Fatalf("%s: handler() = %v", tt.name, err)
+		}
+
+		if ctxID != tt.want {
+			t.Errorf("%s: request ID = %q, want %q", tt.name, ctxID, tt.want)
+		}
+		if got := res.Headers[logging.RequestIDHeader]; got != tt.want {
+			t.Errorf("%s: %s = %q, want %q", tt.name, logging.RequestIDHeader, got, tt.want)
+		}
+		if want := `"request_id":"` + tt.want + `"`; !strings.Contains(buf.String(), want) {
+			t.Errorf("%s: the log doesn't contain %s:\n%s", tt.name, want, buf.String())
+		}
+		if len(sent.events) != 1 || sent.events[0].Tags["request_id"] != tt.want {
+			t.Errorf("%s: the events sent to Sentry = %+v, want one tagged with request_id %s", tt.name, sent.events, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bootstrap
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// sensitiveHeaders are the request headers that aren't sent to Sentry, because they carry
+// credentials
+var sensitiveHeaders = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"x-api-key":     true,
+}
+
+// describe adds the API Gateway request to the scope, so the events sent to Sentry show
+// which request failed and how to find it in the logs.
+func describe(scope *sentry.Scope, ctx context.Context, request events.APIGatewayProxyRequest) {
+	headers := make(map[string]string, len(request.Headers))
+	for k, v := range request.Headers {
+		if !sensitiveHeaders[strings.ToLower(k)] {
+			headers[k] = v
+		}
+	}
+
+	query := url.Values{}
+	for k, v := range request.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, vs := range request.MultiValueQueryStringParameters {
+		query[k] = vs
+	}
+
+	rc := request.RequestContext
+	scope.SetRequest(sentry.Request{
+		URL:         "https://" + apigateway.Header(request, "Host") + request.Path,
+		Method:      request.HTTPMethod,
+		QueryString: query.Encode(),
+		Headers:     headers,
+	})
+
+	scope.SetTag("request_id", logging.RequestIDFromContext(ctx))
+	scope.SetTag("api_id", rc.APIID)
+	scope.SetTag("stage", rc.Stage)
+	scope.SetTag("resource", request.Resource)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		scope.SetTag("aws_request_id", lc.AwsRequestID)
+	}
+
+	scope.SetUser(sentry.User{IPAddress: rc.Identity.SourceIP})
+
+	scope.SetContext("api_gateway", map[string]interface{}{
+		"account_id":    rc.AccountID,
+		"api_id":        rc.APIID,
+		"request_id":    rc.RequestID,
+		"resource_path": rc.ResourcePath,
+		"stage":         rc.Stage,
+		"user_agent":    rc.Identity.UserAgent,
+		"path":          request.Path,
+		"path_params":   request.PathParameters,
+	})
+}
@@ -0,0 +1,159 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// awsSession creates the session of the AWS publishers, in the region in REGION
+func awsSession() *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REGION")),
+	}))
+}
+
+// snsPublisher publishes events to an Amazon SNS topic.
+type snsPublisher struct {
+	client   *sns.SNS
+	topicARN string
+}
+
+// NewSNS creates a publisher that sends the events, in the structured JSON format, as the
+// messages of the SNS topic. The type, source and ID of the event are added as message
+// attributes, so subscriptions can filter on them.
+func NewSNS(topicARN string) Publisher {
+	return &snsPublisher{
+		client:   sns.New(awsSession()),
+		topicARN: topicARN,
+	}
+}
+
+// Publish sends a single event to the topic
+func (p *snsPublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(string(payload)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"ce_type":      stringAttribute(e.Type),
+			"ce_source":    stringAttribute(e.Source),
+			"ce_id":        stringAttribute(e.ID),
+			"content_type": stringAttribute(ContentType),
+		},
+	})
+	return err
+}
+
+// Ping reads the attributes of the topic. A publisher that isn't allowed to read them
+// reached SNS, which counts as a success.
+func (p *snsPublisher) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := p.client.GetTopicAttributesWithContext(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(p.topicARN),
+	})
+	if isAWSError(err, "AuthorizationError") {
+		return nil
+	}
+	return err
+}
+
+// Close does nothing, the SNS client doesn't hold a connection
+func (p *snsPublisher) Close() error {
+	return nil
+}
+
+// stringAttribute returns a message attribute of the String data type
+func stringAttribute(value string) *sns.MessageAttributeValue {
+	return &sns.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+// eventBridgePublisher puts events on an Amazon EventBridge bus.
+type eventBridgePublisher struct {
+	client *eventbridge.EventBridge
+	bus    string
+}
+
+// NewEventBridge creates a publisher that puts the events on the named EventBridge bus.
+// The source and detail type of the EventBridge event are the source and type of the
+// CloudEvent, so rules can match on them, and the detail is the entire CloudEvent.
+func NewEventBridge(bus string) Publisher {
+	return &eventBridgePublisher{
+		client: eventbridge.New(awsSession()),
+		bus:    bus,
+	}
+}
+
+// Publish puts a single event on the bus
+func (p *eventBridgePublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(p.bus),
+				Source:       aws.String(e.Source),
+				DetailType:   aws.String(e.Type),
+				Detail:       aws.String(string(payload)),
+				Time:         aws.Time(e.Time),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// EventBridge accepts the request even when the entry is rejected
+	if aws.Int64Value(res.FailedEntryCount) > 0 && len(res.Entries) > 0 {
+		entry := res.Entries[0]
+		return fmt.Errorf("EventBridge rejected the event: %s: %s", aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+	}
+
+	return nil
+}
+
+// Ping describes the bus. A publisher that isn't allowed to describe it reached
+// EventBridge, which counts as a success.
+func (p *eventBridgePublisher) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := p.client.DescribeEventBusWithContext(ctx, &eventbridge.DescribeEventBusInput{
+		Name: aws.String(p.bus),
+	})
+	if isAWSError(err, "AccessDeniedException") {
+		return nil
+	}
+	return err
+}
+
+// Close does nothing, the EventBridge client doesn't hold a connection
+func (p *eventBridgePublisher) Close() error {
+	return nil
+}
+
+// isAWSError returns true if err is an error of an AWS service with the code
+func isAWSError(err error, code string) bool {
+	var e awserr.Error
+	return errors.As(err, &e) && e.Code() == code
+}
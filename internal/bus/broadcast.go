@@ -0,0 +1,189 @@
+package bus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// listenerBuffer is the number of events a listener can fall behind before it's dropped
+const listenerBuffer = 64
+
+// Listener receives the events sent to a Broadcaster after it subscribed.
+type Listener struct {
+	b      *Broadcaster
+	events chan Event
+
+	// skip is the ID of the last event the listener got from the log, so the events up
+	// to it aren't sent again when the broadcaster reads them
+	skip string
+}
+
+// Events returns the channel the events are sent on. The channel is closed when the
+// listener is cancelled, when the broadcaster is closed, or when the listener fell too far
+// behind, in which case it can subscribe again after the last event it received.
+func (l *Listener) Events() <-chan Event {
+	return l.events
+}
+
+// Cancel stops sending events to the listener
+func (l *Listener) Cancel() {
+	l.b.mu.Lock()
+	defer l.b.mu.Unlock()
+
+	l.b.remove(l)
+}
+
+// Broadcaster is a publisher that sends every event to the listeners in the same process,
+// like the clients of a live feed, and keeps the most recent events in memory so listeners
+// that reconnect can catch up on the events they missed. A broadcaster that follows a
+// FeedLog sends the events published by every instance of the service.
+type Broadcaster struct {
+	mu        sync.Mutex
+	size      int
+	recent    []Event
+	listeners map[*Listener]struct{}
+	closed    bool
+	log       FeedLog
+}
+
+// NewBroadcaster creates a broadcaster that keeps the last size events.
+func NewBroadcaster(size int) *Broadcaster {
+	if size < 0 {
+		size = 0
+	}
+
+	return &Broadcaster{
+		size:      size,
+		listeners: make(map[*Listener]struct{}),
+	}
+}
+
+// Follow publishes the events of the log until ctx is done or the log can't be read, and
+// looks up the events a listener subscribes after in the log when the broadcaster doesn't
+// have them yet. It returns nil when ctx is done, and the error of the log otherwise, in
+// which case the caller can follow the log again.
+func (b *Broadcaster) Follow(ctx context.Context, log FeedLog) error {
+	b.mu.Lock()
+	b.log = log
+	b.mu.Unlock()
+
+	return log.Tail(ctx, func(e Event) {
+		b.Publish(ctx, e)
+	})
+}
+
+// Subscribe creates a listener for the events published from now on. When after is the
+// ID of an event the broadcaster still has, the events published after it are returned
+// too, so no event is missed between the two. When the broadcaster follows a log, an
+// event it doesn't have yet, like one published by another instance, is looked up in
+// the log. It returns false when after isn't empty and the event isn't known anymore, in
+// which case the listener may have missed events.
+func (b *Broadcaster) Subscribe(ctx context.Context, after string) (*Listener, []Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := &Listener{
+		b:      b,
+		events: make(chan Event, listenerBuffer),
+	}
+	if b.closed {
+		close(l.events)
+		return l, nil, true
+	}
+	b.listeners[l] = struct{}{}
+
+	if after == "" {
+		return l, nil, true
+	}
+
+	for i := len(b.recent) - 1; i >= 0; i-- {
+		if b.recent[i].ID == after {
+			missed := make([]Event, len(b.recent)-i-1)
+			copy(missed, b.recent[i+1:])
+			return l, missed, true
+		}
+	}
+	if b.log == nil {
+		return l, nil, false
+	}
+
+	// The broadcaster hasn't read the event from the log yet. The lock is held while the
+	// log is read, so the events it reads next are the ones the listener skips.
+	missed, ok, err := b.log.Since(ctx, after)
+	if err != nil {
+		logging.FromContext(ctx).Error("error reading feed log", "function", "Subscribe", "after", after, "error", err)
+		return l, nil, false
+	}
+	if !ok {
+		return l, nil, false
+	}
+
+	l.skip = after
+	if len(missed) > 0 {
+		l.skip = missed[len(missed)-1].ID
+	}
+	return l, missed, true
+}
+
+// Publish sends the event to every listener. An event that was already published, like
+// an event from the outbox that is published again, is dropped. Listeners that can't keep
+// up are dropped too, so Publish never blocks.
+func (b *Broadcaster) Publish(ctx context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	for _, r := range b.recent {
+		if r.ID == e.ID {
+			return nil
+		}
+	}
+
+	b.recent = append(b.recent, e)
+	if len(b.recent) > b.size {
+		b.recent = b.recent[len(b.recent)-b.size:]
+	}
+
+	for l := range b.listeners {
+		if l.skip != "" {
+			if l.skip == e.ID {
+				l.skip = ""
+			}
+			continue
+		}
+
+		select {
+		case l.events <- e:
+		default:
+			b.remove(l)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the channels of all listeners, so the feeds end before the server stops
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for l := range b.listeners {
+		b.remove(l)
+	}
+	b.closed = true
+	return nil
+}
+
+// remove closes the channel of a listener and forgets it. The caller holds the lock.
+func (b *Broadcaster) remove(l *Listener) {
+	if _, ok := b.listeners[l]; !ok {
+		return
+	}
+	delete(b.listeners, l)
+	close(l.events)
+}
@@ -0,0 +1,114 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// lagging is a feed log that isn't tailed until ready is closed, like the log of an
+// instance that hasn't read the events of another instance yet.
+type lagging struct {
+	FeedLog
+	tailing chan struct{}
+	ready   chan struct{}
+}
+
+func (l *lagging) Tail(ctx context.Context, fn func(e Event)) error {
+	close(l.tailing)
+	<-l.ready
+	return l.FeedLog.Tail(ctx, fn)
+}
+
+// next returns the next event of the listener, or fails the test if it doesn't arrive.
+func next(t *testing.T, l *Listener) Event {
+	t.Helper()
+
+	select {
+	case e, open := <-l.Events():
+		if !open {
+			t.Fatal("the listener was closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event for the listener")
+	}
+	return Event{}
+}
+
+// TestBroadcasterFollow checks that the events published on one instance reach the
+// listeners of another, and that a listener can subscribe after an event that another
+// instance published, before its own instance has read it.
+func TestBroadcasterFollow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := NewMemoryLog(10)
+	first, second := NewBroadcaster(10), NewBroadcaster(10)
+	go first.Follow(ctx, log)
+
+	lag := &lagging{FeedLog: log, tailing: make(chan struct{}), ready: make(chan struct{})}
+	go second.Follow(ctx, lag)
+	<-lag.tailing
+
+	listener, _, _ := first.Subscribe(ctx, "")
+	events := []Event{testEvent(t), testEvent(t), testEvent(t)}
+	for _, e := range events {
+		log.Publish(ctx, e)
+	}
+	for _, e := range events {
+		if got := next(t, listener); got.ID != e.ID {
+			t.Errorf("the first instance got event %s, want %s", got.ID, e.ID)
+		}
+	}
+
+	// The second instance looks up the event in the log
+	tests := []struct {
+		name   string
+		after  string
+		missed []Event
+		wantOK bool
+	}{
+		{name: "first event", after: events[0].ID, missed: events[1:], wantOK: true},
+		{name: "last event", after: events[2].ID, wantOK: true},
+		{name: "unknown event", after: "unknown"},
+	}
+
+	var listeners []*Listener
+	for _, tt := range tests {
+		l, missed, ok := second.Subscribe(ctx, tt.after)
+		if ok != tt.wantOK || len(missed) != len(tt.missed) {
+			t.Errorf("%s: Subscribe() = %d events, %t, want %d, %t", tt.name, len(missed), ok, len(tt.missed), tt.wantOK)
+			continue
+		}
+		for i := range missed {
+			if missed[i].ID != tt.missed[i].ID {
+				t.Errorf("%s: missed event %d is %s, want %s", tt.name, i, missed[i].ID, tt.missed[i].ID)
+			}
+		}
+		if ok {
+			listeners = append(listeners, l)
+		}
+	}
+
+	// Once the second instance reads the log, the listeners only get the events they
+	// didn't get from the log
+	close(lag.ready)
+	e := testEvent(t)
+	log.Publish(ctx, e)
+	for _, l := range listeners {
+		if got := next(t, l); got.ID != e.ID {
+			t.Errorf("the second instance got event %s, want %s", got.ID, e.ID)
+		}
+	}
+
+	// An event that is published again isn't sent twice
+	next(t, listener)
+	log.Publish(ctx, e)
+	first.Publish(ctx, e)
+	select {
+	case got := <-listener.Events():
+		t.Errorf("the first instance got event %s again", got.ID)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
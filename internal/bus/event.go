@@ -0,0 +1,134 @@
+// Package bus publishes the changes to the catalog of the ACME Serverless Fitness Shop as
+// events, so other services, like the cart, order and recommendation services, can react
+// to them. The events are CloudEvents in the structured JSON format and are sent to a
+// Publisher, which can be Amazon SNS or EventBridge, Google Cloud Pub/Sub, NATS, standard
+// output or memory.
+package bus
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification the events follow
+	SpecVersion = "1.0"
+
+	// ContentType is the media type of an event in the structured JSON format
+	ContentType = "application/cloudevents+json"
+
+	// DefaultSource is the source of the events when EVENTS_SOURCE isn't set
+	DefaultSource = "/acme/catalog"
+)
+
+const (
+	// TypeProductCreated is the type of the event sent when a product is added
+	TypeProductCreated = "com.acme.catalog.ProductCreated"
+
+	// TypeProductUpdated is the type of the event sent when an existing product is
+	// changed, including changes to its status and availability window and rollbacks
+	TypeProductUpdated = "com.acme.catalog.ProductUpdated"
+
+	// TypeProductDeleted is the type of the event sent when a product is permanently
+	// removed
+	TypeProductDeleted = "com.acme.catalog.ProductDeleted"
+
+	// TypeProductPublished is the type of the event sent when the availability window of
+	// a published product starts, so it's listed on the storefront
+	TypeProductPublished = "com.acme.catalog.ProductPublished"
+
+	// TypeProductUnpublished is the type of the event sent when the availability window
+	// of a published product ends, so it's taken off the storefront
+	TypeProductUnpublished = "com.acme.catalog.ProductUnpublished"
+)
+
+// Event is a CloudEvent, with the attributes of the specification that the Catalog
+// service sets.
+type Event struct {
+	// SpecVersion is the version of the CloudEvents specification, always 1.0
+	SpecVersion string `json:"specversion"`
+
+	// ID identifies the event, consumers use it together with Source to drop duplicates
+	ID string `json:"id"`
+
+	// Source identifies the Catalog service that sent the event
+	Source string `json:"source"`
+
+	// Type is one of the Type* constants
+	Type string `json:"type"`
+
+	// Subject is the ID of the product the event is about
+	Subject string `json:"subject"`
+
+	// Time is the moment the product changed
+	Time time.Time `json:"time"`
+
+	// DataContentType is the media type of Data, always application/json
+	DataContentType string `json:"datacontenttype"`
+
+	// Data describes the change, it's a ProductData for the product events
+	Data json.RawMessage `json:"data"`
+}
+
+// ProductData is the data of the product events.
+type ProductData struct {
+	// ProductID is the unique identifier of the product
+	ProductID string `json:"productId"`
+
+	// Operation is the kind of write that changed the product, it's left out of the
+	// events of the schedule, which aren't caused by a write
+	Operation datastore.Operation `json:"operation,omitempty"`
+
+	// Actor is who made the change, if that's known
+	Actor string `json:"actor,omitempty"`
+
+	// Product is the product after the change. For ProductDeleted it's the product as
+	// it was removed.
+	Product datastore.Product `json:"product"`
+}
+
+// NewEvent creates an event with a new ID, sent from source now.
+func NewEvent(source string, eventType string, subject string, data interface{}) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.Must(uuid.NewV4()).String(),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            payload,
+	}, nil
+}
+
+// ProductEvent creates the event for a write to a product. The type follows from the
+// operation and whether the product existed before the write.
+func ProductEvent(source string, actor string, op datastore.Operation, existed bool, p datastore.Product) (Event, error) {
+	eventType := TypeProductUpdated
+	switch {
+	case op == datastore.OperationDelete:
+		eventType = TypeProductDeleted
+	case !existed:
+		eventType = TypeProductCreated
+	}
+
+	return NewEvent(source, eventType, p.Item.ID, ProductData{
+		ProductID: p.Item.ID,
+		Operation: op,
+		Actor:     actor,
+		Product:   p,
+	})
+}
+
+// Marshal returns the event in the structured JSON format
+func (e Event) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
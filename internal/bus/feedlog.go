@@ -0,0 +1,120 @@
+package bus
+
+import (
+	"context"
+	"sync"
+)
+
+// FeedLog is a publisher that keeps the most recent events in a store that is shared by
+// the instances of a service, in the order they were published. Every instance follows
+// the log with a Broadcaster, so the clients of a live feed get the changes made through
+// any instance, and can reconnect to any instance.
+type FeedLog interface {
+	Publisher
+
+	// Tail sends the events in the log to fn, oldest first, and then every event that is
+	// published, until ctx is done or the log can't be read. It returns nil when ctx is
+	// done, and the error of the log otherwise, in which case the caller can tail the log
+	// again.
+	Tail(ctx context.Context, fn func(e Event)) error
+
+	// Since returns the events published after the event with the ID, oldest first. It
+	// returns false when the event isn't in the log.
+	Since(ctx context.Context, id string) ([]Event, bool, error)
+}
+
+// MemoryLog is a feed log in memory, for tests and for a service that runs a single
+// instance.
+type MemoryLog struct {
+	mu      sync.Mutex
+	size    int
+	events  []Event
+	updated chan struct{}
+}
+
+// NewMemoryLog creates a log that keeps the last size events.
+func NewMemoryLog(size int) *MemoryLog {
+	return &MemoryLog{
+		size:    size,
+		updated: make(chan struct{}),
+	}
+}
+
+// Publish adds the event to the log, unless it's in the log already
+func (l *MemoryLog) Publish(ctx context.Context, e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, r := range l.events {
+		if r.ID == e.ID {
+			return nil
+		}
+	}
+
+	l.events = append(l.events, e)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+
+	close(l.updated)
+	l.updated = make(chan struct{})
+	return nil
+}
+
+// Tail sends the events in the log and every event that is published, until ctx is done.
+// Events that were dropped from the log before they were sent are skipped.
+func (l *MemoryLog) Tail(ctx context.Context, fn func(e Event)) error {
+	last := ""
+	for {
+		l.mu.Lock()
+		events := l.events
+		if i := l.index(last); i >= 0 {
+			events = events[i+1:]
+		}
+		events = append([]Event(nil), events...)
+		updated := l.updated
+		l.mu.Unlock()
+
+		for _, e := range events {
+			fn(e)
+			last = e.ID
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updated:
+		}
+	}
+}
+
+// Since returns the events after the event with the ID
+func (l *MemoryLog) Since(ctx context.Context, id string) ([]Event, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := l.index(id)
+	if i < 0 {
+		return nil, false, nil
+	}
+	return append([]Event(nil), l.events[i+1:]...), true, nil
+}
+
+// Close does nothing, the log can still be read
+func (l *MemoryLog) Close() error {
+	return nil
+}
+
+// index returns the position of the event with the ID in the log, or -1. The caller
+// holds the lock.
+func (l *MemoryLog) index(id string) int {
+	if id == "" {
+		return -1
+	}
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
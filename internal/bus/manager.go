@@ -0,0 +1,115 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getsentry/sentry-go"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// manager publishes an event for every change made through the Manager it wraps.
+type manager struct {
+	datastore.Manager
+	publisher Publisher
+	source    string
+	actor     string
+	ctx       context.Context
+}
+
+// Wrap returns a Manager that publishes an event from source for every change made
+// through db, built from the revision the change returns.
+func Wrap(db datastore.Manager, publisher Publisher, source string) datastore.Manager {
+	return manager{
+		Manager:   db,
+		publisher: publisher,
+		source:    source,
+		ctx:       context.Background(),
+	}
+}
+
+// WithActor returns a Manager that records actor in the revisions and the events
+func (m manager) WithActor(actor string) datastore.Manager {
+	m.Manager = m.Manager.WithActor(actor)
+	m.actor = actor
+	return m
+}
+
+// WithContext returns a Manager that runs its operations, and publishes the events,
+// with ctx
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	m.Manager = m.Manager.WithContext(ctx)
+	m.ctx = ctx
+	return m
+}
+
+// AddProduct stores a product and publishes ProductCreated or ProductUpdated
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	return m.publish(m.Manager.AddProduct(p))
+}
+
+// SetProductStatus moves a product to a new status and publishes ProductUpdated
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	return m.publish(m.Manager.SetProductStatus(productID, status))
+}
+
+// UpdateProductItem replaces the catalog item of a product and publishes
+// ProductUpdated
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	return m.publish(m.Manager.UpdateProductItem(item))
+}
+
+// SetProductWindow changes the availability window of a product and publishes
+// ProductUpdated
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	return m.publish(m.Manager.SetProductWindow(productID, window))
+}
+
+// DeleteProduct removes a product and publishes ProductDeleted
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	return m.publish(m.Manager.DeleteProduct(productID))
+}
+
+// RollbackProduct restores an earlier revision of a product and publishes ProductCreated,
+// when the product had been deleted, or ProductUpdated
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	return m.publish(m.Manager.RollbackProduct(productID, version))
+}
+
+// publish sends the event for the revision a change stored, unless the change failed.
+func (m manager) publish(rev datastore.Revision, err error) (datastore.Revision, error) {
+	if err != nil {
+		return rev, err
+	}
+
+	m.send(rev.Operation, rev.Previous != nil, rev.Product)
+	return rev, nil
+}
+
+// send publishes the event for a change to a product. The product was changed already,
+// so an event that can't be published doesn't fail the change. The error is logged and
+// sent to Sentry instead, and the event is lost, which the outbox delivery prevents.
+func (m manager) send(op datastore.Operation, existed bool, p datastore.Product) {
+	e, err := ProductEvent(m.source, m.actor, op, existed, p)
+	if err == nil {
+		err = m.publisher.Publish(m.ctx, e)
+	}
+	if err == nil {
+		return
+	}
+
+	logging.FromContext(m.ctx).Error("error publishing change event", "product_id", p.Item.ID, "operation", op, "error", err)
+
+	hub := sentry.GetHubFromContext(m.ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		if id := logging.RequestIDFromContext(m.ctx); id != "" {
+			scope.SetTag("request_id", id)
+		}
+		hub.CaptureException(fmt.Errorf("the product was changed, but the event couldn't be published: %s", err.Error()))
+	})
+}
@@ -0,0 +1,130 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+)
+
+// failing is a publisher that can't publish any event.
+type failing struct{}
+
+func (failing) Publish(ctx context.Context, e Event) error { return errors.New("bus is down") }
+func (failing) Close() error                               { return nil }
+
+// counting is a data store that counts the reads of products.
+type counting struct {
+	datastore.Manager
+	reads int
+}
+
+func (c *counting) GetProduct(productID string) (datastore.Product, error) {
+	c.reads++
+	return c.Manager.GetProduct(productID)
+}
+
+func (c *counting) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	c.reads++
+	return c.Manager.GetProductsByIDs(productIDs)
+}
+
+// testStore returns a data store in a snapshot file that is removed when the test ends.
+func testStore(t *testing.T) datastore.Manager {
+	dir, err := ioutil.TempDir("", "bus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := file.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWrap(t *testing.T) {
+	publisher := NewMemory()
+	store := &counting{Manager: testStore(t)}
+	db := Wrap(store, publisher, "/acme/catalog").WithActor("jane")
+
+	p := datastore.Product{Item: acmeserverless.CatalogItem{ID: "1", Name: "Bottle"}, Status: datastore.StatusDraft}
+	if _, err := db.AddProduct(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetProductStatus("1", datastore.StatusPublished); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetProductStatus("1", datastore.StatusPublished); err == nil {
+		t.Fatal("expected an error for a transition that isn't allowed")
+	}
+	if _, err := db.DeleteProduct("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RollbackProduct("1", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The events are built from the revisions the writes return
+	if store.reads != 0 {
+		t.Errorf("the products were read %d times, want 0", store.reads)
+	}
+
+	tests := []struct {
+		eventType string
+		operation datastore.Operation
+		status    datastore.Status
+	}{
+		{TypeProductCreated, datastore.OperationCreate, datastore.StatusDraft},
+		{TypeProductUpdated, datastore.OperationStatus, datastore.StatusPublished},
+		{TypeProductDeleted, datastore.OperationDelete, datastore.StatusPublished},
+		{TypeProductCreated, datastore.OperationRollback, datastore.StatusPublished},
+	}
+
+	events := publisher.Events()
+	if len(events) != len(tests) {
+		t.Fatalf("got %d events, want %d", len(events), len(tests))
+	}
+
+	for i, tt := range tests {
+		e := events[i]
+		if e.Type != tt.eventType || e.Subject != "1" || e.Source != "/acme/catalog" {
+			t.Errorf("event %d: got %+v", i, e)
+		}
+
+		var data ProductData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Operation != tt.operation || data.Actor != "jane" || data.Product.Status != tt.status {
+			t.Errorf("event %d: got data %+v", i, data)
+		}
+	}
+}
+
+// TestWrapPublishError checks that a change that was made isn't reported as failed when
+// its event can't be published.
+func TestWrapPublishError(t *testing.T) {
+	store := testStore(t)
+	db := Wrap(store, failing{}, "/acme/catalog")
+
+	p := datastore.Product{Item: acmeserverless.CatalogItem{ID: "1", Name: "Bottle"}, Status: datastore.StatusPublished}
+	if _, err := db.AddProduct(p); err != nil {
+		t.Fatalf("got %v, want the change to succeed", err)
+	}
+	if _, err := db.RollbackProduct("1", 1); err != nil {
+		t.Fatalf("got %v, want the rollback to succeed", err)
+	}
+
+	if _, err := store.GetProduct("1"); err != nil {
+		t.Errorf("the product wasn't stored: %v", err)
+	}
+}
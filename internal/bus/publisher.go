@@ -0,0 +1,181 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Publisher sends events to a message bus.
+type Publisher interface {
+	// Publish sends a single event, it returns when the bus has accepted the event
+	Publish(ctx context.Context, e Event) error
+
+	// Close releases the connection to the bus
+	Close() error
+}
+
+// Open returns the publisher described by spec, which is the value of the
+// EVENTS_PUBLISHER environment variable:
+//
+//   - "" or "none" doesn't publish the events
+//   - "stdout" writes the events as JSON lines to standard output
+//   - "memory" keeps the events in memory, for tests
+//   - "sns:<topic arn>" publishes to an Amazon SNS topic
+//   - "eventbridge:<bus name>" puts the events on an Amazon EventBridge bus
+//   - "pubsub:projects/<project>/topics/<topic>" publishes to a Google Cloud Pub/Sub topic
+//   - "nats://[user:password@]host:port/<subject>" publishes to a NATS subject, tls://
+//     instead of nats:// connects with TLS
+func Open(spec string) (Publisher, error) {
+	switch {
+	case spec == "" || spec == "none":
+		return Discard, nil
+	case spec == "stdout":
+		return NewWriter(os.Stdout), nil
+	case spec == "memory":
+		return NewMemory(), nil
+	case strings.HasPrefix(spec, "sns:"):
+		return NewSNS(strings.TrimPrefix(spec, "sns:")), nil
+	case strings.HasPrefix(spec, "eventbridge:"):
+		return NewEventBridge(strings.TrimPrefix(spec, "eventbridge:")), nil
+	case strings.HasPrefix(spec, "pubsub:"):
+		return NewPubSub(strings.TrimPrefix(spec, "pubsub:"))
+	case strings.HasPrefix(spec, "nats://") || strings.HasPrefix(spec, "tls://"):
+		return NewNATS(spec)
+	default:
+		return nil, fmt.Errorf("unknown events publisher %q, must be none, stdout, memory, sns:<topic arn>, eventbridge:<bus name>, pubsub:projects/<project>/topics/<topic> or nats://<host>:<port>/<subject>", spec)
+	}
+}
+
+// SourceFromEnv returns the source of the events, which is EVENTS_SOURCE or else
+// DefaultSource.
+func SourceFromEnv() string {
+	if s := os.Getenv("EVENTS_SOURCE"); s != "" {
+		return s
+	}
+	return DefaultSource
+}
+
+// discard is a publisher that drops the events.
+type discard struct{}
+
+// Discard is the publisher used when no bus is configured
+var Discard Publisher = discard{}
+
+// Publish drops the event
+func (discard) Publish(ctx context.Context, e Event) error {
+	return nil
+}
+
+// Close does nothing
+func (discard) Close() error {
+	return nil
+}
+
+// writer writes events as JSON, one event per line.
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter creates a publisher that writes events as JSON lines to w, to ship them with
+// a log collector or to see them while developing.
+func NewWriter(w io.Writer) Publisher {
+	return &writer{w: w}
+}
+
+// Publish writes a single event as a line of JSON
+func (p *writer) Publish(ctx context.Context, e Event) error {
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.w.Write(append(payload, '\n'))
+	return err
+}
+
+// Close does nothing, w is owned by the caller
+func (p *writer) Close() error {
+	return nil
+}
+
+// Memory is a publisher that keeps the events in memory, so tests can check which events
+// were sent.
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemory creates an empty in-memory publisher
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Publish keeps the event
+func (p *Memory) Publish(ctx context.Context, e Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, e)
+	return nil
+}
+
+// Events returns the events published so far, oldest first
+func (p *Memory) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Event(nil), p.events...)
+}
+
+// Close does nothing, the events can still be read
+func (p *Memory) Close() error {
+	return nil
+}
+
+// multi sends every event to more than one publisher.
+type multi []Publisher
+
+// Multi creates a publisher that sends every event to all publishers, like the message bus
+// and the webhooks.
+func Multi(publishers ...Publisher) Publisher {
+	return multi(publishers)
+}
+
+// Publish sends the event to every publisher, also when one of them fails, and returns
+// the errors of the publishers that failed
+func (m multi) Publish(ctx context.Context, e Event) error {
+	var errs []string
+	for _, p := range m {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close closes every publisher and returns the errors of the publishers that failed
+func (m multi) Close() error {
+	var errs []string
+	for _, p := range m {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pubSubPublisher publishes events to a Google Cloud Pub/Sub topic.
+type pubSubPublisher struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// NewPubSub creates a publisher that sends the events, in the structured JSON format, as
+// the data of the messages of the topic, which is named like projects/<project>/topics/<topic>.
+// The type, source and ID of the event are added as attributes, so subscriptions can
+// filter on them. The client uses the application default credentials, which is the
+// service account the service runs as, or, when PUBSUB_EMULATOR_HOST is set, sends the
+// messages to the emulator.
+func NewPubSub(topic string) (Publisher, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[2] != "topics" || parts[3] == "" {
+		return nil, fmt.Errorf("the Pub/Sub topic %q must be named like projects/<project>/topics/<topic>", topic)
+	}
+
+	client, err := pubsub.NewClient(context.Background(), parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("error creating Pub/Sub client: %s", err.Error())
+	}
+
+	return &pubSubPublisher{
+		client: client,
+		topic:  client.Topic(parts[3]),
+	}, nil
+}
+
+// Publish sends a single event to the topic and waits for Pub/Sub to accept it
+func (p *pubSubPublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	res := p.topic.Publish(ctx, &pubsub.Message{
+		Data: payload,
+		Attributes: map[string]string{
+			"ce-type":      e.Type,
+			"ce-source":    e.Source,
+			"ce-id":        e.ID,
+			"content-type": ContentType,
+		},
+	})
+	if _, err := res.Get(ctx); err != nil {
+		return fmt.Errorf("error publishing to Pub/Sub: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Ping checks that the topic exists. The Pub/Sub Publisher role can't read the topic, a
+// request that is denied reached Pub/Sub and counts as a success.
+func (p *pubSubPublisher) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ok, err := p.topic.Exists(ctx)
+	if status.Code(err) == codes.PermissionDenied {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error checking Pub/Sub topic: %s", err.Error())
+	}
+	if !ok {
+		return fmt.Errorf("the Pub/Sub topic %s doesn't exist", p.topic.String())
+	}
+	return nil
+}
+
+// Close sends the messages that are still batched and closes the connection to Pub/Sub
+func (p *pubSubPublisher) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,141 @@
+package bus
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/pstest"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+// emulator starts a fake Pub/Sub server with the topic, which the publishers use until the
+// test ends
+func emulator(t *testing.T, topic string) *pstest.Server {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	if _, err := srv.GServer.CreateTopic(context.Background(), &pb.Topic{Name: topic}); err != nil {
+		t.Fatal(err)
+	}
+
+	old, ok := os.LookupEnv("PUBSUB_EMULATOR_HOST")
+	os.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("PUBSUB_EMULATOR_HOST", old)
+		} else {
+			os.Unsetenv("PUBSUB_EMULATOR_HOST")
+		}
+	})
+
+	return srv
+}
+
+func TestNewPubSub(t *testing.T) {
+	emulator(t, "projects/acme/topics/catalog")
+
+	tests := []struct {
+		topic   string
+		wantErr bool
+	}{
+		{topic: "projects/acme/topics/catalog"},
+		{topic: "projects/acme/topics/", wantErr: true},
+		{topic: "projects//topics/catalog", wantErr: true},
+		{topic: "projects/acme/subscriptions/catalog", wantErr: true},
+		{topic: "catalog", wantErr: true},
+		{topic: "projects/acme/topics/catalog/extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPubSub(tt.topic)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPubSub(%q) = %v, want error %t", tt.topic, err, tt.wantErr)
+		}
+		if err == nil {
+			p.Close()
+		}
+	}
+}
+
+func TestPubSubPublish(t *testing.T) {
+	srv := emulator(t, "projects/acme/topics/catalog")
+
+	p, err := NewPubSub("projects/acme/topics/catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := testEvent(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, e); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("%d messages published, want 1", len(msgs))
+	}
+
+	want, _ := e.Marshal()
+	if string(msgs[0].Data) != string(want) {
+		t.Errorf("data = %s, want %s", msgs[0].Data, want)
+	}
+	wantAttrs := map[string]string{
+		"ce-type":      TypeProductCreated,
+		"ce-source":    DefaultSource,
+		"ce-id":        e.ID,
+		"content-type": ContentType,
+	}
+	if !reflect.DeepEqual(msgs[0].Attributes, wantAttrs) {
+		t.Errorf("attributes = %v, want %v", msgs[0].Attributes, wantAttrs)
+	}
+}
+
+func TestPubSubPublishError(t *testing.T) {
+	emulator(t, "projects/acme/topics/catalog")
+
+	p, err := NewPubSub("projects/acme/topics/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, testEvent(t)); err == nil {
+		t.Errorf("Publish() to a topic that doesn't exist = nil, want an error")
+	}
+}
+
+func TestPubSubPing(t *testing.T) {
+	emulator(t, "projects/acme/topics/catalog")
+
+	tests := []struct {
+		topic   string
+		wantErr bool
+	}{
+		{topic: "projects/acme/topics/catalog"},
+		{topic: "projects/acme/topics/missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPubSub(tt.topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.(interface{ Ping() error }).Ping(); (err != nil) != tt.wantErr {
+			t.Errorf("Ping() of %s = %v, want error %t", tt.topic, err, tt.wantErr)
+		}
+		p.Close()
+	}
+}
@@ -0,0 +1,176 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+const (
+	// DeliveryDirect publishes the event right after the change, which loses the event
+	// when the process stops in between
+	DeliveryDirect = "direct"
+
+	// DeliveryOutbox records the event in the outbox together with the change, and a
+	// relay publishes it from there
+	DeliveryOutbox = "outbox"
+
+	// relayBatchSize is the number of outbox entries read at a time
+	relayBatchSize = 25
+)
+
+// DeliveryFromEnv returns how events are delivered, which is EVENTS_DELIVERY or else
+// DeliveryDirect.
+func DeliveryFromEnv() (string, error) {
+	switch d := os.Getenv("EVENTS_DELIVERY"); d {
+	case "":
+		return DeliveryDirect, nil
+	case DeliveryDirect, DeliveryOutbox:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown EVENTS_DELIVERY %q, must be direct or outbox", d)
+	}
+}
+
+// OutboxEvent creates the event for an outbox entry. The ID of the event is the ID of the
+// entry, so an entry that is published more than once always has the same ID.
+func OutboxEvent(source string, e datastore.OutboxEntry) (Event, error) {
+	rev := e.Revision
+
+	event, err := ProductEvent(source, rev.Actor, rev.Operation, e.Existed, rev.Product)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event.ID = e.ID
+	event.Time = rev.CreatedAt.UTC()
+	return event, nil
+}
+
+// Relay publishes the entries of an outbox to one or more publishers.
+type Relay struct {
+	outbox     datastore.Outbox
+	publishers map[string]Publisher
+	names      []string
+	source     string
+
+	// mu makes sure the outbox is drained by one caller at a time, so entries aren't
+	// published twice by the same process
+	mu sync.Mutex
+}
+
+// NewRelay creates a relay that publishes the entries of outbox to the publishers, from
+// source. The names of the publishers are recorded in the entries that were published to
+// some of them, so they must stay the same when the relay is restarted.
+func NewRelay(outbox datastore.Outbox, publishers map[string]Publisher, source string) *Relay {
+	names := make([]string, 0, len(publishers))
+	for name := range publishers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &Relay{
+		outbox:     outbox,
+		publishers: publishers,
+		names:      names,
+		source:     source,
+	}
+}
+
+// Drain publishes the entries in the outbox to every publisher, oldest first, and removes
+// every entry after it has been published to all of them. A publisher that fails is
+// skipped for the rest of the drain, so it gets the entries of a product in order, while
+// the others go on. The publishers an entry was published to are recorded in the entry,
+// so it's only published again to the ones that failed. Drain returns the number of
+// removed entries, and the errors of the publishers that failed. An entry is published
+// again when it can't be marked or removed, so every entry is published at least once.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := make(map[string]error)
+	n := 0
+	var after *datastore.OutboxEntry
+	for {
+		entries, err := r.outbox.Pending(ctx, after, relayBatchSize)
+		if err != nil {
+			return n, fmt.Errorf("error reading the outbox: %s", err.Error())
+		}
+
+		for i, e := range entries {
+			after = &entries[i]
+
+			removed, err := r.publish(ctx, e, failed)
+			if err != nil {
+				return n, err
+			}
+			if removed {
+				n++
+			}
+		}
+
+		if len(entries) < relayBatchSize || len(failed) == len(r.names) {
+			break
+		}
+	}
+
+	if len(failed) > 0 {
+		errs := make([]string, 0, len(failed))
+		for _, name := range r.names {
+			if err, ok := failed[name]; ok {
+				errs = append(errs, err.Error())
+			}
+		}
+		return n, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return n, nil
+}
+
+// publish publishes the entry to the publishers that don't have it yet and haven't failed
+// in this drain, and records the publishers that fail in failed. The entry is removed when
+// every publisher has it, and marked with the publishers it was published to otherwise.
+// It returns true if the entry was removed, and the errors of the outbox.
+func (r *Relay) publish(ctx context.Context, e datastore.OutboxEntry, failed map[string]error) (bool, error) {
+	event, err := OutboxEvent(r.source, e)
+	if err != nil {
+		return false, err
+	}
+
+	done := true
+	published := make([]string, 0, len(r.names))
+	for _, name := range r.names {
+		if e.PublishedTo(name) {
+			continue
+		}
+		if _, ok := failed[name]; ok {
+			done = false
+			continue
+		}
+
+		if err := r.publishers[name].Publish(ctx, event); err != nil {
+			failed[name] = fmt.Errorf("error publishing event %s to %s: %s", e.ID, name, err.Error())
+			done = false
+			continue
+		}
+		published = append(published, name)
+	}
+
+	if done {
+		if err := r.outbox.Remove(ctx, e); err != nil {
+			return false, fmt.Errorf("event %s was published, but couldn't be removed from the outbox: %s", e.ID, err.Error())
+		}
+		return true, nil
+	}
+
+	if len(published) > 0 {
+		if err := r.outbox.MarkPublished(ctx, e, published); err != nil {
+			return false, fmt.Errorf("event %s was published to %s, but couldn't be marked in the outbox: %s", e.ID, strings.Join(published, ", "), err.Error())
+		}
+	}
+	return false, nil
+}
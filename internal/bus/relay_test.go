@@ -0,0 +1,128 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// memoryOutbox keeps the entries of an outbox in memory, oldest first.
+type memoryOutbox struct {
+	entries []datastore.OutboxEntry
+}
+
+func (o *memoryOutbox) Pending(ctx context.Context, after *datastore.OutboxEntry, limit int) ([]datastore.OutboxEntry, error) {
+	start := 0
+	if after != nil {
+		for i, e := range o.entries {
+			if e.ID == after.ID {
+				start = i + 1
+			}
+		}
+	}
+
+	end := start + limit
+	if end > len(o.entries) {
+		end = len(o.entries)
+	}
+	return append([]datastore.OutboxEntry(nil), o.entries[start:end]...), nil
+}
+
+func (o *memoryOutbox) MarkPublished(ctx context.Context, e datastore.OutboxEntry, names []string) error {
+	for i := range o.entries {
+		if o.entries[i].ID == e.ID {
+			o.entries[i].Published = append(o.entries[i].Published, names...)
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) Remove(ctx context.Context, e datastore.OutboxEntry) error {
+	for i := range o.entries {
+		if o.entries[i].ID == e.ID {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// flaky is a publisher that keeps the events in memory, and fails after it published up
+// to events while down is set.
+type flaky struct {
+	*Memory
+	down bool
+	upTo int
+}
+
+func (p *flaky) Publish(ctx context.Context, e Event) error {
+	if p.down && len(p.Events()) >= p.upTo {
+		return errors.New("bus is down")
+	}
+	return p.Memory.Publish(ctx, e)
+}
+
+// TestRelayDrain checks that a publisher that fails doesn't hold up the others, also past
+// the first batch of entries, and that the entries are only published again to the
+// publisher that failed.
+func TestRelayDrain(t *testing.T) {
+	outbox := &memoryOutbox{}
+	created := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < relayBatchSize+5; i++ {
+		rev := datastore.Revision{
+			ProductID: "1",
+			Version:   i + 1,
+			Operation: datastore.OperationUpdate,
+			Product:   datastore.Product{Item: acmeserverless.CatalogItem{ID: "1", Name: fmt.Sprintf("Bottle %d", i)}},
+			CreatedAt: created.Add(time.Duration(i) * time.Second),
+		}
+		outbox.entries = append(outbox.entries, datastore.NewOutboxEntry(rev, i > 0))
+	}
+	total := len(outbox.entries)
+
+	events := &flaky{Memory: NewMemory(), down: true, upTo: 3}
+	webhooks := NewMemory()
+	relay := NewRelay(outbox, map[string]Publisher{"events": events, "webhooks": webhooks}, "/acme/catalog")
+
+	n, err := relay.Drain(context.Background())
+	if err == nil {
+		t.Error("expected the error of the publisher that failed")
+	}
+	if n != 3 {
+		t.Errorf("removed %d entries, want the 3 both publishers got", n)
+	}
+	if got := len(webhooks.Events()); got != total {
+		t.Errorf("webhooks got %d events while the bus was down, want %d", got, total)
+	}
+	if got := len(events.Events()); got != 3 {
+		t.Errorf("the bus got %d events, want the 3 before it failed", got)
+	}
+	for _, e := range outbox.entries {
+		if !e.PublishedTo("webhooks") || e.PublishedTo("events") {
+			t.Errorf("entry %s is marked as published to %v, want webhooks", e.ID, e.Published)
+		}
+	}
+
+	// The bus gets the rest of the entries in order, and the webhooks don't get them again
+	events.down = false
+	n, err = relay.Drain(context.Background())
+	if err != nil || n != total-3 {
+		t.Fatalf("Drain() = %d, %v, want %d", n, err, total-3)
+	}
+	if got := len(webhooks.Events()); got != total {
+		t.Errorf("webhooks got %d events, want each of the %d once", got, total)
+	}
+	for i, e := range events.Events() {
+		if want := created.Add(time.Duration(i) * time.Second); e.Subject != "1" || !e.Time.Equal(want) {
+			t.Errorf("event %d of the bus is %+v, want the change at %s", i, e, want)
+		}
+	}
+	if len(outbox.entries) != 0 {
+		t.Errorf("%d entries are left in the outbox, want none", len(outbox.entries))
+	}
+}
@@ -0,0 +1,18 @@
+package catalog
+
+import (
+	"encoding/json"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+)
+
+// AuditResponse is the response to a query of the audit trail.
+type AuditResponse struct {
+	// Data are the entries matching the query, oldest first
+	Data []audit.Entry `json:"data"`
+}
+
+// Marshal returns the JSON encoding of AuditResponse
+func (r *AuditResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,104 @@
+// Package catalog contains the requests and responses of the Catalog service API that
+// aren't part of the shared types of the ACME Serverless Fitness Shop, so the Google Cloud
+// Run and the AWS Lambda flavors of the service can use the same payloads.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// MaxBatchSize is the maximum number of products that can be requested at once.
+const MaxBatchSize = 100
+
+// BatchGetRequest is the request to get several products at once.
+type BatchGetRequest struct {
+	// IDs are the unique identifiers of the requested products
+	IDs []string `json:"ids"`
+}
+
+// UnmarshalBatchGetRequest parses the JSON-encoded data and stores the result
+// in a BatchGetRequest
+func UnmarshalBatchGetRequest(data string) (BatchGetRequest, error) {
+	var r BatchGetRequest
+	err := json.Unmarshal([]byte(data), &r)
+	return r, err
+}
+
+// BatchGetResponse is the response to a request to get several products at once.
+type BatchGetResponse struct {
+	// Data are the products that were found, in the order in which they were requested
+	Data []acmeserverless.CatalogItem `json:"data"`
+
+	// Missing are the IDs of the requested products that don't exist
+	Missing []string `json:"missing"`
+}
+
+// Marshal returns the JSON encoding of BatchGetResponse
+func (r *BatchGetResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// NewBatchGetResponse creates the response for the requested IDs from the products the data
+// store returned, which may be in any order. Products that aren't visible to the public at
+// the given time are reported as missing.
+func NewBatchGetResponse(ids []string, products []datastore.Product, now time.Time) BatchGetResponse {
+	found := make(map[string]acmeserverless.CatalogItem, len(products))
+	for _, p := range products {
+		if p.Visible(now) {
+			found[p.Item.ID] = p.Item
+		}
+	}
+
+	res := BatchGetResponse{
+		Data:    make([]acmeserverless.CatalogItem, 0, len(products)),
+		Missing: make([]string, 0),
+	}
+
+	for _, id := range ids {
+		if p, ok := found[id]; ok {
+			res.Data = append(res.Data, p)
+		} else {
+			res.Missing = append(res.Missing, id)
+		}
+	}
+
+	return res
+}
+
+// ParseIDs splits a comma separated list of IDs, like the ids query parameter
+// of GET /products, into a list of IDs.
+func ParseIDs(ids string) []string {
+	return strings.Split(ids, ",")
+}
+
+// CleanIDs removes empty and duplicate IDs, keeping the order of the remaining IDs, and
+// checks that no more than MaxBatchSize IDs are requested.
+func CleanIDs(ids []string) ([]string, error) {
+	seen := make(map[string]bool, len(ids))
+	res := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		res = append(res, id)
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no product ids requested")
+	}
+
+	if len(res) > MaxBatchSize {
+		return nil, fmt.Errorf("%d product ids requested, at most %d are allowed", len(res), MaxBatchSize)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+const (
+	// FeedProduct is the event of the live feed that carries a product that is listed on
+	// the storefront after it changed, as a CatalogItem
+	FeedProduct = "product"
+
+	// FeedRemove is the event of the live feed that carries the ID of a product that
+	// isn't listed on the storefront anymore, or never was
+	FeedRemove = "remove"
+
+	// FeedReset is the event of the live feed that tells the client it may have missed
+	// changes and should read the products again
+	FeedReset = "reset"
+)
+
+// FeedRemoval is the data of a remove event of the live feed.
+type FeedRemoval struct {
+	// ID is the unique identifier of the product
+	ID string `json:"id"`
+}
+
+// FeedMessage returns the event and the data of the live feed for a change event. The
+// feed only shows what GET /products shows at the given time, so products that aren't
+// listed are sent as a removal with only their ID.
+func FeedMessage(e bus.Event, now time.Time) (string, []byte, error) {
+	event, productID, item, err := FeedChange(e, now)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if event == FeedProduct {
+		payload, err := json.Marshal(item)
+		return event, payload, err
+	}
+
+	payload, err := json.Marshal(FeedRemoval{ID: productID})
+	return event, payload, err
+}
+
+// FeedChange returns the event of the live feed for a change event, together with the ID
+// of the product and, for a product event, the product as it's listed, for feeds that
+// aren't written as JSON.
+func FeedChange(e bus.Event, now time.Time) (string, string, acmeserverless.CatalogItem, error) {
+	var data bus.ProductData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return "", "", acmeserverless.CatalogItem{}, fmt.Errorf("invalid data of event %s: %s", e.ID, err.Error())
+	}
+
+	if e.Type != bus.TypeProductDeleted && data.Product.Listed(now) {
+		return FeedProduct, data.ProductID, data.Product.Item, nil
+	}
+	return FeedRemove, data.ProductID, acmeserverless.CatalogItem{}, nil
+}
@@ -0,0 +1,57 @@
+package catalog
+
+import (
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+func TestFeedChange(t *testing.T) {
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	product := func(status datastore.Status, window datastore.Window) datastore.Product {
+		return datastore.Product{Item: acmeserverless.CatalogItem{ID: "1", Name: "Bottle"}, Status: status, Window: window}
+	}
+
+	tests := []struct {
+		name      string
+		eventType string
+		product   datastore.Product
+		want      string
+	}{
+		{name: "created", eventType: bus.TypeProductCreated, product: product(datastore.StatusPublished, datastore.Window{}), want: FeedProduct},
+		{name: "created as draft", eventType: bus.TypeProductCreated, product: product(datastore.StatusDraft, datastore.Window{}), want: FeedRemove},
+		{name: "archived", eventType: bus.TypeProductUpdated, product: product(datastore.StatusArchived, datastore.Window{}), want: FeedRemove},
+		{name: "deleted", eventType: bus.TypeProductDeleted, product: product(datastore.StatusPublished, datastore.Window{}), want: FeedRemove},
+		{name: "window not started", eventType: bus.TypeProductUpdated, product: product(datastore.StatusPublished, datastore.Window{PublishAt: &future}), want: FeedRemove},
+		{name: "window started", eventType: bus.TypeProductPublished, product: product(datastore.StatusPublished, datastore.Window{PublishAt: &past}), want: FeedProduct},
+		{name: "window ended", eventType: bus.TypeProductUnpublished, product: product(datastore.StatusPublished, datastore.Window{UnpublishAt: &past}), want: FeedRemove},
+	}
+
+	for _, tt := range tests {
+		e, err := bus.NewEvent("/acme/catalog", tt.eventType, "1", bus.ProductData{ProductID: "1", Product: tt.product})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event, productID, item, err := FeedChange(e, now)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if event != tt.want || productID != "1" {
+			t.Errorf("%s: got %s for %s, want %s", tt.name, event, productID, tt.want)
+		}
+		if event == FeedProduct && item.Name != "Bottle" {
+			t.Errorf("%s: got item %+v", tt.name, item)
+		}
+	}
+
+	if _, _, _, err := FeedChange(bus.Event{ID: "1", Data: []byte("[")}, now); err == nil {
+		t.Errorf("expected an error for invalid data")
+	}
+}
@@ -0,0 +1,105 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// UnmarshalProduct parses the JSON-encoded data of a new product, which is a regular
+// CatalogItem with an optional status. New products without a status are drafts, unlike
+// stored products without one, which are published.
+func UnmarshalProduct(data string) (datastore.Product, error) {
+	var r datastore.Product
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return r, err
+	}
+
+	var s struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return r, err
+	}
+	if s.Status == "" {
+		r.Status = datastore.StatusDraft
+	}
+	return r, nil
+}
+
+// StatusRequest is the request to move a product to a new lifecycle status.
+type StatusRequest struct {
+	// Status is the new status of the product
+	Status datastore.Status `json:"status"`
+}
+
+// UnmarshalStatusRequest parses the JSON-encoded data and stores the result
+// in a StatusRequest
+func UnmarshalStatusRequest(data string) (StatusRequest, error) {
+	var r StatusRequest
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return r, err
+	}
+
+	if r.Status == "" {
+		return r, fmt.Errorf("status is required")
+	}
+
+	_, err := datastore.ParseStatus(string(r.Status))
+	return r, err
+}
+
+// StatusResponse is the response after a product moved to a new lifecycle status.
+type StatusResponse struct {
+	// ID is the unique identifier of the product
+	ID string `json:"id"`
+
+	// Previous is the status of the product before the change
+	Previous datastore.Status `json:"previous"`
+
+	// Status is the current status of the product
+	Status datastore.Status `json:"status"`
+}
+
+// Marshal returns the JSON encoding of StatusResponse
+func (r *StatusResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// UnmarshalWindowRequest parses the JSON-encoded data of a request to change the
+// availability window of a product. Leaving out publishAt or unpublishAt opens the
+// window on that side.
+func UnmarshalWindowRequest(data string) (datastore.Window, error) {
+	var r datastore.Window
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return r, err
+	}
+
+	return r, r.Validate()
+}
+
+// ProductsResponse is the response to the admin view of the catalog, which shows products
+// in every status together with that status.
+type ProductsResponse struct {
+	Data []datastore.Product `json:"data"`
+}
+
+// Marshal returns the JSON encoding of ProductsResponse
+func (r *ProductsResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// ListedItems returns the catalog items of the products that are shown in the
+// public listings of the storefront at the given time.
+func ListedItems(products []datastore.Product, now time.Time) []acmeserverless.CatalogItem {
+	items := make([]acmeserverless.CatalogItem, 0, len(products))
+	for _, p := range products {
+		if p.Listed(now) {
+			items = append(items, p.Item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+func TestUnmarshalProduct(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    datastore.Status
+		wantErr bool
+	}{
+		{name: "without a status", data: `{"name":"Bottle","price":10}`, want: datastore.StatusDraft},
+		{name: "empty status", data: `{"name":"Bottle","status":""}`, want: datastore.StatusDraft},
+		{name: "published", data: `{"name":"Bottle","status":"published"}`, want: datastore.StatusPublished},
+		{name: "draft", data: `{"name":"Bottle","status":"draft"}`, want: datastore.StatusDraft},
+		{name: "unknown status", data: `{"name":"Bottle","status":"live"}`, wantErr: true},
+		{name: "not JSON", data: `{"name":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := UnmarshalProduct(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: UnmarshalProduct() = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.Status != tt.want || got.Item.Name != "Bottle") {
+			t.Errorf("%s: UnmarshalProduct() = %+v, want a %s product", tt.name, got, tt.want)
+		}
+	}
+
+	// Stored products without a status are published
+	var stored datastore.Product
+	if err := stored.UnmarshalJSON([]byte(`{"name":"Bottle"}`)); err != nil || stored.Status != datastore.StatusPublished {
+		t.Errorf("stored product = %+v, %v, want published", stored, err)
+	}
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// RevisionsResponse is the response to a request for the revision history of a product.
+type RevisionsResponse struct {
+	// Data are the revisions of the product, oldest first
+	Data []datastore.Revision `json:"data"`
+}
+
+// Marshal returns the JSON encoding of RevisionsResponse
+func (r *RevisionsResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// RollbackRequest is the request to restore a product as it was in an earlier revision.
+type RollbackRequest struct {
+	// Version is the revision to restore
+	Version int `json:"version"`
+}
+
+// UnmarshalRollbackRequest parses the JSON-encoded data and stores the result
+// in a RollbackRequest
+func UnmarshalRollbackRequest(data string) (RollbackRequest, error) {
+	var r RollbackRequest
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return r, err
+	}
+
+	if r.Version < 1 {
+		return r, fmt.Errorf("version is required")
+	}
+
+	return r, nil
+}
@@ -0,0 +1,72 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+)
+
+// WebhookRequest is the request to subscribe an endpoint to the changes of the catalog.
+type WebhookRequest struct {
+	// URL is the endpoint the events are delivered to
+	URL string `json:"url"`
+
+	// Events are the types of the events that are delivered, or every event if it's empty
+	Events []string `json:"events"`
+
+	// Secret is the key the deliveries are signed with, a random secret is generated when
+	// it's empty
+	Secret string `json:"secret"`
+}
+
+// UnmarshalWebhookRequest parses the JSON-encoded data and stores the result
+// in a WebhookRequest
+func UnmarshalWebhookRequest(data string) (WebhookRequest, error) {
+	var r WebhookRequest
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return r, err
+	}
+
+	if r.URL == "" {
+		return r, fmt.Errorf("url is required")
+	}
+
+	return r, nil
+}
+
+// WebhookResponse is the response after a subscription was created. It's the only
+// response that contains the secret of the subscription.
+type WebhookResponse struct {
+	webhook.Subscription
+
+	// Secret is the key the deliveries are signed with
+	Secret string `json:"secret"`
+}
+
+// Marshal returns the JSON encoding of WebhookResponse
+func (r *WebhookResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// WebhooksResponse is the response to a request for the webhook subscriptions.
+type WebhooksResponse struct {
+	// Data are the subscriptions, without their secrets
+	Data []webhook.Subscription `json:"data"`
+}
+
+// Marshal returns the JSON encoding of WebhooksResponse
+func (r *WebhooksResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// DeliveriesResponse is the response to a request for the delivery log of a subscription.
+type DeliveriesResponse struct {
+	// Data are the deliveries, newest first
+	Data []webhook.Delivery `json:"data"`
+}
+
+// Marshal returns the JSON encoding of DeliveriesResponse
+func (r *DeliveriesResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,103 @@
+// Package catalogio reads and writes snapshots of the catalog of the ACME Serverless
+// Fitness Shop. A snapshot written by an Encoder can be read back by a Decoder of the
+// same format, so exports from one environment can be used to seed another one.
+package catalogio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Format is the serialization format of a catalog snapshot.
+type Format string
+
+const (
+	// JSON writes the catalog in the same shape as the response of GET /products,
+	// a single object with all products in the data array. Every product has an
+	// additional status field.
+	JSON Format = "json"
+
+	// NDJSON writes the catalog as newline delimited JSON, one product per line.
+	NDJSON Format = "ndjson"
+
+	// CSV writes the catalog as comma separated values with a header row.
+	CSV Format = "csv"
+)
+
+// ParseFormat returns the Format matching the name, or an error if the
+// format isn't supported. An empty name defaults to JSON.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case "":
+		return JSON, nil
+	case JSON, NDJSON, CSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, must be one of json, ndjson or csv", name)
+	}
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case CSV:
+		return "text/csv"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the file extension, without a leading dot, of the format.
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Encoder writes products to a catalog snapshot.
+type Encoder interface {
+	// Encode writes a single product to the snapshot.
+	Encode(p datastore.Product) error
+
+	// Close writes any trailing data of the snapshot and flushes the
+	// underlying writer. It doesn't close the underlying writer.
+	Close() error
+}
+
+// Decoder reads products from a catalog snapshot.
+type Decoder interface {
+	// Decode returns the next product of the snapshot, or io.EOF when
+	// there are no more products.
+	Decode() (datastore.Product, error)
+}
+
+// NewEncoder returns an Encoder that writes a snapshot in format f to w.
+func NewEncoder(w io.Writer, f Format) (Encoder, error) {
+	switch f {
+	case JSON:
+		return newJSONEncoder(w), nil
+	case NDJSON:
+		return newNDJSONEncoder(w), nil
+	case CSV:
+		return newCSVEncoder(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", f)
+	}
+}
+
+// NewDecoder returns a Decoder that reads a snapshot in format f from r.
+func NewDecoder(r io.Reader, f Format) (Decoder, error) {
+	switch f {
+	case JSON:
+		return newJSONDecoder(r), nil
+	case NDJSON:
+		return newNDJSONDecoder(r), nil
+	case CSV:
+		return newCSVDecoder(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", f)
+	}
+}
@@ -0,0 +1,267 @@
+package catalogio
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// testProducts are products that use every field of a snapshot
+func testProducts() []datastore.Product {
+	publishAt := time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC)
+	unpublishAt := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	return []datastore.Product{
+		{
+			Item: acmeserverless.CatalogItem{
+				ID:               "p1",
+				Name:             "Yoga mat",
+				ShortDescription: "A mat, for yoga",
+				Description:      "A \"non-slip\" mat\nfor every workout",
+				ImageURL1:        "/static/images/mat1.png",
+				ImageURL2:        "/static/images/mat2.png",
+				ImageURL3:        "/static/images/mat3.png",
+				Price:            19.99,
+				Tags:             []string{"yoga", "mat"},
+			},
+			Status: datastore.StatusPublished,
+			Window: datastore.Window{PublishAt: &publishAt, UnpublishAt: &unpublishAt},
+		},
+		{
+			Item:   acmeserverless.CatalogItem{ID: "p2", Name: "Water bottle", Price: 10, Tags: []string{"hydration"}},
+			Status: datastore.StatusDraft,
+		},
+		{
+			Item:   acmeserverless.CatalogItem{ID: "p3", Name: "Old socks", Price: 4.5, Tags: []string{"running"}},
+			Status: datastore.StatusArchived,
+		},
+	}
+}
+
+// encode returns the snapshot of the products in the format
+func encode(t *testing.T, f Format, products []datastore.Product) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range products {
+		if err := enc.Encode(p); err != nil {
+			t.Fatalf("%s: Encode() = %v", f, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("%s: Close() = %v", f, err)
+	}
+	return buf.String()
+}
+
+// decode returns the products of the snapshot in the format
+func decode(f Format, snapshot string) ([]datastore.Product, error) {
+	dec, err := NewDecoder(strings.NewReader(snapshot), f)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []datastore.Product
+	for {
+		p, err := dec.Decode()
+		if err == io.EOF {
+			return products, nil
+		}
+		if err != nil {
+			return products, err
+		}
+		products = append(products, p)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{name: "", want: JSON},
+		{name: "json", want: JSON},
+		{name: "NDJSON", want: NDJSON},
+		{name: "Csv", want: CSV},
+		{name: "xml", wantErr: true},
+		{name: ".json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q, error %t", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		format      Format
+		contentType string
+		extension   string
+	}{
+		{JSON, "application/json", "json"},
+		{NDJSON, "application/x-ndjson", "ndjson"},
+		{CSV, "text/csv", "csv"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.ContentType(); got != tt.contentType {
+			t.Errorf("%s: ContentType() = %q, want %q", tt.format, got, tt.contentType)
+		}
+		if got := tt.format.Extension(); got != tt.extension {
+			t.Errorf("%s: Extension() = %q, want %q", tt.format, got, tt.extension)
+		}
+	}
+
+	if _, err := NewEncoder(&bytes.Buffer{}, "xml"); err == nil {
+		t.Errorf("NewEncoder() for xml = nil, want an error")
+	}
+	if _, err := NewDecoder(strings.NewReader(""), "xml"); err == nil {
+		t.Errorf("NewDecoder() for xml = nil, want an error")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range []Format{JSON, NDJSON, CSV} {
+		for _, products := range [][]datastore.Product{testProducts(), testProducts()[:1], nil} {
+			snapshot := encode(t, f, products)
+
+			got, err := decode(f, snapshot)
+			if err != nil {
+				t.Errorf("%s: decoding %q = %v", f, snapshot, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, products) {
+				t.Errorf("%s: %q decoded to %+v, want %+v", f, snapshot, got, products)
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	products := testProducts()[1:]
+
+	tests := []struct {
+		format   Format
+		products []datastore.Product
+		want     string
+	}{
+		{
+			format:   JSON,
+			products: products,
+			want: `{"data":[` +
+				`{"id":"p2","name":"Water bottle","shortDescription":"","description":"","imageUrl1":"","imageUrl2":"","imageUrl3":"","price":10,"tags":["hydration"],"status":"draft"},` +
+				`{"id":"p3","name":"Old socks","shortDescription":"","description":"","imageUrl1":"","imageUrl2":"","imageUrl3":"","price":4.5,"tags":["running"],"status":"archived"}` +
+				`]}`,
+		},
+		{format: JSON, want: `{"data":[]}`},
+		{
+			format:   NDJSON,
+			products: products,
+			want: `{"id":"p2","name":"Water bottle","shortDescription":"","description":"","imageUrl1":"","imageUrl2":"","imageUrl3":"","price":10,"tags":["hydration"],"status":"draft"}` + "\n" +
+				`{"id":"p3","name":"Old socks","shortDescription":"","description":"","imageUrl1":"","imageUrl2":"","imageUrl3":"","price":4.5,"tags":["running"],"status":"archived"}` + "\n",
+		},
+		{format: NDJSON, want: ""},
+		{
+			format:   CSV,
+			products: testProducts()[:1],
+			want: "id,name,shortDescription,description,imageUrl1,imageUrl2,imageUrl3,price,tags,status,publishAt,unpublishAt\n" +
+				`p1,Yoga mat,"A mat, for yoga","A ""non-slip"" mat` + "\n" + `for every workout",/static/images/mat1.png,/static/images/mat2.png,/static/images/mat3.png,19.99,yoga|mat,published,2020-06-01T09:00:00Z,2020-09-01T00:00:00Z` + "\n",
+		},
+		{format: CSV, want: "id,name,shortDescription,description,imageUrl1,imageUrl2,imageUrl3,price,tags,status,publishAt,unpublishAt\n"},
+	}
+
+	for _, tt := range tests {
+		if got := encode(t, tt.format, tt.products); got != tt.want {
+			t.Errorf("%s with %d products = %q, want %q", tt.format, len(tt.products), got, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	bottle := datastore.Product{
+		Item:   acmeserverless.CatalogItem{ID: "p2", Name: "Water bottle", Price: 10, Tags: []string{"hydration"}},
+		Status: datastore.StatusPublished,
+	}
+
+	tests := []struct {
+		name     string
+		format   Format
+		snapshot string
+		want     []datastore.Product
+		wantErr  bool
+	}{
+		{
+			name:     "response of GET /products",
+			format:   JSON,
+			snapshot: `{"data":[{"id":"p2","name":"Water bottle","price":10,"tags":["hydration"]}]}`,
+			want:     []datastore.Product{bottle},
+		},
+		{
+			name:     "data after other fields",
+			format:   JSON,
+			snapshot: `{"count": 1, "meta": {"data": []}, "data": [{"id":"p2","name":"Water bottle","price":10,"tags":["hydration"]}], "next": null}`,
+			want:     []datastore.Product{bottle},
+		},
+		{
+			name:     "plain array",
+			format:   JSON,
+			snapshot: `[{"id":"p2","name":"Water bottle","price":10,"tags":["hydration"],"status":"published"}]`,
+			want:     []datastore.Product{bottle},
+		},
+		{name: "no data", format: JSON, snapshot: `{"products": []}`, wantErr: true},
+		{name: "data isn't an array", format: JSON, snapshot: `{"data": {}}`, wantErr: true},
+		{name: "not an object", format: JSON, snapshot: `"data"`, wantErr: true},
+		{name: "empty file", format: JSON, snapshot: ``},
+		{name: "unknown status", format: JSON, snapshot: `[{"id":"p2","status":"sold"}]`, wantErr: true},
+		{
+			name:     "window that ends before it starts",
+			format:   JSON,
+			snapshot: `[{"id":"p2","publishAt":"2020-09-01T00:00:00Z","unpublishAt":"2020-06-01T00:00:00Z"}]`,
+			wantErr:  true,
+		},
+		{
+			name:     "blank lines",
+			format:   NDJSON,
+			snapshot: "\n" + `{"id":"p2","name":"Water bottle","price":10,"tags":["hydration"]}` + "\n\n",
+			want:     []datastore.Product{bottle},
+		},
+		{name: "line that isn't JSON", format: NDJSON, snapshot: `{"id":"p2"}` + "\nid,name\n", wantErr: true},
+		{
+			name:     "columns in another order, unknown columns and no status",
+			format:   CSV,
+			snapshot: "price, name ,color,id,tags\n10,Water bottle,blue,p2,hydration\n",
+			want:     []datastore.Product{bottle},
+		},
+		{name: "only a header", format: CSV, snapshot: "id,name\n"},
+		{name: "invalid price", format: CSV, snapshot: "id,price\np2,ten\n", wantErr: true},
+		{name: "invalid status", format: CSV, snapshot: "id,status\np2,sold\n", wantErr: true},
+		{name: "invalid time", format: CSV, snapshot: "id,publishAt\np2,tomorrow\n", wantErr: true},
+		{name: "invalid window", format: CSV, snapshot: "id,publishAt,unpublishAt\np2,2020-09-01T00:00:00Z,2020-06-01T00:00:00Z\n", wantErr: true},
+		{name: "rows of different lengths", format: CSV, snapshot: "id,name\np2\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := decode(tt.format, tt.snapshot)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: decode() = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decode() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package catalogio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// csvHeader contains the column names of a CSV snapshot. The names match the JSON
+// field names of a CatalogItem, followed by the status and the availability window of
+// the product.
+var csvHeader = []string{"id", "name", "shortDescription", "description", "imageUrl1", "imageUrl2", "imageUrl3", "price", "tags", "status", "publishAt", "unpublishAt"}
+
+// tagSeparator separates the tags of a product inside the single tags column.
+const tagSeparator = "|"
+
+// csvEncoder writes a header row followed by one row per product.
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+// Encode writes a single product as a row
+func (e *csvEncoder) Encode(prod datastore.Product) error {
+	if !e.headerWritten {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	p := prod.Item
+	return e.w.Write([]string{
+		p.ID,
+		p.Name,
+		p.ShortDescription,
+		p.Description,
+		p.ImageURL1,
+		p.ImageURL2,
+		p.ImageURL3,
+		strconv.FormatFloat(float64(p.Price), 'f', -1, 32),
+		strings.Join(p.Tags, tagSeparator),
+		string(prod.Status),
+		formatTime(prod.Window.PublishAt),
+		formatTime(prod.Window.UnpublishAt),
+	})
+}
+
+// Close writes the header row if no products were written and flushes the writer
+func (e *csvEncoder) Close() error {
+	if !e.headerWritten {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvDecoder reads products from rows, using the header row to find the columns.
+// Columns may be in any order and unknown columns are ignored.
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVDecoder(r io.Reader) *csvDecoder {
+	return &csvDecoder{r: csv.NewReader(r)}
+}
+
+// Decode returns the product in the next row
+func (d *csvDecoder) Decode() (datastore.Product, error) {
+	if d.columns == nil {
+		header, err := d.r.Read()
+		if err != nil {
+			return datastore.Product{}, err
+		}
+
+		d.columns = make(map[string]int)
+		for idx, name := range header {
+			d.columns[strings.TrimSpace(name)] = idx
+		}
+	}
+
+	row, err := d.r.Read()
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	p := acmeserverless.CatalogItem{
+		ID:               d.field(row, "id"),
+		Name:             d.field(row, "name"),
+		ShortDescription: d.field(row, "shortDescription"),
+		Description:      d.field(row, "description"),
+		ImageURL1:        d.field(row, "imageUrl1"),
+		ImageURL2:        d.field(row, "imageUrl2"),
+		ImageURL3:        d.field(row, "imageUrl3"),
+	}
+
+	if price := d.field(row, "price"); price != "" {
+		f, err := strconv.ParseFloat(price, 32)
+		if err != nil {
+			return datastore.Product{}, fmt.Errorf("invalid price %q for product %q: %s", price, p.ID, err.Error())
+		}
+		p.Price = float32(f)
+	}
+
+	if tags := d.field(row, "tags"); tags != "" {
+		p.Tags = strings.Split(tags, tagSeparator)
+	}
+
+	// Snapshots without a status column are treated as published
+	status, err := datastore.ParseStatus(d.field(row, "status"))
+	if err != nil {
+		return datastore.Product{}, fmt.Errorf("invalid status for product %q: %s", p.ID, err.Error())
+	}
+
+	publishAt, err := parseTime(d.field(row, "publishAt"))
+	if err != nil {
+		return datastore.Product{}, fmt.Errorf("invalid publishAt for product %q: %s", p.ID, err.Error())
+	}
+
+	unpublishAt, err := parseTime(d.field(row, "unpublishAt"))
+	if err != nil {
+		return datastore.Product{}, fmt.Errorf("invalid unpublishAt for product %q: %s", p.ID, err.Error())
+	}
+
+	window := datastore.Window{PublishAt: publishAt, UnpublishAt: unpublishAt}
+	if err := window.Validate(); err != nil {
+		return datastore.Product{}, fmt.Errorf("invalid window for product %q: %s", p.ID, err.Error())
+	}
+
+	return datastore.Product{Item: p, Status: status, Window: window}, nil
+}
+
+// field returns the value of the named column, or an empty string if the
+// snapshot doesn't have that column
+func (d *csvDecoder) field(row []string, name string) string {
+	idx, ok := d.columns[name]
+	if !ok || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+// formatTime formats t as an RFC3339 timestamp, or returns an empty string if t is nil
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTime parses an RFC3339 timestamp, an empty value means the time isn't set
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,131 @@
+package catalogio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// jsonEncoder writes the snapshot as {"data":[...]} one product at a time.
+type jsonEncoder struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes a single product to the data array
+func (e *jsonEncoder) Encode(p datastore.Product) error {
+	prefix := ","
+	if e.count == 0 {
+		prefix = `{"data":[`
+	}
+
+	payload, err := p.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.w.WriteString(prefix); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(payload); err != nil {
+		return err
+	}
+
+	e.count++
+	return nil
+}
+
+// Close terminates the data array and flushes the writer
+func (e *jsonEncoder) Close() error {
+	suffix := "]}"
+	if e.count == 0 {
+		suffix = `{"data":[]}`
+	}
+
+	if _, err := e.w.WriteString(suffix); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+// jsonDecoder reads products from the data array of a {"data":[...]} document.
+// A document that is a plain array of products is accepted as well.
+type jsonDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+func newJSONDecoder(r io.Reader) *jsonDecoder {
+	return &jsonDecoder{dec: json.NewDecoder(r)}
+}
+
+// Decode returns the next product of the data array
+func (d *jsonDecoder) Decode() (datastore.Product, error) {
+	if !d.started {
+		if err := d.start(); err != nil {
+			return datastore.Product{}, err
+		}
+		d.started = true
+	}
+
+	if !d.dec.More() {
+		return datastore.Product{}, io.EOF
+	}
+
+	var p datastore.Product
+	if err := d.dec.Decode(&p); err != nil {
+		return datastore.Product{}, err
+	}
+
+	return p, nil
+}
+
+// start moves the decoder to the first element of the data array
+func (d *jsonDecoder) start() error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		return nil
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("unexpected token %v at the start of the catalog", tok)
+	}
+
+	// Skip over all keys until the data array is found
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if tok == "data" {
+			tok, err := d.dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok != json.Delim('[') {
+				return fmt.Errorf("unexpected token %v, data must be an array of products", tok)
+			}
+			return nil
+		}
+
+		var skip json.RawMessage
+		if err := d.dec.Decode(&skip); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("no data array found in the catalog")
+}
@@ -0,0 +1,57 @@
+package catalogio
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// ndjsonEncoder writes every product as a JSON document on its own line.
+type ndjsonEncoder struct {
+	w *bufio.Writer
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes a single product followed by a newline
+func (e *ndjsonEncoder) Encode(p datastore.Product) error {
+	payload, err := p.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.w.Write(payload); err != nil {
+		return err
+	}
+
+	return e.w.WriteByte('\n')
+}
+
+// Close flushes the writer
+func (e *ndjsonEncoder) Close() error {
+	return e.w.Flush()
+}
+
+// ndjsonDecoder reads one product per line. Since a json.Decoder reads a stream
+// of whitespace separated values, blank lines are skipped automatically.
+type ndjsonDecoder struct {
+	dec *json.Decoder
+}
+
+func newNDJSONDecoder(r io.Reader) *ndjsonDecoder {
+	return &ndjsonDecoder{dec: json.NewDecoder(r)}
+}
+
+// Decode returns the product on the next line
+func (d *ndjsonDecoder) Decode() (datastore.Product, error) {
+	var p datastore.Product
+	if err := d.dec.Decode(&p); err != nil {
+		return datastore.Product{}, err
+	}
+
+	return p, nil
+}
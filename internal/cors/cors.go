@@ -0,0 +1,190 @@
+// Package cors applies the Cross-Origin Resource Sharing policy of the catalog API of the
+// ACME Serverless Fitness Shop, so browsers on the storefront and admin sites can call it.
+// The same policy is used for preflight requests and actual responses, on Google Cloud
+// Run and on AWS Lambda.
+package cors
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides which origins can call the API and what they can send.
+type Policy struct {
+	// AllowedOrigins are the origins that can call the API. An origin can contain a single
+	// wildcard, like https://*.example.com, and "*" allows every origin
+	AllowedOrigins []string
+
+	// AllowedMethods are the methods that can be used by other origins
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers that can be sent by other origins
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers that other origins can read
+	ExposedHeaders []string
+
+	// AllowCredentials allows other origins to send cookies and Authorization headers
+	// along with the request
+	AllowCredentials bool
+
+	// MaxAge is how long browsers can cache the result of a preflight request
+	MaxAge time.Duration
+}
+
+// DefaultPolicy allows every origin to use the methods and headers of the API, and to read
+// the request ID of the responses, without credentials.
+func DefaultPolicy() Policy {
+	return Policy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "traceparent"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         time.Hour,
+	}
+}
+
+// FromEnv creates the policy configured in the environment. CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS and CORS_EXPOSED_HEADERS are comma separated
+// lists, CORS_ALLOW_CREDENTIALS is a boolean and CORS_MAX_AGE a Go duration. Variables
+// that aren't set, or are empty, keep the value of the DefaultPolicy.
+func FromEnv() (Policy, error) {
+	p := DefaultPolicy()
+
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		p.AllowedOrigins = list(v)
+	}
+	if v := os.Getenv("CORS_ALLOWED_METHODS"); v != "" {
+		p.AllowedMethods = list(strings.ToUpper(v))
+	}
+	if v := os.Getenv("CORS_ALLOWED_HEADERS"); v != "" {
+		p.AllowedHeaders = list(v)
+	}
+	if v := os.Getenv("CORS_EXPOSED_HEADERS"); v != "" {
+		p.ExposedHeaders = list(v)
+	}
+
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("error parsing CORS_ALLOW_CREDENTIALS: %s", err.Error())
+		}
+		p.AllowCredentials = b
+	}
+
+	if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("error parsing CORS_MAX_AGE: %s", err.Error())
+		}
+		p.MaxAge = d
+	}
+
+	return p, p.Validate()
+}
+
+// Validate returns an error if browsers would reject the policy.
+func (p Policy) Validate() error {
+	for _, o := range p.AllowedOrigins {
+		if strings.Count(o, "*") > 1 {
+			return fmt.Errorf("the origin %q can only have one wildcard", o)
+		}
+		if o == "*" && p.AllowCredentials {
+			return fmt.Errorf("credentials can't be allowed for every origin, list the allowed origins instead")
+		}
+	}
+
+	if p.MaxAge < 0 {
+		return fmt.Errorf("the max age can't be negative")
+	}
+
+	return nil
+}
+
+// Headers returns the CORS headers of the response to a request from the origin. The
+// headers for a preflight request also describe the methods and headers that can be
+// used. A request from an origin that isn't allowed doesn't get any CORS headers, so
+// the browser blocks it.
+func (p Policy) Headers(origin string, preflight bool) map[string]string {
+	headers := make(map[string]string)
+
+	// Responses that depend on the origin can't be cached for other origins
+	anyOrigin := p.allowsAnyOrigin()
+	if !anyOrigin {
+		headers["Vary"] = "Origin"
+	}
+
+	if origin == "" || !p.allows(origin) {
+		return headers
+	}
+
+	if anyOrigin {
+		headers["Access-Control-Allow-Origin"] = "*"
+	} else {
+		headers["Access-Control-Allow-Origin"] = origin
+	}
+
+	if p.AllowCredentials {
+		headers["Access-Control-Allow-Credentials"] = "true"
+	}
+
+	if preflight {
+		if len(p.AllowedMethods) > 0 {
+			headers["Access-Control-Allow-Methods"] = strings.Join(p.AllowedMethods, ", ")
+		}
+		if len(p.AllowedHeaders) > 0 {
+			headers["Access-Control-Allow-Headers"] = strings.Join(p.AllowedHeaders, ", ")
+		}
+		headers["Access-Control-Max-Age"] = strconv.Itoa(int(p.MaxAge / time.Second))
+	} else if len(p.ExposedHeaders) > 0 {
+		headers["Access-Control-Expose-Headers"] = strings.Join(p.ExposedHeaders, ", ")
+	}
+
+	return headers
+}
+
+// allowsAnyOrigin returns true if every origin can call the API
+func (p Policy) allowsAnyOrigin() bool {
+	for _, o := range p.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if the origin can call the API
+func (p Policy) allows(origin string) bool {
+	for _, o := range p.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+
+		if i := strings.Index(o, "*"); i >= 0 {
+			prefix, suffix := strings.ToLower(o[:i]), strings.ToLower(o[i+1:])
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				// The wildcard stands for a part of the host or the port, never for a path
+				// or user info that would let another host match
+				if !strings.ContainsAny(lower[len(prefix):len(lower)-len(suffix)], "/@") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// list splits a comma separated list and drops the empty values
+func list(s string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,246 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/valyala/fasthttp"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "default", policy: DefaultPolicy()},
+		{name: "listed origins with credentials", policy: Policy{AllowedOrigins: []string{"https://shop.example.com", "https://*.example.com"}, AllowCredentials: true}},
+		{name: "every origin with credentials", policy: Policy{AllowedOrigins: []string{"https://shop.example.com", "*"}, AllowCredentials: true}, wantErr: true},
+		{name: "two wildcards", policy: Policy{AllowedOrigins: []string{"https://*.*.example.com"}}, wantErr: true},
+		{name: "negative max age", policy: Policy{AllowedOrigins: []string{"*"}, MaxAge: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	p := Policy{AllowedOrigins: []string{"https://shop.example.com", "https://*.admin.example.com", "http://localhost:*"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "https://shop.example.com", want: true},
+		{origin: "HTTPS://Shop.Example.com", want: true},
+		{origin: "https://eu.admin.example.com", want: true},
+		{origin: "https://a.b.admin.example.com", want: true},
+		{origin: "http://localhost:3000", want: true},
+		{origin: "https://admin.example.com"},
+		{origin: "https://.admin.example.com"},
+		{origin: "https://evil.com/.admin.example.com"},
+		{origin: "https://eu.admin.example.com.evil.com"},
+		{origin: "http://eu.admin.example.com"},
+		{origin: "https://other.example.com"},
+		{origin: "http://localhost"},
+	}
+
+	for _, tt := range tests {
+		if got := p.allows(tt.origin); got != tt.want {
+			t.Errorf("allows(%q) = %t, want %t", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	listed := Policy{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		origin    string
+		preflight bool
+		want      map[string]string
+	}{
+		{
+			name:   "every origin",
+			policy: DefaultPolicy(),
+			origin: "https://shop.example.com",
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+			},
+		},
+		{
+			name:      "every origin preflight",
+			policy:    DefaultPolicy(),
+			origin:    "https://shop.example.com",
+			preflight: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type, X-API-Key, X-Request-ID, traceparent",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name:   "every origin without origin",
+			policy: DefaultPolicy(),
+			want:   map[string]string{},
+		},
+		{
+			name:   "listed origin",
+			policy: listed,
+			origin: "https://shop.example.com",
+			want: map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "https://shop.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+			},
+		},
+		{
+			name:      "listed origin preflight",
+			policy:    listed,
+			origin:    "https://shop.example.com",
+			preflight: true,
+			want: map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "https://shop.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Authorization",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:   "origin that isn't allowed",
+			policy: listed,
+			origin: "https://example.org",
+			want:   map[string]string{"Vary": "Origin"},
+		},
+		{
+			name:   "listed origins without origin",
+			policy: listed,
+			want:   map[string]string{"Vary": "Origin"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := tt.policy.Headers(tt.origin, tt.preflight)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Headers() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: header %s = %q, want %q", tt.name, k, got[k], v)
+			}
+		}
+	}
+}
+
+// preflightTests are the requests that both handlers get, with the status and the
+// Access-Control-Allow-Origin header of the response, next answers 200.
+var preflightTests = []struct {
+	name          string
+	method        string
+	origin        string
+	requestMethod string
+	wantStatus    int
+	wantOrigin    string
+}{
+	{name: "preflight", method: http.MethodOptions, origin: "https://shop.example.com", requestMethod: "PUT", wantStatus: http.StatusNoContent, wantOrigin: "https://shop.example.com"},
+	{name: "preflight from an origin that isn't allowed", method: http.MethodOptions, origin: "https://example.org", requestMethod: "PUT", wantStatus: http.StatusNoContent},
+	{name: "OPTIONS that isn't a preflight", method: http.MethodOptions, origin: "https://shop.example.com", wantStatus: http.StatusOK, wantOrigin: "https://shop.example.com"},
+	{name: "actual request", method: http.MethodGet, origin: "https://shop.example.com", wantStatus: http.StatusOK, wantOrigin: "https://shop.example.com"},
+	{name: "actual request from an origin that isn't allowed", method: http.MethodGet, origin: "https://example.org", wantStatus: http.StatusOK},
+}
+
+// testPolicy allows the subdomains of example.com
+func testPolicy() Policy {
+	p := DefaultPolicy()
+	p.AllowedOrigins = []string{"https://*.example.com"}
+	return p
+}
+
+func TestHandler(t *testing.T) {
+	for _, tt := range preflightTests {
+		called := false
+		handler := Handler(testPolicy(), func(ctx *fasthttp.RequestCtx) {
+			called = true
+			ctx.SetStatusCode(http.StatusOK)
+		})
+
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(tt.method)
+		ctx.Request.SetRequestURI("/products")
+		ctx.Request.Header.Set("Origin", tt.origin)
+		if tt.requestMethod != "" {
+			ctx.Request.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		handler(&ctx)
+
+		if got := ctx.Response.StatusCode(); got != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.wantStatus)
+		}
+		if called != (tt.wantStatus == http.StatusOK) {
+			t.Errorf("%s: next called = %t, want %t", tt.name, called, tt.wantStatus == http.StatusOK)
+		}
+		if got := string(ctx.Response.Header.Peek("Access-Control-Allow-Origin")); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := string(ctx.Response.Header.Peek("Vary")); got != "Origin" {
+			t.Errorf("%s: Vary = %q, want Origin", tt.name, got)
+		}
+	}
+}
+
+func TestWrapLambda(t *testing.T) {
+	for _, tt := range preflightTests {
+		called := false
+		handler := WrapLambda(testPolicy(), func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			called = true
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+		})
+
+		request := events.APIGatewayProxyRequest{
+			HTTPMethod: tt.method,
+			Path:       "/products",
+			Headers:    map[string]string{"origin": tt.origin},
+		}
+		if tt.requestMethod != "" {
+			request.Headers["access-control-request-method"] = tt.requestMethod
+		}
+
+		res, err := handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("%s: handler() = %v", tt.name, err)
+		}
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.wantStatus)
+		}
+		if called != (tt.wantStatus == http.StatusOK) {
+			t.Errorf("%s: next called = %t, want %t", tt.name, called, tt.wantStatus == http.StatusOK)
+		}
+		if got := res.Headers["Access-Control-Allow-Origin"]; got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := res.Headers["Vary"]; got != "Origin" {
+			t.Errorf("%s: Vary = %q, want Origin", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package cors
+
+import (
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Handler returns a fasthttp handler that answers preflight requests and adds the CORS
+// headers to the responses of next.
+func Handler(p Policy, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		preflight := ctx.IsOptions() && len(ctx.Request.Header.Peek("Access-Control-Request-Method")) > 0
+
+		for k, v := range p.Headers(string(ctx.Request.Header.Peek("Origin")), preflight) {
+			ctx.Response.Header.Set(k, v)
+		}
+
+		if preflight {
+			ctx.SetStatusCode(http.StatusNoContent)
+			return
+		}
+
+		next(ctx)
+	}
+}
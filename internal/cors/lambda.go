@@ -0,0 +1,42 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+)
+
+// LambdaHandler handles an API Gateway event.
+type LambdaHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WrapLambda returns a Lambda handler that answers preflight requests and adds the CORS
+// headers to the responses of next.
+func WrapLambda(p Policy, next LambdaHandler) LambdaHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		preflight := request.HTTPMethod == http.MethodOptions && apigateway.Header(request, "Access-Control-Request-Method") != ""
+		headers := p.Headers(apigateway.Header(request, "Origin"), preflight)
+
+		if preflight {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNoContent,
+				Headers:    headers,
+			}, nil
+		}
+
+		res, err := next(ctx, request)
+		if err != nil {
+			return res, err
+		}
+
+		if res.Headers == nil {
+			res.Headers = make(map[string]string)
+		}
+		for k, v := range headers {
+			res.Headers[k] = v
+		}
+
+		return res, nil
+	}
+}
@@ -4,13 +4,67 @@
 // needs to be implemented.
 package datastore
 
-import acmeserverless "github.com/retgits/acme-serverless"
+import (
+	"context"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
 
 // Manager is the interface that describes the methods the
 // data store needs to implement to be able to work with
-// the ACME Serverless Fitness Shop.
+// the ACME Serverless Fitness Shop. The methods return products
+// in any status, it's up to the caller to decide which products
+// can be shown. Every write to a product is recorded as a Revision,
+// which the write returns.
 type Manager interface {
-	AddProduct(p acmeserverless.CatalogItem) error
-	GetProduct(productID string) (acmeserverless.CatalogItem, error)
-	GetProducts() ([]acmeserverless.CatalogItem, error)
+	AddProduct(p Product) (Revision, error)
+	GetProduct(productID string) (Product, error)
+	GetProducts() ([]Product, error)
+
+	// GetProductsByIDs retrieves the products with the given IDs in a single
+	// call. IDs that don't exist are left out of the result, so callers should
+	// compare the result with the IDs they asked for.
+	GetProductsByIDs(productIDs []string) ([]Product, error)
+
+	// UpdateProductItem replaces the catalog item of an existing product, keeping
+	// its status and availability window.
+	UpdateProductItem(item acmeserverless.CatalogItem) (Revision, error)
+
+	// SetProductStatus moves an existing product to a new status. A transition
+	// that isn't allowed returns a *TransitionError.
+	SetProductStatus(productID string, status Status) (Revision, error)
+
+	// SetProductWindow changes the period in which an existing product is
+	// available. Open sides of the window are removed from the data store.
+	SetProductWindow(productID string, window Window) (Revision, error)
+
+	// DeleteProduct permanently removes a product. Products that have been sold
+	// should be archived instead, so they're kept for the order history.
+	DeleteProduct(productID string) (Revision, error)
+
+	// ForEachProduct calls fn for every product in the data store, one at a
+	// time, without loading the entire catalog in memory. Iteration stops at
+	// the first error returned by fn and that error is returned.
+	ForEachProduct(fn func(p Product) error) error
+
+	// GetRevisions returns all revisions of a product, oldest first, including the
+	// revisions of a product that has been deleted.
+	GetRevisions(productID string) ([]Revision, error)
+
+	// RollbackProduct restores the product as it was in the given version. The
+	// rollback is recorded as a new revision, which is returned.
+	RollbackProduct(productID string, version int) (Revision, error)
+
+	// Ping checks that the data store can be reached and is ready to serve
+	// requests, for readiness probes.
+	Ping() error
+
+	// WithActor returns a Manager that uses the same data store, but records actor
+	// as the one who made the changes in the revisions it creates.
+	WithActor(actor string) Manager
+
+	// WithContext returns a Manager that uses the same data store, but runs its
+	// operations with ctx, so they're cancelled with ctx and traced as part of the
+	// request that holds it.
+	WithContext(ctx context.Context) Manager
 }
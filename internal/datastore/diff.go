@@ -0,0 +1,77 @@
+package datastore
+
+import (
+	"strings"
+	"time"
+)
+
+// Change is a single field that differs between two versions of a product. The field
+// names match the JSON field names of a Product.
+type Change struct {
+	// Field is the name of the field that changed
+	Field string `json:"field"`
+
+	// Before is the value before the change, it's left out if the product didn't exist
+	Before interface{} `json:"before,omitempty"`
+
+	// After is the value after the change, it's left out if the product was removed
+	After interface{} `json:"after,omitempty"`
+}
+
+// Diff returns the fields that differ between the product before and after a change. A
+// nil product means the product doesn't exist on that side of the change, in which case
+// all fields that are set on the other side are reported.
+func Diff(before *Product, after *Product) []Change {
+	var a, b Product
+	if before != nil {
+		a = *before
+	}
+	if after != nil {
+		b = *after
+	}
+
+	fields := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		same   bool
+	}{
+		{"name", a.Item.Name, b.Item.Name, a.Item.Name == b.Item.Name},
+		{"shortDescription", a.Item.ShortDescription, b.Item.ShortDescription, a.Item.ShortDescription == b.Item.ShortDescription},
+		{"description", a.Item.Description, b.Item.Description, a.Item.Description == b.Item.Description},
+		{"imageUrl1", a.Item.ImageURL1, b.Item.ImageURL1, a.Item.ImageURL1 == b.Item.ImageURL1},
+		{"imageUrl2", a.Item.ImageURL2, b.Item.ImageURL2, a.Item.ImageURL2 == b.Item.ImageURL2},
+		{"imageUrl3", a.Item.ImageURL3, b.Item.ImageURL3, a.Item.ImageURL3 == b.Item.ImageURL3},
+		{"price", a.Item.Price, b.Item.Price, a.Item.Price == b.Item.Price},
+		{"tags", a.Item.Tags, b.Item.Tags, strings.Join(a.Item.Tags, "\x00") == strings.Join(b.Item.Tags, "\x00")},
+		{"status", a.Status, b.Status, a.Status == b.Status},
+		{"publishAt", a.Window.PublishAt, b.Window.PublishAt, sameTime(a.Window.PublishAt, b.Window.PublishAt)},
+		{"unpublishAt", a.Window.UnpublishAt, b.Window.UnpublishAt, sameTime(a.Window.UnpublishAt, b.Window.UnpublishAt)},
+	}
+
+	changes := make([]Change, 0)
+	for _, f := range fields {
+		if f.same {
+			continue
+		}
+
+		c := Change{Field: f.name}
+		if before != nil {
+			c.Before = f.before
+		}
+		if after != nil {
+			c.After = f.after
+		}
+		changes = append(changes, c)
+	}
+
+	return changes
+}
+
+// sameTime returns true if both times are unset, or are set to the same moment.
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,89 @@
+package datastore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func TestDiff(t *testing.T) {
+	start := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	sameStart := start.In(time.FixedZone("CEST", 2*60*60))
+
+	bottle := Product{
+		Item:   acmeserverless.CatalogItem{ID: "1", Name: "Bottle", Price: 10, Tags: []string{"bottle"}},
+		Status: StatusPublished,
+		Window: Window{PublishAt: &start},
+	}
+
+	renamed := bottle
+	renamed.Item.Name = "Water bottle"
+	renamed.Item.Tags = []string{"bottle", "water"}
+
+	archived := bottle
+	archived.Status = StatusArchived
+	archived.Window = Window{PublishAt: &sameStart}
+
+	tests := []struct {
+		name   string
+		before *Product
+		after  *Product
+		want   []Change
+	}{
+		{
+			name:   "unchanged",
+			before: &bottle,
+			after:  &bottle,
+			want:   []Change{},
+		},
+		{
+			name:   "item fields",
+			before: &bottle,
+			after:  &renamed,
+			want: []Change{
+				{Field: "name", Before: "Bottle", After: "Water bottle"},
+				{Field: "tags", Before: []string{"bottle"}, After: []string{"bottle", "water"}},
+			},
+		},
+		{
+			name:   "status, and the same moment in another zone",
+			before: &bottle,
+			after:  &archived,
+			want: []Change{
+				{Field: "status", Before: StatusPublished, After: StatusArchived},
+			},
+		},
+		{
+			name:   "created",
+			before: nil,
+			after:  &bottle,
+			want: []Change{
+				{Field: "name", After: "Bottle"},
+				{Field: "price", After: float32(10)},
+				{Field: "tags", After: []string{"bottle"}},
+				{Field: "status", After: StatusPublished},
+				{Field: "publishAt", After: &start},
+			},
+		},
+		{
+			name:   "deleted",
+			before: &bottle,
+			after:  nil,
+			want: []Change{
+				{Field: "name", Before: "Bottle"},
+				{Field: "price", Before: float32(10)},
+				{Field: "tags", Before: []string{"bottle"}},
+				{Field: "status", Before: StatusPublished},
+				{Field: "publishAt", Before: &start},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Diff() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// auditTimeLayout formats the time in the sort key of audit entries, with a fixed number
+// of digits so the sort keys are ordered by time.
+const auditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// auditLog is an empty struct that implements the methods of the audit Log interface.
+type auditLog struct{}
+
+// NewAuditLog creates an audit log using Amazon DynamoDB as backend. Every entry is stored
+// twice in the table, once for the product (PK = AUDIT#PRODUCT#<id>) and once for the actor
+// (PK = AUDIT#ACTOR#<actor>), so the trail can be queried by both.
+func NewAuditLog() audit.Log {
+	return auditLog{}
+}
+
+// Write stores a single entry in DynamoDB
+func (l auditLog) Write(e audit.Entry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+
+	sk := fmt.Sprintf("%s#%s", e.Time.UTC().Format(auditTimeLayout), e.ID)
+
+	puts := make([]*dynamodb.TransactWriteItem, 0, 2)
+	for _, pk := range []string{"AUDIT#PRODUCT#" + e.ProductID, "AUDIT#ACTOR#" + e.Actor} {
+		// Create a map of DynamoDB Attribute Values containing the table keys and data elements
+		item := make(map[string]*dynamodb.AttributeValue)
+		item["PK"] = &dynamodb.AttributeValue{
+			S: aws.String(pk),
+		}
+		item["SK"] = &dynamodb.AttributeValue{
+			S: aws.String(sk),
+		}
+		item["ID"] = &dynamodb.AttributeValue{
+			S: aws.String(e.ID),
+		}
+		item["Time"] = &dynamodb.AttributeValue{
+			S: aws.String(e.Time.UTC().Format(time.RFC3339Nano)),
+		}
+		item["Actor"] = &dynamodb.AttributeValue{
+			S: aws.String(e.Actor),
+		}
+		item["Operation"] = &dynamodb.AttributeValue{
+			S: aws.String(string(e.Operation)),
+		}
+		item["ProductID"] = &dynamodb.AttributeValue{
+			S: aws.String(e.ProductID),
+		}
+		item["Changes"] = &dynamodb.AttributeValue{
+			S: aws.String(string(changes)),
+		}
+		if e.SourceIP != "" {
+			item["SourceIP"] = &dynamodb.AttributeValue{
+				S: aws.String(e.SourceIP),
+			}
+		}
+
+		// The condition makes sure entries are never overwritten
+		puts = append(puts, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(os.Getenv("TABLE")),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(SK)"),
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = dbs.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: puts,
+	})
+
+	return err
+}
+
+// Query retrieves the entries matching the filter from DynamoDB, using the copy of the
+// entries stored for the product when the filter selects a product
+func (l auditLog) Query(f audit.Filter) ([]audit.Entry, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	pk := "AUDIT#ACTOR#" + f.Actor
+	if f.ProductID != "" {
+		pk = "AUDIT#PRODUCT#" + f.ProductID
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = AUDIT#<type>#<value>
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(pk),
+	}
+
+	// Create the QueryInput
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: km,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entries := make([]audit.Entry, 0)
+
+	var entryErr error
+	err := dbs.QueryPagesWithContext(ctx, qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, ct := range qo.Items {
+			e, err := unmarshalEntry(ct)
+			if err != nil {
+				entryErr = err
+				return false
+			}
+			if f.Match(e) {
+				entries = append(entries, e)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, entryErr
+}
+
+// Ping checks that the table in DynamoDB that holds the audit trail can serve requests
+func (l auditLog) Ping() error {
+	return pingTable(context.Background())
+}
+
+// unmarshalEntry creates an audit entry from the attributes of a DynamoDB item
+func unmarshalEntry(item map[string]*dynamodb.AttributeValue) (audit.Entry, error) {
+	var e audit.Entry
+
+	for name, field := range map[string]*string{
+		"ID":        &e.ID,
+		"Actor":     &e.Actor,
+		"SourceIP":  &e.SourceIP,
+		"ProductID": &e.ProductID,
+	} {
+		if av, ok := item[name]; ok && av.S != nil {
+			*field = *av.S
+		}
+	}
+
+	if av, ok := item["Operation"]; ok && av.S != nil {
+		e.Operation = datastore.Operation(*av.S)
+	}
+
+	t, err := timeAttribute(item, "Time")
+	if err != nil {
+		return audit.Entry{}, err
+	}
+	if t != nil {
+		e.Time = *t
+	}
+
+	if av, ok := item["Changes"]; ok && av.S != nil {
+		if err := json.Unmarshal([]byte(*av.S), &e.Changes); err != nil {
+			return audit.Entry{}, fmt.Errorf("invalid Changes: %s", err.Error())
+		}
+	}
+
+	return e, nil
+}
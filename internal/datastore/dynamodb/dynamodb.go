@@ -3,15 +3,26 @@
 package dynamodb
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	acmeserverless "github.com/retgits/acme-serverless"
 	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+const (
+	// maxBatchGetKeys is the maximum number of keys DynamoDB accepts in a single BatchGetItem request
+	maxBatchGetKeys = 100
+
+	// maxBatchGetRetries is the number of times unprocessed keys of a BatchGetItem request are retried
+	maxBatchGetRetries = 5
 )
 
 // The pointer to DynamoDB provides the API operation methods for making requests to Amazon DynamoDB.
@@ -19,9 +30,15 @@ import (
 // container stays warm.
 var dbs *dynamodb.DynamoDB
 
-// manager is an empty struct that implements the methods of the
-// Manager interface.
-type manager struct{}
+// manager implements the methods of the Manager interface. The actor
+// is recorded in the revisions of the products it changes, and the
+// requests are made with ctx. When outbox is true, every change is
+// recorded in the outbox as well.
+type manager struct {
+	actor  string
+	ctx    context.Context
+	outbox bool
+}
 
 // init creates the connection to dynamoDB. If the environment variable
 // DYNAMO_URL is set, the connection is made to that URL instead of
@@ -36,6 +53,10 @@ func init() {
 	}
 
 	dbs = dynamodb.New(awsSession)
+
+	// Requests made as part of a traced request get a span of their own
+	dbs.Handlers.Validate.PushFrontNamed(startSpan)
+	dbs.Handlers.Complete.PushBackNamed(endSpan)
 }
 
 // New creates a new datastore manager using Amazon DynamoDB as backend
@@ -43,46 +64,34 @@ func New() datastore.Manager {
 	return manager{}
 }
 
-// AddProduct stores a new product in Amazon DynamoDB
-func (m manager) AddProduct(p acmeserverless.CatalogItem) error {
-	// Marshal the newly updated product struct
-	payload, err := p.Marshal()
-	if err != nil {
-		return err
-	}
-
-	// Create a map of DynamoDB Attribute Values containing the table keys
-	km := make(map[string]*dynamodb.AttributeValue)
-	km["PK"] = &dynamodb.AttributeValue{
-		S: aws.String("PRODUCT"),
-	}
-	km["SK"] = &dynamodb.AttributeValue{
-		S: aws.String(p.ID),
-	}
-
-	// Create a map of DynamoDB Attribute Values containing the table data elements
-	em := make(map[string]*dynamodb.AttributeValue)
-	em[":payload"] = &dynamodb.AttributeValue{
-		S: aws.String(string(payload)),
-	}
+// WithActor returns a manager that records actor in the revisions it creates
+func (m manager) WithActor(actor string) datastore.Manager {
+	m.actor = actor
+	return m
+}
 
-	uii := &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(os.Getenv("TABLE")),
-		Key:                       km,
-		ExpressionAttributeValues: em,
-		UpdateExpression:          aws.String("SET Payload = :payload"),
-	}
+// WithContext returns a manager that makes its requests with ctx
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	m.ctx = ctx
+	return m
+}
 
-	_, err = dbs.UpdateItem(uii)
-	if err != nil {
-		return err
+// parent returns the context the requests of the manager are made with
+func (m manager) parent() context.Context {
+	if m.ctx == nil {
+		return context.Background()
 	}
+	return m.ctx
+}
 
-	return nil
+// AddProduct stores a new product in Amazon DynamoDB, or replaces the product
+// if one with the same ID already exists
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	return m.write(p.Item.ID, datastore.Put(p))
 }
 
 // GetProduct retrieves a single product from DynamoDB based on the productID
-func (m manager) GetProduct(productID string) (acmeserverless.CatalogItem, error) {
+func (m manager) GetProduct(productID string) (datastore.Product, error) {
 	// Create a map of DynamoDB Attribute Values containing the table keys
 	// for the access pattern PK = PRODUCT SK = ID
 	km := make(map[string]*dynamodb.AttributeValue)
@@ -101,23 +110,87 @@ func (m manager) GetProduct(productID string) (acmeserverless.CatalogItem, error
 	}
 
 	// Execute the DynamoDB query
-	qo, err := dbs.Query(qi)
+	qo, err := dbs.QueryWithContext(m.parent(), qi)
 	if err != nil {
-		return acmeserverless.CatalogItem{}, err
+		return datastore.Product{}, err
 	}
 
 	// Return an error if no product was found
 	if len(qo.Items) == 0 {
-		return acmeserverless.CatalogItem{}, fmt.Errorf("Unable to find product with id %s", productID)
+		return datastore.Product{}, fmt.Errorf("Unable to find product with id %s", productID)
 	}
 
 	// Create a product struct from the data
-	str := *qo.Items[0]["Payload"].S
-	return acmeserverless.UnmarshalCatalogItem(str)
+	return unmarshalProduct(qo.Items[0])
+}
+
+// GetProductsByIDs retrieves the products with the given IDs from DynamoDB using BatchGetItem
+func (m manager) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	prods := make([]datastore.Product, 0, len(productIDs))
+
+	// BatchGetItem accepts at most 100 keys per request, so the
+	// IDs are split up in chunks of that size
+	for start := 0; start < len(productIDs); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(productIDs) {
+			end = len(productIDs)
+		}
+
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		for _, id := range productIDs[start:end] {
+			// Create a map of DynamoDB Attribute Values containing the table keys
+			km := make(map[string]*dynamodb.AttributeValue)
+			km["PK"] = &dynamodb.AttributeValue{
+				S: aws.String("PRODUCT"),
+			}
+			km["SK"] = &dynamodb.AttributeValue{
+				S: aws.String(id),
+			}
+			keys = append(keys, km)
+		}
+
+		table := os.Getenv("TABLE")
+		requestItems := map[string]*dynamodb.KeysAndAttributes{
+			table: {
+				Keys: keys,
+			},
+		}
+
+		// DynamoDB can return part of the keys as unprocessed when the request is
+		// throttled, those keys are retried with a backoff until none are left
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > maxBatchGetRetries {
+				return nil, fmt.Errorf("unable to get all products after %d retries", maxBatchGetRetries)
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(1<<uint(attempt-1)) * 50 * time.Millisecond)
+			}
+
+			bgo, err := dbs.BatchGetItemWithContext(m.parent(), &dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, ct := range bgo.Responses[table] {
+				prod, err := unmarshalProduct(ct)
+				if err != nil {
+					m.skip(ct, err)
+					continue
+				}
+				prods = append(prods, prod)
+			}
+
+			requestItems = bgo.UnprocessedKeys
+		}
+	}
+
+	return prods, nil
 }
 
 // GetProducts retrieves all products from DynamoDB
-func (m manager) GetProducts() ([]acmeserverless.CatalogItem, error) {
+func (m manager) GetProducts() ([]datastore.Product, error) {
 	// Create a map of DynamoDB Attribute Values containing the table keys
 	// for the access pattern PK = PRODUCT
 	km := make(map[string]*dynamodb.AttributeValue)
@@ -132,22 +205,226 @@ func (m manager) GetProducts() ([]acmeserverless.CatalogItem, error) {
 		ExpressionAttributeValues: km,
 	}
 
-	qo, err := dbs.Query(qi)
+	qo, err := dbs.QueryWithContext(m.parent(), qi)
 	if err != nil {
 		return nil, err
 	}
 
-	prods := make([]acmeserverless.CatalogItem, len(qo.Items))
+	prods := make([]datastore.Product, 0, len(qo.Items))
 
-	for idx, ct := range qo.Items {
-		str := *ct["Payload"].S
-		prod, err := acmeserverless.UnmarshalCatalogItem(str)
+	for _, ct := range qo.Items {
+		prod, err := unmarshalProduct(ct)
 		if err != nil {
-			log.Println(fmt.Sprintf("error unmarshalling product data: %s", err.Error()))
+			m.skip(ct, err)
 			continue
 		}
-		prods[idx] = prod
+		prods = append(prods, prod)
 	}
 
 	return prods, nil
 }
+
+// ForEachProduct pages through all products in DynamoDB and calls fn for each of them
+func (m manager) ForEachProduct(fn func(p datastore.Product) error) error {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = PRODUCT
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("PRODUCT"),
+	}
+
+	// Create the QueryInput
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type"),
+		ExpressionAttributeValues: km,
+	}
+
+	// The error returned by fn is kept separately so it can be returned
+	// to the caller as-is, rather than wrapped in a DynamoDB error
+	var fnErr error
+
+	err := dbs.QueryPagesWithContext(m.parent(), qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, ct := range qo.Items {
+			prod, err := unmarshalProduct(ct)
+			if err != nil {
+				m.skip(ct, err)
+				continue
+			}
+			if fnErr = fn(prod); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// SetProductStatus changes the status of a single product in DynamoDB based on the productID
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetStatus(productID, status))
+}
+
+// UpdateProductItem replaces the catalog item of a single product in DynamoDB based on its ID
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	return m.write(item.ID, datastore.SetItem(item))
+}
+
+// SetProductWindow changes the availability window of a single product in DynamoDB based on the productID
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetWindow(productID, window))
+}
+
+// DeleteProduct removes a single product from DynamoDB based on the productID
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	return m.write(productID, datastore.Delete(productID))
+}
+
+// skip logs a product that can't be unmarshalled and is left out of the result, with its
+// key so the item can be found and fixed
+func (m manager) skip(item map[string]*dynamodb.AttributeValue, err error) {
+	var pk, sk string
+	if av, ok := item["PK"]; ok && av.S != nil {
+		pk = *av.S
+	}
+	if av, ok := item["SK"]; ok && av.S != nil {
+		sk = *av.S
+	}
+
+	logging.FromContext(m.parent()).Warn("skipping product that can't be unmarshalled", "table", os.Getenv("TABLE"), "pk", pk, "sk", sk, "error", err)
+}
+
+// unmarshalProduct creates a product from the attributes of a DynamoDB item. The catalog
+// item is stored as a JSON document in the Payload attribute, or as a map with the same
+// fields in the Item attribute, so the fields can be read and updated on their own. Items
+// stored before products had a status don't have the Status attribute, those products
+// are published.
+func unmarshalProduct(item map[string]*dynamodb.AttributeValue) (datastore.Product, error) {
+	var payload string
+	switch {
+	case item["Payload"] != nil && item["Payload"].S != nil:
+		payload = *item["Payload"].S
+	case item["Item"] != nil && item["Item"].M != nil:
+		doc, err := json.Marshal(attributeJSON(item["Item"]))
+		if err != nil {
+			return datastore.Product{}, err
+		}
+		payload = string(doc)
+	default:
+		return datastore.Product{}, fmt.Errorf("item has no Payload")
+	}
+
+	prod, err := acmeserverless.UnmarshalCatalogItem(payload)
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	var status string
+	if av, ok := item["Status"]; ok && av.S != nil {
+		status = *av.S
+	}
+
+	st, err := datastore.ParseStatus(status)
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	publishAt, err := timeAttribute(item, "PublishAt")
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	unpublishAt, err := timeAttribute(item, "UnpublishAt")
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	return datastore.Product{
+		Item:   prod,
+		Status: st,
+		Window: datastore.Window{
+			PublishAt:   publishAt,
+			UnpublishAt: unpublishAt,
+		},
+	}, nil
+}
+
+// timeAttribute parses the RFC3339 timestamp stored in the named attribute, or
+// returns nil if the item doesn't have the attribute.
+func timeAttribute(item map[string]*dynamodb.AttributeValue, name string) (*time.Time, error) {
+	av, ok := item[name]
+	if !ok || av.S == nil {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, *av.S)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+
+	return &t, nil
+}
+
+// Ping checks that the table in DynamoDB can serve requests
+func (m manager) Ping() error {
+	return pingTable(m.parent())
+}
+
+// pingTable describes the table in DynamoDB and checks that it can serve requests
+func pingTable(ctx context.Context) error {
+	res, err := dbs.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(os.Getenv("TABLE")),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch status := aws.StringValue(res.Table.TableStatus); status {
+	case dynamodb.TableStatusActive, dynamodb.TableStatusUpdating:
+		return nil
+	default:
+		return fmt.Errorf("table %s is %s", aws.StringValue(res.Table.TableName), status)
+	}
+}
+
+// attributeJSON converts a DynamoDB attribute to the value it has in JSON
+func attributeJSON(av *dynamodb.AttributeValue) interface{} {
+	switch {
+	case av == nil:
+		return nil
+	case av.S != nil:
+		return *av.S
+	case av.N != nil:
+		return json.Number(*av.N)
+	case av.BOOL != nil:
+		return *av.BOOL
+	case av.M != nil:
+		m := make(map[string]interface{}, len(av.M))
+		for k, v := range av.M {
+			m[k] = attributeJSON(v)
+		}
+		return m
+	case av.L != nil:
+		l := make([]interface{}, 0, len(av.L))
+		for _, v := range av.L {
+			l = append(l, attributeJSON(v))
+		}
+		return l
+	case av.SS != nil:
+		return aws.StringValueSlice(av.SS)
+	case av.NS != nil:
+		l := make([]json.Number, 0, len(av.NS))
+		for _, n := range av.NS {
+			l = append(l, json.Number(aws.StringValue(n)))
+		}
+		return l
+	case av.B != nil:
+		return av.B
+	default:
+		return nil
+	}
+}
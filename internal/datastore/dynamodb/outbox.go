@@ -0,0 +1,156 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// outbox is an empty struct that implements the methods of the Outbox interface.
+type outbox struct{}
+
+// NewWithOutbox creates a new datastore manager using Amazon DynamoDB as backend, which
+// records every change in the outbox (PK = OUTBOX) in the same transaction as the change
+func NewWithOutbox() datastore.Manager {
+	return manager{outbox: true}
+}
+
+// NewOutbox creates an outbox using Amazon DynamoDB as backend. The entries are ordered by
+// their sort key, which starts with the time of the change.
+func NewOutbox() datastore.Outbox {
+	return outbox{}
+}
+
+// Pending retrieves the oldest entries from DynamoDB
+func (o outbox) Pending(ctx context.Context, after *datastore.OutboxEntry, limit int) ([]datastore.OutboxEntry, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = OUTBOX
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("OUTBOX"),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type"),
+		ExpressionAttributeValues: km,
+		ConsistentRead:            aws.Bool(true),
+		Limit:                     aws.Int64(int64(limit)),
+	}
+	if after != nil {
+		qi.ExclusiveStartKey = outboxKey(*after)
+	}
+
+	qo, err := dbs.QueryWithContext(ctx, qi)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]datastore.OutboxEntry, 0, len(qo.Items))
+	for _, item := range qo.Items {
+		e, err := unmarshalOutboxEntry(item)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// MarkPublished adds the names to the publishers of a single entry in DynamoDB, unless
+// the entry was removed in the meantime
+func (o outbox) MarkPublished(ctx context.Context, e datastore.OutboxEntry, names []string) error {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":names"] = &dynamodb.AttributeValue{
+		SS: aws.StringSlice(names),
+	}
+
+	_, err := dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       outboxKey(e),
+		UpdateExpression:          aws.String("ADD Published :names"),
+		ConditionExpression:       aws.String("attribute_exists(SK)"),
+		ExpressionAttributeValues: km,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+// Remove deletes a single entry from DynamoDB
+func (o outbox) Remove(ctx context.Context, e datastore.OutboxEntry) error {
+	_, err := dbs.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(os.Getenv("TABLE")),
+		Key:       outboxKey(e),
+	})
+	return err
+}
+
+// outboxKey creates a map of DynamoDB Attribute Values containing the table keys of an
+// outbox entry
+func outboxKey(e datastore.OutboxEntry) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("OUTBOX"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(fmt.Sprintf("%s#%s", e.Revision.CreatedAt.UTC().Format(auditTimeLayout), e.ID)),
+	}
+	return km
+}
+
+// outboxItem creates the DynamoDB item of an outbox entry, which contains the same
+// attributes as the revision it describes
+func outboxItem(e datastore.OutboxEntry) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := revisionItem(e.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range outboxKey(e) {
+		item[k] = v
+	}
+	item["ID"] = &dynamodb.AttributeValue{
+		S: aws.String(e.ID),
+	}
+	item["Existed"] = &dynamodb.AttributeValue{
+		BOOL: aws.Bool(e.Existed),
+	}
+
+	return item, nil
+}
+
+// unmarshalOutboxEntry creates an outbox entry from the attributes of a DynamoDB item
+func unmarshalOutboxEntry(item map[string]*dynamodb.AttributeValue) (datastore.OutboxEntry, error) {
+	rev, err := unmarshalRevision(item)
+	if err != nil {
+		return datastore.OutboxEntry{}, err
+	}
+
+	e := datastore.OutboxEntry{
+		Revision: rev,
+	}
+
+	if av, ok := item["ID"]; ok && av.S != nil {
+		e.ID = *av.S
+	}
+	if av, ok := item["Existed"]; ok && av.BOOL != nil {
+		e.Existed = *av.BOOL
+	}
+	if av, ok := item["Published"]; ok {
+		e.Published = aws.StringValueSlice(av.SS)
+	}
+
+	if e.ID == "" {
+		return datastore.OutboxEntry{}, fmt.Errorf("outbox entry has no ID")
+	}
+
+	return e, nil
+}
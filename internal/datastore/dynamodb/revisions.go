@@ -0,0 +1,431 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+const (
+	// maxWriteRetries is the number of times a write is retried when another write
+	// changed the same product at the same time
+	maxWriteRetries = 5
+)
+
+// errConflict is returned when a write was cancelled because the product changed since
+// it was read.
+var errConflict = fmt.Errorf("the product was changed by another request")
+
+// GetRevisions retrieves all revisions of a product from DynamoDB, oldest first
+func (m manager) GetRevisions(productID string) ([]datastore.Revision, error) {
+	revs, err := revisions(m.parent(), productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("Unable to find product with id %s", productID)
+	}
+
+	return revs, nil
+}
+
+// RollbackProduct restores the product in DynamoDB as it was in the given version
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	gio, err := dbs.GetItemWithContext(m.parent(), &dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("TABLE")),
+		Key:            revisionKey(productID, version),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	if len(gio.Item) == 0 {
+		return datastore.Revision{}, fmt.Errorf("Unable to find revision %d of product with id %s", version, productID)
+	}
+
+	rev, err := unmarshalRevision(gio.Item)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	return m.write(productID, datastore.Rollback(rev))
+}
+
+// write applies the mutation to the current product and stores the result, together
+// with its revision, in a single transaction. Writes that conflict with another write
+// to the same product are retried with a backoff.
+func (m manager) write(productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * 50 * time.Millisecond)
+		}
+
+		rev, err := m.tryWrite(productID, mutate)
+		if err == errConflict && attempt < maxWriteRetries {
+			continue
+		}
+
+		return rev, err
+	}
+}
+
+// tryWrite reads the current product, applies the mutation and writes the result. The
+// transaction is cancelled if the version of the product changed since it was read.
+func (m manager) tryWrite(productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	gio, err := dbs.GetItemWithContext(m.parent(), &dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("TABLE")),
+		Key:            productKey(productID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	var current *datastore.Product
+	var version int
+
+	// Create a map of DynamoDB Attribute Values containing the values of the condition
+	em := make(map[string]*dynamodb.AttributeValue)
+	condition := "attribute_not_exists(SK)"
+
+	if len(gio.Item) > 0 {
+		prod, err := unmarshalProduct(gio.Item)
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+		current = &prod
+
+		// Products stored before revisions existed don't have a version
+		condition = "attribute_not_exists(Version)"
+		if av, ok := gio.Item["Version"]; ok && av.N != nil {
+			if version, err = strconv.Atoi(*av.N); err != nil {
+				return datastore.Revision{}, fmt.Errorf("invalid Version: %s", err.Error())
+			}
+			condition = "Version = :version"
+			em[":version"] = av
+		}
+	} else {
+		// A product that was deleted continues with the version after its last revision
+		revs, err := revisions(m.parent(), productID)
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+		if len(revs) > 0 {
+			version = revs[len(revs)-1].Version
+		}
+	}
+
+	rev, err := mutate(current)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	rev.ProductID = productID
+	rev.Version = version + 1
+	rev.Actor = m.actor
+	rev.CreatedAt = time.Now().UTC()
+	rev.Previous = current
+
+	ri, err := revisionItem(rev)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	var pw *dynamodb.TransactWriteItem
+	if rev.Operation == datastore.OperationDelete {
+		pw = &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName:           aws.String(os.Getenv("TABLE")),
+				Key:                 productKey(productID),
+				ConditionExpression: aws.String(condition),
+			},
+		}
+		if len(em) > 0 {
+			pw.Delete.ExpressionAttributeValues = em
+		}
+	} else {
+		pi, err := productItem(rev.Product, rev.Version)
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+
+		pw = &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(os.Getenv("TABLE")),
+				Item:                pi,
+				ConditionExpression: aws.String(condition),
+			},
+		}
+		if len(em) > 0 {
+			pw.Put.ExpressionAttributeValues = em
+		}
+	}
+
+	// The revision is only written if no other write created the same version
+	rw := &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:           aws.String(os.Getenv("TABLE")),
+			Item:                ri,
+			ConditionExpression: aws.String("attribute_not_exists(SK)"),
+		},
+	}
+
+	items := []*dynamodb.TransactWriteItem{pw, rw}
+
+	// The change is published from the outbox, which is written in the same transaction
+	if m.outbox {
+		oi, err := outboxItem(datastore.NewOutboxEntry(rev, current != nil))
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+
+		items = append(items, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Item:      oi,
+			},
+		})
+	}
+
+	_, err = dbs.TransactWriteItemsWithContext(m.parent(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if conflict(err) {
+		return datastore.Revision{}, errConflict
+	}
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	return rev, nil
+}
+
+// conflict returns true if err reports that the transaction was cancelled because another
+// write changed the same product, which is worth retrying. Transactions that were cancelled
+// for other reasons, like validation errors or throttling, are reported as they are.
+func conflict(err error) bool {
+	tce, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok {
+		return false
+	}
+
+	found := false
+	for _, r := range tce.CancellationReasons {
+		switch aws.StringValue(r.Code) {
+		case "None", "":
+			// The item didn't cause the cancellation
+		case "ConditionalCheckFailed", "TransactionConflict":
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// revisions retrieves all revisions of a product from DynamoDB, sorted by version
+func revisions(ctx context.Context, productID string) ([]datastore.Revision, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = REVISION SK begins_with ID#v
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("REVISION"),
+	}
+	km[":prefix"] = &dynamodb.AttributeValue{
+		S: aws.String(productID + "#v"),
+	}
+
+	// Create the QueryInput
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: km,
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	revs := make([]datastore.Revision, 0)
+
+	var revErr error
+	err := dbs.QueryPagesWithContext(ctx, qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, ct := range qo.Items {
+			rev, err := unmarshalRevision(ct)
+			if err != nil {
+				revErr = err
+				return false
+			}
+			revs = append(revs, rev)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revErr != nil {
+		return nil, revErr
+	}
+
+	// The sort key orders v10 before v2, so the revisions are sorted by their version
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].Version < revs[j].Version
+	})
+
+	return revs, nil
+}
+
+// productKey creates a map of DynamoDB Attribute Values containing the table keys of a product
+func productKey(productID string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("PRODUCT"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(productID),
+	}
+	return km
+}
+
+// revisionKey creates a map of DynamoDB Attribute Values containing the table keys of a revision
+func revisionKey(productID string, version int) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("REVISION"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(fmt.Sprintf("%s#v%d", productID, version)),
+	}
+	return km
+}
+
+// productItem creates the DynamoDB item of a product at the given version
+func productItem(p datastore.Product, version int) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := productAttributes(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range productKey(p.Item.ID) {
+		item[k] = v
+	}
+	item["Version"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.Itoa(version)),
+	}
+
+	return item, nil
+}
+
+// revisionItem creates the DynamoDB item of a revision, which contains the same product
+// attributes as the product item itself
+func revisionItem(rev datastore.Revision) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := productAttributes(rev.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range revisionKey(rev.ProductID, rev.Version) {
+		item[k] = v
+	}
+	item["ProductID"] = &dynamodb.AttributeValue{
+		S: aws.String(rev.ProductID),
+	}
+	item["Version"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.Itoa(rev.Version)),
+	}
+	item["Operation"] = &dynamodb.AttributeValue{
+		S: aws.String(string(rev.Operation)),
+	}
+	item["CreatedAt"] = &dynamodb.AttributeValue{
+		S: aws.String(rev.CreatedAt.Format(time.RFC3339Nano)),
+	}
+	if rev.Actor != "" {
+		item["Actor"] = &dynamodb.AttributeValue{
+			S: aws.String(rev.Actor),
+		}
+	}
+	if rev.RestoredFrom > 0 {
+		item["RestoredFrom"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.Itoa(rev.RestoredFrom)),
+		}
+	}
+
+	return item, nil
+}
+
+// productAttributes creates the attributes that describe a product, without the table keys.
+// Open sides of the window are left out.
+func productAttributes(p datastore.Product) (map[string]*dynamodb.AttributeValue, error) {
+	payload, err := p.Item.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue)
+	item["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+	item["Status"] = &dynamodb.AttributeValue{
+		S: aws.String(string(p.Status)),
+	}
+	if p.Window.PublishAt != nil {
+		item["PublishAt"] = &dynamodb.AttributeValue{
+			S: aws.String(p.Window.PublishAt.UTC().Format(time.RFC3339Nano)),
+		}
+	}
+	if p.Window.UnpublishAt != nil {
+		item["UnpublishAt"] = &dynamodb.AttributeValue{
+			S: aws.String(p.Window.UnpublishAt.UTC().Format(time.RFC3339Nano)),
+		}
+	}
+
+	return item, nil
+}
+
+// unmarshalRevision creates a revision from the attributes of a DynamoDB item
+func unmarshalRevision(item map[string]*dynamodb.AttributeValue) (datastore.Revision, error) {
+	prod, err := unmarshalProduct(item)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	rev := datastore.Revision{
+		Product: prod,
+	}
+
+	if av, ok := item["ProductID"]; ok && av.S != nil {
+		rev.ProductID = *av.S
+	}
+	if av, ok := item["Operation"]; ok && av.S != nil {
+		rev.Operation = datastore.Operation(*av.S)
+	}
+	if av, ok := item["Actor"]; ok && av.S != nil {
+		rev.Actor = *av.S
+	}
+	if av, ok := item["Version"]; ok && av.N != nil {
+		if rev.Version, err = strconv.Atoi(*av.N); err != nil {
+			return datastore.Revision{}, fmt.Errorf("invalid Version: %s", err.Error())
+		}
+	}
+	if av, ok := item["RestoredFrom"]; ok && av.N != nil {
+		if rev.RestoredFrom, err = strconv.Atoi(*av.N); err != nil {
+			return datastore.Revision{}, fmt.Errorf("invalid RestoredFrom: %s", err.Error())
+		}
+	}
+
+	createdAt, err := timeAttribute(item, "CreatedAt")
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+	if createdAt != nil {
+		rev.CreatedAt = *createdAt
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,41 @@
+package dynamodb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestConflict(t *testing.T) {
+	cancelled := func(codes ...string) error {
+		tce := &dynamodb.TransactionCanceledException{}
+		for _, c := range codes {
+			tce.CancellationReasons = append(tce.CancellationReasons, &dynamodb.CancellationReason{Code: aws.String(c)})
+		}
+		return tce
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "other error", err: fmt.Errorf("connection reset"), want: false},
+		{name: "condition on the product", err: cancelled("ConditionalCheckFailed", "None"), want: true},
+		{name: "condition on the revision", err: cancelled("None", "ConditionalCheckFailed"), want: true},
+		{name: "concurrent transaction", err: cancelled("TransactionConflict", "None", "None"), want: true},
+		{name: "validation", err: cancelled("ValidationError", "None"), want: false},
+		{name: "throttled", err: cancelled("None", "ThrottlingError"), want: false},
+		{name: "condition and validation", err: cancelled("ConditionalCheckFailed", "ValidationError"), want: false},
+		{name: "no reasons", err: cancelled(), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := conflict(tt.err); got != tt.want {
+			t.Errorf("%s: conflict() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
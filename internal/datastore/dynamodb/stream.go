@@ -0,0 +1,153 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+)
+
+// DecodeRecord creates the change of a DynamoDB stream record. It returns false if the
+// record doesn't change a product, because revisions and the entries of the audit trail
+// and the outbox are stored in the same table. The ID of the change is the ID of the
+// record, and the stream must include both the new and the old image.
+func DecodeRecord(record events.DynamoDBEventRecord) (stream.Change, bool, error) {
+	image := record.Change.NewImage
+	if record.EventName == "REMOVE" {
+		image = record.Change.OldImage
+	}
+	if !isProductImage(image) {
+		return stream.Change{}, false, nil
+	}
+
+	c := stream.Change{
+		ID:   record.EventID,
+		Time: record.Change.ApproximateCreationDateTime.Time,
+	}
+
+	if len(record.Change.OldImage) > 0 {
+		p, version, err := productFromImage(record.Change.OldImage)
+		if err != nil {
+			return stream.Change{}, true, fmt.Errorf("error decoding old image of record %s: %s", record.EventID, err.Error())
+		}
+		c.Old = &p
+
+		// A product without a version was written before revisions existed, and so is
+		// the version of its removal
+		if version > 0 {
+			c.Version = version + 1
+		}
+	}
+
+	if record.EventName != "REMOVE" && len(record.Change.NewImage) > 0 {
+		p, version, err := productFromImage(record.Change.NewImage)
+		if err != nil {
+			return stream.Change{}, true, fmt.Errorf("error decoding new image of record %s: %s", record.EventID, err.Error())
+		}
+		c.New = &p
+		c.Version = version
+	}
+
+	switch record.EventName {
+	case "INSERT":
+		c.Operation = datastore.OperationCreate
+	case "MODIFY":
+		c.Operation = datastore.OperationUpdate
+	case "REMOVE":
+		c.Operation = datastore.OperationDelete
+	default:
+		return stream.Change{}, true, fmt.Errorf("unknown event %q in record %s", record.EventName, record.EventID)
+	}
+
+	if c.New == nil && c.Old == nil {
+		return stream.Change{}, true, fmt.Errorf("record %s has no image, the stream must include new and old images", record.EventID)
+	}
+	c.ProductID = c.Product().Item.ID
+
+	return c, true, nil
+}
+
+// isProductImage returns true if the image of a DynamoDB stream record is a product, and
+// not a revision or an entry of the audit trail or the outbox, which are stored in the
+// same table.
+func isProductImage(image map[string]events.DynamoDBAttributeValue) bool {
+	pk, ok := image["PK"]
+	return ok && pk.DataType() == events.DataTypeString && pk.String() == "PRODUCT"
+}
+
+// productFromImage creates a product from the image of a DynamoDB stream record, in any
+// of the formats the product items are stored in. It returns the version of the product
+// too, which is 0 for products stored before revisions existed.
+func productFromImage(image map[string]events.DynamoDBAttributeValue) (datastore.Product, int, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(image))
+	for k, v := range image {
+		item[k] = streamAttribute(v)
+	}
+
+	prod, err := unmarshalProduct(item)
+	if err != nil {
+		return datastore.Product{}, 0, err
+	}
+
+	var version int
+	if av, ok := item["Version"]; ok && av.N != nil {
+		if version, err = strconv.Atoi(*av.N); err != nil {
+			return datastore.Product{}, 0, fmt.Errorf("invalid Version: %s", err.Error())
+		}
+	}
+
+	return prod, version, nil
+}
+
+// streamAttribute converts an attribute of a stream record to the attribute the SDK uses
+func streamAttribute(av events.DynamoDBAttributeValue) *dynamodb.AttributeValue {
+	switch av.DataType() {
+	case events.DataTypeString:
+		s := av.String()
+		return &dynamodb.AttributeValue{S: &s}
+	case events.DataTypeNumber:
+		n := av.Number()
+		return &dynamodb.AttributeValue{N: &n}
+	case events.DataTypeBoolean:
+		b := av.Boolean()
+		return &dynamodb.AttributeValue{BOOL: &b}
+	case events.DataTypeNull:
+		null := true
+		return &dynamodb.AttributeValue{NULL: &null}
+	case events.DataTypeBinary:
+		return &dynamodb.AttributeValue{B: av.Binary()}
+	case events.DataTypeMap:
+		m := make(map[string]*dynamodb.AttributeValue, len(av.Map()))
+		for k, v := range av.Map() {
+			m[k] = streamAttribute(v)
+		}
+		return &dynamodb.AttributeValue{M: m}
+	case events.DataTypeList:
+		l := make([]*dynamodb.AttributeValue, 0, len(av.List()))
+		for _, v := range av.List() {
+			l = append(l, streamAttribute(v))
+		}
+		return &dynamodb.AttributeValue{L: l}
+	case events.DataTypeStringSet:
+		ss := make([]*string, 0, len(av.StringSet()))
+		for _, s := range av.StringSet() {
+			s := s
+			ss = append(ss, &s)
+		}
+		return &dynamodb.AttributeValue{SS: ss}
+	case events.DataTypeNumberSet:
+		ns := make([]*string, 0, len(av.NumberSet()))
+		for _, n := range av.NumberSet() {
+			n := n
+			ns = append(ns, &n)
+		}
+		return &dynamodb.AttributeValue{NS: ns}
+	case events.DataTypeBinarySet:
+		return &dynamodb.AttributeValue{BS: av.BinarySet()}
+	default:
+		return &dynamodb.AttributeValue{}
+	}
+}
@@ -0,0 +1,49 @@
+package dynamodb
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+)
+
+// spanKey is the key of the span of a DynamoDB request in the context of the request.
+type spanKey struct{}
+
+// startSpan starts a span for a request to DynamoDB made as part of a traced request. It
+// runs once per request, before the request is built, so retries are part of the span.
+var startSpan = request.NamedHandler{
+	Name: "catalog.tracing.start",
+	Fn: func(r *request.Request) {
+		ctx, span := tracing.StartChild(r.Context(), "dynamodb."+r.Operation.Name, tracing.KindClient)
+		if span == nil {
+			return
+		}
+
+		span.SetAttribute("db.system", "dynamodb")
+		span.SetAttribute("db.operation", r.Operation.Name)
+		span.SetAttribute("aws.service", r.ClientInfo.ServiceName)
+		span.SetAttribute("aws.region", aws.StringValue(r.Config.Region))
+
+		r.SetContext(context.WithValue(ctx, spanKey{}, span))
+	},
+}
+
+// endSpan ends the span of a request to DynamoDB, after the last attempt completed.
+var endSpan = request.NamedHandler{
+	Name: "catalog.tracing.end",
+	Fn: func(r *request.Request) {
+		span, ok := r.Context().Value(spanKey{}).(*tracing.Span)
+		if !ok {
+			return
+		}
+
+		span.SetAttribute("aws.request_id", r.RequestID)
+		span.SetAttribute("aws.retries", r.RetryCount)
+		if r.HTTPResponse != nil {
+			span.SetAttribute("http.status_code", r.HTTPResponse.StatusCode)
+		}
+		span.End(r.Error)
+	},
+}
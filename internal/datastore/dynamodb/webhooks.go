@@ -0,0 +1,419 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+)
+
+// webhooks is an empty struct that implements the methods of the webhook Store interface.
+type webhooks struct{}
+
+// NewWebhooks creates a webhook store using Amazon DynamoDB as backend. The subscriptions
+// are stored under PK = WEBHOOK, the log of every subscription under
+// PK = WEBHOOK#<subscription id> and the deliveries that are still pending are stored a
+// second time under PK = WEBHOOK_PENDING, so the due deliveries can be found without
+// reading the logs. The IDs of all deliveries are kept under PK = WEBHOOK_DELIVERY, so a
+// delivery is only queued once.
+func NewWebhooks() webhook.Store {
+	return webhooks{}
+}
+
+// Ping checks that the table in DynamoDB can serve requests
+func (w webhooks) Ping() error {
+	return pingTable(context.Background())
+}
+
+// AddSubscription stores a single subscription in DynamoDB
+func (w webhooks) AddSubscription(ctx context.Context, s webhook.Subscription) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table keys and data elements
+	item := webhookSubscriptionKey(s.ID)
+	item["Data"] = &dynamodb.AttributeValue{
+		S: aws.String(string(data)),
+	}
+	item["Secret"] = &dynamodb.AttributeValue{
+		S: aws.String(s.Secret),
+	}
+
+	_, err = dbs.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("TABLE")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
+	})
+	return err
+}
+
+// GetSubscription retrieves a single subscription from DynamoDB
+func (w webhooks) GetSubscription(ctx context.Context, id string) (webhook.Subscription, error) {
+	gio, err := dbs.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("TABLE")),
+		Key:            webhookSubscriptionKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return webhook.Subscription{}, err
+	}
+
+	if len(gio.Item) == 0 {
+		return webhook.Subscription{}, webhook.ErrNotFound
+	}
+
+	return unmarshalSubscription(gio.Item)
+}
+
+// Subscriptions retrieves all subscriptions from DynamoDB
+func (w webhooks) Subscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = WEBHOOK
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK"),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type"),
+		ExpressionAttributeValues: km,
+	}
+
+	subs := make([]webhook.Subscription, 0)
+
+	var subErr error
+	err := dbs.QueryPagesWithContext(ctx, qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range qo.Items {
+			s, err := unmarshalSubscription(item)
+			if err != nil {
+				subErr = err
+				return false
+			}
+			subs = append(subs, s)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, subErr
+}
+
+// RemoveSubscription deletes a single subscription from DynamoDB
+func (w webhooks) RemoveSubscription(ctx context.Context, id string) error {
+	_, err := dbs.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(os.Getenv("TABLE")),
+		Key:                 webhookSubscriptionKey(id),
+		ConditionExpression: aws.String("attribute_exists(SK)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return webhook.ErrNotFound
+	}
+	return err
+}
+
+// AddDelivery stores a new delivery like SaveDelivery, unless a delivery with its ID is
+// stored already. The IDs of the deliveries are kept under PK = WEBHOOK_DELIVERY, in the
+// same transaction, as the log is sorted by the moment a delivery was created.
+func (w webhooks) AddDelivery(ctx context.Context, d webhook.Delivery) error {
+	items, err := deliveryWrites(d)
+	if err != nil {
+		return err
+	}
+
+	marker := &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:           aws.String(os.Getenv("TABLE")),
+			Item:                deliveryIDKey(d.ID),
+			ConditionExpression: aws.String("attribute_not_exists(SK)"),
+		},
+	}
+
+	_, err = dbs.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]*dynamodb.TransactWriteItem{marker}, items...),
+	})
+	if tce, ok := err.(*dynamodb.TransactionCanceledException); ok && len(tce.CancellationReasons) > 0 && aws.StringValue(tce.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return nil
+	}
+	return err
+}
+
+// SaveDelivery stores a delivery in the log of its subscription, and in the pending
+// deliveries as long as it's pending, in a single transaction
+func (w webhooks) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	items, err := deliveryWrites(d)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbs.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	return err
+}
+
+// ClaimDelivery moves the next attempt of a pending delivery in DynamoDB to until, with an
+// update that is conditional on the next attempt it was read with
+func (w webhooks) ClaimDelivery(ctx context.Context, d webhook.Delivery, until time.Time) (bool, error) {
+	if d.NextAttemptAt == nil {
+		return false, nil
+	}
+
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":read"] = &dynamodb.AttributeValue{
+		S: aws.String(d.NextAttemptAt.UTC().Format(auditTimeLayout)),
+	}
+	km[":until"] = &dynamodb.AttributeValue{
+		S: aws.String(until.UTC().Format(auditTimeLayout)),
+	}
+
+	_, err := dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       pendingDeliveryKey(d.ID),
+		UpdateExpression:          aws.String("SET NextAttemptAt = :until"),
+		ConditionExpression:       aws.String("NextAttemptAt = :read"),
+		ExpressionAttributeValues: km,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// deliveryWrites returns the writes that store a delivery in the log of its subscription,
+// and in the pending deliveries as long as it's pending, or remove it from there
+func deliveryWrites(d webhook.Delivery) ([]*dynamodb.TransactWriteItem, error) {
+	item, err := deliveryItem(d)
+	if err != nil {
+		return nil, err
+	}
+
+	logItem := make(map[string]*dynamodb.AttributeValue, len(item)+2)
+	for k, v := range item {
+		logItem[k] = v
+	}
+	logItem["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK#" + d.SubscriptionID),
+	}
+	logItem["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(fmt.Sprintf("%s#%s", d.CreatedAt.UTC().Format(auditTimeLayout), d.ID)),
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{
+			Put: &dynamodb.Put{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Item:      logItem,
+			},
+		},
+	}
+
+	if d.Status == webhook.StatusPending && d.NextAttemptAt != nil {
+		pendingItem := pendingDeliveryKey(d.ID)
+		for k, v := range item {
+			pendingItem[k] = v
+		}
+		pendingItem["NextAttemptAt"] = &dynamodb.AttributeValue{
+			S: aws.String(d.NextAttemptAt.UTC().Format(auditTimeLayout)),
+		}
+		items = append(items, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Item:      pendingItem,
+			},
+		})
+	} else {
+		items = append(items, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Key:       pendingDeliveryKey(d.ID),
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// DueDeliveries retrieves the pending deliveries from DynamoDB that are due at now
+func (w webhooks) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = WEBHOOK_PENDING
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK_PENDING"),
+	}
+	km[":now"] = &dynamodb.AttributeValue{
+		S: aws.String(now.UTC().Format(auditTimeLayout)),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type"),
+		FilterExpression:          aws.String("NextAttemptAt <= :now"),
+		ExpressionAttributeValues: km,
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	return queryDeliveries(ctx, qi, limit)
+}
+
+// Deliveries retrieves the newest deliveries of a subscription from DynamoDB
+func (w webhooks) Deliveries(ctx context.Context, subscriptionID string, limit int) ([]webhook.Delivery, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = WEBHOOK#<subscription id>
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK#" + subscriptionID),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: km,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	return queryDeliveries(ctx, qi, limit)
+}
+
+// queryDeliveries reads the deliveries returned by a query, up to limit. The pages are
+// read until there are enough, because a filter is applied after the limit of a page.
+func queryDeliveries(ctx context.Context, qi *dynamodb.QueryInput, limit int) ([]webhook.Delivery, error) {
+	deliveries := make([]webhook.Delivery, 0)
+
+	var deliveryErr error
+	err := dbs.QueryPagesWithContext(ctx, qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range qo.Items {
+			d, err := unmarshalDelivery(item)
+			if err != nil {
+				deliveryErr = err
+				return false
+			}
+			deliveries = append(deliveries, d)
+			if len(deliveries) == limit {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, deliveryErr
+}
+
+// webhookSubscriptionKey creates a map of DynamoDB Attribute Values containing the table
+// keys of a subscription
+func webhookSubscriptionKey(id string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(id),
+	}
+	return km
+}
+
+// pendingDeliveryKey creates a map of DynamoDB Attribute Values containing the table keys
+// of a pending delivery
+func pendingDeliveryKey(id string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK_PENDING"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(id),
+	}
+	return km
+}
+
+// deliveryIDKey creates a map of DynamoDB Attribute Values containing the table keys of
+// the ID of a delivery
+func deliveryIDKey(id string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("WEBHOOK_DELIVERY"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(id),
+	}
+	return km
+}
+
+// deliveryItem creates the data elements of a delivery, without the table keys
+func deliveryItem(d webhook.Delivery) (map[string]*dynamodb.AttributeValue, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue)
+	item["Data"] = &dynamodb.AttributeValue{
+		S: aws.String(string(data)),
+	}
+	item["Event"] = &dynamodb.AttributeValue{
+		B: d.Payload,
+	}
+	return item, nil
+}
+
+// unmarshalSubscription creates a subscription from the attributes of a DynamoDB item
+func unmarshalSubscription(item map[string]*dynamodb.AttributeValue) (webhook.Subscription, error) {
+	var s webhook.Subscription
+
+	av, ok := item["Data"]
+	if !ok || av.S == nil {
+		return webhook.Subscription{}, fmt.Errorf("webhook subscription has no Data")
+	}
+	if err := json.Unmarshal([]byte(*av.S), &s); err != nil {
+		return webhook.Subscription{}, fmt.Errorf("invalid Data: %s", err.Error())
+	}
+
+	if av, ok := item["Secret"]; ok && av.S != nil {
+		s.Secret = *av.S
+	}
+
+	return s, nil
+}
+
+// unmarshalDelivery creates a delivery from the attributes of a DynamoDB item
+func unmarshalDelivery(item map[string]*dynamodb.AttributeValue) (webhook.Delivery, error) {
+	var d webhook.Delivery
+
+	av, ok := item["Data"]
+	if !ok || av.S == nil {
+		return webhook.Delivery{}, fmt.Errorf("webhook delivery has no Data")
+	}
+	if err := json.Unmarshal([]byte(*av.S), &d); err != nil {
+		return webhook.Delivery{}, fmt.Errorf("invalid Data: %s", err.Error())
+	}
+
+	if av, ok := item["Event"]; ok {
+		d.Payload = av.B
+	}
+
+	// The next attempt of a pending delivery is moved when it's claimed, without
+	// rewriting its Data
+	if av, ok := item["NextAttemptAt"]; ok && av.S != nil {
+		t, err := time.Parse(auditTimeLayout, *av.S)
+		if err != nil {
+			return webhook.Delivery{}, fmt.Errorf("invalid NextAttemptAt: %s", err.Error())
+		}
+		d.NextAttemptAt = &t
+	}
+
+	return d, nil
+}
@@ -0,0 +1,236 @@
+// Package file stores the catalog in a snapshot file on the local disk. It's meant for local
+// development and for moving catalogs between environments, not for production use.
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/catalogio"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// store keeps the entire catalog in memory and writes it back to the snapshot
+// file after every change. The revisions of the products are appended to a
+// history file next to the snapshot file, with one revision per line.
+type store struct {
+	path     string
+	format   catalogio.Format
+	mu       sync.RWMutex
+	products map[string]datastore.Product
+	history  map[string][]datastore.Revision
+}
+
+// manager implements the methods of the Manager interface on top of a store. The
+// actor is recorded in the revisions of the products it changes.
+type manager struct {
+	*store
+	actor string
+}
+
+// New creates a new datastore manager using the snapshot file at path as backend. The
+// format of the file is derived from its extension and defaults to JSON. If the file
+// doesn't exist yet, it's created when the first product is added. The revisions are
+// kept in the file at path with the .history extension added.
+func New(path string) (datastore.Manager, error) {
+	format, err := catalogio.ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		format = catalogio.JSON
+	}
+
+	s := &store{
+		path:     path,
+		format:   format,
+		products: make(map[string]datastore.Product),
+		history:  make(map[string][]datastore.Revision),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadHistory(); err != nil {
+		return nil, err
+	}
+
+	return manager{store: s}, nil
+}
+
+// WithActor returns a manager that records actor in the revisions it creates
+func (m manager) WithActor(actor string) datastore.Manager {
+	return manager{store: m.store, actor: actor}
+}
+
+// WithContext returns the manager, the catalog is kept in memory so there are no
+// operations to cancel or trace
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	return m
+}
+
+// AddProduct stores a new product in the snapshot file, or replaces the
+// product if one with the same ID already exists
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	return m.write(p.Item.ID, datastore.Put(p))
+}
+
+// GetProduct retrieves a single product from the snapshot file based on the productID
+func (s *store) GetProduct(productID string) (datastore.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.products[productID]
+	if !ok {
+		return datastore.Product{}, fmt.Errorf("Unable to find product with id %s", productID)
+	}
+
+	return p, nil
+}
+
+// GetProductsByIDs retrieves the products with the given IDs from the snapshot file
+func (s *store) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := make([]datastore.Product, 0, len(productIDs))
+	for _, id := range productIDs {
+		if p, ok := s.products[id]; ok {
+			products = append(products, p)
+		}
+	}
+
+	return products, nil
+}
+
+// GetProducts retrieves all products from the snapshot file, sorted by ID
+func (s *store) GetProducts() ([]datastore.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted(), nil
+}
+
+// SetProductStatus changes the status of a single product in the snapshot file based on the productID
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetStatus(productID, status))
+}
+
+// UpdateProductItem replaces the catalog item of a single product in the snapshot file based on its ID
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	return m.write(item.ID, datastore.SetItem(item))
+}
+
+// SetProductWindow changes the availability window of a single product in the snapshot file based on the productID
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetWindow(productID, window))
+}
+
+// DeleteProduct removes a single product from the snapshot file based on the productID
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	return m.write(productID, datastore.Delete(productID))
+}
+
+// Ping checks that the directory of the snapshot file can be reached
+func (s *store) Ping() error {
+	_, err := os.Stat(filepath.Dir(s.path))
+	return err
+}
+
+// ForEachProduct calls fn for each product in the snapshot file, sorted by ID. Since the
+// products are copied before fn is called, fn can safely change the catalog.
+func (s *store) ForEachProduct(fn func(p datastore.Product) error) error {
+	s.mu.RLock()
+	products := s.sorted()
+	s.mu.RUnlock()
+
+	for _, p := range products {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sorted returns all products sorted by ID. The caller must hold the lock.
+func (s *store) sorted() []datastore.Product {
+	products := make([]datastore.Product, 0, len(s.products))
+	for _, p := range s.products {
+		products = append(products, p)
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Item.ID < products[j].Item.ID
+	})
+
+	return products
+}
+
+// load reads the products from the snapshot file, if it exists.
+func (s *store) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := catalogio.NewDecoder(f, s.format)
+	if err != nil {
+		return err
+	}
+
+	for {
+		p, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %s", s.path, err.Error())
+		}
+		s.products[p.Item.ID] = p
+	}
+}
+
+// save writes the catalog to a temporary file, which replaces the snapshot file once
+// it's completely written. The caller must hold the write lock.
+func (s *store) save() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc, err := catalogio.NewEncoder(tmp, s.format)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	for _, p := range s.sorted() {
+		if err := enc.Encode(p); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,126 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// GetRevisions retrieves all revisions of a product from the history file, oldest first
+func (s *store) GetRevisions(productID string) ([]datastore.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revs, ok := s.history[productID]
+	if !ok {
+		return nil, fmt.Errorf("Unable to find product with id %s", productID)
+	}
+
+	return append([]datastore.Revision(nil), revs...), nil
+}
+
+// RollbackProduct restores the product in the snapshot file as it was in the given version
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	m.mu.RLock()
+	revs := m.history[productID]
+	m.mu.RUnlock()
+
+	for _, rev := range revs {
+		if rev.Version == version {
+			return m.write(productID, datastore.Rollback(rev))
+		}
+	}
+
+	return datastore.Revision{}, fmt.Errorf("Unable to find revision %d of product with id %s", version, productID)
+}
+
+// write applies the mutation to the current product and stores the result in the
+// snapshot file, after its revision has been added to the history file.
+func (m manager) write(productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current *datastore.Product
+	if p, ok := m.products[productID]; ok {
+		current = &p
+	}
+
+	rev, err := mutate(current)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	rev.ProductID = productID
+	rev.Version = len(m.history[productID]) + 1
+	rev.Actor = m.actor
+	rev.CreatedAt = time.Now().UTC()
+
+	if err := m.appendHistory(rev); err != nil {
+		return datastore.Revision{}, err
+	}
+	m.history[productID] = append(m.history[productID], rev)
+
+	if rev.Operation == datastore.OperationDelete {
+		delete(m.products, productID)
+	} else {
+		m.products[productID] = rev.Product
+	}
+
+	rev.Previous = current
+	return rev, m.save()
+}
+
+// historyPath returns the path of the history file.
+func (s *store) historyPath() string {
+	return s.path + ".history"
+}
+
+// loadHistory reads the revisions from the history file, if it exists.
+func (s *store) loadHistory() error {
+	f, err := os.Open(s.historyPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var rev datastore.Revision
+		if err := json.Unmarshal(scanner.Bytes(), &rev); err != nil {
+			return fmt.Errorf("error reading %s on line %d: %s", s.historyPath(), line, err.Error())
+		}
+		s.history[rev.ProductID] = append(s.history[rev.ProductID], rev)
+	}
+
+	return scanner.Err()
+}
+
+// appendHistory adds a single revision to the end of the history file. The caller
+// must hold the write lock.
+func (s *store) appendHistory(rev datastore.Revision) error {
+	payload, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(payload, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditLog is an empty struct that implements the methods of the audit Log interface.
+type auditLog struct{}
+
+// NewAuditLog creates an audit log using the catalog_audit collection in MongoDB as backend
+func NewAuditLog() audit.Log {
+	connectOnce.Do(connect)
+	return auditLog{}
+}
+
+// Write stores a single entry in MongoDB
+func (l auditLog) Write(e audit.Entry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.D{
+		{Key: "ID", Value: e.ID},
+		{Key: "Time", Value: e.Time},
+		{Key: "Actor", Value: e.Actor},
+		{Key: "SourceIP", Value: e.SourceIP},
+		{Key: "Operation", Value: string(e.Operation)},
+		{Key: "ProductID", Value: e.ProductID},
+		{Key: "Changes", Value: string(changes)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = auditTrail.InsertOne(ctx, doc)
+
+	return err
+}
+
+// Query retrieves the entries matching the filter from MongoDB
+func (l auditLog) Query(f audit.Filter) ([]audit.Entry, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	filter := bson.D{}
+	if f.ProductID != "" {
+		filter = append(filter, bson.E{Key: "ProductID", Value: f.ProductID})
+	}
+	if f.Actor != "" {
+		filter = append(filter, bson.E{Key: "Actor", Value: f.Actor})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := auditTrail.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "Time", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	entries := make([]audit.Entry, 0, len(results))
+
+	for _, raw := range results {
+		e, err := unmarshalEntry(raw)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// Ping checks that MongoDB, which holds the audit trail, can be reached
+func (l auditLog) Ping() error {
+	return ping(context.Background())
+}
+
+// unmarshalEntry creates an audit entry from a MongoDB document
+func unmarshalEntry(raw bson.Raw) (audit.Entry, error) {
+	id, _ := raw.Lookup("ID").StringValueOK()
+	actor, _ := raw.Lookup("Actor").StringValueOK()
+	sourceIP, _ := raw.Lookup("SourceIP").StringValueOK()
+	operation, _ := raw.Lookup("Operation").StringValueOK()
+	productID, _ := raw.Lookup("ProductID").StringValueOK()
+
+	e := audit.Entry{
+		ID:        id,
+		Actor:     actor,
+		SourceIP:  sourceIP,
+		Operation: datastore.Operation(operation),
+		ProductID: productID,
+	}
+
+	if t := timeValue(raw, "Time"); t != nil {
+		e.Time = *t
+	}
+
+	if changes, ok := raw.Lookup("Changes").StringValueOK(); ok {
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return audit.Entry{}, fmt.Errorf("invalid Changes: %s", err.Error())
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,187 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// feedEventSize is the number of bytes the capped collection reserves per event
+	feedEventSize = 8192
+
+	// feedReopen is how long a tailable cursor that the server closed, like the one of an
+	// empty collection, waits before it's opened again
+	feedReopen = time.Second
+)
+
+// feedLog is an empty struct that implements the methods of the FeedLog interface.
+type feedLog struct{}
+
+// NewFeedLog creates a feed log using the capped catalog_feed collection in MongoDB as
+// backend, which keeps the last size events in the order they were inserted. The
+// collection is created when it doesn't exist yet.
+func NewFeedLog(size int) bus.FeedLog {
+	connectOnce.Do(connect)
+
+	if size < 1 {
+		size = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := feedEvents.Database().RunCommand(ctx, bson.D{
+		{Key: "create", Value: feedEvents.Name()},
+		{Key: "capped", Value: true},
+		{Key: "size", Value: int64(size) * feedEventSize},
+		{Key: "max", Value: int64(size)},
+	}).Err()
+	if err != nil && !isCommandError(err, namespaceExists) {
+		logging.Default().Warn("error creating collection", "collection", "catalog_feed", "error", err)
+	}
+
+	return feedLog{}
+}
+
+// Publish inserts the event into MongoDB. An event that is in the log already fails on
+// the _id and is dropped.
+func (l feedLog) Publish(ctx context.Context, e bus.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = feedEvents.InsertOne(ctx, bson.D{
+		{Key: "_id", Value: e.ID},
+		{Key: "Event", Value: string(payload)},
+	})
+	if isDuplicateKey(err) {
+		return nil
+	}
+	return err
+}
+
+// Tail reads the collection with a tailable cursor. The server closes the cursor when
+// the collection is empty, or when the oldest events overwrote its position, after which
+// it's opened again and continues after the last event it sent.
+func (l feedLog) Tail(ctx context.Context, fn func(e bus.Event)) error {
+	last := ""
+	for {
+		err := tailFeed(ctx, &last, fn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !isCommandError(err, cappedPositionLost) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(feedReopen):
+		}
+	}
+}
+
+// tailFeed sends the events after last to fn, until the cursor is closed, and sets last
+// to the ID of every event it sent. When last isn't in the collection anymore, every
+// event in it is sent.
+func tailFeed(ctx context.Context, last *string, fn func(e bus.Event)) error {
+	skip := false
+	if *last != "" {
+		err := feedEvents.FindOne(ctx, bson.D{{Key: "_id", Value: *last}}).Err()
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		skip = err == nil
+	}
+
+	cursor, err := feedEvents.Find(ctx, bson.D{}, options.Find().SetCursorType(options.TailableAwait))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		e, err := unmarshalFeedEvent(cursor.Current)
+		if err != nil {
+			return err
+		}
+		if skip {
+			skip = e.ID != *last
+			continue
+		}
+
+		fn(e)
+		*last = e.ID
+	}
+
+	return cursor.Err()
+}
+
+// Since reads the collection in the order the events were inserted
+func (l feedLog) Since(ctx context.Context, id string) ([]bus.Event, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := feedEvents.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "$natural", Value: 1}}))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, false, err
+	}
+
+	var events []bus.Event
+	found := false
+	for _, result := range results {
+		e, err := unmarshalFeedEvent(result)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			events = append(events, e)
+		}
+		found = found || e.ID == id
+	}
+
+	return events, found, nil
+}
+
+// Close does nothing, the connection is shared with the data store
+func (l feedLog) Close() error {
+	return nil
+}
+
+// unmarshalFeedEvent reads the event of a document in the catalog_feed collection
+func unmarshalFeedEvent(raw bson.Raw) (bus.Event, error) {
+	payload, ok := raw.Lookup("Event").StringValueOK()
+	if !ok {
+		return bus.Event{}, fmt.Errorf("feed event %s has no Event", raw.Lookup("_id").String())
+	}
+
+	var e bus.Event
+	err := json.Unmarshal([]byte(payload), &e)
+	return e, err
+}
+
+// isCommandError returns true if err is the error of a command with the code
+func isCommandError(err error, code int32) bool {
+	var e mongo.CommandError
+	return errors.As(err, &e) && e.Code == code
+}
@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/lease"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// leases is an empty struct that implements the methods of the lease Store interface.
+type leases struct{}
+
+// NewLeases creates a lease store using the catalog_leases collection in MongoDB as
+// backend. The expiry of a lease is set with the clock of the holder, so the clocks of
+// the instances must be in sync within a fraction of the ttl.
+func NewLeases() lease.Store {
+	connectOnce.Do(connect)
+	return leases{}
+}
+
+// Acquire upserts the lease when the holder has it or it expired. When somebody else
+// holds it, the filter doesn't match and the insert of the upsert fails on the _id.
+func (l leases) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	filter := bson.D{
+		{Key: "_id", Value: name},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "Holder", Value: holder}},
+			bson.D{{Key: "ExpiresAt", Value: bson.D{{Key: "$lte", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "Holder", Value: holder},
+		{Key: "ExpiresAt", Value: now.Add(ttl)},
+	}}}
+
+	_, err := leaseEntries.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release removes the lease, if the holder has it
+func (l leases) Release(ctx context.Context, name, holder string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := leaseEntries.DeleteOne(ctx, bson.D{{Key: "_id", Value: name}, {Key: "Holder", Value: holder}})
+	return err
+}
@@ -8,13 +8,16 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	acmeserverless "github.com/retgits/acme-serverless"
 	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // The pointer to MongoDB provides the API operation methods for making requests to MongoDB.
@@ -22,12 +25,57 @@ import (
 // container stays warm.
 var dbs *mongo.Collection
 
-// manager is an empty struct that implements the methods of the
-// Manager interface.
-type manager struct{}
+// history is the collection that contains the revisions of all products
+var history *mongo.Collection
 
-// init creates the connection to MongoDB.
-func init() {
+// auditTrail is the collection that contains the audit trail of the catalog
+var auditTrail *mongo.Collection
+
+// outboxEntries is the collection that contains the changes that still have to be published
+var outboxEntries *mongo.Collection
+
+// resumeTokens is the collection that contains the positions of the watchers in the
+// change stream of the catalog
+var resumeTokens *mongo.Collection
+
+// watchedProducts is the collection that contains the product documents as the watchers
+// last read them from the change stream
+var watchedProducts *mongo.Collection
+
+// webhookSubscriptions is the collection that contains the webhook subscriptions
+var webhookSubscriptions *mongo.Collection
+
+// webhookDeliveries is the collection that contains the deliveries of the webhooks
+var webhookDeliveries *mongo.Collection
+
+// leaseEntries is the collection that contains the leases of the background jobs
+var leaseEntries *mongo.Collection
+
+// scheduleWatermarks is the collection that contains the moments up to which the
+// schedulers checked the availability windows
+var scheduleWatermarks *mongo.Collection
+
+// feedEvents is the capped collection that contains the most recent events of the live
+// feeds
+var feedEvents *mongo.Collection
+
+// connectOnce makes sure the connection to MongoDB is only created once, the first
+// time a manager is created, so programs that link this package without using it
+// don't need a MongoDB server.
+var connectOnce sync.Once
+
+// manager implements the methods of the Manager interface. The actor
+// is recorded in the revisions of the products it changes, and the
+// operations are run with ctx. When outbox is true, every change is
+// recorded in the outbox as well.
+type manager struct {
+	actor  string
+	ctx    context.Context
+	outbox bool
+}
+
+// connect creates the connection to MongoDB.
+func connect() {
 	username := os.Getenv("MONGO_USERNAME")
 	password := os.Getenv("MONGO_PASSWORD")
 	hostname := os.Getenv("MONGO_HOSTNAME")
@@ -37,66 +85,200 @@ func init() {
 	if strings.HasSuffix(connString, ":") {
 		connString = connString[:len(connString)-1]
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connString))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connString).SetMonitor(monitor()))
 	if err != nil {
 		log.Fatalf("error connecting to MongoDB: %s", err.Error())
 	}
 	dbs = client.Database("acmeserverless").Collection("catalog")
+	history = client.Database("acmeserverless").Collection("catalog_history")
+	auditTrail = client.Database("acmeserverless").Collection("catalog_audit")
+	outboxEntries = client.Database("acmeserverless").Collection("catalog_outbox")
+	resumeTokens = client.Database("acmeserverless").Collection("catalog_watch")
+	watchedProducts = client.Database("acmeserverless").Collection("catalog_watch_products")
+	webhookSubscriptions = client.Database("acmeserverless").Collection("catalog_webhooks")
+	webhookDeliveries = client.Database("acmeserverless").Collection("catalog_webhook_deliveries")
+	leaseEntries = client.Database("acmeserverless").Collection("catalog_leases")
+	scheduleWatermarks = client.Database("acmeserverless").Collection("catalog_schedule")
+	feedEvents = client.Database("acmeserverless").Collection("catalog_feed")
+
+	// The unique index makes sure two writes can't create the same product, when they're
+	// made without a transaction on a standalone server
+	_, err = dbs.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "SK", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logging.Default().Warn("error creating index", "collection", "catalog", "error", err)
+	}
+
+	// The unique index makes sure two writes can't create the same version of a product
+	_, err = history.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ProductID", Value: 1}, {Key: "Version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logging.Default().Warn("error creating index", "collection", "catalog_history", "error", err)
+	}
+
+	// The index orders the outbox, and creates the collection, which can't be created
+	// as part of a transaction
+	_, err = outboxEntries.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "CreatedAt", Value: 1}, {Key: "Version", Value: 1}, {Key: "ID", Value: 1}},
+	})
+	if err != nil {
+		logging.Default().Warn("error creating index", "collection", "catalog_outbox", "error", err)
+	}
+
+	// The index serves the products a watcher loads when it resumes
+	_, err = watchedProducts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "Watcher", Value: 1}},
+	})
+	if err != nil {
+		logging.Default().Warn("error creating index", "collection", "catalog_watch_products", "error", err)
+	}
+
+	// The indexes serve the due deliveries and the log of every subscription
+	_, err = webhookDeliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "Status", Value: 1}, {Key: "NextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "SubscriptionID", Value: 1}, {Key: "CreatedAt", Value: -1}}},
+	})
+	if err != nil {
+		logging.Default().Warn("error creating index", "collection", "catalog_webhook_deliveries", "error", err)
+	}
 }
 
-// New creates a new datastore manager using Amazon DynamoDB as backend
+// New creates a new datastore manager using MongoDB as backend
 func New() datastore.Manager {
+	connectOnce.Do(connect)
 	return manager{}
 }
 
-// AddProduct stores a new product in Amazon DynamoDB
-func (m manager) AddProduct(p acmeserverless.CatalogItem) error {
-	payload, err := p.Marshal()
-	if err != nil {
-		return err
-	}
+// WithActor returns a manager that records actor in the revisions it creates
+func (m manager) WithActor(actor string) datastore.Manager {
+	m.actor = actor
+	return m
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	_, err = dbs.InsertOne(ctx, bson.D{{"SK", p.ID}, {"PK", "PRODUCT"}, {"Payload", string(payload)}})
+// WithContext returns a manager that runs its operations with ctx
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	m.ctx = ctx
+	return m
+}
+
+// parent returns the context the operations of the manager are derived from
+func (m manager) parent() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
 
-	return err
+// AddProduct stores a new product in MongoDB, or replaces the product
+// if one with the same ID already exists
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	return m.write(p.Item.ID, datastore.Put(p))
 }
 
-// GetProduct retrieves a single product from DynamoDB based on the productID
-func (m manager) GetProduct(productID string) (acmeserverless.CatalogItem, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+// GetProduct retrieves a single product from MongoDB based on the productID
+func (m manager) GetProduct(productID string) (datastore.Product, error) {
+	ctx, cancel := context.WithTimeout(m.parent(), 10*time.Second)
+	defer cancel()
 
-	res := dbs.FindOne(ctx, bson.D{{"SK", productID}})
+	res := dbs.FindOne(ctx, bson.D{{Key: "SK", Value: productID}})
 
 	raw, err := res.DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return datastore.Product{}, fmt.Errorf("Unable to find product with id %s", productID)
+	}
 	if err != nil {
-		return acmeserverless.CatalogItem{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+		return datastore.Product{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
 	}
 
-	return acmeserverless.UnmarshalCatalogItem(raw.Lookup("Payload").StringValue())
+	return unmarshalProduct(raw)
+}
+
+// GetProductsByIDs retrieves the products with the given IDs from MongoDB using an $in query
+func (m manager) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	return find(m.parent(), bson.D{{Key: "SK", Value: bson.D{{Key: "$in", Value: productIDs}}}})
+}
+
+// GetProducts retrieves all products from MongoDB
+func (m manager) GetProducts() ([]datastore.Product, error) {
+	return find(m.parent(), bson.D{})
 }
 
-// GetProducts retrieves all products from DynamoDB
-func (m manager) GetProducts() ([]acmeserverless.CatalogItem, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+// SetProductStatus changes the status of a single product in MongoDB based on the productID
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetStatus(productID, status))
+}
+
+// UpdateProductItem replaces the catalog item of a single product in MongoDB based on its ID
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	return m.write(item.ID, datastore.SetItem(item))
+}
+
+// SetProductWindow changes the availability window of a single product in MongoDB based on the productID
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	return m.write(productID, datastore.SetWindow(productID, window))
+}
+
+// DeleteProduct removes a single product from MongoDB based on the productID
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	return m.write(productID, datastore.Delete(productID))
+}
+
+// ForEachProduct iterates over a cursor of all products in MongoDB and calls fn for each of them
+func (m manager) ForEachProduct(fn func(p datastore.Product) error) error {
+	// The cursor is not bound to a timeout, because the time it takes depends on
+	// how fast the caller consumes the products
+	ctx, cancel := context.WithCancel(m.parent())
+	defer cancel()
+
 	cursor, err := dbs.Find(ctx, bson.D{})
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		prod, err := unmarshalProduct(cursor.Current)
+		if err != nil {
+			skip(ctx, cursor.Current, err)
+			continue
+		}
+
+		if err := fn(prod); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// find returns all products matching the filter
+func find(parent context.Context, filter bson.D) ([]datastore.Product, error) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	cursor, err := dbs.Find(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	var results []bson.M
+	var results []bson.Raw
 
 	if err = cursor.All(ctx, &results); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	prods := make([]acmeserverless.CatalogItem, 0)
+	prods := make([]datastore.Product, 0, len(results))
 
 	for _, result := range results {
-		prod, err := acmeserverless.UnmarshalCatalogItem(result["Payload"].(string))
+		prod, err := unmarshalProduct(result)
 		if err != nil {
-			log.Println(fmt.Sprintf("error unmarshalling catalog item data: %s", err.Error()))
+			skip(ctx, result, err)
 			continue
 		}
 
@@ -105,3 +287,77 @@ func (m manager) GetProducts() ([]acmeserverless.CatalogItem, error) {
 
 	return prods, nil
 }
+
+// skip logs a document that can't be unmarshalled and is left out of the result, with its
+// ID so the document can be found and fixed
+func skip(ctx context.Context, raw bson.Raw, err error) {
+	sk, _ := raw.Lookup("SK").StringValueOK()
+	logging.FromContext(ctx).Warn("skipping product that can't be unmarshalled", "collection", "catalog", "_id", raw.Lookup("_id").String(), "sk", sk, "error", err)
+}
+
+// unmarshalProduct creates a product from a MongoDB document. Documents stored before
+// products had a status don't have the Status field, those products are published.
+func unmarshalProduct(raw bson.Raw) (datastore.Product, error) {
+	payload, ok := raw.Lookup("Payload").StringValueOK()
+	if !ok {
+		return datastore.Product{}, fmt.Errorf("document has no Payload")
+	}
+
+	prod, err := acmeserverless.UnmarshalCatalogItem(payload)
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	status, _ := raw.Lookup("Status").StringValueOK()
+	st, err := datastore.ParseStatus(status)
+	if err != nil {
+		return datastore.Product{}, err
+	}
+
+	return datastore.Product{
+		Item:   prod,
+		Status: st,
+		Window: datastore.Window{
+			PublishAt:   timeValue(raw, "PublishAt"),
+			UnpublishAt: timeValue(raw, "UnpublishAt"),
+		},
+	}, nil
+}
+
+// timeValue returns the date stored in the named field of the document, or nil
+// if the document doesn't have the field.
+func timeValue(raw bson.Raw, key string) *time.Time {
+	t, ok := raw.Lookup(key).TimeOK()
+	if !ok {
+		return nil
+	}
+
+	t = t.UTC()
+	return &t
+}
+
+// Ping checks that MongoDB can be reached
+func (m manager) Ping() error {
+	return ping(m.parent())
+}
+
+// ping checks that the primary of the MongoDB replica set, which takes the writes, can be reached
+func ping(parent context.Context) error {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	return dbs.Database().Client().Ping(ctx, readpref.Primary())
+}
+
+// Close disconnects from MongoDB, if a connection was made. The managers can't be used
+// after the connection is closed.
+func Close() error {
+	if dbs == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return dbs.Database().Client().Disconnect(ctx)
+}
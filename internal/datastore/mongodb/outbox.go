@@ -0,0 +1,136 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// outbox is an empty struct that implements the methods of the Outbox interface.
+type outbox struct{}
+
+// NewWithOutbox creates a new datastore manager using MongoDB as backend, which records
+// every change in the catalog_outbox collection in the same transaction as the change.
+// Changes fail on a server that doesn't support transactions.
+func NewWithOutbox() datastore.Manager {
+	connectOnce.Do(connect)
+	return manager{outbox: true}
+}
+
+// NewOutbox creates an outbox using the catalog_outbox collection in MongoDB as backend
+func NewOutbox() datastore.Outbox {
+	connectOnce.Do(connect)
+	return outbox{}
+}
+
+// Pending retrieves the oldest entries from MongoDB. The time of a change is stored in
+// milliseconds, so changes to the same product in the same millisecond are ordered by
+// their version.
+func (o outbox) Pending(ctx context.Context, after *datastore.OutboxEntry, limit int) ([]datastore.OutboxEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.D{}
+	if after != nil {
+		t, v := after.Revision.CreatedAt.UTC(), after.Revision.Version
+		filter = bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "CreatedAt", Value: bson.D{{Key: "$gt", Value: t}}}},
+			bson.D{{Key: "CreatedAt", Value: t}, {Key: "Version", Value: bson.D{{Key: "$gt", Value: v}}}},
+			bson.D{{Key: "CreatedAt", Value: t}, {Key: "Version", Value: v}, {Key: "ID", Value: bson.D{{Key: "$gt", Value: after.ID}}}},
+		}}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}, {Key: "Version", Value: 1}, {Key: "ID", Value: 1}}).SetLimit(int64(limit))
+
+	cursor, err := outboxEntries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	entries := make([]datastore.OutboxEntry, 0, len(results))
+	for _, result := range results {
+		e, err := unmarshalOutboxEntry(result)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// MarkPublished adds the names to the publishers of a single entry in MongoDB
+func (o outbox) MarkPublished(ctx context.Context, e datastore.OutboxEntry, names []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := outboxEntries.UpdateOne(ctx, bson.D{{Key: "ID", Value: e.ID}}, bson.D{
+		{Key: "$addToSet", Value: bson.D{{Key: "Published", Value: bson.D{{Key: "$each", Value: names}}}}},
+	})
+	return err
+}
+
+// Remove deletes a single entry from MongoDB
+func (o outbox) Remove(ctx context.Context, e datastore.OutboxEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := outboxEntries.DeleteOne(ctx, bson.D{{Key: "ID", Value: e.ID}})
+	return err
+}
+
+// outboxDocument creates the MongoDB document of an outbox entry, which contains the
+// same fields as the revision it describes
+func outboxDocument(e datastore.OutboxEntry) (bson.D, error) {
+	doc, err := revisionDocument(e.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(bson.D{
+		{Key: "ID", Value: e.ID},
+		{Key: "Existed", Value: e.Existed},
+	}, doc...), nil
+}
+
+// unmarshalOutboxEntry creates an outbox entry from a MongoDB document
+func unmarshalOutboxEntry(raw bson.Raw) (datastore.OutboxEntry, error) {
+	rev, err := unmarshalRevision(raw)
+	if err != nil {
+		return datastore.OutboxEntry{}, err
+	}
+
+	id, _ := raw.Lookup("ID").StringValueOK()
+	if id == "" {
+		return datastore.OutboxEntry{}, fmt.Errorf("outbox entry has no ID")
+	}
+	existed, _ := raw.Lookup("Existed").BooleanOK()
+
+	e := datastore.OutboxEntry{
+		ID:       id,
+		Existed:  existed,
+		Revision: rev,
+	}
+	if arr, ok := raw.Lookup("Published").ArrayOK(); ok {
+		values, err := arr.Values()
+		if err != nil {
+			return datastore.OutboxEntry{}, fmt.Errorf("invalid Published: %s", err.Error())
+		}
+		for _, v := range values {
+			if name, ok := v.StringValueOK(); ok {
+				e.Published = append(e.Published, name)
+			}
+		}
+	}
+
+	return e, nil
+}
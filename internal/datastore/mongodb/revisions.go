@@ -0,0 +1,438 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxWriteRetries is the number of times a write without a transaction is retried
+	// when another write changed the same product at the same time
+	maxWriteRetries = 5
+
+	// illegalOperation is the code of the error a standalone server returns for
+	// transactions
+	illegalOperation = 20
+
+	// duplicateKey is the code of the error for a write that violates a unique index
+	duplicateKey = 11000
+
+	// changeStreamHistoryLost is the code of the error for a change stream that is
+	// resumed from a change the oplog no longer has
+	changeStreamHistoryLost = 286
+
+	// namespaceExists is the code of the error for a collection that is created when it
+	// exists already
+	namespaceExists = 48
+
+	// cappedPositionLost is the code of the error for a tailable cursor whose position
+	// was overwritten in a capped collection
+	cappedPositionLost = 136
+)
+
+// errConflict is returned when a write without a transaction didn't happen because the
+// product changed since it was read.
+var errConflict = fmt.Errorf("the product was changed by another request")
+
+// errTransactionsRequired is returned by a manager with an outbox when MongoDB doesn't
+// support transactions.
+var errTransactionsRequired = fmt.Errorf("the outbox needs transactions, which MongoDB only supports on a replica set")
+
+// standalone is set to 1 once MongoDB reported that it doesn't support transactions,
+// so the writes of managers without an outbox don't try them anymore
+var standalone int32
+
+// GetRevisions retrieves all revisions of a product from MongoDB, oldest first
+func (m manager) GetRevisions(productID string) ([]datastore.Revision, error) {
+	ctx, cancel := context.WithTimeout(m.parent(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := history.Find(ctx, bson.D{{Key: "ProductID", Value: productID}}, options.Find().SetSort(bson.D{{Key: "Version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("Unable to find product with id %s", productID)
+	}
+
+	revs := make([]datastore.Revision, 0, len(results))
+
+	for _, result := range results {
+		rev, err := unmarshalRevision(result)
+		if err != nil {
+			return nil, err
+		}
+
+		revs = append(revs, rev)
+	}
+
+	return revs, nil
+}
+
+// RollbackProduct restores the product in MongoDB as it was in the given version
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	ctx, cancel := context.WithTimeout(m.parent(), 10*time.Second)
+	defer cancel()
+
+	res := history.FindOne(ctx, bson.D{{Key: "ProductID", Value: productID}, {Key: "Version", Value: version}})
+
+	raw, err := res.DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return datastore.Revision{}, fmt.Errorf("Unable to find revision %d of product with id %s", version, productID)
+	}
+	if err != nil {
+		return datastore.Revision{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	rev, err := unmarshalRevision(raw)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	return m.write(productID, datastore.Rollback(rev))
+}
+
+// write applies the mutation to the current product and stores the result, together
+// with its revision, in a single transaction. The transaction is retried by MongoDB
+// when it conflicts with another write. Transactions need a replica set, so on a
+// standalone server the write falls back to conditional writes, which are retried when
+// another write changed the same product.
+func (m manager) write(productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	ctx, cancel := context.WithTimeout(m.parent(), 10*time.Second)
+	defer cancel()
+
+	return m.writeWith(func() (datastore.Revision, error) {
+		return m.writeTransaction(ctx, productID, mutate)
+	}, func() (datastore.Revision, error) {
+		return m.writeConditional(ctx, productID, mutate)
+	})
+}
+
+// writeWith makes a write with transaction, or with conditional once the server reported
+// that it doesn't support transactions. The outbox is only consistent with the products
+// when both are written in one transaction, so a manager with an outbox never falls back
+// and returns errTransactionsRequired instead.
+func (m manager) writeWith(transaction, conditional func() (datastore.Revision, error)) (datastore.Revision, error) {
+	if m.outbox || atomic.LoadInt32(&standalone) == 0 {
+		rev, err := transaction()
+		if !transactionsUnsupported(err) {
+			return rev, err
+		}
+
+		if m.outbox {
+			return datastore.Revision{}, errTransactionsRequired
+		}
+
+		if atomic.CompareAndSwapInt32(&standalone, 0, 1) {
+			logging.Default().Warn("MongoDB doesn't support transactions, products are written without them", "error", err)
+		}
+	}
+
+	return conditional()
+}
+
+// writeConditional applies the mutation without a transaction, and retries it when
+// another write changed the same product at the same time.
+func (m manager) writeConditional(ctx context.Context, productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * 50 * time.Millisecond)
+		}
+
+		rev, err := m.tryWrite(ctx, productID, mutate, true)
+		if err == errConflict && attempt < maxWriteRetries {
+			continue
+		}
+
+		return rev, err
+	}
+}
+
+// writeTransaction applies the mutation in a transaction.
+func (m manager) writeTransaction(ctx context.Context, productID string, mutate datastore.Mutation) (datastore.Revision, error) {
+	session, err := dbs.Database().Client().StartSession()
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+	defer session.EndSession(ctx)
+
+	res, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return m.tryWrite(sc, productID, mutate, false)
+	})
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	return res.(datastore.Revision), nil
+}
+
+// tryWrite reads the current product, applies the mutation and writes the result, as
+// part of the transaction in ctx. Without a transaction the product is only written if
+// it's still at the version it was read at, and errConflict is returned otherwise.
+func (m manager) tryWrite(ctx context.Context, productID string, mutate datastore.Mutation, conditional bool) (datastore.Revision, error) {
+	var current *datastore.Product
+	var version int
+
+	filter := bson.D{{Key: "SK", Value: productID}}
+
+	raw, err := dbs.FindOne(ctx, filter).DecodeBytes()
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// A product that was deleted continues with the version after its last revision
+		version, err = latestVersion(ctx, productID)
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+	case err != nil:
+		return datastore.Revision{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	default:
+		prod, err := unmarshalProduct(raw)
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+		current = &prod
+
+		// Products stored before revisions existed don't have a version
+		version = intValue(raw, "Version")
+	}
+
+	rev, err := mutate(current)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	rev.ProductID = productID
+	rev.Version = version + 1
+	rev.Actor = m.actor
+	rev.CreatedAt = time.Now().UTC()
+	rev.Previous = current
+
+	if conditional && current != nil {
+		filter = append(filter, versionCondition(version))
+	}
+
+	if err := writeProduct(ctx, filter, rev, conditional && current == nil, conditional); err != nil {
+		return datastore.Revision{}, err
+	}
+
+	doc, err := revisionDocument(rev)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	if _, err := history.InsertOne(ctx, doc); err != nil {
+		return datastore.Revision{}, err
+	}
+
+	// The change is published from the outbox, which is written in the same transaction
+	if m.outbox {
+		doc, err := outboxDocument(datastore.NewOutboxEntry(rev, current != nil))
+		if err != nil {
+			return datastore.Revision{}, err
+		}
+
+		if _, err := outboxEntries.InsertOne(ctx, doc); err != nil {
+			return datastore.Revision{}, err
+		}
+	}
+
+	return rev, nil
+}
+
+// writeProduct stores the product of the revision in the document that matches the
+// filter, or removes that document for a delete. A product that doesn't exist yet is
+// inserted when create is true, which fails on the unique index if another write
+// created it first. When conditional is true, errConflict is returned if no document
+// matched.
+func writeProduct(ctx context.Context, filter bson.D, rev datastore.Revision, create bool, conditional bool) error {
+	if rev.Operation == datastore.OperationDelete {
+		res, err := dbs.DeleteOne(ctx, filter)
+		if err == nil && conditional && res.DeletedCount == 0 {
+			return errConflict
+		}
+		return err
+	}
+
+	doc, err := productDocument(rev.Product, rev.Version)
+	if err != nil {
+		return err
+	}
+
+	if create {
+		_, err := dbs.InsertOne(ctx, doc)
+		if isDuplicateKey(err) {
+			return errConflict
+		}
+		return err
+	}
+
+	res, err := dbs.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(!conditional))
+	if err == nil && conditional && res.MatchedCount == 0 {
+		return errConflict
+	}
+	return err
+}
+
+// versionCondition matches a product at the given version. Products stored before
+// revisions existed don't have a version.
+func versionCondition(version int) bson.E {
+	if version == 0 {
+		return bson.E{Key: "Version", Value: bson.D{{Key: "$exists", Value: false}}}
+	}
+	return bson.E{Key: "Version", Value: version}
+}
+
+// transactionsUnsupported returns true if err reports that the server can't run
+// transactions, because it isn't part of a replica set.
+func transactionsUnsupported(err error) bool {
+	var e mongo.CommandError
+	return errors.As(err, &e) && e.Code == illegalOperation && strings.Contains(e.Message, "Transaction numbers")
+}
+
+// isDuplicateKey returns true if err reports that a write violated a unique index.
+func isDuplicateKey(err error) bool {
+	var e mongo.WriteException
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, we := range e.WriteErrors {
+		if we.Code == duplicateKey {
+			return true
+		}
+	}
+	return false
+}
+
+// latestVersion returns the version of the last revision of a product, or 0 if the
+// product doesn't have any revisions.
+func latestVersion(ctx context.Context, productID string) (int, error) {
+	res := history.FindOne(ctx, bson.D{{Key: "ProductID", Value: productID}}, options.FindOne().SetSort(bson.D{{Key: "Version", Value: -1}}))
+
+	raw, err := res.DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	return intValue(raw, "Version"), nil
+}
+
+// productDocument creates the MongoDB document of a product at the given version
+func productDocument(p datastore.Product, version int) (bson.D, error) {
+	fields, err := productFields(p)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.D{
+		{Key: "SK", Value: p.Item.ID},
+		{Key: "PK", Value: "PRODUCT"},
+	}
+	doc = append(doc, fields...)
+	doc = append(doc, bson.E{Key: "Version", Value: version})
+
+	return doc, nil
+}
+
+// revisionDocument creates the MongoDB document of a revision, which contains the same
+// product fields as the product document itself
+func revisionDocument(rev datastore.Revision) (bson.D, error) {
+	fields, err := productFields(rev.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.D{
+		{Key: "ProductID", Value: rev.ProductID},
+		{Key: "Version", Value: rev.Version},
+		{Key: "Operation", Value: string(rev.Operation)},
+		{Key: "Actor", Value: rev.Actor},
+		{Key: "CreatedAt", Value: rev.CreatedAt},
+		{Key: "RestoredFrom", Value: rev.RestoredFrom},
+	}
+	doc = append(doc, fields...)
+
+	return doc, nil
+}
+
+// productFields creates the fields that describe a product, without the keys. Open
+// sides of the window are left out.
+func productFields(p datastore.Product) (bson.D, error) {
+	payload, err := p.Item.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.D{
+		{Key: "Payload", Value: string(payload)},
+		{Key: "Status", Value: string(p.Status)},
+	}
+
+	if p.Window.PublishAt != nil {
+		doc = append(doc, bson.E{Key: "PublishAt", Value: *p.Window.PublishAt})
+	}
+	if p.Window.UnpublishAt != nil {
+		doc = append(doc, bson.E{Key: "UnpublishAt", Value: *p.Window.UnpublishAt})
+	}
+
+	return doc, nil
+}
+
+// unmarshalRevision creates a revision from a MongoDB document
+func unmarshalRevision(raw bson.Raw) (datastore.Revision, error) {
+	prod, err := unmarshalProduct(raw)
+	if err != nil {
+		return datastore.Revision{}, err
+	}
+
+	productID, _ := raw.Lookup("ProductID").StringValueOK()
+	operation, _ := raw.Lookup("Operation").StringValueOK()
+	actor, _ := raw.Lookup("Actor").StringValueOK()
+
+	rev := datastore.Revision{
+		ProductID:    productID,
+		Version:      intValue(raw, "Version"),
+		Operation:    datastore.Operation(operation),
+		Actor:        actor,
+		RestoredFrom: intValue(raw, "RestoredFrom"),
+		Product:      prod,
+	}
+
+	if t := timeValue(raw, "CreatedAt"); t != nil {
+		rev.CreatedAt = *t
+	}
+
+	return rev, nil
+}
+
+// intValue returns the number stored in the named field of the document, or 0 if
+// the document doesn't have the field.
+func intValue(raw bson.Raw, key string) int {
+	val := raw.Lookup(key)
+	if i, ok := val.Int32OK(); ok {
+		return int(i)
+	}
+	if i, ok := val.Int64OK(); ok {
+		return int(i)
+	}
+	return 0
+}
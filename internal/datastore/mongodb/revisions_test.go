@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTransactionsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "standalone", err: mongo.CommandError{Code: 20, Name: "IllegalOperation", Message: "Transaction numbers are only allowed on a replica set member or mongos"}, want: true},
+		{name: "other illegal operation", err: mongo.CommandError{Code: 20, Name: "IllegalOperation", Message: "something else"}, want: false},
+		{name: "write conflict", err: mongo.CommandError{Code: 112, Name: "WriteConflict"}, want: false},
+		{name: "other error", err: fmt.Errorf("connection reset"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := transactionsUnsupported(tt.err); got != tt.want {
+			t.Errorf("%s: transactionsUnsupported() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "duplicate key", err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}, want: true},
+		{name: "other write error", err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 121}}}, want: false},
+		{name: "other error", err: fmt.Errorf("connection reset"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isDuplicateKey(tt.err); got != tt.want {
+			t.Errorf("%s: isDuplicateKey() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVersionCondition(t *testing.T) {
+	tests := []struct {
+		version int
+		want    bson.E
+	}{
+		{version: 0, want: bson.E{Key: "Version", Value: bson.D{{Key: "$exists", Value: false}}}},
+		{version: 3, want: bson.E{Key: "Version", Value: 3}},
+	}
+
+	for _, tt := range tests {
+		got := versionCondition(tt.version)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("versionCondition(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestWriteWith(t *testing.T) {
+	unsupported := mongo.CommandError{Code: 20, Name: "IllegalOperation", Message: "Transaction numbers are only allowed on a replica set member or mongos"}
+	defer atomic.StoreInt32(&standalone, 0)
+
+	tests := []struct {
+		name            string
+		outbox          bool
+		standalone      int32
+		transactionErr  error
+		wantTransaction bool
+		wantConditional bool
+		wantErr         error
+	}{
+		{name: "transaction", wantTransaction: true},
+		{name: "transaction with outbox", outbox: true, wantTransaction: true},
+		{name: "standalone", transactionErr: unsupported, wantTransaction: true, wantConditional: true},
+		{name: "known standalone", standalone: 1, wantConditional: true},
+		{name: "standalone with outbox", outbox: true, transactionErr: unsupported, wantTransaction: true, wantErr: errTransactionsRequired},
+		{name: "known standalone with outbox", outbox: true, standalone: 1, transactionErr: unsupported, wantTransaction: true, wantErr: errTransactionsRequired},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&standalone, tt.standalone)
+
+		var transaction, conditional bool
+		m := manager{outbox: tt.outbox}
+		_, err := m.writeWith(func() (datastore.Revision, error) {
+			transaction = true
+			return datastore.Revision{}, tt.transactionErr
+		}, func() (datastore.Revision, error) {
+			conditional = true
+			return datastore.Revision{}, nil
+		})
+
+		if err != tt.wantErr {
+			t.Errorf("%s: writeWith() = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if transaction != tt.wantTransaction || conditional != tt.wantConditional {
+			t.Errorf("%s: transaction %t and conditional write %t, want %t and %t", tt.name, transaction, conditional, tt.wantTransaction, tt.wantConditional)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/schedule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// watermark implements the methods of the schedule Watermark interface, for the
+// scheduler with the name.
+type watermark struct {
+	name string
+}
+
+// NewWatermark creates a schedule watermark that is stored under name in the
+// catalog_schedule collection in MongoDB
+func NewWatermark(name string) schedule.Watermark {
+	connectOnce.Do(connect)
+	return watermark{name: name}
+}
+
+// Load retrieves the moment up to which the windows were checked from MongoDB
+func (w watermark) Load(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var doc struct {
+		CheckedUntil time.Time `bson:"CheckedUntil"`
+	}
+	err := scheduleWatermarks.FindOne(ctx, bson.D{{Key: "_id", Value: w.name}}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	return doc.CheckedUntil, err
+}
+
+// Save stores the moment up to which the windows were checked in MongoDB
+func (w watermark) Save(ctx context.Context, t time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := scheduleWatermarks.ReplaceOne(ctx, bson.D{{Key: "_id", Value: w.name}}, bson.D{
+		{Key: "_id", Value: w.name},
+		{Key: "CheckedUntil", Value: t.UTC()},
+	}, options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// monitor returns a command monitor that records a span for every command sent to
+// MongoDB as part of a traced request. The driver reports the end of a command with its
+// request ID, so the spans are kept by request ID until the command finishes.
+func monitor() *event.CommandMonitor {
+	var mu sync.Mutex
+	spans := make(map[int64]*tracing.Span)
+
+	end := func(requestID int64, err error) {
+		mu.Lock()
+		span, ok := spans[requestID]
+		delete(spans, requestID)
+		mu.Unlock()
+
+		if ok {
+			span.End(err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := tracing.StartChild(ctx, "mongodb."+evt.CommandName, tracing.KindClient)
+			if span == nil {
+				return
+			}
+
+			span.SetAttribute("db.system", "mongodb")
+			span.SetAttribute("db.name", evt.DatabaseName)
+			span.SetAttribute("db.operation", evt.CommandName)
+			if collection, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+				span.SetAttribute("db.mongodb.collection", collection)
+			}
+			span.SetAttribute("net.peer.name", evt.ConnectionID)
+
+			mu.Lock()
+			spans[evt.RequestID] = span
+			mu.Unlock()
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			end(evt.RequestID, nil)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			end(evt.RequestID, fmt.Errorf("%s", evt.Failure))
+		},
+	}
+}
@@ -0,0 +1,525 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/stream"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subscription receives the changes read by a Watcher.
+type Subscription struct {
+	name    string
+	changes chan stream.Change
+	done    chan struct{}
+}
+
+// Name returns the name the subscription was created with
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Changes returns the channel the changes are sent on. The channel is closed when the
+// watcher is closed.
+func (s *Subscription) Changes() <-chan stream.Change {
+	return s.changes
+}
+
+// Done tells the watcher the last change that was received has been handled. The
+// watcher sends the next change once every subscription is done with the current one.
+func (s *Subscription) Done() {
+	select {
+	case s.done <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot is the last known state of a product document
+type snapshot struct {
+	product datastore.Product
+	version int
+}
+
+// Watcher reads the changes to the catalog collection from its change stream, which
+// includes the changes made by other writers, and sends them to its subscriptions. The
+// resume token of the last change that every subscription is done with is stored in the
+// catalog_watch collection, so a watcher that is restarted continues where it left off
+// and every change is received at least once.
+//
+// The change stream only has the _id of a deleted document, so the watcher keeps the
+// product documents it read in the catalog_watch_products collection as well. A watcher
+// that resumes loads them from there, rather than from the catalog, so it still knows the
+// products that were deleted while it wasn't running. When the oplog no longer has the
+// change of the resume token, the watcher starts from now and sends the differences
+// between the stored documents and the catalog instead.
+type Watcher struct {
+	name string
+
+	mu   sync.Mutex
+	subs []*Subscription
+
+	// products are the product documents by their _id
+	products map[string]snapshot
+}
+
+// NewWatcher creates a watcher that stores its resume token under name, so watchers with
+// different names don't share their position in the change stream.
+func NewWatcher(name string) *Watcher {
+	connectOnce.Do(connect)
+	return &Watcher{
+		name: name,
+	}
+}
+
+// Subscribe creates a subscription that receives every change read after it was created.
+// The subscriptions must be created before the watcher runs, and every change must be
+// acknowledged with Done, or the watcher stops sending changes.
+func (w *Watcher) Subscribe(name string) *Subscription {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := &Subscription{
+		name:    name,
+		changes: make(chan stream.Change),
+		done:    make(chan struct{}, 1),
+	}
+	w.subs = append(w.subs, s)
+	return s
+}
+
+// Close closes the channels of the subscriptions, after Run has returned. The watcher
+// can't be used after it's closed.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range w.subs {
+		close(s.changes)
+	}
+	w.subs = nil
+}
+
+// Run reads the change stream, from the stored resume token or else from now, until ctx
+// is done or the stream fails. It returns nil when ctx is done, and the error of the
+// stream otherwise, in which case the caller can run it again.
+func (w *Watcher) Run(ctx context.Context) error {
+	token, err := w.loadToken(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading resume token: %s", err.Error())
+	}
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if token != nil {
+		opts.SetResumeAfter(token)
+	}
+
+	cs, err := dbs.Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if historyLost(err) {
+			return w.startOver(err)
+		}
+		return fmt.Errorf("error opening change stream: %s", err.Error())
+	}
+	defer cs.Close(context.Background())
+
+	// The documents are read after the stream is opened, so no change is missed between
+	// the two
+	if err := w.loadProducts(ctx, token != nil); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("error reading products: %s", err.Error())
+	}
+
+	for cs.Next(ctx) {
+		key := cs.Current.Lookup("documentKey", "_id").String()
+		c, ok, err := w.decode(cs.Current)
+		if err != nil {
+			return err
+		}
+
+		// The new document is stored before the change is sent, so the change can be
+		// read again after a restart. A deleted document is only removed once the resume
+		// token is past the delete, so a delete that is read again is still known.
+		if ok && c.New != nil {
+			doc := cs.Current.Lookup("fullDocument").Document()
+			if err := w.saveProduct(ctx, key, doc, c.Version); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("error saving product: %s", err.Error())
+			}
+		}
+
+		if ok {
+			if err := w.send(ctx, c); err != nil {
+				return nil
+			}
+		}
+
+		if err := w.saveToken(ctx, cs.ResumeToken()); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error saving resume token: %s", err.Error())
+		}
+
+		if ok && c.New == nil {
+			if err := w.removeProduct(ctx, key); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("error removing product: %s", err.Error())
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := cs.Err(); historyLost(err) {
+		return w.startOver(err)
+	}
+	return cs.Err()
+}
+
+// historyLost returns true if err reports that the oplog no longer has the change of the
+// resume token, so the change stream can't be resumed from it.
+func historyLost(err error) bool {
+	var e mongo.CommandError
+	return errors.As(err, &e) && e.Code == changeStreamHistoryLost
+}
+
+// startOver removes the resume token that can't be used anymore, so the next run starts
+// from now and catches up from the stored documents, and returns the error of the stream.
+func (w *Watcher) startOver(err error) error {
+	if rerr := w.removeToken(context.Background()); rerr != nil {
+		return fmt.Errorf("error removing resume token: %s", rerr.Error())
+	}
+	return fmt.Errorf("change stream can't be resumed, starting from now: %s", err.Error())
+}
+
+// send sends the change to every subscription and waits until all of them are done with
+// it. It only fails when ctx is done.
+func (w *Watcher) send(ctx context.Context, c stream.Change) error {
+	w.mu.Lock()
+	subs := w.subs
+	w.mu.Unlock()
+
+	for _, s := range subs {
+		// Drop an acknowledgement that is left over from a run that was stopped
+		select {
+		case <-s.done:
+		default:
+		}
+
+		select {
+		case s.changes <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// decode creates the change of a change stream event. It returns false for events that
+// don't change a product, like an update of a document that has been deleted since, and
+// an error when the stream can't continue.
+func (w *Watcher) decode(event bson.Raw) (stream.Change, bool, error) {
+	op, _ := event.Lookup("operationType").StringValueOK()
+	key := event.Lookup("documentKey", "_id").String()
+
+	c := stream.Change{
+		ID: event.Lookup("_id").String(),
+	}
+	if id, ok := event.Lookup("_id", "_data").StringValueOK(); ok {
+		c.ID = id
+	}
+	if t, _, ok := event.Lookup("clusterTime").TimestampOK(); ok {
+		c.Time = time.Unix(int64(t), 0).UTC()
+	}
+
+	switch op {
+	case "insert", "update", "replace":
+		doc, ok := event.Lookup("fullDocument").DocumentOK()
+		if !ok {
+			return stream.Change{}, false, nil
+		}
+
+		prod, err := unmarshalProduct(doc)
+		if err != nil {
+			logging.Default().Warn("skipping change that can't be unmarshalled", "collection", "catalog", "_id", key, "error", err)
+			return stream.Change{}, false, nil
+		}
+
+		c.Operation = datastore.OperationCreate
+		if old, ok := w.products[key]; ok {
+			c.Operation = datastore.OperationUpdate
+			c.Old = &old.product
+		}
+		c.New = &prod
+		c.Version = intValue(doc, "Version")
+		w.products[key] = snapshot{product: prod, version: c.Version}
+	case "delete":
+		old, ok := w.products[key]
+		if !ok {
+			logging.Default().Warn("skipping delete of a document that wasn't read", "collection", "catalog", "_id", key)
+			return stream.Change{}, false, nil
+		}
+		delete(w.products, key)
+
+		c.Operation = datastore.OperationDelete
+		c.Old = &old.product
+		if old.version > 0 {
+			c.Version = old.version + 1
+		}
+	default:
+		// The collection was dropped or renamed, which ends the stream. The resume token
+		// can't be used anymore, so the next run starts from now.
+		if err := w.removeToken(context.Background()); err != nil {
+			return stream.Change{}, false, fmt.Errorf("error removing resume token: %s", err.Error())
+		}
+		return stream.Change{}, false, fmt.Errorf("change stream ended by %s", op)
+	}
+
+	c.ProductID = c.Product().Item.ID
+	return c, true, nil
+}
+
+// loadProducts reads the products the watcher knows. A watcher that resumes loads the
+// documents it stored. The others read the catalog as it is now, and when they stored
+// documents before, like when the change stream couldn't be resumed, they send a change
+// for every product that changed since, so the targets catch up with the changes that
+// weren't read. The stored documents are only replaced after that, so a catch-up that is
+// stopped halfway is made again.
+func (w *Watcher) loadProducts(ctx context.Context, resume bool) error {
+	stored, err := w.storedProducts(ctx)
+	if err != nil {
+		return err
+	}
+	if resume && len(stored) > 0 {
+		w.products = stored
+		return nil
+	}
+
+	w.products = make(map[string]snapshot)
+	docs := make(map[string]bson.Raw)
+
+	cursor, err := dbs.Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		prod, err := unmarshalProduct(cursor.Current)
+		if err != nil {
+			skip(ctx, cursor.Current, err)
+			continue
+		}
+
+		key := cursor.Current.Lookup("_id").String()
+		w.products[key] = snapshot{
+			product: prod,
+			version: intValue(cursor.Current, "Version"),
+		}
+		docs[key] = append(bson.Raw(nil), cursor.Current...)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if len(stored) > 0 {
+		for _, c := range catchUp(stored, w.products, time.Now()) {
+			if err := w.send(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The documents stored by an earlier run are stale when the watcher starts from now
+	if _, err := watchedProducts.DeleteMany(ctx, bson.D{{Key: "Watcher", Value: w.name}}); err != nil {
+		return err
+	}
+	for key, doc := range docs {
+		if err := w.saveProduct(ctx, key, doc, w.products[key].version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storedProducts returns the product documents the watcher stored
+func (w *Watcher) storedProducts(ctx context.Context) (map[string]snapshot, error) {
+	products := make(map[string]snapshot)
+
+	cursor, err := watchedProducts.Find(ctx, bson.D{{Key: "Watcher", Value: w.name}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		doc, ok := cursor.Current.Lookup("Document").DocumentOK()
+		if !ok {
+			continue
+		}
+		prod, err := unmarshalProduct(doc)
+		if err != nil {
+			skip(ctx, doc, err)
+			continue
+		}
+
+		key, _ := cursor.Current.Lookup("Key").StringValueOK()
+		products[key] = snapshot{
+			product: prod,
+			version: intValue(cursor.Current, "Version"),
+		}
+	}
+
+	return products, cursor.Err()
+}
+
+// catchUp returns the changes that turn the products the watcher knew into the products
+// that are in the catalog now, ordered by product ID. The ID of a change is derived from
+// the product and its version, so a catch-up that is made again has the same IDs.
+func catchUp(known map[string]snapshot, current map[string]snapshot, now time.Time) []stream.Change {
+	changes := make([]stream.Change, 0)
+
+	for key, cur := range current {
+		cur := cur
+		c := stream.Change{
+			ProductID: cur.product.Item.ID,
+			Operation: datastore.OperationCreate,
+			New:       &cur.product,
+			Version:   cur.version,
+			Time:      now.UTC(),
+		}
+		if old, ok := known[key]; ok {
+			if old.version == cur.version && reflect.DeepEqual(old.product, cur.product) {
+				continue
+			}
+			c.Operation = datastore.OperationUpdate
+			c.Old = &old.product
+		}
+		c.ID = fmt.Sprintf("catchup/%s/%d", key, c.Version)
+		changes = append(changes, c)
+	}
+
+	for key, old := range known {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		old := old
+		c := stream.Change{
+			ProductID: old.product.Item.ID,
+			Operation: datastore.OperationDelete,
+			Old:       &old.product,
+			Time:      now.UTC(),
+		}
+		if old.version > 0 {
+			c.Version = old.version + 1
+		}
+		c.ID = fmt.Sprintf("catchup/%s/%d", key, c.Version)
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ProductID < changes[j].ProductID
+	})
+	return changes
+}
+
+// saveProduct stores the product document with the given _id as the watcher read it
+func (w *Watcher) saveProduct(ctx context.Context, key string, doc bson.Raw, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	id := w.name + "/" + key
+	_, err := watchedProducts.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "Watcher", Value: w.name},
+		{Key: "Key", Value: key},
+		{Key: "Document", Value: doc},
+		{Key: "Version", Value: version},
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+// removeProduct removes the stored product document with the given _id
+func (w *Watcher) removeProduct(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := watchedProducts.DeleteOne(ctx, bson.D{{Key: "_id", Value: w.name + "/" + key}})
+	return err
+}
+
+// loadToken returns the stored resume token, or nil if there isn't one
+func (w *Watcher) loadToken(ctx context.Context) (bson.Raw, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	raw, err := resumeTokens.FindOne(ctx, bson.D{{Key: "_id", Value: w.name}}).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	token, ok := raw.Lookup("Token").DocumentOK()
+	if !ok {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// saveToken stores the resume token
+func (w *Watcher) saveToken(ctx context.Context, token bson.Raw) error {
+	if token == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := resumeTokens.ReplaceOne(ctx, bson.D{{Key: "_id", Value: w.name}}, bson.D{
+		{Key: "_id", Value: w.name},
+		{Key: "Token", Value: token},
+		{Key: "UpdatedAt", Value: time.Now().UTC()},
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+// removeToken removes the stored resume token
+func (w *Watcher) removeToken(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := resumeTokens.DeleteOne(ctx, bson.D{{Key: "_id", Value: w.name}})
+	return err
+}
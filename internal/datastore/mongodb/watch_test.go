@@ -0,0 +1,163 @@
+package mongodb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// changeEvent returns a change stream event for the document with the given _id, with
+// the product as its full document unless productID is empty
+func changeEvent(t *testing.T, op string, id primitive.ObjectID, productID string, version int) bson.Raw {
+	t.Helper()
+
+	event := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: fmt.Sprintf("%s-%s-%d", op, productID, version)}}},
+		{Key: "operationType", Value: op},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1590000000}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+	}
+	if productID != "" {
+		event = append(event, bson.E{Key: "fullDocument", Value: bson.D{
+			{Key: "_id", Value: id},
+			{Key: "SK", Value: productID},
+			{Key: "Payload", Value: fmt.Sprintf(`{"id":%q,"name":"Bottle","price":10}`, productID)},
+			{Key: "Version", Value: version},
+		}})
+	}
+
+	raw, err := bson.Marshal(event)
+	if err != nil {
+		t.Fatalf("error marshalling event: %s", err.Error())
+	}
+	return raw
+}
+
+func TestDecode(t *testing.T) {
+	id1, id2 := primitive.NewObjectID(), primitive.NewObjectID()
+
+	// The watcher resumes with a product it stored before, which was deleted while it
+	// wasn't running
+	stored := datastore.Product{Item: acmeserverless.CatalogItem{ID: "p2"}, Status: datastore.StatusPublished}
+	w := &Watcher{name: "test", products: map[string]snapshot{
+		bson.RawValue{Type: bson.TypeObjectID, Value: id2[:]}.String(): {product: stored, version: 3},
+	}}
+
+	tests := []struct {
+		name      string
+		event     bson.Raw
+		ok        bool
+		operation datastore.Operation
+		productID string
+		version   int
+		old       bool
+	}{
+		{name: "insert", event: changeEvent(t, "insert", id1, "p1", 1), ok: true, operation: datastore.OperationCreate, productID: "p1", version: 1},
+		{name: "update", event: changeEvent(t, "update", id1, "p1", 2), ok: true, operation: datastore.OperationUpdate, productID: "p1", version: 2, old: true},
+		{name: "update of a deleted document", event: changeEvent(t, "update", id1, "", 0), ok: false},
+		{name: "delete", event: changeEvent(t, "delete", id1, "", 0), ok: true, operation: datastore.OperationDelete, productID: "p1", version: 3, old: true},
+		{name: "delete again", event: changeEvent(t, "delete", id1, "", 0), ok: false},
+		{name: "delete of a stored product", event: changeEvent(t, "delete", id2, "", 0), ok: true, operation: datastore.OperationDelete, productID: "p2", version: 4, old: true},
+	}
+
+	for _, tt := range tests {
+		c, ok, err := w.decode(tt.event)
+		if err != nil || ok != tt.ok {
+			t.Errorf("%s: decode() = %v, %v, want %v", tt.name, ok, err, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if c.Operation != tt.operation || c.ProductID != tt.productID || c.Version != tt.version || (c.Old != nil) != tt.old {
+			t.Errorf("%s: decode() = %s %s version %d old %v, want %s %s version %d old %v", tt.name, c.Operation, c.ProductID, c.Version, c.Old != nil, tt.operation, tt.productID, tt.version, tt.old)
+		}
+		if c.ID == "" || c.Time.IsZero() {
+			t.Errorf("%s: decode() ID %q time %s, want the ID and time of the event", tt.name, c.ID, c.Time)
+		}
+	}
+
+	if len(w.products) != 0 {
+		t.Errorf("products after the deletes = %v, want none", w.products)
+	}
+}
+
+func TestHistoryLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil},
+		{name: "history lost", err: mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"}, want: true},
+		{name: "wrapped", err: fmt.Errorf("getMore: %w", mongo.CommandError{Code: 286}), want: true},
+		{name: "other command error", err: mongo.CommandError{Code: 280, Name: "ChangeStreamFatalError"}},
+		{name: "other error", err: fmt.Errorf("connection reset")},
+	}
+
+	for _, tt := range tests {
+		if got := historyLost(tt.err); got != tt.want {
+			t.Errorf("%s: historyLost() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	product := func(id, name string) datastore.Product {
+		return datastore.Product{Item: acmeserverless.CatalogItem{ID: id, Name: name}, Status: datastore.StatusPublished}
+	}
+
+	known := map[string]snapshot{
+		"a": {product: product("p1", "Bottle"), version: 1},
+		"b": {product: product("p2", "Mat"), version: 4},
+		"c": {product: product("p3", "Band"), version: 2},
+	}
+	current := map[string]snapshot{
+		"a": {product: product("p1", "Bottle"), version: 1},
+		"b": {product: product("p2", "Yoga mat"), version: 5},
+		"d": {product: product("p4", "Towel"), version: 1},
+	}
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		productID string
+		operation datastore.Operation
+		version   int
+		old       bool
+		new       bool
+	}{
+		{productID: "p2", operation: datastore.OperationUpdate, version: 5, old: true, new: true},
+		{productID: "p3", operation: datastore.OperationDelete, version: 3, old: true},
+		{productID: "p4", operation: datastore.OperationCreate, version: 1, new: true},
+	}
+
+	changes := catchUp(known, current, now)
+	if len(changes) != len(tests) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(tests))
+	}
+	for i, tt := range tests {
+		c := changes[i]
+		if c.ProductID != tt.productID || c.Operation != tt.operation || c.Version != tt.version || (c.Old != nil) != tt.old || (c.New != nil) != tt.new || !c.Time.Equal(now) {
+			t.Errorf("change %d: got %+v, want %s %s version %d", i, c, tt.operation, tt.productID, tt.version)
+		}
+	}
+
+	// A catch-up that is made again has the same IDs, so targets can drop duplicates
+	again := catchUp(known, current, now.Add(time.Minute))
+	for i := range changes {
+		if changes[i].ID != again[i].ID {
+			t.Errorf("change %d: got IDs %s and %s", i, changes[i].ID, again[i].ID)
+		}
+	}
+
+	if got := catchUp(current, current, now); len(got) != 0 {
+		t.Errorf("got %d changes when nothing changed, want none", len(got))
+	}
+}
@@ -0,0 +1,284 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/webhook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// webhooks is an empty struct that implements the methods of the webhook Store interface.
+type webhooks struct{}
+
+// NewWebhooks creates a webhook store using the catalog_webhooks and
+// catalog_webhook_deliveries collections in MongoDB as backend
+func NewWebhooks() webhook.Store {
+	connectOnce.Do(connect)
+	return webhooks{}
+}
+
+// Ping checks that MongoDB can be reached
+func (w webhooks) Ping() error {
+	return ping(context.Background())
+}
+
+// AddSubscription stores a single subscription in MongoDB
+func (w webhooks) AddSubscription(ctx context.Context, s webhook.Subscription) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := webhookSubscriptions.InsertOne(ctx, bson.D{
+		{Key: "_id", Value: s.ID},
+		{Key: "URL", Value: s.URL},
+		{Key: "Events", Value: s.Events},
+		{Key: "Secret", Value: s.Secret},
+		{Key: "CreatedAt", Value: s.CreatedAt.UTC()},
+		{Key: "CreatedBy", Value: s.CreatedBy},
+	})
+	return err
+}
+
+// GetSubscription retrieves a single subscription from MongoDB
+func (w webhooks) GetSubscription(ctx context.Context, id string) (webhook.Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	raw, err := webhookSubscriptions.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return webhook.Subscription{}, webhook.ErrNotFound
+	}
+	if err != nil {
+		return webhook.Subscription{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	return unmarshalSubscription(raw)
+}
+
+// Subscriptions retrieves all subscriptions from MongoDB
+func (w webhooks) Subscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := webhookSubscriptions.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	subs := make([]webhook.Subscription, 0, len(results))
+	for _, result := range results {
+		s, err := unmarshalSubscription(result)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+
+	return subs, nil
+}
+
+// RemoveSubscription deletes a single subscription from MongoDB
+func (w webhooks) RemoveSubscription(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := webhookSubscriptions.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+// AddDelivery stores a new delivery in MongoDB, unless a delivery with its ID is stored
+func (w webhooks) AddDelivery(ctx context.Context, d webhook.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := webhookDeliveries.InsertOne(ctx, deliveryDoc(d))
+	if isDuplicateKey(err) {
+		return nil
+	}
+	return err
+}
+
+// SaveDelivery stores a delivery in MongoDB, replacing its previous state
+func (w webhooks) SaveDelivery(ctx context.Context, d webhook.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := webhookDeliveries.ReplaceOne(ctx, bson.D{{Key: "_id", Value: d.ID}}, deliveryDoc(d), options.Replace().SetUpsert(true))
+	return err
+}
+
+// ClaimDelivery moves the next attempt of a pending delivery in MongoDB to until, with an
+// update that only matches while the delivery has the next attempt it was read with
+func (w webhooks) ClaimDelivery(ctx context.Context, d webhook.Delivery, until time.Time) (bool, error) {
+	if d.NextAttemptAt == nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	res, err := webhookDeliveries.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: d.ID},
+		{Key: "Status", Value: string(webhook.StatusPending)},
+		{Key: "NextAttemptAt", Value: d.NextAttemptAt.UTC()},
+	}, bson.D{{Key: "$set", Value: bson.D{{Key: "NextAttemptAt", Value: until.UTC()}}}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// deliveryDoc creates the MongoDB document of a delivery
+func deliveryDoc(d webhook.Delivery) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: d.ID},
+		{Key: "SubscriptionID", Value: d.SubscriptionID},
+		{Key: "EventID", Value: d.EventID},
+		{Key: "EventType", Value: d.EventType},
+		{Key: "Payload", Value: d.Payload},
+		{Key: "Status", Value: string(d.Status)},
+		{Key: "Attempts", Value: d.Attempts},
+		{Key: "StatusCode", Value: d.StatusCode},
+		{Key: "Error", Value: d.Error},
+		{Key: "CreatedAt", Value: d.CreatedAt.UTC()},
+	}
+	if d.NextAttemptAt != nil {
+		doc = append(doc, bson.E{Key: "NextAttemptAt", Value: d.NextAttemptAt.UTC()})
+	}
+	if d.CompletedAt != nil {
+		doc = append(doc, bson.E{Key: "CompletedAt", Value: d.CompletedAt.UTC()})
+	}
+	return doc
+}
+
+// DueDeliveries retrieves the pending deliveries from MongoDB that are due at now, the
+// ones that are due the longest first
+func (w webhooks) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	filter := bson.D{
+		{Key: "Status", Value: string(webhook.StatusPending)},
+		{Key: "NextAttemptAt", Value: bson.D{{Key: "$lte", Value: now.UTC()}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "NextAttemptAt", Value: 1}}).SetLimit(int64(limit))
+
+	return findDeliveries(ctx, filter, opts)
+}
+
+// Deliveries retrieves the newest deliveries of a subscription from MongoDB
+func (w webhooks) Deliveries(ctx context.Context, subscriptionID string, limit int) ([]webhook.Delivery, error) {
+	filter := bson.D{{Key: "SubscriptionID", Value: subscriptionID}}
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}).SetLimit(int64(limit))
+
+	return findDeliveries(ctx, filter, opts)
+}
+
+// findDeliveries reads the deliveries matching the filter
+func findDeliveries(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]webhook.Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := webhookDeliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]webhook.Delivery, 0, len(results))
+	for _, result := range results {
+		d, err := unmarshalDelivery(result)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// unmarshalSubscription creates a subscription from a MongoDB document
+func unmarshalSubscription(raw bson.Raw) (webhook.Subscription, error) {
+	var s webhook.Subscription
+
+	s.ID, _ = raw.Lookup("_id").StringValueOK()
+	s.URL, _ = raw.Lookup("URL").StringValueOK()
+	s.Secret, _ = raw.Lookup("Secret").StringValueOK()
+	s.CreatedBy, _ = raw.Lookup("CreatedBy").StringValueOK()
+	if t, ok := raw.Lookup("CreatedAt").TimeOK(); ok {
+		s.CreatedAt = t.UTC()
+	}
+
+	s.Events = make([]string, 0)
+	if arr, ok := raw.Lookup("Events").ArrayOK(); ok {
+		values, err := arr.Values()
+		if err != nil {
+			return webhook.Subscription{}, fmt.Errorf("invalid Events: %s", err.Error())
+		}
+		for _, v := range values {
+			if e, ok := v.StringValueOK(); ok {
+				s.Events = append(s.Events, e)
+			}
+		}
+	}
+
+	if s.ID == "" {
+		return webhook.Subscription{}, fmt.Errorf("webhook subscription has no _id")
+	}
+
+	return s, nil
+}
+
+// unmarshalDelivery creates a delivery from a MongoDB document
+func unmarshalDelivery(raw bson.Raw) (webhook.Delivery, error) {
+	var d webhook.Delivery
+
+	d.ID, _ = raw.Lookup("_id").StringValueOK()
+	d.SubscriptionID, _ = raw.Lookup("SubscriptionID").StringValueOK()
+	d.EventID, _ = raw.Lookup("EventID").StringValueOK()
+	d.EventType, _ = raw.Lookup("EventType").StringValueOK()
+	d.Error, _ = raw.Lookup("Error").StringValueOK()
+	d.Attempts = intValue(raw, "Attempts")
+	d.StatusCode = intValue(raw, "StatusCode")
+
+	if _, payload, ok := raw.Lookup("Payload").BinaryOK(); ok {
+		d.Payload = payload
+	}
+	if status, ok := raw.Lookup("Status").StringValueOK(); ok {
+		d.Status = webhook.Status(status)
+	}
+	if t, ok := raw.Lookup("CreatedAt").TimeOK(); ok {
+		d.CreatedAt = t.UTC()
+	}
+	if t, ok := raw.Lookup("NextAttemptAt").TimeOK(); ok {
+		t = t.UTC()
+		d.NextAttemptAt = &t
+	}
+	if t, ok := raw.Lookup("CompletedAt").TimeOK(); ok {
+		t = t.UTC()
+		d.CompletedAt = &t
+	}
+
+	if d.ID == "" {
+		return webhook.Delivery{}, fmt.Errorf("webhook delivery has no _id")
+	}
+
+	return d, nil
+}
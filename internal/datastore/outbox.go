@@ -0,0 +1,59 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+)
+
+// OutboxEntry is a change to a product that still has to be published. It's written in
+// the same transaction as the change itself, so a change is never stored without its
+// entry, or the other way around.
+type OutboxEntry struct {
+	// ID identifies the entry. It's the same every time the entry is published, so the
+	// consumers can use it to drop duplicates.
+	ID string
+
+	// Existed is true if the product existed before the change
+	Existed bool
+
+	// Revision is the revision the change created
+	Revision Revision
+
+	// Published are the names of the publishers the entry was published to already, when
+	// it's published to more than one
+	Published []string
+}
+
+// PublishedTo returns true if the entry was published to the publisher with the name
+func (e OutboxEntry) PublishedTo(name string) bool {
+	for _, p := range e.Published {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewOutboxEntry creates the entry for a change that created rev.
+func NewOutboxEntry(rev Revision, existed bool) OutboxEntry {
+	return OutboxEntry{
+		ID:       uuid.Must(uuid.NewV4()).String(),
+		Existed:  existed,
+		Revision: rev,
+	}
+}
+
+// Outbox gives access to the changes that still have to be published. Entries are
+// removed once they have been published, so an entry is published at least once.
+type Outbox interface {
+	// Pending returns up to limit entries that haven't been removed yet, oldest first,
+	// starting after the entry after, or at the oldest entry if it's nil
+	Pending(ctx context.Context, after *OutboxEntry, limit int) ([]OutboxEntry, error)
+
+	// MarkPublished records that an entry was published to the publishers with the names
+	MarkPublished(ctx context.Context, e OutboxEntry, names []string) error
+
+	// Remove deletes an entry after it has been published
+	Remove(ctx context.Context, e OutboxEntry) error
+}
@@ -0,0 +1,174 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// Status is the lifecycle state of a product in the catalog.
+type Status string
+
+const (
+	// StatusDraft products are being prepared and aren't visible on the storefront
+	StatusDraft Status = "draft"
+
+	// StatusPublished products are visible on the storefront
+	StatusPublished Status = "published"
+
+	// StatusArchived products are no longer sold, but are kept for the order history
+	StatusArchived Status = "archived"
+)
+
+// transitions lists, for every status, the statuses a product can move to.
+var transitions = map[Status][]Status{
+	StatusDraft:     {StatusPublished, StatusArchived},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft},
+}
+
+// ParseStatus returns the Status matching the name, or an error if the status
+// doesn't exist. An empty name is treated as published, which is the status of
+// all products stored before the lifecycle existed.
+func ParseStatus(name string) (Status, error) {
+	switch s := Status(name); s {
+	case "":
+		return StatusPublished, nil
+	case StatusDraft, StatusPublished, StatusArchived:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown status %q, must be one of draft, published or archived", name)
+	}
+}
+
+// TransitionError is returned when a product can't move from its current status to the
+// next status.
+type TransitionError struct {
+	// Current is the status of the product
+	Current Status
+
+	// Next is the status the product can't move to
+	Next Status
+}
+
+// Error returns the statuses of the transition
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("a product can't move from %s to %s", e.Current, e.Next)
+}
+
+// CanTransition returns a *TransitionError if a product can't move from the current
+// status to the next status. Archived products have to go back to draft before they can
+// be published again.
+func CanTransition(current Status, next Status) error {
+	for _, s := range transitions[current] {
+		if s == next {
+			return nil
+		}
+	}
+
+	return &TransitionError{Current: current, Next: next}
+}
+
+// Window is the period in which a published product is available on the storefront,
+// for example for product launches and limited editions. A missing start or end means
+// the window is open on that side.
+type Window struct {
+	// PublishAt is the moment the product goes live
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+
+	// UnpublishAt is the moment the product is taken off the storefront
+	UnpublishAt *time.Time `json:"unpublishAt,omitempty"`
+}
+
+// Validate returns an error if the window ends before it starts.
+func (w Window) Validate() error {
+	if w.PublishAt != nil && w.UnpublishAt != nil && !w.UnpublishAt.After(*w.PublishAt) {
+		return fmt.Errorf("unpublishAt must be after publishAt")
+	}
+	return nil
+}
+
+// Started returns true if the window has started at the given time.
+func (w Window) Started(now time.Time) bool {
+	return w.PublishAt == nil || !now.Before(*w.PublishAt)
+}
+
+// Ended returns true if the window has ended at the given time.
+func (w Window) Ended(now time.Time) bool {
+	return w.UnpublishAt != nil && !now.Before(*w.UnpublishAt)
+}
+
+// Product is a catalog item as it's kept in the data store, together with the
+// lifecycle information that decides who gets to see it.
+type Product struct {
+	// Item is the catalog item as it's shown on the storefront
+	Item acmeserverless.CatalogItem
+
+	// Status is the lifecycle state of the product
+	Status Status
+
+	// Window limits when a published product is available
+	Window Window
+}
+
+// Listed returns true if the product is shown in the public listings of the storefront
+// at the given time.
+func (p Product) Listed(now time.Time) bool {
+	return p.Status == StatusPublished && p.Window.Started(now) && !p.Window.Ended(now)
+}
+
+// Visible returns true if the product can be looked up by its ID by the public at the
+// given time. Next to listed products, this includes archived products and products
+// whose window has ended, so orders that contain them can still show their details.
+func (p Product) Visible(now time.Time) bool {
+	switch p.Status {
+	case StatusPublished:
+		return p.Window.Started(now)
+	case StatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
+// productJSON is the JSON representation of a Product, with the fields of the catalog
+// item at the top level so it can be read as a regular CatalogItem as well.
+type productJSON struct {
+	acmeserverless.CatalogItem
+	Window
+	Status Status `json:"status"`
+}
+
+// MarshalJSON returns the JSON encoding of Product
+func (p Product) MarshalJSON() ([]byte, error) {
+	return json.Marshal(productJSON{
+		CatalogItem: p.Item,
+		Window:      p.Window,
+		Status:      p.Status,
+	})
+}
+
+// UnmarshalJSON parses the JSON-encoded data and stores the result in the Product. A
+// missing status is treated as published.
+func (p *Product) UnmarshalJSON(data []byte) error {
+	var r productJSON
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	status, err := ParseStatus(string(r.Status))
+	if err != nil {
+		return err
+	}
+
+	if err := r.Window.Validate(); err != nil {
+		return err
+	}
+
+	p.Item = r.CatalogItem
+	p.Status = status
+	p.Window = r.Window
+	return nil
+}
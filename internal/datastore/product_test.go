@@ -0,0 +1,122 @@
+package datastore
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		current Status
+		next    Status
+		allowed bool
+	}{
+		{StatusDraft, StatusPublished, true},
+		{StatusDraft, StatusArchived, true},
+		{StatusDraft, StatusDraft, false},
+		{StatusPublished, StatusDraft, true},
+		{StatusPublished, StatusArchived, true},
+		{StatusPublished, StatusPublished, false},
+		{StatusArchived, StatusDraft, true},
+		{StatusArchived, StatusPublished, false},
+		{StatusArchived, StatusArchived, false},
+		{Status("deleted"), StatusDraft, false},
+	}
+
+	for _, tt := range tests {
+		err := CanTransition(tt.current, tt.next)
+		if tt.allowed {
+			if err != nil {
+				t.Errorf("CanTransition(%s, %s) = %v, want nil", tt.current, tt.next, err)
+			}
+			continue
+		}
+
+		var te *TransitionError
+		if !errors.As(err, &te) || te.Current != tt.current || te.Next != tt.next {
+			t.Errorf("CanTransition(%s, %s) = %v, want a *TransitionError", tt.current, tt.next, err)
+		}
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *Product
+		status  Status
+		wantErr bool
+	}{
+		{name: "missing product", current: nil, status: StatusPublished, wantErr: true},
+		{name: "publish draft", current: &Product{Status: StatusDraft}, status: StatusPublished},
+		{name: "publish archived", current: &Product{Status: StatusArchived}, status: StatusPublished, wantErr: true},
+		{name: "archive published", current: &Product{Status: StatusPublished}, status: StatusArchived},
+	}
+
+	for _, tt := range tests {
+		rev, err := SetStatus("1", tt.status)(tt.current)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if rev.Operation != OperationStatus || rev.Product.Status != tt.status {
+			t.Errorf("%s: got operation %s and status %s, want %s and %s", tt.name, rev.Operation, rev.Product.Status, OperationStatus, tt.status)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Status
+		wantErr bool
+	}{
+		{name: "", want: StatusPublished},
+		{name: "draft", want: StatusDraft},
+		{name: "published", want: StatusPublished},
+		{name: "archived", want: StatusArchived},
+		{name: "Draft", wantErr: true},
+		{name: "deleted", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStatus(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStatus(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestListed(t *testing.T) {
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		product Product
+		want    bool
+	}{
+		{name: "published", product: Product{Status: StatusPublished}, want: true},
+		{name: "draft", product: Product{Status: StatusDraft}, want: false},
+		{name: "archived", product: Product{Status: StatusArchived}, want: false},
+		{name: "window started", product: Product{Status: StatusPublished, Window: Window{PublishAt: &before}}, want: true},
+		{name: "window not started", product: Product{Status: StatusPublished, Window: Window{PublishAt: &after}}, want: false},
+		{name: "window starts now", product: Product{Status: StatusPublished, Window: Window{PublishAt: &now}}, want: true},
+		{name: "window ended", product: Product{Status: StatusPublished, Window: Window{UnpublishAt: &before}}, want: false},
+		{name: "window ends now", product: Product{Status: StatusPublished, Window: Window{UnpublishAt: &now}}, want: false},
+		{name: "draft in window", product: Product{Status: StatusDraft, Window: Window{PublishAt: &before, UnpublishAt: &after}}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.product.Listed(now); got != tt.want {
+			t.Errorf("%s: Listed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// Operation is the kind of write that created a revision of a product.
+type Operation string
+
+const (
+	// OperationCreate adds a product that didn't exist yet
+	OperationCreate Operation = "create"
+
+	// OperationUpdate replaces an existing product
+	OperationUpdate Operation = "update"
+
+	// OperationStatus moves a product to a new status
+	OperationStatus Operation = "status"
+
+	// OperationWindow changes the availability window of a product
+	OperationWindow Operation = "window"
+
+	// OperationDelete permanently removes a product
+	OperationDelete Operation = "delete"
+
+	// OperationRollback restores an earlier revision of a product
+	OperationRollback Operation = "rollback"
+)
+
+// Revision is an immutable copy of a product as it was after a single write. Every write
+// to a product creates a revision with the next version, starting at 1.
+type Revision struct {
+	// ProductID is the unique identifier of the product
+	ProductID string `json:"productId"`
+
+	// Version is the number of the revision
+	Version int `json:"version"`
+
+	// Operation is the kind of write that created the revision
+	Operation Operation `json:"operation"`
+
+	// Actor is who made the change, if that's known
+	Actor string `json:"actor,omitempty"`
+
+	// CreatedAt is the moment the change was made
+	CreatedAt time.Time `json:"createdAt"`
+
+	// RestoredFrom is the version that was restored by a rollback
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+
+	// Product is the product after the change. For a delete it's the product as it
+	// was removed, so it can be restored by a rollback.
+	Product Product `json:"product"`
+
+	// Previous is the product as it was before the change, or nil if it didn't exist.
+	// It's only set on the revision a write returns, so wrappers of a Manager know what
+	// the change did without reading the product again. It isn't stored.
+	Previous *Product `json:"-"`
+}
+
+// Mutation computes a single write to a product from the current product, which is nil
+// if the product doesn't exist. It returns the revision the write creates, the data store
+// fills in the product ID, version, actor and time.
+type Mutation func(current *Product) (Revision, error)
+
+// Put creates or replaces a product.
+func Put(p Product) Mutation {
+	return func(current *Product) (Revision, error) {
+		status, err := ParseStatus(string(p.Status))
+		if err != nil {
+			return Revision{}, err
+		}
+		p.Status = status
+
+		op := OperationUpdate
+		if current == nil {
+			op = OperationCreate
+		}
+
+		return Revision{Operation: op, Product: p}, nil
+	}
+}
+
+// SetItem replaces the catalog item of an existing product, keeping its status and
+// availability window as they are when it's written.
+func SetItem(item acmeserverless.CatalogItem) Mutation {
+	return func(current *Product) (Revision, error) {
+		if current == nil {
+			return Revision{}, fmt.Errorf("Unable to find product with id %s", item.ID)
+		}
+
+		p := *current
+		p.Item = item
+		return Revision{Operation: OperationUpdate, Product: p}, nil
+	}
+}
+
+// SetStatus moves an existing product to a new status, if the lifecycle allows that
+// transition. It's checked against the product the write is applied to, so concurrent
+// writes can't make a transition that isn't allowed.
+func SetStatus(productID string, status Status) Mutation {
+	return func(current *Product) (Revision, error) {
+		if current == nil {
+			return Revision{}, fmt.Errorf("Unable to find product with id %s", productID)
+		}
+
+		if err := CanTransition(current.Status, status); err != nil {
+			return Revision{}, err
+		}
+
+		p := *current
+		p.Status = status
+		return Revision{Operation: OperationStatus, Product: p}, nil
+	}
+}
+
+// SetWindow changes the availability window of an existing product.
+func SetWindow(productID string, window Window) Mutation {
+	return func(current *Product) (Revision, error) {
+		if current == nil {
+			return Revision{}, fmt.Errorf("Unable to find product with id %s", productID)
+		}
+
+		p := *current
+		p.Window = window
+		return Revision{Operation: OperationWindow, Product: p}, nil
+	}
+}
+
+// Delete removes an existing product.
+func Delete(productID string) Mutation {
+	return func(current *Product) (Revision, error) {
+		if current == nil {
+			return Revision{}, fmt.Errorf("Unable to find product with id %s", productID)
+		}
+
+		return Revision{Operation: OperationDelete, Product: *current}, nil
+	}
+}
+
+// Rollback restores the product in an earlier revision, whether the product still
+// exists or has been deleted since.
+func Rollback(rev Revision) Mutation {
+	return func(current *Product) (Revision, error) {
+		return Revision{Operation: OperationRollback, RestoredFrom: rev.Version, Product: rev.Product}, nil
+	}
+}
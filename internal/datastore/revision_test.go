@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func TestSetItem(t *testing.T) {
+	start := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	current := &Product{
+		Item:   acmeserverless.CatalogItem{ID: "1", Name: "Bottle"},
+		Status: StatusDraft,
+		Window: Window{PublishAt: &start},
+	}
+
+	rev, err := SetItem(acmeserverless.CatalogItem{ID: "1", Name: "Water bottle"})(current)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rev.Operation != OperationUpdate || rev.Product.Item.Name != "Water bottle" {
+		t.Errorf("got operation %s and name %q, want %s and %q", rev.Operation, rev.Product.Item.Name, OperationUpdate, "Water bottle")
+	}
+	if rev.Product.Status != StatusDraft || rev.Product.Window.PublishAt != &start {
+		t.Errorf("the status and window of the product weren't kept: %+v", rev.Product)
+	}
+
+	if _, err := SetItem(acmeserverless.CatalogItem{ID: "2"})(nil); err == nil {
+		t.Errorf("expected an error for a product that doesn't exist")
+	}
+}
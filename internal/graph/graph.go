@@ -0,0 +1,410 @@
+// Package graph is the GraphQL API of the catalog of the ACME Serverless Fitness Shop. The
+// schema describes the products as they're shown on the storefront, with queries to list,
+// look up and search them, and mutations for the changes the REST API makes. The
+// resolvers read and change the catalog through the datastore.Manager of the request.
+// Requests are parsed, validated and executed, introspection included, by graphql-go.
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Session is the state of a single GraphQL request that is shared by its resolvers.
+type Session struct {
+	// DB reads the catalog as part of the request
+	DB datastore.Manager
+
+	// Writer changes the catalog on behalf of the caller and records the changes in the
+	// audit trail. It's nil when the caller isn't allowed to change the catalog.
+	Writer datastore.Manager
+
+	// Denied is the reason the caller isn't allowed to change the catalog, like missing
+	// credentials, when Writer is nil
+	Denied error
+
+	// Changed is set when a mutation changed the catalog
+	Changed bool
+}
+
+// sessionKey is the key of the session in the context of a request
+type sessionKey struct{}
+
+// WithSession returns a context that holds the session, which has to be passed to Do.
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// errNoSession is returned by the resolvers when the context doesn't hold a session
+var errNoSession = errors.New("the request has no session")
+
+// session returns the session of the request.
+func session(ctx context.Context) (*Session, error) {
+	s, ok := ctx.Value(sessionKey{}).(*Session)
+	if !ok || s == nil || s.DB == nil {
+		return nil, errNoSession
+	}
+	return s, nil
+}
+
+// writer returns the session of a request that is allowed to change the catalog, and to
+// see the products that aren't visible on the storefront.
+func writer(ctx context.Context) (*Session, error) {
+	s, err := session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.Writer == nil {
+		if s.Denied != nil {
+			return nil, s.Denied
+		}
+		return nil, errors.New("authentication required")
+	}
+	return s, nil
+}
+
+// schema is the GraphQL schema of the catalog
+var schema graphql.Schema
+
+func init() {
+	var err error
+	schema, err = graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("invalid catalog schema: %s", err.Error()))
+	}
+}
+
+// Schema returns the GraphQL schema of the catalog.
+func Schema() graphql.Schema {
+	return schema
+}
+
+// dateTimeType is a moment in time, written as an RFC 3339 string
+var dateTimeType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "DateTime",
+	Description: "A moment in time, written as an RFC 3339 string like 2020-06-01T09:00:00Z.",
+	Serialize: func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format(time.RFC3339)
+		case *time.Time:
+			if t == nil {
+				return nil
+			}
+			return t.UTC().Format(time.RFC3339)
+		default:
+			return nil
+		}
+	},
+	ParseValue: func(v interface{}) interface{} {
+		s, ok := v.(string)
+		if !ok {
+			return nil
+		}
+		return parseDateTime(s)
+	},
+	ParseLiteral: func(v ast.Value) interface{} {
+		s, ok := v.(*ast.StringValue)
+		if !ok {
+			return nil
+		}
+		return parseDateTime(s.Value)
+	},
+})
+
+// parseDateTime returns the time of an RFC 3339 string, or nil if it isn't one, which
+// graphql-go reports as an invalid value
+func parseDateTime(s string) interface{} {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// statusType is the lifecycle status of a product
+var statusType = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "ProductStatus",
+	Description: "The lifecycle state of a product.",
+	Values: graphql.EnumValueConfigMap{
+		"DRAFT":     {Value: "DRAFT", Description: "The product is being prepared and isn't visible on the storefront."},
+		"PUBLISHED": {Value: "PUBLISHED", Description: "The product is visible on the storefront, within its availability window."},
+		"ARCHIVED":  {Value: "ARCHIVED", Description: "The product is no longer sold, but is kept for the order history."},
+	},
+})
+
+// statuses maps the values of the ProductStatus enum to the statuses of the data store
+var statuses = map[string]datastore.Status{
+	"DRAFT":     datastore.StatusDraft,
+	"PUBLISHED": datastore.StatusPublished,
+	"ARCHIVED":  datastore.StatusArchived,
+}
+
+// statusValue returns the value of the ProductStatus enum for a status of the data store.
+func statusValue(s datastore.Status) string {
+	for name, status := range statuses {
+		if status == s {
+			return name
+		}
+	}
+	return ""
+}
+
+// productType is a product of the catalog
+var productType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Product",
+	Description: "A product of the catalog, as it's shown on the storefront.",
+	Fields: graphql.Fields{
+		"id":               {Description: "The unique identifier of the product.", Type: graphql.NewNonNull(graphql.ID), Resolve: item(func(p datastore.Product) interface{} { return p.Item.ID })},
+		"name":             {Description: "The name of the product.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.Name })},
+		"shortDescription": {Description: "A short description of the product suited for Point of Sales or mobile apps.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.ShortDescription })},
+		"description":      {Description: "A longer description of the product suited for websites.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.Description })},
+		"imageUrl1":        {Description: "The location of the first image.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.ImageURL1 })},
+		"imageUrl2":        {Description: "The location of the second image.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.ImageURL2 })},
+		"imageUrl3":        {Description: "The location of the third image.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return p.Item.ImageURL3 })},
+		"price":            {Description: "The monetary value of the product.", Type: graphql.NewNonNull(graphql.Float), Resolve: item(func(p datastore.Product) interface{} { return p.Item.Price })},
+		"tags": {Description: "The keys that represent additional sorting information for front-end displays.", Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: item(func(p datastore.Product) interface{} {
+			if p.Item.Tags == nil {
+				return []string{}
+			}
+			return p.Item.Tags
+		})},
+		"status":      {Description: "The lifecycle state of the product. Requires the catalog:write role.", Type: statusType, Resolve: admin(func(p datastore.Product) interface{} { return statusValue(p.Status) })},
+		"publishAt":   {Description: "The moment the product goes live, if it's scheduled. Requires the catalog:write role.", Type: dateTimeType, Resolve: admin(func(p datastore.Product) interface{} { return p.Window.PublishAt })},
+		"unpublishAt": {Description: "The moment the product is taken off the storefront, if it's scheduled. Requires the catalog:write role.", Type: dateTimeType, Resolve: admin(func(p datastore.Product) interface{} { return p.Window.UnpublishAt })},
+	},
+})
+
+// item returns the resolver of a field of a product.
+func item(fn func(p datastore.Product) interface{}) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		p, ok := params.Source.(datastore.Product)
+		if !ok {
+			return nil, fmt.Errorf("unexpected source %T", params.Source)
+		}
+		return fn(p), nil
+	}
+}
+
+// admin returns the resolver of a field of a product that only callers that can change
+// the catalog can see.
+func admin(fn func(p datastore.Product) interface{}) graphql.FieldResolveFn {
+	resolve := item(fn)
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		if _, err := writer(params.Context); err != nil {
+			return nil, err
+		}
+		return resolve(params)
+	}
+}
+
+// pageInfoType describes a page of a connection
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "PageInfo",
+	Description: "Information about a page of products.",
+	Fields: graphql.Fields{
+		"hasNextPage": {Description: "True if there are more products after this page.", Type: graphql.NewNonNull(graphql.Boolean), Resolve: page(func(c connection) interface{} { return c.hasNextPage })},
+		"endCursor": {Description: "The cursor of the last product of the page, to pass as after for the next page.", Type: graphql.String, Resolve: page(func(c connection) interface{} {
+			if len(c.products) == 0 {
+				return nil
+			}
+			return encodeCursor(c.products[len(c.products)-1].Item.ID)
+		})},
+	},
+})
+
+// productEdgeType is a product in a page of a connection
+var productEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ProductEdge",
+	Description: "A product in a page of products, together with its cursor.",
+	Fields: graphql.Fields{
+		"cursor": {Description: "The cursor of the product, to pass as after for the products that follow it.", Type: graphql.NewNonNull(graphql.String), Resolve: item(func(p datastore.Product) interface{} { return encodeCursor(p.Item.ID) })},
+		"node":   {Description: "The product.", Type: graphql.NewNonNull(productType), Resolve: item(func(p datastore.Product) interface{} { return p })},
+	},
+})
+
+// productConnectionType is a page of products
+var productConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ProductConnection",
+	Description: "A page of products, ordered by their ID.",
+	Fields: graphql.Fields{
+		"edges":      {Description: "The products of the page, together with their cursors.", Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productEdgeType))), Resolve: page(func(c connection) interface{} { return c.products })},
+		"nodes":      {Description: "The products of the page.", Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))), Resolve: page(func(c connection) interface{} { return c.products })},
+		"pageInfo":   {Description: "Information about the page.", Type: graphql.NewNonNull(pageInfoType), Resolve: page(func(c connection) interface{} { return c })},
+		"totalCount": {Description: "The number of products that match, on all pages.", Type: graphql.NewNonNull(graphql.Int), Resolve: page(func(c connection) interface{} { return c.totalCount })},
+	},
+})
+
+// page returns the resolver of a field of a connection.
+func page(fn func(c connection) interface{}) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		c, ok := params.Source.(connection)
+		if !ok {
+			return nil, fmt.Errorf("unexpected source %T", params.Source)
+		}
+		return fn(c), nil
+	}
+}
+
+// filterType limits the products of a list or search
+var filterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ProductFilter",
+	Description: "Limits a list of products to the products that match all of the given fields.",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"tags":     {Description: "The products have all of these tags.", Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		"minPrice": {Description: "The products cost at least this much.", Type: graphql.Float},
+		"maxPrice": {Description: "The products cost at most this much.", Type: graphql.Float},
+	},
+})
+
+// pageArgs returns the arguments of a field that returns a connection, with the other
+// arguments of the field
+func pageArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	result := graphql.FieldConfigArgument{
+		"filter": {Description: "Limits the products to the products that match the filter.", Type: filterType},
+		"first":  {Description: "The number of products on the page, at most 100.", Type: graphql.Int, DefaultValue: defaultPageSize},
+		"after":  {Description: "The cursor of the product the page starts after.", Type: graphql.String},
+	}
+	for name, arg := range args {
+		result[name] = arg
+	}
+	return result
+}
+
+// queryType is the root of the queries
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"products": {
+			Description: "The products listed on the storefront.",
+			Type:        graphql.NewNonNull(productConnectionType),
+			Args:        pageArgs(nil),
+			Resolve:     resolveProducts,
+		},
+		"product": {
+			Description: "A single product, or null if it doesn't exist. Archived products can still be looked up, so orders that contain them can show their details.",
+			Type:        productType,
+			Args: graphql.FieldConfigArgument{
+				"id": idArg,
+			},
+			Resolve: resolveProduct,
+		},
+		"search": {
+			Description: "The products listed on the storefront whose name, descriptions or tags contain all words of the query, ignoring case.",
+			Type:        graphql.NewNonNull(productConnectionType),
+			Args: pageArgs(graphql.FieldConfigArgument{
+				"query": {Description: "The words to look for.", Type: graphql.NewNonNull(graphql.String)},
+			}),
+			Resolve: resolveSearch,
+		},
+		"adminProducts": {
+			Description: "All products in the catalog, in any status. Requires the catalog:write role.",
+			Type:        graphql.NewNonNull(productConnectionType),
+			Args: pageArgs(graphql.FieldConfigArgument{
+				"status": {Description: "Limits the products to the products in this status.", Type: statusType},
+			}),
+			Resolve: resolveAdminProducts,
+		},
+		"adminProduct": {
+			Description: "A single product in any status, or null if it doesn't exist. Requires the catalog:write role.",
+			Type:        productType,
+			Args: graphql.FieldConfigArgument{
+				"id": idArg,
+			},
+			Resolve: resolveAdminProduct,
+		},
+	},
+})
+
+// productInputType are the fields of a product that is created or updated
+var productInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ProductInput",
+	Description: "The fields of a product that is created or updated.",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":             {Description: "The name of the product.", Type: graphql.NewNonNull(graphql.String)},
+		"shortDescription": {Description: "A short description of the product suited for Point of Sales or mobile apps.", Type: graphql.String},
+		"description":      {Description: "A longer description of the product suited for websites.", Type: graphql.String},
+		"imageUrl1":        {Description: "The location of the first image.", Type: graphql.String},
+		"imageUrl2":        {Description: "The location of the second image.", Type: graphql.String},
+		"imageUrl3":        {Description: "The location of the third image.", Type: graphql.String},
+		"price":            {Description: "The monetary value of the product.", Type: graphql.NewNonNull(graphql.Float)},
+		"tags":             {Description: "The keys that represent additional sorting information for front-end displays.", Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	},
+})
+
+// idArg is the argument that selects the product a query or mutation is about
+var idArg = &graphql.ArgumentConfig{Description: "The unique identifier of the product.", Type: graphql.NewNonNull(graphql.ID)}
+
+// mutationType is the root of the mutations, which all require the catalog:write role
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createProduct": {
+			Description: "Adds a product to the catalog. Products are drafts, unless another status is given.",
+			Type:        graphql.NewNonNull(productType),
+			Args: graphql.FieldConfigArgument{
+				"input":  {Description: "The fields of the product.", Type: graphql.NewNonNull(productInputType)},
+				"status": {Description: "The lifecycle state of the new product.", Type: statusType, DefaultValue: "DRAFT"},
+			},
+			Resolve: resolveCreateProduct,
+		},
+		"updateProduct": {
+			Description: "Replaces the fields of an existing product. The status and availability window of the product are kept.",
+			Type:        graphql.NewNonNull(productType),
+			Args: graphql.FieldConfigArgument{
+				"id":    idArg,
+				"input": {Description: "The new fields of the product.", Type: graphql.NewNonNull(productInputType)},
+			},
+			Resolve: resolveUpdateProduct,
+		},
+		"setProductStatus": {
+			Description: "Moves a product to a new status, if the lifecycle allows that transition. Archived products have to go back to draft before they can be published again.",
+			Type:        graphql.NewNonNull(productType),
+			Args: graphql.FieldConfigArgument{
+				"id":     idArg,
+				"status": {Description: "The new status of the product.", Type: graphql.NewNonNull(statusType)},
+			},
+			Resolve: resolveSetProductStatus,
+		},
+		"setProductAvailability": {
+			Description: "Changes the period in which a product is available on the storefront. Leaving out publishAt or unpublishAt opens the window on that side.",
+			Type:        graphql.NewNonNull(productType),
+			Args: graphql.FieldConfigArgument{
+				"id":          idArg,
+				"publishAt":   {Description: "The moment the product goes live.", Type: dateTimeType},
+				"unpublishAt": {Description: "The moment the product is taken off the storefront.", Type: dateTimeType},
+			},
+			Resolve: resolveSetProductAvailability,
+		},
+		"archiveProduct": {
+			Description: "Removes a product from the storefront by archiving it. The product is kept, so orders that contain it can still show its details.",
+			Type:        graphql.NewNonNull(productType),
+			Args:        graphql.FieldConfigArgument{"id": idArg},
+			Resolve:     resolveArchiveProduct,
+		},
+		"deleteProduct": {
+			Description: "Permanently removes a product and returns its ID. Products that have been sold should be archived instead.",
+			Type:        graphql.NewNonNull(graphql.ID),
+			Args:        graphql.FieldConfigArgument{"id": idArg},
+			Resolve:     resolveDeleteProduct,
+		},
+		"rollbackProduct": {
+			Description: "Restores a product as it was in an earlier revision.",
+			Type:        graphql.NewNonNull(productType),
+			Args: graphql.FieldConfigArgument{
+				"id":      idArg,
+				"version": {Description: "The version of the revision to restore.", Type: graphql.NewNonNull(graphql.Int)},
+			},
+			Resolve: resolveRollbackProduct,
+		},
+	},
+})
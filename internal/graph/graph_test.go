@@ -0,0 +1,632 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+)
+
+// errDenied is the reason the public sessions can't change the catalog
+var errDenied = errors.New("missing credentials")
+
+// catalog returns a data store with these products:
+//
+//	p1  Yoga mat       published  20.00  yoga, mat
+//	p2  Water bottle   published  10.00  hydration
+//	p3  Yoga block     published   9.99  yoga
+//	p4  Running shoes  draft      99.00  running
+//	p5  Old socks      archived    4.50  running
+//	p6  Yoga strap     published  12.00  yoga, starts tomorrow
+func catalog(t *testing.T) datastore.Manager {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := file.New(filepath.Join(dir, "catalog.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tomorrow := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	products := []datastore.Product{
+		{Item: product("p1", "Yoga mat", 20, "yoga", "mat"), Status: datastore.StatusPublished},
+		{Item: product("p2", "Water bottle", 10, "hydration"), Status: datastore.StatusPublished},
+		{Item: product("p3", "Yoga block", 9.99, "yoga"), Status: datastore.StatusPublished},
+		{Item: product("p4", "Running shoes", 99, "running"), Status: datastore.StatusDraft},
+		{Item: product("p5", "Old socks", 4.5, "running"), Status: datastore.StatusArchived},
+		{Item: product("p6", "Yoga strap", 12, "yoga"), Status: datastore.StatusPublished, Window: datastore.Window{PublishAt: &tomorrow}},
+	}
+	for _, p := range products {
+		if _, err := db.AddProduct(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// product returns a catalog item
+func product(id string, name string, price float32, tags ...string) acmeserverless.CatalogItem {
+	return acmeserverless.CatalogItem{
+		ID:               id,
+		Name:             name,
+		ShortDescription: "The " + strings.ToLower(name),
+		Description:      "A " + strings.ToLower(name) + " for your workout",
+		Price:            price,
+		Tags:             tags,
+	}
+}
+
+// public returns a session of a caller that can only read the catalog
+func public(db datastore.Manager) *Session {
+	return &Session{DB: db, Denied: errDenied}
+}
+
+// writerSession returns a session of a caller that can change the catalog
+func writerSession(db datastore.Manager) *Session {
+	return &Session{DB: db, Writer: db}
+}
+
+// run runs the query and returns the response as it's sent to the client, decoded from
+// JSON
+func run(t *testing.T, s *Session, query string, variables map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	res := Do(context.Background(), s, Request{Query: query, Variables: variables})
+	payload, err := res.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("the response %s isn't JSON: %v", payload, err)
+	}
+	return got
+}
+
+// decode returns the value of a JSON document
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+// messages returns the messages of the errors of a response
+func messages(res map[string]interface{}) []string {
+	errs, _ := res["errors"].([]interface{})
+	result := make([]string, 0, len(errs))
+	for _, e := range errs {
+		m, _ := e.(map[string]interface{})
+		msg, _ := m["message"].(string)
+		result = append(result, msg)
+	}
+	return result
+}
+
+func TestQueries(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			name:  "listed products",
+			query: `{ products { totalCount nodes { id name price tags } } }`,
+			want: `{"products": {"totalCount": 3, "nodes": [
+				{"id": "p1", "name": "Yoga mat", "price": 20, "tags": ["yoga", "mat"]},
+				{"id": "p2", "name": "Water bottle", "price": 10, "tags": ["hydration"]},
+				{"id": "p3", "name": "Yoga block", "price": 9.99, "tags": ["yoga"]}
+			]}}`,
+		},
+		{
+			name:  "tag filter ignores case",
+			query: `{ products(filter: {tags: ["YOGA"]}) { nodes { id } } }`,
+			want:  `{"products": {"nodes": [{"id": "p1"}, {"id": "p3"}]}}`,
+		},
+		{
+			name:  "all tags of the filter",
+			query: `{ products(filter: {tags: ["yoga", "mat"]}) { nodes { id } } }`,
+			want:  `{"products": {"nodes": [{"id": "p1"}]}}`,
+		},
+		{
+			name:  "price filter",
+			query: `{ products(filter: {minPrice: 9.99, maxPrice: 10}) { totalCount nodes { id } } }`,
+			want:  `{"products": {"totalCount": 2, "nodes": [{"id": "p2"}, {"id": "p3"}]}}`,
+		},
+		{
+			name:      "filter in a variable",
+			query:     `query ($filter: ProductFilter) { products(filter: $filter) { nodes { id } } }`,
+			variables: map[string]interface{}{"filter": map[string]interface{}{"minPrice": 15}},
+			want:      `{"products": {"nodes": [{"id": "p1"}]}}`,
+		},
+		{
+			name:  "product",
+			query: `{ product(id: "p2") { name shortDescription description imageUrl1 } }`,
+			want:  `{"product": {"name": "Water bottle", "shortDescription": "The water bottle", "description": "A water bottle for your workout", "imageUrl1": ""}}`,
+		},
+		{
+			name:  "unknown product",
+			query: `{ product(id: "p9") { name } }`,
+			want:  `{"product": null}`,
+		},
+		{
+			name:  "drafts don't exist",
+			query: `{ product(id: "p4") { name } }`,
+			want:  `{"product": null}`,
+		},
+		{
+			name:  "scheduled products don't exist yet",
+			query: `{ product(id: "p6") { name } }`,
+			want:  `{"product": null}`,
+		},
+		{
+			name:  "archived products can be looked up",
+			query: `{ product(id: "p5") { name } }`,
+			want:  `{"product": {"name": "Old socks"}}`,
+		},
+		{
+			name:  "search",
+			query: `{ search(query: "yoga") { nodes { id } } }`,
+			want:  `{"search": {"nodes": [{"id": "p1"}, {"id": "p3"}]}}`,
+		},
+		{
+			name:  "search for all words",
+			query: `{ search(query: "  BLOCK workout ") { nodes { id } } }`,
+			want:  `{"search": {"nodes": [{"id": "p3"}]}}`,
+		},
+		{
+			name:  "search leaves out products that aren't listed",
+			query: `{ search(query: "running") { totalCount } }`,
+			want:  `{"search": {"totalCount": 0}}`,
+		},
+		{
+			name:      "operation with variables",
+			query:     `query Product($id: ID!) { product(id: $id) { ...item } } fragment item on Product { id price }`,
+			variables: map[string]interface{}{"id": "p1"},
+			want:      `{"product": {"id": "p1", "price": 20}}`,
+		},
+		{
+			name:  "aliases and typename",
+			query: `{ mat: product(id: "p1") { __typename name } bottle: product(id: "p2") { name } }`,
+			want:  `{"mat": {"__typename": "Product", "name": "Yoga mat"}, "bottle": {"name": "Water bottle"}}`,
+		},
+	}
+
+	s := public(catalog(t))
+	for _, tt := range tests {
+		got := run(t, s, tt.query, tt.variables)
+		if errs := messages(got); len(errs) > 0 {
+			t.Errorf("%s: errors %v", tt.name, errs)
+			continue
+		}
+		if want := decode(t, tt.want); !reflect.DeepEqual(got["data"], want) {
+			t.Errorf("%s: data = %v, want %v", tt.name, got["data"], want)
+		}
+	}
+}
+
+func TestPagination(t *testing.T) {
+	s := public(catalog(t))
+	query := `query ($first: Int, $after: String) {
+		products(first: $first, after: $after) {
+			totalCount
+			edges { cursor node { id } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+
+	var ids []string
+	var after interface{}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("more pages than products")
+		}
+
+		got := run(t, s, query, map[string]interface{}{"first": 2, "after": after})
+		if errs := messages(got); len(errs) > 0 {
+			t.Fatalf("errors %v", errs)
+		}
+
+		conn := got["data"].(map[string]interface{})["products"].(map[string]interface{})
+		if conn["totalCount"] != float64(3) {
+			t.Errorf("totalCount = %v on page %d, want 3", conn["totalCount"], pages)
+		}
+
+		edges := conn["edges"].([]interface{})
+		info := conn["pageInfo"].(map[string]interface{})
+		for _, e := range edges {
+			edge := e.(map[string]interface{})
+			id := edge["node"].(map[string]interface{})["id"].(string)
+			if edge["cursor"] != encodeCursor(id) {
+				t.Errorf("cursor of %s = %v", id, edge["cursor"])
+			}
+			ids = append(ids, id)
+		}
+
+		if len(edges) > 0 && info["endCursor"] != edges[len(edges)-1].(map[string]interface{})["cursor"] {
+			t.Errorf("endCursor = %v, want the cursor of the last edge", info["endCursor"])
+		}
+		if info["hasNextPage"] != true {
+			break
+		}
+		after = info["endCursor"]
+	}
+
+	if want := []string{"p1", "p2", "p3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("pages have products %v, want %v", ids, want)
+	}
+
+	// An empty page has no end cursor
+	got := run(t, s, `{ products(first: 0) { nodes { id } pageInfo { hasNextPage endCursor } } }`, nil)
+	want := decode(t, `{"products": {"nodes": [], "pageInfo": {"hasNextPage": true, "endCursor": null}}}`)
+	if !reflect.DeepEqual(got["data"], want) {
+		t.Errorf("first: 0 returned %v, want %v", got["data"], want)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		data  string
+		error string
+	}{
+		{
+			name:  "page too large",
+			query: `{ products(first: 101) { totalCount } }`,
+			data:  `null`,
+			error: "first must be between 0 and 100",
+		},
+		{
+			name:  "negative page",
+			query: `{ products(first: -1) { totalCount } }`,
+			data:  `null`,
+			error: "first must be between 0 and 100",
+		},
+		{
+			name:  "invalid cursor",
+			query: `{ products(after: "p1") { totalCount } }`,
+			data:  `null`,
+			error: `invalid cursor "p1"`,
+		},
+		{
+			name:  "cursor of another kind",
+			query: `{ products(after: "` + "b3JkZXI6cDE=" + `") { totalCount } }`,
+			data:  `null`,
+			error: `invalid cursor "b3JkZXI6cDE="`,
+		},
+		{
+			name:  "empty search",
+			query: `{ search(query: " ") { totalCount } }`,
+			data:  `null`,
+			error: "query must contain at least one word",
+		},
+		{
+			name:  "nullable fields keep the rest of the data",
+			query: `{ adminProduct(id: "p1") { name } product(id: "p1") { name } }`,
+			data:  `{"adminProduct": null, "product": {"name": "Yoga mat"}}`,
+			error: "missing credentials",
+		},
+	}
+
+	s := public(catalog(t))
+	for _, tt := range tests {
+		got := run(t, s, tt.query, nil)
+
+		data, ok := got["data"]
+		if !ok {
+			t.Errorf("%s: the response has no data, want the data of the executed request", tt.name)
+		}
+		if want := decode(t, tt.data); !reflect.DeepEqual(data, want) {
+			t.Errorf("%s: data = %v, want %v", tt.name, data, want)
+		}
+		if errs := messages(got); len(errs) != 1 || errs[0] != tt.error {
+			t.Errorf("%s: errors = %q, want %q", tt.name, errs, tt.error)
+		}
+
+		// Field errors say which field failed
+		e := got["errors"].([]interface{})[0].(map[string]interface{})
+		if path, _ := e["path"].([]interface{}); len(path) == 0 {
+			t.Errorf("%s: the error has no path", tt.name)
+		}
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	db := catalog(t)
+
+	queries := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: `{ adminProducts { totalCount nodes { id status } } }`,
+			want: `{"adminProducts": {"totalCount": 6, "nodes": [
+				{"id": "p1", "status": "PUBLISHED"},
+				{"id": "p2", "status": "PUBLISHED"},
+				{"id": "p3", "status": "PUBLISHED"},
+				{"id": "p4", "status": "DRAFT"},
+				{"id": "p5", "status": "ARCHIVED"},
+				{"id": "p6", "status": "PUBLISHED"}
+			]}}`,
+		},
+		{
+			query: `{ adminProducts(status: DRAFT) { nodes { id } } }`,
+			want:  `{"adminProducts": {"nodes": [{"id": "p4"}]}}`,
+		},
+		{
+			query: `{ adminProducts(status: PUBLISHED, filter: {tags: ["yoga"]}, first: 1, after: "` + encodeCursor("p1") + `") { nodes { id } pageInfo { hasNextPage } } }`,
+			want:  `{"adminProducts": {"nodes": [{"id": "p3"}], "pageInfo": {"hasNextPage": true}}}`,
+		},
+		{
+			query: `{ adminProduct(id: "p4") { name status publishAt unpublishAt } }`,
+			want:  `{"adminProduct": {"name": "Running shoes", "status": "DRAFT", "publishAt": null, "unpublishAt": null}}`,
+		},
+		{
+			query: `{ adminProduct(id: "p9") { name } }`,
+			want:  `{"adminProduct": null}`,
+		},
+	}
+
+	for _, tt := range queries {
+		got := run(t, writerSession(db), tt.query, nil)
+		if errs := messages(got); len(errs) > 0 {
+			t.Errorf("%s: errors %v", tt.query, errs)
+			continue
+		}
+		if want := decode(t, tt.want); !reflect.DeepEqual(got["data"], want) {
+			t.Errorf("%s: data = %v, want %v", tt.query, got["data"], want)
+		}
+
+		// The public gets the reason they can't see the fields
+		got = run(t, public(db), tt.query, nil)
+		if errs := messages(got); len(errs) == 0 || errs[0] != errDenied.Error() {
+			t.Errorf("%s: public errors = %q, want %q", tt.query, errs, errDenied)
+		}
+	}
+
+	// The admin fields of products are null for the public, the other fields are shown
+	got := run(t, public(db), `{ product(id: "p1") { name status publishAt } }`, nil)
+	if want := decode(t, `{"product": {"name": "Yoga mat", "status": null, "publishAt": null}}`); !reflect.DeepEqual(got["data"], want) {
+		t.Errorf("public product = %v, want %v", got["data"], want)
+	}
+	if errs := messages(got); len(errs) != 2 {
+		t.Errorf("public product errors = %q, want one for each admin field", errs)
+	}
+
+	// Without credentials or a reason, authentication is required
+	got = run(t, &Session{DB: db}, `{ adminProduct(id: "p1") { name } }`, nil)
+	if errs := messages(got); len(errs) != 1 || errs[0] != "authentication required" {
+		t.Errorf("errors without a reason = %q, want authentication required", errs)
+	}
+
+	// A request without a session fails in every resolver
+	res := Do(context.Background(), nil, Request{Query: `{ product(id: "p1") { name } }`})
+	if len(res.Errors) != 1 || res.Errors[0].Message != errNoSession.Error() {
+		t.Errorf("errors without a session = %v, want %v", res.Errors, errNoSession)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	db := catalog(t)
+	s := writerSession(db)
+
+	// createProduct makes a draft unless another status is given
+	got := run(t, s, `mutation ($input: ProductInput!) {
+		createProduct(input: $input) { id name price tags status }
+	}`, map[string]interface{}{"input": map[string]interface{}{"name": "Foam roller", "price": 24.5, "tags": []interface{}{"recovery"}}})
+	if errs := messages(got); len(errs) > 0 {
+		t.Fatalf("createProduct errors %v", errs)
+	}
+	created := got["data"].(map[string]interface{})["createProduct"].(map[string]interface{})
+	id, _ := created["id"].(string)
+	if id == "" || created["name"] != "Foam roller" || created["price"] != 24.5 || created["status"] != "DRAFT" {
+		t.Errorf("createProduct = %v", created)
+	}
+	if !s.Changed {
+		t.Errorf("createProduct didn't mark the session as changed")
+	}
+	if p, err := db.GetProduct(id); err != nil || p.Item.Name != "Foam roller" || p.Status != datastore.StatusDraft {
+		t.Errorf("GetProduct(%s) = %+v, %v after createProduct", id, p, err)
+	}
+
+	got = run(t, s, `mutation { createProduct(input: {name: "Kettlebell", price: 40}, status: PUBLISHED) { status tags } }`, nil)
+	if want := decode(t, `{"createProduct": {"status": "PUBLISHED", "tags": []}}`); !reflect.DeepEqual(got["data"], want) {
+		t.Errorf("createProduct as published = %v, want %v (errors %v)", got["data"], want, messages(got))
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		check func(p datastore.Product) bool
+	}{
+		{
+			name:  "updateProduct",
+			query: `mutation { updateProduct(id: "p4", input: {name: "Trail shoes", price: 89.5, tags: ["running", "trail"]}) { name price tags status } }`,
+			want:  `{"updateProduct": {"name": "Trail shoes", "price": 89.5, "tags": ["running", "trail"], "status": "DRAFT"}}`,
+			check: func(p datastore.Product) bool {
+				return p.Item.Name == "Trail shoes" && p.Status == datastore.StatusDraft
+			},
+		},
+		{
+			name:  "setProductStatus",
+			query: `mutation { setProductStatus(id: "p4", status: PUBLISHED) { status } }`,
+			want:  `{"setProductStatus": {"status": "PUBLISHED"}}`,
+			check: func(p datastore.Product) bool { return p.Status == datastore.StatusPublished },
+		},
+		{
+			name:  "setProductAvailability",
+			query: `mutation { setProductAvailability(id: "p4", publishAt: "2020-06-01T11:00:00+02:00", unpublishAt: "2030-01-01T00:00:00Z") { publishAt unpublishAt } }`,
+			want:  `{"setProductAvailability": {"publishAt": "2020-06-01T09:00:00Z", "unpublishAt": "2030-01-01T00:00:00Z"}}`,
+			check: func(p datastore.Product) bool {
+				return p.Window.PublishAt != nil && p.Window.PublishAt.Equal(time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC))
+			},
+		},
+		{
+			name:  "open the availability window",
+			query: `mutation { setProductAvailability(id: "p4") { publishAt unpublishAt } }`,
+			want:  `{"setProductAvailability": {"publishAt": null, "unpublishAt": null}}`,
+			check: func(p datastore.Product) bool { return p.Window.PublishAt == nil && p.Window.UnpublishAt == nil },
+		},
+		{
+			name:  "archiveProduct",
+			query: `mutation { archiveProduct(id: "p4") { status } }`,
+			want:  `{"archiveProduct": {"status": "ARCHIVED"}}`,
+			check: func(p datastore.Product) bool { return p.Status == datastore.StatusArchived },
+		},
+		{
+			name:  "rollbackProduct",
+			query: `mutation { rollbackProduct(id: "p4", version: 1) { name status } }`,
+			want:  `{"rollbackProduct": {"name": "Running shoes", "status": "DRAFT"}}`,
+			check: func(p datastore.Product) bool { return p.Item.Name == "Running shoes" },
+		},
+	}
+
+	for _, tt := range tests {
+		got := run(t, s, tt.query, nil)
+		if errs := messages(got); len(errs) > 0 {
+			t.Errorf("%s: errors %v", tt.name, errs)
+			continue
+		}
+		if want := decode(t, tt.want); !reflect.DeepEqual(got["data"], want) {
+			t.Errorf("%s: data = %v, want %v", tt.name, got["data"], want)
+		}
+		if p, err := db.GetProduct("p4"); err != nil || !tt.check(p) {
+			t.Errorf("%s: GetProduct() = %+v, %v", tt.name, p, err)
+		}
+	}
+
+	got = run(t, s, `mutation { deleteProduct(id: "p5") }`, nil)
+	if want := decode(t, `{"deleteProduct": "p5"}`); !reflect.DeepEqual(got["data"], want) {
+		t.Errorf("deleteProduct = %v, want %v (errors %v)", got["data"], want, messages(got))
+	}
+	if _, err := db.GetProduct("p5"); err == nil {
+		t.Errorf("p5 exists after deleteProduct")
+	}
+}
+
+func TestMutationErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{
+			name:  "transition the lifecycle doesn't allow",
+			query: `mutation { setProductStatus(id: "p5", status: PUBLISHED) { status } }`,
+			error: "archived",
+		},
+		{
+			name:  "window that ends before it starts",
+			query: `mutation { setProductAvailability(id: "p1", publishAt: "2030-01-01T00:00:00Z", unpublishAt: "2020-01-01T00:00:00Z") { id } }`,
+			error: "unpublishAt",
+		},
+		{
+			name:  "unknown product",
+			query: `mutation { archiveProduct(id: "p9") { status } }`,
+			error: "p9",
+		},
+		{
+			name:  "unknown revision",
+			query: `mutation { rollbackProduct(id: "p1", version: 7) { name } }`,
+			error: "7",
+		},
+	}
+
+	db := catalog(t)
+	for _, tt := range tests {
+		s := writerSession(db)
+		got := run(t, s, tt.query, nil)
+
+		// The mutations return products that can't be null, so the data is null
+		if data, ok := got["data"]; !ok || data != nil {
+			t.Errorf("%s: data = %v, want null", tt.name, data)
+		}
+		if errs := messages(got); len(errs) != 1 || !strings.Contains(errs[0], tt.error) {
+			t.Errorf("%s: errors = %q, want one about %q", tt.name, errs, tt.error)
+		}
+		if s.Changed {
+			t.Errorf("%s: the failed mutation marked the session as changed", tt.name)
+		}
+	}
+
+	// The public can't change the catalog
+	s := public(db)
+	got := run(t, s, `mutation { deleteProduct(id: "p1") }`, nil)
+	if errs := messages(got); len(errs) != 1 || errs[0] != errDenied.Error() {
+		t.Errorf("public deleteProduct errors = %q, want %q", errs, errDenied)
+	}
+	if _, err := db.GetProduct("p1"); err != nil || s.Changed {
+		t.Errorf("public deleteProduct removed the product")
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	s := public(catalog(t))
+
+	got := run(t, s, `{
+		__schema { queryType { name } mutationType { name } types { name } }
+		__type(name: "ProductStatus") { kind enumValues { name description } }
+	}`, nil)
+	if errs := messages(got); len(errs) > 0 {
+		t.Fatalf("errors %v", errs)
+	}
+	data := got["data"].(map[string]interface{})
+
+	schema := data["__schema"].(map[string]interface{})
+	if want := decode(t, `{"name": "Query"}`); !reflect.DeepEqual(schema["queryType"], want) {
+		t.Errorf("queryType = %v, want %v", schema["queryType"], want)
+	}
+	if want := decode(t, `{"name": "Mutation"}`); !reflect.DeepEqual(schema["mutationType"], want) {
+		t.Errorf("mutationType = %v, want %v", schema["mutationType"], want)
+	}
+	types := map[string]bool{}
+	for _, typ := range schema["types"].([]interface{}) {
+		types[typ.(map[string]interface{})["name"].(string)] = true
+	}
+	for _, name := range []string{"Product", "ProductConnection", "ProductEdge", "PageInfo", "ProductFilter", "ProductInput", "ProductStatus", "DateTime", "__Schema"} {
+		if !types[name] {
+			t.Errorf("the schema has no type %s", name)
+		}
+	}
+
+	status := data["__type"].(map[string]interface{})
+	if status["kind"] != "ENUM" || len(status["enumValues"].([]interface{})) != 3 {
+		t.Errorf("ProductStatus = %v, want an enum with 3 values", status)
+	}
+
+	// The arguments are described with their defaults, as code generators need them
+	got = run(t, s, `{ __type(name: "Query") { fields { name args { name defaultValue type { kind ofType { name } } } } } }`, nil)
+	fields := got["data"].(map[string]interface{})["__type"].(map[string]interface{})["fields"].([]interface{})
+	for _, f := range fields {
+		field := f.(map[string]interface{})
+		if field["name"] != "product" {
+			continue
+		}
+		want := decode(t, `[{"name": "id", "defaultValue": null, "type": {"kind": "NON_NULL", "ofType": {"name": "ID"}}}]`)
+		if !reflect.DeepEqual(field["args"], want) {
+			t.Errorf("arguments of product = %v, want %v", field["args"], want)
+		}
+	}
+}
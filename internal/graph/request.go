@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Request is a GraphQL request.
+type Request struct {
+	// Query is the document with the operations
+	Query string `json:"query"`
+
+	// OperationName selects the operation to run when the document has more than one
+	OperationName string `json:"operationName,omitempty"`
+
+	// Variables are the values of the variables of the operation
+	Variables map[string]interface{} `json:"variables,omitempty"`
+
+	// ReadOnly rejects mutations, for requests that can't have side effects, like HTTP
+	// GET requests
+	ReadOnly bool `json:"-"`
+}
+
+// UnmarshalRequest parses the JSON-encoded data and stores the result in a Request.
+func UnmarshalRequest(data []byte) (Request, error) {
+	var r Request
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	if r.Query == "" {
+		return r, fmt.Errorf("query is required")
+	}
+	return r, nil
+}
+
+// QueryRequest returns the request that is sent in the query parameters of an HTTP GET
+// request, with the variables as a JSON object. The request is read-only.
+func QueryRequest(query string, operationName string, variables string) (Request, error) {
+	r := Request{
+		Query:         query,
+		OperationName: operationName,
+		ReadOnly:      true,
+	}
+
+	if r.Query == "" {
+		return r, fmt.Errorf("query is required")
+	}
+
+	if variables != "" {
+		if err := json.Unmarshal([]byte(variables), &r.Variables); err != nil {
+			return r, fmt.Errorf("invalid variables: %s", err.Error())
+		}
+	}
+	return r, nil
+}
+
+// Response is the result of a GraphQL request.
+type Response struct {
+	// Data is the result of the operation, or nil if the request couldn't be executed
+	// or a field that can't be null failed
+	Data interface{}
+
+	// Errors are the errors that happened while the request was parsed, validated or
+	// executed
+	Errors []gqlerrors.FormattedError
+
+	// executed is true if the operation was executed, in which case the data is included
+	// in the response even when it's null
+	executed bool
+}
+
+// MarshalJSON returns the JSON encoding of Response
+func (r *Response) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+
+	if r.executed {
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"data":`)
+		buf.Write(data)
+	}
+
+	if len(r.Errors) > 0 {
+		errs, err := json.Marshal(r.Errors)
+		if err != nil {
+			return nil, err
+		}
+		if r.executed {
+			buf.WriteString(",")
+		}
+		buf.WriteString(`"errors":`)
+		buf.Write(errs)
+	}
+
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// Marshal returns the JSON encoding of Response
+func (r *Response) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Executed returns true if the operation was executed. It's false when the request was
+// rejected before it ran, because it couldn't be parsed, isn't valid or its variables
+// don't match the operation.
+func (r *Response) Executed() bool {
+	return r.executed
+}
+
+// Do runs a GraphQL request against the catalog, on behalf of the caller of the session.
+// Errors are returned in the response, as GraphQL requires.
+func Do(ctx context.Context, s *Session, r Request) *Response {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(r.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &Response{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if v := graphql.ValidateDocument(&schema, doc, nil); !v.IsValid {
+		return &Response{Errors: v.Errors}
+	}
+
+	if op := operation(doc, r.OperationName); op != nil && op.Operation == ast.OperationTypeMutation && r.ReadOnly {
+		return &Response{Errors: []gqlerrors.FormattedError{
+			gqlerrors.NewFormattedError("Mutations are not allowed in a read-only request."),
+		}}
+	}
+
+	res := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: r.OperationName,
+		Args:          r.Variables,
+		Context:       WithSession(ctx, s),
+	})
+
+	return &Response{
+		Data:     res.Data,
+		Errors:   res.Errors,
+		executed: res.Data != nil || failedField(res.Errors),
+	}
+}
+
+// operation returns the operation of the document with the name, or the only operation
+// if the name is empty. It returns nil if there's no such operation, which Execute
+// reports.
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "" && found != nil:
+			return nil
+		case name == "":
+			found = op
+		case op.Name != nil && op.Name.Value == name:
+			return op
+		}
+	}
+	return found
+}
+
+// failedField returns true if one of the errors is the error of a field, which means the
+// operation was executed
+func failedField(errs []gqlerrors.FormattedError) bool {
+	for _, e := range errs {
+		if len(e.Path) > 0 {
+			return true
+		}
+	}
+	return false
+}
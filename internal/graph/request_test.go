@@ -0,0 +1,252 @@
+package graph
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+func TestUnmarshalRequest(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Request
+		wantErr bool
+	}{
+		{
+			data: `{"query": "{ products { totalCount } }"}`,
+			want: Request{Query: "{ products { totalCount } }"},
+		},
+		{
+			data: `{"query": "query P($id: ID!) { product(id: $id) { name } }", "operationName": "P", "variables": {"id": "p1", "first": 2}}`,
+			want: Request{
+				Query:         "query P($id: ID!) { product(id: $id) { name } }",
+				OperationName: "P",
+				Variables:     map[string]interface{}{"id": "p1", "first": float64(2)},
+			},
+		},
+		{
+			data: `{"query": "{ products { totalCount } }", "variables": null, "ReadOnly": false}`,
+			want: Request{Query: "{ products { totalCount } }"},
+		},
+		{data: `{"operationName": "P"}`, wantErr: true},
+		{data: `{"query": ""}`, wantErr: true},
+		{data: `{"query": 1}`, wantErr: true},
+		{data: `query { products { totalCount } }`, wantErr: true},
+		{data: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := UnmarshalRequest([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalRequest(%s) = %v, want error %t", tt.data, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UnmarshalRequest(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRequest(t *testing.T) {
+	tests := []struct {
+		query         string
+		operationName string
+		variables     string
+		want          Request
+		wantErr       bool
+	}{
+		{
+			query: "{ products { totalCount } }",
+			want:  Request{Query: "{ products { totalCount } }", ReadOnly: true},
+		},
+		{
+			query:         "query P($id: ID!) { product(id: $id) { name } }",
+			operationName: "P",
+			variables:     `{"id": "p1"}`,
+			want: Request{
+				Query:         "query P($id: ID!) { product(id: $id) { name } }",
+				OperationName: "P",
+				Variables:     map[string]interface{}{"id": "p1"},
+				ReadOnly:      true,
+			},
+		},
+		{query: "", wantErr: true},
+		{query: "{ products { totalCount } }", variables: `{"id": `, wantErr: true},
+		{query: "{ products { totalCount } }", variables: `["p1"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := QueryRequest(tt.query, tt.operationName, tt.variables)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("QueryRequest(%q, %q, %q) = %v, want error %t", tt.query, tt.operationName, tt.variables, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryRequest(%q, %q, %q) = %+v, want %+v", tt.query, tt.operationName, tt.variables, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	const operations = `
+		query Products { products { totalCount } }
+		mutation Archive { archiveProduct(id: "p2") { status } }
+	`
+
+	tests := []struct {
+		name     string
+		request  Request
+		executed bool
+		error    string
+	}{
+		{
+			name:     "query",
+			request:  Request{Query: `{ products { totalCount } }`, ReadOnly: true},
+			executed: true,
+		},
+		{
+			name:     "field error",
+			request:  Request{Query: `{ products(first: 500) { totalCount } }`},
+			executed: true,
+			error:    "first must be between 0 and 100",
+		},
+		{
+			name:    "syntax error",
+			request: Request{Query: `{ products { totalCount }`},
+			error:   "Syntax Error",
+		},
+		{
+			name:    "unknown field",
+			request: Request{Query: `{ products { color } }`},
+			error:   `Cannot query field "color" on type "ProductConnection".`,
+		},
+		{
+			name:    "missing argument",
+			request: Request{Query: `{ product { name } }`},
+			error:   `Field "product" argument "id" of type "ID!" is required but not provided.`,
+		},
+		{
+			name:    "invalid enum value",
+			request: Request{Query: `{ adminProducts(status: SOLD) { totalCount } }`},
+			error:   `Argument "status" has invalid value SOLD.`,
+		},
+		{
+			name:    "invalid date",
+			request: Request{Query: `mutation { setProductAvailability(id: "p1", publishAt: "tomorrow") { id } }`},
+			error:   `Argument "publishAt" has invalid value "tomorrow".`,
+		},
+		{
+			name:    "missing variable",
+			request: Request{Query: `query ($id: ID!) { product(id: $id) { name } }`},
+			error:   `Variable "$id" of required type "ID!" was not provided.`,
+		},
+		{
+			name:    "variable of the wrong type",
+			request: Request{Query: `query ($first: Int) { products(first: $first) { totalCount } }`, Variables: map[string]interface{}{"first": "two"}},
+			error:   `Variable "$first" got invalid value "two".`,
+		},
+		{
+			name:    "more than one operation without a name",
+			request: Request{Query: operations},
+			error:   "Must provide operation name if query contains multiple operations.",
+		},
+		{
+			name:    "unknown operation",
+			request: Request{Query: operations, OperationName: "Delete"},
+			error:   `Unknown operation named "Delete".`,
+		},
+		{
+			name:     "query next to a mutation",
+			request:  Request{Query: operations, OperationName: "Products", ReadOnly: true},
+			executed: true,
+		},
+		{
+			name:    "read-only mutation",
+			request: Request{Query: operations, OperationName: "Archive", ReadOnly: true},
+			error:   "Mutations are not allowed in a read-only request.",
+		},
+		{
+			name:    "read-only anonymous mutation",
+			request: Request{Query: `mutation { archiveProduct(id: "p2") { status } }`, ReadOnly: true},
+			error:   "Mutations are not allowed in a read-only request.",
+		},
+	}
+
+	db := catalog(t)
+	for _, tt := range tests {
+		s := writerSession(db)
+		res := Do(context.Background(), s, tt.request)
+
+		if res.Executed() != tt.executed {
+			t.Errorf("%s: Executed() = %t, want %t", tt.name, res.Executed(), tt.executed)
+		}
+		if !tt.executed && res.Data != nil {
+			t.Errorf("%s: data = %v, want none", tt.name, res.Data)
+		}
+
+		if tt.error == "" {
+			if len(res.Errors) > 0 {
+				t.Errorf("%s: errors %v", tt.name, res.Errors)
+			}
+		} else if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, tt.error) {
+			t.Errorf("%s: errors = %v, want %q", tt.name, res.Errors, tt.error)
+		}
+
+		if s.Changed {
+			t.Errorf("%s: the request changed the catalog", tt.name)
+		}
+	}
+
+	// The syntax error says where it is
+	res := Do(context.Background(), public(db), Request{Query: "{\n  products {\n    totalCount(\n  }\n}"})
+	if len(res.Errors) != 1 || len(res.Errors[0].Locations) != 1 || res.Errors[0].Locations[0].Line != 4 {
+		t.Errorf("syntax error = %+v, want one on line 4", res.Errors)
+	}
+}
+
+func TestResponseMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		response Response
+		want     string
+	}{
+		{
+			name:     "data",
+			response: Response{Data: map[string]interface{}{"product": nil}, executed: true},
+			want:     `{"data":{"product":null}}`,
+		},
+		{
+			name: "data and errors",
+			response: Response{
+				Data:     map[string]interface{}{"product": nil},
+				Errors:   []gqlerrors.FormattedError{{Message: "boom", Path: []interface{}{"product"}}},
+				executed: true,
+			},
+			want: `{"data":{"product":null},"errors":[{"message":"boom","locations":null,"path":["product"]}]}`,
+		},
+		{
+			name:     "null data of an executed request",
+			response: Response{Errors: []gqlerrors.FormattedError{{Message: "boom", Path: []interface{}{"archiveProduct"}}}, executed: true},
+			want:     `{"data":null,"errors":[{"message":"boom","locations":null,"path":["archiveProduct"]}]}`,
+		},
+		{
+			name:     "request that wasn't executed",
+			response: Response{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("boom")}},
+			want:     `{"errors":[{"message":"boom","locations":[]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.response.Marshal()
+		if err != nil {
+			t.Errorf("%s: Marshal() = %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: Marshal() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/graphql-go/graphql"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+const (
+	// defaultPageSize is the number of products on a page when first isn't given
+	defaultPageSize = 20
+
+	// maxPageSize is the largest number of products on a page
+	maxPageSize = 100
+
+	// cursorPrefix is put in front of the ID of a product in a cursor, so cursors can
+	// change without breaking clients that treat them as opaque
+	cursorPrefix = "product:"
+)
+
+// connection is a page of products, after they've been filtered.
+type connection struct {
+	products    []datastore.Product
+	totalCount  int
+	hasNextPage bool
+}
+
+// resolveProducts returns a page of the products listed on the storefront.
+func resolveProducts(p graphql.ResolveParams) (interface{}, error) {
+	s, err := session(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := s.DB.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	return paginate(listed(products, time.Now()), p.Args)
+}
+
+// resolveProduct returns a product that can be looked up by the public, or nil if it
+// doesn't exist.
+func resolveProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := session(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	prod, ok, err := lookup(s.DB, p.Args["id"].(string))
+	if err != nil || !ok || !prod.Visible(time.Now()) {
+		// Drafts are treated as if they don't exist yet
+		return nil, err
+	}
+	return prod, nil
+}
+
+// resolveSearch returns a page of the products listed on the storefront that contain all
+// words of the query.
+func resolveSearch(p graphql.ResolveParams) (interface{}, error) {
+	s, err := session(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(p.Args["query"].(string)))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("query must contain at least one word")
+	}
+
+	products, err := s.DB.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]datastore.Product, 0)
+	for _, prod := range listed(products, time.Now()) {
+		if matchesAll(prod, words) {
+			matches = append(matches, prod)
+		}
+	}
+
+	return paginate(matches, p.Args)
+}
+
+// resolveAdminProducts returns a page of all products, in any status.
+func resolveAdminProducts(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := s.DB.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	if status, ok := p.Args["status"].(string); ok {
+		filtered := make([]datastore.Product, 0, len(products))
+		for _, prod := range products {
+			if prod.Status == statuses[status] {
+				filtered = append(filtered, prod)
+			}
+		}
+		products = filtered
+	}
+
+	return paginate(products, p.Args)
+}
+
+// resolveAdminProduct returns a product in any status, or nil if it doesn't exist.
+func resolveAdminProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	prod, ok, err := lookup(s.DB, p.Args["id"].(string))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return prod, nil
+}
+
+// resolveCreateProduct adds a product with a new ID.
+func resolveCreateProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	prod := datastore.Product{
+		Item:   catalogItem(uuid.Must(uuid.NewV4()).String(), p.Args["input"].(map[string]interface{})),
+		Status: statuses[p.Args["status"].(string)],
+	}
+	if _, err := s.Writer.AddProduct(prod); err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return prod, nil
+}
+
+// resolveUpdateProduct replaces the catalog item of an existing product.
+func resolveUpdateProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	productID := p.Args["id"].(string)
+	if _, err := s.Writer.UpdateProductItem(catalogItem(productID, p.Args["input"].(map[string]interface{}))); err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return s.DB.GetProduct(productID)
+}
+
+// resolveSetProductStatus moves a product to a new status.
+func resolveSetProductStatus(p graphql.ResolveParams) (interface{}, error) {
+	return setStatus(p, statuses[p.Args["status"].(string)])
+}
+
+// resolveArchiveProduct archives a product.
+func resolveArchiveProduct(p graphql.ResolveParams) (interface{}, error) {
+	return setStatus(p, datastore.StatusArchived)
+}
+
+// setStatus moves the product in the arguments to a new status, if the lifecycle allows
+// that transition, and returns the updated product.
+func setStatus(p graphql.ResolveParams, status datastore.Status) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	productID := p.Args["id"].(string)
+	if _, err := s.Writer.SetProductStatus(productID, status); err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return s.DB.GetProduct(productID)
+}
+
+// resolveSetProductAvailability changes the availability window of a product.
+func resolveSetProductAvailability(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	var window datastore.Window
+	if t, ok := p.Args["publishAt"].(time.Time); ok {
+		window.PublishAt = &t
+	}
+	if t, ok := p.Args["unpublishAt"].(time.Time); ok {
+		window.UnpublishAt = &t
+	}
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+
+	productID := p.Args["id"].(string)
+	if _, err := s.Writer.SetProductWindow(productID, window); err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return s.DB.GetProduct(productID)
+}
+
+// resolveDeleteProduct permanently removes a product.
+func resolveDeleteProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	productID := p.Args["id"].(string)
+	if _, err := s.Writer.DeleteProduct(productID); err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return productID, nil
+}
+
+// resolveRollbackProduct restores a product as it was in an earlier revision.
+func resolveRollbackProduct(p graphql.ResolveParams) (interface{}, error) {
+	s, err := writer(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := s.Writer.RollbackProduct(p.Args["id"].(string), p.Args["version"].(int))
+	if err != nil {
+		return nil, err
+	}
+	s.Changed = true
+
+	return rev.Product, nil
+}
+
+// lookup returns a single product, and false if it doesn't exist.
+func lookup(db datastore.Manager, productID string) (datastore.Product, bool, error) {
+	products, err := db.GetProductsByIDs([]string{productID})
+	if err != nil || len(products) == 0 {
+		return datastore.Product{}, false, err
+	}
+	return products[0], true, nil
+}
+
+// listed returns the products that are shown in the public listings of the storefront at
+// the given time.
+func listed(products []datastore.Product, now time.Time) []datastore.Product {
+	result := make([]datastore.Product, 0, len(products))
+	for _, p := range products {
+		if p.Listed(now) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// paginate returns the page of the products that match the filter, as selected by the
+// first and after arguments.
+func paginate(products []datastore.Product, args map[string]interface{}) (connection, error) {
+	first := args["first"].(int)
+	if first < 0 || first > maxPageSize {
+		return connection{}, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+	}
+
+	filter, _ := args["filter"].(map[string]interface{})
+	matches := make([]datastore.Product, 0, len(products))
+	for _, p := range products {
+		if matchesFilter(p, filter) {
+			matches = append(matches, p)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Item.ID < matches[j].Item.ID
+	})
+
+	start := 0
+	if after, ok := args["after"].(string); ok {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return connection{}, err
+		}
+		start = sort.Search(len(matches), func(i int) bool {
+			return matches[i].Item.ID > id
+		})
+	}
+
+	end := start + first
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	return connection{
+		products:    matches[start:end],
+		totalCount:  len(matches),
+		hasNextPage: end < len(matches),
+	}, nil
+}
+
+// matchesFilter returns true if the product matches all fields of the filter.
+func matchesFilter(p datastore.Product, filter map[string]interface{}) bool {
+	if tags, ok := filter["tags"].([]interface{}); ok {
+		for _, t := range tags {
+			if !hasTag(p.Item.Tags, t.(string)) {
+				return false
+			}
+		}
+	}
+
+	// Prices are compared as float32, the type they're stored as, so a filter of 19.99
+	// matches a price of 19.99
+	if min, ok := filter["minPrice"].(float64); ok && p.Item.Price < float32(min) {
+		return false
+	}
+	if max, ok := filter["maxPrice"].(float64); ok && p.Item.Price > float32(max) {
+		return false
+	}
+	return true
+}
+
+// hasTag returns true if the tag is one of the tags, ignoring case.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll returns true if all words, which are in lower case, appear in the name,
+// descriptions or tags of the product.
+func matchesAll(p datastore.Product, words []string) bool {
+	text := strings.ToLower(strings.Join(append([]string{p.Item.Name, p.Item.ShortDescription, p.Item.Description}, p.Item.Tags...), "\n"))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// catalogItem returns the catalog item with the ID and the fields of a ProductInput.
+func catalogItem(id string, input map[string]interface{}) acmeserverless.CatalogItem {
+	str := func(name string) string {
+		s, _ := input[name].(string)
+		return s
+	}
+
+	item := acmeserverless.CatalogItem{
+		ID:               id,
+		Name:             str("name"),
+		ShortDescription: str("shortDescription"),
+		Description:      str("description"),
+		ImageURL1:        str("imageUrl1"),
+		ImageURL2:        str("imageUrl2"),
+		ImageURL3:        str("imageUrl3"),
+		Price:            float32(input["price"].(float64)),
+		Tags:             []string{},
+	}
+	if tags, ok := input["tags"].([]interface{}); ok {
+		for _, t := range tags {
+			item.Tags = append(item.Tags, t.(string))
+		}
+	}
+	return item
+}
+
+// encodeCursor returns the cursor of the product with the ID.
+func encodeCursor(productID string) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + productID))
+}
+
+// decodeCursor returns the ID of the product of a cursor.
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(b), cursorPrefix) {
+		return "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return strings.TrimPrefix(string(b), cursorPrefix), nil
+}
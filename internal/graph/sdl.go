@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// builtinScalars are the scalars every GraphQL schema has, which aren't written in the SDL
+var builtinScalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+// SDL returns the schema of the catalog in the schema definition language. The query and
+// mutation types come first, the other types, fields, arguments and enum values are in
+// alphabetical order.
+func SDL() string {
+	var names []string
+	for name, t := range schema.TypeMap() {
+		if strings.HasPrefix(name, "__") || builtinScalars[name] || t == schema.QueryType() || t == schema.MutationType() {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	types := []graphql.Type{schema.QueryType(), schema.MutationType()}
+	for _, name := range names {
+		types = append(types, schema.Type(name))
+	}
+
+	var b strings.Builder
+	for i, t := range types {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeDescription(&b, t.Description(), "")
+
+		switch t := t.(type) {
+		case *graphql.Object:
+			fmt.Fprintf(&b, "type %s {\n", t.Name())
+			fields := t.Fields()
+			var names []string
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				f := fields[name]
+				writeDescription(&b, f.Description, "  ")
+				fmt.Fprintf(&b, "  %s%s: %s\n", f.Name, arguments(f.Args), f.Type)
+			}
+			b.WriteString("}\n")
+		case *graphql.InputObject:
+			fmt.Fprintf(&b, "input %s {\n", t.Name())
+			fields := t.Fields()
+			var names []string
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				f := fields[name]
+				writeDescription(&b, f.Description(), "  ")
+				fmt.Fprintf(&b, "  %s\n", inputValue(f.Name(), f.Type, f.DefaultValue))
+			}
+			b.WriteString("}\n")
+		case *graphql.Enum:
+			fmt.Fprintf(&b, "enum %s {\n", t.Name())
+			values := t.Values()
+			sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+			for _, v := range values {
+				writeDescription(&b, v.Description, "  ")
+				fmt.Fprintf(&b, "  %s\n", v.Name)
+			}
+			b.WriteString("}\n")
+		case *graphql.Scalar:
+			fmt.Fprintf(&b, "scalar %s\n", t.Name())
+		}
+	}
+
+	return b.String()
+}
+
+// writeDescription writes a description above a definition.
+func writeDescription(b *strings.Builder, description string, indent string) {
+	if description == "" {
+		return
+	}
+	if !strings.Contains(description, "\n") {
+		fmt.Fprintf(b, "%s%s\n", indent, strconv.Quote(description))
+		return
+	}
+
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(description, "\n") {
+		fmt.Fprintf(b, "%s%s\n", indent, strings.Replace(line, `"""`, `\"""`, -1))
+	}
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+}
+
+// arguments returns the arguments of a field as they're written in the SDL.
+func arguments(args []*graphql.Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	sorted := make([]*graphql.Argument, len(args))
+	copy(sorted, args)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+
+	parts := make([]string, 0, len(sorted))
+	for _, a := range sorted {
+		parts = append(parts, inputValue(a.Name(), a.Type, a.DefaultValue))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// inputValue returns an argument or input field as it's written in the SDL. The default
+// values of the schema are enum values, numbers and strings.
+func inputValue(name string, t graphql.Input, defaultValue interface{}) string {
+	s := fmt.Sprintf("%s: %s", name, t)
+	switch v := defaultValue.(type) {
+	case nil:
+	case string:
+		if _, ok := t.(*graphql.Enum); ok {
+			s += " = " + v
+		} else {
+			s += " = " + strconv.Quote(v)
+		}
+	default:
+		s += fmt.Sprintf(" = %v", v)
+	}
+	return s
+}
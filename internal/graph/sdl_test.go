@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestSDL(t *testing.T) {
+	sdl := SDL()
+
+	if again := SDL(); again != sdl {
+		t.Errorf("SDL() isn't the same every time")
+	}
+
+	// The SDL is valid and has the types of the schema, Query and Mutation first
+	doc, err := parser.Parse(parser.ParseParams{Source: sdl})
+	if err != nil {
+		t.Fatalf("SDL() can't be parsed: %v", err)
+	}
+
+	var names []string
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.ObjectDefinition:
+			names = append(names, def.Name.Value)
+		case *ast.InputObjectDefinition:
+			names = append(names, def.Name.Value)
+		case *ast.EnumDefinition:
+			names = append(names, def.Name.Value)
+		case *ast.ScalarDefinition:
+			names = append(names, def.Name.Value)
+		default:
+			t.Errorf("unexpected definition %T", def)
+		}
+	}
+	want := []string{"Query", "Mutation", "DateTime", "PageInfo", "Product", "ProductConnection", "ProductEdge", "ProductFilter", "ProductInput", "ProductStatus"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("SDL() has types %v, want %v", names, want)
+	}
+
+	for _, line := range []string{
+		`  products(after: String, filter: ProductFilter, first: Int = 20): ProductConnection!`,
+		`  createProduct(input: ProductInput!, status: ProductStatus = DRAFT): Product!`,
+		`  tags: [String!]!`,
+		`  "The lifecycle state of the product. Requires the catalog:write role."`,
+		`input ProductFilter {`,
+		`enum ProductStatus {`,
+		`scalar DateTime`,
+	} {
+		if !strings.Contains(sdl, line+"\n") {
+			t.Errorf("SDL() has no line %q", line)
+		}
+	}
+}
+
+func TestWriteDescription(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"", ""},
+		{"A product.", "  \"A product.\"\n"},
+		{`The "best" product.`, "  \"The \\\"best\\\" product.\"\n"},
+		{"A product.\nOf the catalog.", "  \"\"\"\n  A product.\n  Of the catalog.\n  \"\"\"\n"},
+		{"Quoted\n\"\"\"", "  \"\"\"\n  Quoted\n  \\\"\"\"\n  \"\"\"\n"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		writeDescription(&b, tt.description, "  ")
+		if b.String() != tt.want {
+			t.Errorf("writeDescription(%q) = %q, want %q", tt.description, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package health reports whether the Catalog service of the ACME Serverless Fitness Shop
+// and the components it depends on are up, for liveness and readiness probes.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status is the state of the service or one of its components.
+type Status string
+
+const (
+	// StatusUp means the component can serve requests
+	StatusUp Status = "up"
+
+	// StatusDown means the component can't serve requests
+	StatusDown Status = "down"
+)
+
+// Pinger is implemented by components that can check whether they can be reached.
+type Pinger interface {
+	Ping() error
+}
+
+// Check is a named component of the service that is checked for readiness.
+type Check struct {
+	// Name identifies the component in the report
+	Name string
+
+	// Pinger checks the component
+	Pinger Pinger
+}
+
+// Component is the result of the check of a single component.
+type Component struct {
+	// Status is the state of the component
+	Status Status `json:"status"`
+
+	// Latency is how long the check took
+	Latency string `json:"latency"`
+
+	// Error is the reason the component is down
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the state of the service and its components.
+type Report struct {
+	// Status is up when all components are up
+	Status Status `json:"status"`
+
+	// Components are the results of the checks, by name
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Marshal returns the JSON encoding of Report
+func (r Report) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Live returns the report of a process that is up, without checking its components.
+func Live() Report {
+	return Report{Status: StatusUp}
+}
+
+// Ready runs the checks in parallel and returns the report. A check that doesn't finish
+// within the timeout marks its component as down.
+func Ready(checks []Check, timeout time.Duration) Report {
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]Component, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+
+			res := run(c.Pinger, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[c.Name] = res
+			if res.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// run pings a single component
+func run(p Pinger, timeout time.Duration) Component {
+	start := time.Now()
+
+	// The channel is buffered, so a ping that finishes after the timeout doesn't block
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Ping()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		err = fmt.Errorf("no response within %s", timeout)
+	}
+
+	c := Component{
+		Status:  StatusUp,
+		Latency: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = err.Error()
+	}
+
+	return c
+}
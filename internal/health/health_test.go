@@ -0,0 +1,129 @@
+package health
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pinger is a component that answers after the delay with err, or never when block is set.
+type pinger struct {
+	delay time.Duration
+	err   error
+	block chan struct{}
+}
+
+func (p pinger) Ping() error {
+	if p.block != nil {
+		<-p.block
+	}
+	time.Sleep(p.delay)
+	return p.err
+}
+
+func TestReady(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus Status
+		wantErrors map[string]string
+	}{
+		{
+			name:       "no checks",
+			wantStatus: StatusUp,
+		},
+		{
+			name: "all up",
+			checks: []Check{
+				{Name: "datastore", Pinger: pinger{}},
+				{Name: "events", Pinger: pinger{delay: 10 * time.Millisecond}},
+			},
+			wantStatus: StatusUp,
+		},
+		{
+			name: "one down",
+			checks: []Check{
+				{Name: "datastore", Pinger: pinger{}},
+				{Name: "events", Pinger: pinger{err: errors.New("connection refused")}},
+			},
+			wantStatus: StatusDown,
+			wantErrors: map[string]string{"events": "connection refused"},
+		},
+		{
+			name: "one doesn't respond",
+			checks: []Check{
+				{Name: "datastore", Pinger: pinger{}},
+				{Name: "webhooks", Pinger: pinger{block: block}},
+			},
+			wantStatus: StatusDown,
+			wantErrors: map[string]string{"webhooks": "no response within 50ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		report := Ready(tt.checks, 50*time.Millisecond)
+		if report.Status != tt.wantStatus {
+			t.Errorf("%s: status = %s, want %s", tt.name, report.Status, tt.wantStatus)
+		}
+		if len(report.Components) != len(tt.checks) {
+			t.Errorf("%s: %d components, want %d", tt.name, len(report.Components), len(tt.checks))
+		}
+		for _, c := range tt.checks {
+			got := report.Components[c.Name]
+			want := tt.wantErrors[c.Name]
+			if got.Error != want || (got.Status == StatusUp) != (want == "") {
+				t.Errorf("%s: component %s = %+v, want error %q", tt.name, c.Name, got, want)
+			}
+		}
+	}
+}
+
+// TestReadyTimeout checks that components that don't respond don't hold up the report for
+// longer than the timeout.
+func TestReadyTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	checks := []Check{
+		{Name: "datastore", Pinger: pinger{block: block}},
+		{Name: "audit", Pinger: pinger{block: block}},
+		{Name: "events", Pinger: pinger{}},
+	}
+
+	start := time.Now()
+	report := Ready(checks, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Ready() took %s, want about the timeout of 100ms", elapsed)
+	}
+
+	if report.Status != StatusDown || report.Components["events"].Status != StatusUp {
+		t.Errorf("Ready() = %+v, want down with events up", report)
+	}
+	for _, name := range []string{"datastore", "audit"} {
+		if c := report.Components[name]; c.Status != StatusDown || !strings.Contains(c.Error, "no response") {
+			t.Errorf("component %s = %+v, want down without a response", name, c)
+		}
+	}
+}
+
+// TestReadyParallel checks that the components are checked at the same time, so the
+// report takes as long as the slowest check rather than all checks together.
+func TestReadyParallel(t *testing.T) {
+	var checks []Check
+	for _, name := range []string{"datastore", "audit", "events", "webhooks"} {
+		checks = append(checks, Check{Name: name, Pinger: pinger{delay: 100 * time.Millisecond}})
+	}
+
+	start := time.Now()
+	report := Ready(checks, time.Second)
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Ready() took %s, want about 100ms", elapsed)
+	}
+	if report.Status != StatusUp {
+		t.Errorf("Ready() = %+v, want up", report)
+	}
+}
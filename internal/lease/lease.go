@@ -0,0 +1,118 @@
+// Package lease makes sure a background job of the catalog runs on a single instance at a
+// time, when the service is scaled out. The instances compete for a lease with a name in
+// a shared store, and only the holder runs the job. The holder renews the lease while the
+// job runs, and when it stops or can't renew it in time, the lease expires and another
+// instance takes over.
+package lease
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// Store keeps the leases, and who holds them until when.
+type Store interface {
+	// Acquire gives the lease to the holder for ttl and returns true, if nobody holds it
+	// or it expired. When the holder has the lease already, it's extended. It returns
+	// false when somebody else holds the lease.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+
+	// Release ends the lease, if the holder has it, so another holder can take it
+	// without waiting for it to expire.
+	Release(ctx context.Context, name, holder string) error
+}
+
+// NewHolder returns a name for this instance that is unique, and readable in the store.
+func NewHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%s", host, uuid.Must(uuid.NewV4()).String())
+}
+
+// Run calls fn while the holder has the lease, until ctx is done. The lease is tried
+// every third of ttl, and renewed as often while fn runs. The context of fn is cancelled
+// when the lease is lost, and fn is called again when the lease is taken again or fn
+// returned while the lease is still held. The lease is released when ctx is done.
+func Run(ctx context.Context, store Store, name, holder string, ttl time.Duration, fn func(ctx context.Context)) {
+	every := ttl / 3
+
+	for {
+		ok, err := store.Acquire(ctx, name, holder, ttl)
+		if err != nil && ctx.Err() == nil {
+			logging.Default().Error("error acquiring lease", "function", "Run", "lease", name, "error", err)
+		}
+		if ok {
+			logging.Default().Info("acquired lease", "lease", name, "holder", holder)
+			hold(ctx, store, name, holder, ttl, fn)
+		}
+
+		select {
+		case <-ctx.Done():
+			release(store, name, holder)
+			return
+		case <-time.After(every):
+		}
+	}
+}
+
+// hold runs fn and renews the lease every third of ttl, until fn returns. When the lease
+// is taken by somebody else, or it would expire before the next renewal, fn is cancelled.
+func hold(ctx context.Context, store Store, name, holder string, ttl time.Duration, fn func(ctx context.Context)) {
+	every := ttl / 3
+
+	fctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(fctx)
+	}()
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := store.Acquire(ctx, name, holder, ttl)
+		if err == nil && ok {
+			renewed = time.Now()
+			continue
+		}
+		if err != nil && ctx.Err() == nil && time.Since(renewed)+every < ttl {
+			logging.Default().Warn("error renewing lease", "function", "hold", "lease", name, "error", err)
+			continue
+		}
+
+		if ctx.Err() == nil {
+			logging.Default().Warn("lost lease", "lease", name, "holder", holder, "error", err)
+		}
+		cancel()
+		<-done
+		return
+	}
+}
+
+// release ends the lease of the holder with a context of its own, as the one of Run is
+// done by then.
+func release(store Store, name, holder string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := store.Release(ctx, name, holder); err != nil {
+		logging.Default().Error("error releasing lease", "function", "release", "lease", name, "error", err)
+	}
+}
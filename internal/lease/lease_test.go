@@ -0,0 +1,128 @@
+package lease
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		op     func() (bool, error)
+		wantOK bool
+	}{
+		{name: "free lease", op: func() (bool, error) { return m.Acquire(ctx, "watch", "a", time.Minute) }, wantOK: true},
+		{name: "held by another", op: func() (bool, error) { return m.Acquire(ctx, "watch", "b", time.Minute) }},
+		{name: "renewed by the holder", op: func() (bool, error) { return m.Acquire(ctx, "watch", "a", time.Minute) }, wantOK: true},
+		{name: "other lease", op: func() (bool, error) { return m.Acquire(ctx, "schedule", "b", time.Minute) }, wantOK: true},
+		{name: "released by another", op: func() (bool, error) {
+			m.Release(ctx, "watch", "b")
+			return m.Acquire(ctx, "watch", "b", time.Minute)
+		}},
+		{name: "released by the holder", op: func() (bool, error) {
+			m.Release(ctx, "watch", "a")
+			return m.Acquire(ctx, "watch", "b", time.Millisecond)
+		}, wantOK: true},
+		{name: "expired", op: func() (bool, error) {
+			time.Sleep(5 * time.Millisecond)
+			return m.Acquire(ctx, "watch", "a", time.Minute)
+		}, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		ok, err := tt.op()
+		if err != nil || ok != tt.wantOK {
+			t.Errorf("%s: got %t, %v, want %t", tt.name, ok, err, tt.wantOK)
+		}
+	}
+}
+
+// TestRun checks that of two instances only one runs the job, and that the other one
+// takes over when the first one stops.
+func TestRun(t *testing.T) {
+	store := NewMemory()
+	ttl := 30 * time.Millisecond
+
+	var running, max, calls int32
+	job := func(ctx context.Context) {
+		atomic.AddInt32(&calls, 1)
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&running, -1)
+	}
+
+	ctx1, stop1 := context.WithCancel(context.Background())
+	ctx2, stop2 := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		Run(ctx1, store, "watch", "a", ttl, job)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		Run(ctx2, store, "watch", "b", ttl, job)
+	}()
+
+	time.Sleep(4 * ttl)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("the job was started %d times, want 1", n)
+	}
+
+	// The first instance releases the lease when it stops, and the second one takes over
+	stop1()
+	time.Sleep(2 * ttl)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("the job was started %d times after the holder stopped, want 2", n)
+	}
+
+	stop2()
+	wg.Wait()
+	if m := atomic.LoadInt32(&max); m != 1 {
+		t.Errorf("the job ran on %d instances at the same time, want 1", m)
+	}
+}
+
+// taken is a store whose lease is taken by somebody else after the first acquire.
+type taken struct {
+	acquired int32
+}
+
+func (s *taken) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	return atomic.AddInt32(&s.acquired, 1) == 1, nil
+}
+
+func (s *taken) Release(ctx context.Context, name, holder string) error {
+	return nil
+}
+
+// TestRunLost checks that the job is cancelled when the lease is lost.
+func TestRunLost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lost := make(chan struct{})
+	go Run(ctx, &taken{}, "watch", "a", 30*time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		close(lost)
+	})
+
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("the job wasn't cancelled when the lease was lost")
+	}
+}
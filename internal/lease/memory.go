@@ -0,0 +1,50 @@
+package lease
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a store that keeps the leases in memory, for tests and for a service that
+// runs a single instance.
+type Memory struct {
+	mu     sync.Mutex
+	leases map[string]held
+}
+
+// held is who holds a lease until when
+type held struct {
+	holder  string
+	expires time.Time
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{leases: make(map[string]held)}
+}
+
+// Acquire gives the lease to the holder, if nobody else holds it
+func (m *Memory) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if l, ok := m.leases[name]; ok && l.holder != holder && now.Before(l.expires) {
+		return false, nil
+	}
+
+	m.leases[name] = held{holder: holder, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release ends the lease, if the holder has it
+func (m *Memory) Release(ctx context.Context, name, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.leases[name]; ok && l.holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+)
+
+// contextValue is the user value of a fasthttp request that holds its logging context
+const contextValue = "logging.context"
+
+// Middleware returns a fasthttp handler that gives every request handled by next a request
+// ID, taken from the X-Request-ID header or generated, and echoes it in the response. The
+// logger that adds the ID to every line is available to next through FromRequest.
+func Middleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		id := RequestID(string(ctx.Request.Header.Peek(RequestIDHeader)))
+
+		ctx.SetUserValue(contextValue, WithRequestID(context.Background(), id))
+		ctx.Response.Header.Set(RequestIDHeader, id)
+
+		next(ctx)
+	}
+}
+
+// ContextFromRequest returns the context that holds the request ID and logger of the
+// request, or an empty context when the request didn't pass through Middleware.
+func ContextFromRequest(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(contextValue).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
+
+// FromRequest returns the logger of the request
+func FromRequest(ctx *fasthttp.RequestCtx) *Logger {
+	return FromContext(ContextFromRequest(ctx))
+}
+
+// RequestIDFromRequest returns the request ID of the request
+func RequestIDFromRequest(ctx *fasthttp.RequestCtx) string {
+	return RequestIDFromContext(ContextFromRequest(ctx))
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that gives every call a request ID,
+// taken from the x-request-id metadata or generated, and sends it back in the header
+// metadata. The logger that adds the ID to every line is passed to the handler in the
+// context.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withCallID(ctx), req)
+	}
+}
+
+// StreamServerInterceptor returns the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withCallID(ss.Context())})
+	}
+}
+
+// withCallID returns a copy of ctx that holds the request ID of the call, and sends the
+// ID to the client.
+func withCallID(ctx context.Context) context.Context {
+	var candidate string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(strings.ToLower(RequestIDHeader)); len(v) > 0 {
+			candidate = v[0]
+		}
+	}
+
+	id := RequestID(candidate)
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+
+	return WithRequestID(ctx, id)
+}
+
+// serverStream is a server stream with the context of the call replaced.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the call
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,30 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+)
+
+// LambdaHandler handles an API Gateway event.
+type LambdaHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WrapLambda returns a Lambda handler that gives every event handled by next a request ID
+// and echoes it in the response. The ID is taken from the X-Request-ID header or else the
+// ID API Gateway gave the request, so it can be found in the API Gateway logs too. The
+// logger that adds the ID to every line is passed to next in the context.
+func WrapLambda(next LambdaHandler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		id := RequestID(apigateway.Header(request, RequestIDHeader), request.RequestContext.RequestID)
+
+		res, err := next(WithRequestID(ctx, id), request)
+
+		if res.Headers == nil {
+			res.Headers = make(map[string]string)
+		}
+		res.Headers[RequestIDHeader] = id
+
+		return res, err
+	}
+}
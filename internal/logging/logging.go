@@ -0,0 +1,270 @@
+// Package logging writes the logs of the Catalog service of the ACME Serverless Fitness Shop
+// as structured JSON, one object per line, so they can be searched and correlated. Every
+// request gets a request ID, which is added to all log lines written while handling it,
+// echoed to the caller and attached to the errors sent to Sentry.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	// LevelDebug is for details that are only needed while troubleshooting
+	LevelDebug Level = iota
+
+	// LevelInfo is for the normal operation of the service
+	LevelInfo
+
+	// LevelWarn is for problems the service recovered from, like a record that is skipped
+	LevelWarn
+
+	// LevelError is for failed operations
+	LevelError
+)
+
+// String returns the name of the level, as it's written in the logs
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel returns the level with the name, which is case-insensitive
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q, use debug, info, warn or error", s)
+	}
+}
+
+// output is the destination shared by a logger and the loggers derived from it, so lines
+// written at the same time don't interleave.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// field is a key and value added to every line of a logger.
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Logger writes structured log lines at or above its level.
+type Logger struct {
+	out    *output
+	level  Level
+	fields []field
+}
+
+// New creates a logger that writes the lines at or above level to w
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{
+		out:   &output{w: w},
+		level: level,
+	}
+}
+
+// FromEnv creates a logger that writes to stderr at the level in LOG_LEVEL, which is
+// debug, info, warn or error and defaults to info.
+func FromEnv() (*Logger, error) {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing LOG_LEVEL: %s", err.Error())
+	}
+
+	return New(os.Stderr, level), nil
+}
+
+// With returns a logger that adds the key value pairs to every line
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(kv)/2)
+	copy(fields, l.fields)
+
+	return &Logger{
+		out:    l.out,
+		level:  l.level,
+		fields: appendFields(fields, kv),
+	}
+}
+
+// Enabled returns true if lines at the level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes a line at LevelDebug with the key value pairs
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+// Info writes a line at LevelInfo with the key value pairs
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn writes a line at LevelWarn with the key value pairs
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+// Error writes a line at LevelError with the key value pairs
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// Writer returns a writer that logs every line written to it at the level, so the
+// standard library logger can write structured lines too.
+func (l *Logger) Writer(level Level) io.Writer {
+	return writer{l: l, level: level}
+}
+
+// log writes a single line
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeValue(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeValue(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeValue(&buf, msg)
+
+	for _, f := range appendFields(l.fields, kv) {
+		buf.WriteByte(',')
+		writeValue(&buf, f.key)
+		buf.WriteByte(':')
+		writeValue(&buf, f.value)
+	}
+	buf.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+// appendFields adds the key value pairs to fields. A key without a value gets a value
+// that makes the mistake visible in the logs.
+func appendFields(fields []field, kv []interface{}) []field {
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		var value interface{} = "MISSING"
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+		fields = append(fields, field{key: key, value: value})
+	}
+	return fields
+}
+
+// writeValue writes the JSON encoding of v. Errors are written as their message and
+// values that can't be encoded as their default format.
+func writeValue(buf *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(b)
+}
+
+// writer logs the lines written to it.
+type writer struct {
+	l     *Logger
+	level Level
+}
+
+// Write logs p, without the trailing newline
+func (w writer) Write(p []byte) (int, error) {
+	w.l.log(w.level, strings.TrimRight(string(p), "\n"), nil)
+	return len(p), nil
+}
+
+// defaultLogger is used when there is no logger in the context
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stderr, LevelInfo)
+)
+
+// Default returns the logger of the process
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault makes l the logger of the process
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// contextKey is the key of the logger in a context.
+type contextKey struct{}
+
+// requestIDKey is the key of the request ID in a context.
+type requestIDKey struct{}
+
+// NewContext returns a copy of ctx that holds the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger in ctx, or the default logger if ctx doesn't have one
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return Default()
+}
+
+// WithRequestID returns a copy of ctx that holds the request ID and a logger that adds it
+// to every line
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return NewContext(ctx, FromContext(ctx).With("request_id", id))
+}
+
+// RequestIDFromContext returns the request ID in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
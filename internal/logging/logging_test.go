@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// capture makes a logger that writes to the returned buffer the default logger until the
+// test ends.
+func capture(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	old := Default()
+	SetDefault(New(&buf, LevelDebug))
+	t.Cleanup(func() { SetDefault(old) })
+	return &buf
+}
+
+// loggedRequestID returns the request_id of the single line in buf
+func loggedRequestID(t *testing.T, buf *bytes.Buffer) string {
+	t.Helper()
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("the log isn't a single JSON line: %v\n%s", err, buf.String())
+	}
+	buf.Reset()
+
+	id, _ := line["request_id"].(string)
+	return id
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{name: "first valid", candidates: []string{"abc-123", "def-456"}, want: "abc-123"},
+		{name: "empty skipped", candidates: []string{"", "def-456"}, want: "def-456"},
+		{name: "space skipped", candidates: []string{"abc 123", "def-456"}, want: "def-456"},
+		{name: "newline skipped", candidates: []string{"abc\n123", "def-456"}, want: "def-456"},
+		{name: "non-ASCII skipped", candidates: []string{"abc-é", "def-456"}, want: "def-456"},
+		{name: "too long skipped", candidates: []string{strings.Repeat("a", 129), "def-456"}, want: "def-456"},
+		{name: "longest accepted", candidates: []string{strings.Repeat("a", 128)}, want: strings.Repeat("a", 128)},
+		{name: "none valid"},
+		{name: "no candidates"},
+	}
+
+	for _, tt := range tests {
+		got := RequestID(tt.candidates...)
+		if tt.want != "" && got != tt.want {
+			t.Errorf("%s: RequestID() = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.want == "" && (!validRequestID(got) || len(got) != 36) {
+			t.Errorf("%s: RequestID() = %q, want a new UUID", tt.name, got)
+		}
+	}
+}
+
+// propagationTests are the request IDs sent by a caller, with the ID the request gets, or
+// an empty ID when a new one is generated.
+var propagationTests = []struct {
+	name string
+	sent string
+	want string
+}{
+	{name: "sent", sent: "req-123", want: "req-123"},
+	{name: "not sent"},
+	{name: "invalid", sent: "req 123"},
+}
+
+// checkPropagation checks that the request ID of the request is the one it's logged with,
+// sent back and, when the caller sent a valid one, the one it sent.
+func checkPropagation(t *testing.T, name string, want string, ctxID string, logged string, returned string) {
+	t.Helper()
+
+	if want != "" && ctxID != want {
+		t.Errorf("%s: request ID = %q, want %q", name, ctxID, want)
+	}
+	if want == "" && len(ctxID) != 36 {
+		t.Errorf("%s: request ID = %q, want a new UUID", name, ctxID)
+	}
+	if logged != ctxID {
+		t.Errorf("%s: logged request ID = %q, want %q", name, logged, ctxID)
+	}
+	if returned != ctxID {
+		t.Errorf("%s: returned request ID = %q, want %q", name, returned, ctxID)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	buf := capture(t)
+
+	for _, tt := range propagationTests {
+		var ctxID string
+		handler := Middleware(func(ctx *fasthttp.RequestCtx) {
+			ctxID = RequestIDFromRequest(ctx)
+			FromRequest(ctx).Info("handled")
+		})
+
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(http.MethodGet)
+		ctx.Request.SetRequestURI("/products")
+		if tt.sent != "" {
+			ctx.Request.Header.Set(RequestIDHeader, tt.sent)
+		}
+		handler(&ctx)
+
+		checkPropagation(t, tt.name, tt.want, ctxID, loggedRequestID(t, buf), string(ctx.Response.Header.Peek(RequestIDHeader)))
+	}
+}
+
+func TestWrapLambda(t *testing.T) {
+	buf := capture(t)
+
+	for _, tt := range propagationTests {
+		var ctxID string
+		handler := WrapLambda(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			ctxID = RequestIDFromContext(ctx)
+			FromContext(ctx).Info("handled")
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+		})
+
+		request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/products"}
+		if tt.sent != "" {
+			request.Headers = map[string]string{"x-request-id": tt.sent}
+		}
+		res, err := handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("%s: handler() = %v", tt.name, err)
+		}
+
+		checkPropagation(t, tt.name, tt.want, ctxID, loggedRequestID(t, buf), res.Headers[RequestIDHeader])
+	}
+
+	// Without an X-Request-ID, the ID API Gateway gave the request is used
+	handler := WrapLambda(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+	request := events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"x-request-id": "req 123"},
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gw-456"},
+	}
+	res, _ := handler(context.Background(), request)
+	if got := res.Headers[RequestIDHeader]; got != "gw-456" {
+		t.Errorf("request ID = %q, want the ID of API Gateway gw-456", got)
+	}
+}
+
+// TestServerInterceptors calls the health service of a gRPC server with the interceptors,
+// and an interceptor after them that logs with the context the handler gets.
+func TestServerInterceptors(t *testing.T) {
+	buf := capture(t)
+
+	var ctxID string
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(), func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctxID = RequestIDFromContext(ctx)
+			FromContext(ctx).Info("handled")
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(), func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctxID = RequestIDFromContext(ss.Context())
+			FromContext(ss.Context()).Info("handled")
+			return handler(srv, ss)
+		}),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+
+	for _, tt := range propagationTests {
+		callCtx := ctx
+		if tt.sent != "" {
+			callCtx = metadata.AppendToOutgoingContext(ctx, "x-request-id", tt.sent)
+		}
+
+		var header metadata.MD
+		if _, err := client.Check(callCtx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("%s: Check() = %v", tt.name, err)
+		}
+		checkPropagation(t, tt.name+" unary", tt.want, ctxID, loggedRequestID(t, buf), strings.Join(header.Get("x-request-id"), ","))
+
+		watchCtx, cancel := context.WithCancel(callCtx)
+		stream, err := client.Watch(watchCtx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("%s: Watch() = %v", tt.name, err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("%s: Recv() = %v", tt.name, err)
+		}
+		header, err = stream.Header()
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: Header() = %v", tt.name, err)
+		}
+		checkPropagation(t, tt.name+" stream", tt.want, ctxID, loggedRequestID(t, buf), strings.Join(header.Get("x-request-id"), ","))
+	}
+}
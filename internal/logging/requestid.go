@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// RequestIDHeader is the header that carries the request ID, in requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID that is accepted from a caller
+const maxRequestIDLength = 128
+
+// RequestID returns the first of the candidates that is a valid request ID, or a new
+// random ID if none of them is. IDs sent by callers are only used when they're at most
+// 128 visible ASCII characters, so they can't break the logs or the response headers.
+func RequestID(candidates ...string) string {
+	for _, c := range candidates {
+		if validRequestID(c) {
+			return c
+		}
+	}
+	return uuid.Must(uuid.NewV4()).String()
+}
+
+// validRequestID returns true if id can be used as a request ID
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
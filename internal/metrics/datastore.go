@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// manager records the calls to the Manager it wraps.
+type manager struct {
+	db      datastore.Manager
+	backend string
+	r       Recorder
+}
+
+// Instrument returns a Manager that records the latency and errors of every call to db,
+// which is the named backend.
+func Instrument(db datastore.Manager, backend string, r Recorder) datastore.Manager {
+	return manager{
+		db:      db,
+		backend: backend,
+		r:       r,
+	}
+}
+
+// observe records a call to method that started at start
+func (m manager) observe(method string, start time.Time, err error) {
+	m.r.ObserveCall(Call{
+		Backend:  m.backend,
+		Method:   method,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// AddProduct stores a product and records the call
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.AddProduct(p)
+	m.observe("AddProduct", start, err)
+	return rev, err
+}
+
+// GetProduct retrieves a product and records the call
+func (m manager) GetProduct(productID string) (datastore.Product, error) {
+	start := time.Now()
+	p, err := m.db.GetProduct(productID)
+	m.observe("GetProduct", start, err)
+	return p, err
+}
+
+// GetProducts retrieves all products and records the call
+func (m manager) GetProducts() ([]datastore.Product, error) {
+	start := time.Now()
+	p, err := m.db.GetProducts()
+	m.observe("GetProducts", start, err)
+	return p, err
+}
+
+// GetProductsByIDs retrieves the products with the IDs and records the call
+func (m manager) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	start := time.Now()
+	p, err := m.db.GetProductsByIDs(productIDs)
+	m.observe("GetProductsByIDs", start, err)
+	return p, err
+}
+
+// SetProductStatus moves a product to a new status and records the call
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.SetProductStatus(productID, status)
+	m.observe("SetProductStatus", start, err)
+	return rev, err
+}
+
+// UpdateProductItem replaces the catalog item of a product and records the call
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.UpdateProductItem(item)
+	m.observe("UpdateProductItem", start, err)
+	return rev, err
+}
+
+// SetProductWindow changes the availability window of a product and records the call
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.SetProductWindow(productID, window)
+	m.observe("SetProductWindow", start, err)
+	return rev, err
+}
+
+// DeleteProduct removes a product and records the call
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.DeleteProduct(productID)
+	m.observe("DeleteProduct", start, err)
+	return rev, err
+}
+
+// ForEachProduct calls fn for every product and records the call, including the time
+// spent in fn
+func (m manager) ForEachProduct(fn func(p datastore.Product) error) error {
+	start := time.Now()
+	err := m.db.ForEachProduct(fn)
+	m.observe("ForEachProduct", start, err)
+	return err
+}
+
+// GetRevisions retrieves the revisions of a product and records the call
+func (m manager) GetRevisions(productID string) ([]datastore.Revision, error) {
+	start := time.Now()
+	revs, err := m.db.GetRevisions(productID)
+	m.observe("GetRevisions", start, err)
+	return revs, err
+}
+
+// RollbackProduct restores a version of a product and records the call
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	start := time.Now()
+	rev, err := m.db.RollbackProduct(productID, version)
+	m.observe("RollbackProduct", start, err)
+	return rev, err
+}
+
+// Ping checks the data store and records the call
+func (m manager) Ping() error {
+	start := time.Now()
+	err := m.db.Ping()
+	m.observe("Ping", start, err)
+	return err
+}
+
+// WithActor returns a Manager that records actor in the revisions and keeps recording
+// the calls
+func (m manager) WithActor(actor string) datastore.Manager {
+	return Instrument(m.db.WithActor(actor), m.backend, m.r)
+}
+
+// WithContext returns a Manager that runs its operations with ctx and keeps recording
+// the calls
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	return Instrument(m.db.WithContext(ctx), m.backend, m.r)
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Middleware returns a fasthttp handler that records every request handled by next. The
+// route is the pattern next is registered with, so requests for different products are
+// counted together. Streamed responses, like the export and the live feed, are recorded
+// when next returns, before their body is sent.
+func Middleware(r Recorder, route string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		// Reading the body of a streamed response would buffer all of it before it's
+		// sent, so its size isn't recorded
+		bytesOut := 0
+		if !ctx.IsBodyStream() {
+			bytesOut = len(ctx.Response.Body())
+		}
+
+		r.ObserveRequest(Request{
+			Route:    route,
+			Method:   string(ctx.Method()),
+			Status:   ctx.Response.StatusCode(),
+			Duration: time.Since(start),
+			BytesIn:  len(ctx.Request.Body()),
+			BytesOut: bytesOut,
+		})
+	}
+}
+
+// Handler returns a fasthttp handler that serves the metrics in the format Prometheus
+// asks for, the text exposition format by default.
+func Handler(p *Prometheus) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(p.Gatherer(), promhttp.HandlerOpts{}))
+}
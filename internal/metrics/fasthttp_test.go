@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// recorder keeps the requests it observes.
+type recorder struct {
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (r *recorder) ObserveRequest(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+}
+
+func (r *recorder) ObserveCall(c Call)                         {}
+func (r *recorder) SetProducts(status datastore.Status, n int) {}
+func (r *recorder) Close() error                               { return nil }
+
+func (r *recorder) observed() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// serve runs handler on a local port until the test ends and returns its URL.
+func serve(t *testing.T, handler fasthttp.RequestHandler) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go fasthttp.Serve(ln, handler)
+	return "http://" + ln.Addr().String()
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := &recorder{}
+	url := serve(t, Middleware(rec, "/products/{id}", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(http.StatusCreated)
+		ctx.SetBodyString("hello")
+	}))
+
+	res, err := http.Post(url+"/products/1", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	got := rec.observed()
+	if len(got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(got))
+	}
+	if got[0].Route != "/products/{id}" || got[0].Method != http.MethodPost || got[0].Status != http.StatusCreated || got[0].BytesOut != 5 {
+		t.Errorf("got %+v", got[0])
+	}
+}
+
+// TestMiddlewareStream checks that a streamed response is sent while it's being written,
+// instead of being buffered by the middleware until the stream ends.
+func TestMiddlewareStream(t *testing.T) {
+	rec := &recorder{}
+	done := make(chan struct{})
+	defer close(done)
+
+	url := serve(t, Middleware(rec, "/products/stream", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("text/event-stream")
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			w.WriteString(": ping\n\n")
+			w.Flush()
+			<-done
+		})
+	}))
+
+	lines := make(chan string, 1)
+	go func() {
+		res, err := http.Get(url + "/products/stream")
+		if err != nil {
+			lines <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+
+		line, _ := bufio.NewReader(res.Body).ReadString('\n')
+		lines <- line
+	}()
+
+	select {
+	case line := <-lines:
+		if line != ": ping\n" {
+			t.Fatalf("got %q, want the first line of the stream", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream wasn't sent before it ended")
+	}
+
+	got := rec.observed()
+	if len(got) != 1 || got[0].BytesOut != 0 {
+		t.Errorf("got %+v, want a single request without BytesOut", got)
+	}
+}
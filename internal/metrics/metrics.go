@@ -0,0 +1,127 @@
+// Package metrics records the metrics of the Catalog service of the ACME Serverless Fitness
+// Shop: the rate, errors and duration of the requests to every route of the API, the
+// latency of the calls to the data store and the size of the catalog. The metrics are
+// sent to one or more backends, like Prometheus and Wavefront, through a Recorder.
+package metrics
+
+import (
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Request is a single request handled by the API.
+type Request struct {
+	// Route is the pattern of the route that handled the request, like /products/{id}
+	Route string
+
+	// Method is the HTTP method of the request
+	Method string
+
+	// Status is the HTTP status code of the response
+	Status int
+
+	// Duration is how long the request took
+	Duration time.Duration
+
+	// BytesIn is the size of the request body
+	BytesIn int
+
+	// BytesOut is the size of the response body, or 0 for streamed responses
+	BytesOut int
+}
+
+// Call is a single call to a method of a datastore.Manager.
+type Call struct {
+	// Backend is the data store that handled the call, like mongodb
+	Backend string
+
+	// Method is the name of the Manager method
+	Method string
+
+	// Duration is how long the call took
+	Duration time.Duration
+
+	// Err is the error returned by the call
+	Err error
+}
+
+// Recorder is a metrics backend.
+type Recorder interface {
+	// ObserveRequest records a request handled by the API
+	ObserveRequest(r Request)
+
+	// ObserveCall records a call to the data store
+	ObserveCall(c Call)
+
+	// SetProducts sets the number of products in the catalog with the status
+	SetProducts(status datastore.Status, n int)
+
+	// Close sends the metrics that are still buffered and releases the backend
+	Close() error
+}
+
+// multi sends the metrics to several backends.
+type multi []Recorder
+
+// Multi returns a recorder that sends the metrics to all recorders, so backends can run
+// side by side.
+func Multi(recorders ...Recorder) Recorder {
+	return multi(recorders)
+}
+
+// ObserveRequest records the request in all backends
+func (m multi) ObserveRequest(r Request) {
+	for _, rec := range m {
+		rec.ObserveRequest(r)
+	}
+}
+
+// ObserveCall records the call in all backends
+func (m multi) ObserveCall(c Call) {
+	for _, rec := range m {
+		rec.ObserveCall(c)
+	}
+}
+
+// SetProducts sets the number of products in all backends
+func (m multi) SetProducts(status datastore.Status, n int) {
+	for _, rec := range m {
+		rec.SetProducts(status, n)
+	}
+}
+
+// Close closes all backends and returns the first error
+func (m multi) Close() error {
+	var first error
+	for _, rec := range m {
+		if err := rec.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// RecordProducts counts the products in the catalog by status and sets the numbers in the
+// recorder. Statuses without products are set to zero.
+func RecordProducts(db datastore.Manager, r Recorder) error {
+	counts := map[datastore.Status]int{
+		datastore.StatusDraft:     0,
+		datastore.StatusPublished: 0,
+		datastore.StatusArchived:  0,
+	}
+
+	err := db.ForEachProduct(func(p datastore.Product) error {
+		counts[p.Status]++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for status, n := range counts {
+		r.SetProducts(status, n)
+	}
+
+	return nil
+}
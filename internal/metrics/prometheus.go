@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Prometheus keeps the metrics in a Prometheus registry, so Prometheus can scrape them.
+type Prometheus struct {
+	registry         *prometheus.Registry
+	requests         *prometheus.CounterVec
+	requestDurations *prometheus.HistogramVec
+	callDurations    *prometheus.HistogramVec
+	callErrors       *prometheus.CounterVec
+	products         *prometheus.GaugeVec
+}
+
+// NewPrometheus creates a Prometheus recorder with its own registry, which also has the
+// metrics of the Go runtime and the process.
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "catalog_http_requests_total",
+			Help: "Number of HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "catalog_http_request_duration_seconds",
+			Help:    "Time spent handling HTTP requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		callDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "catalog_datastore_call_duration_seconds",
+			Help:    "Time spent in calls to the data store, by backend and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"backend", "method"}),
+		callErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "catalog_datastore_call_errors_total",
+			Help: "Number of calls to the data store that failed, by backend and method.",
+		}, []string{"backend", "method"}),
+		products: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "catalog_products",
+			Help: "Number of products in the catalog, by status.",
+		}, []string{"status"}),
+	}
+
+	p.registry.MustRegister(
+		p.requests,
+		p.requestDurations,
+		p.callDurations,
+		p.callErrors,
+		p.products,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return p
+}
+
+// ObserveRequest counts the request by route, method and status code, and adds its
+// duration to the histogram of the route and method
+func (p *Prometheus) ObserveRequest(r Request) {
+	p.requests.WithLabelValues(r.Route, r.Method, strconv.Itoa(r.Status)).Inc()
+	p.requestDurations.WithLabelValues(r.Route, r.Method).Observe(r.Duration.Seconds())
+}
+
+// ObserveCall adds the duration of the call to the histogram of the backend and method,
+// and counts it when it failed
+func (p *Prometheus) ObserveCall(c Call) {
+	p.callDurations.WithLabelValues(c.Backend, c.Method).Observe(c.Duration.Seconds())
+	if c.Err != nil {
+		p.callErrors.WithLabelValues(c.Backend, c.Method).Inc()
+	}
+}
+
+// SetProducts sets the number of products with the status
+func (p *Prometheus) SetProducts(status datastore.Status, n int) {
+	p.products.WithLabelValues(string(status)).Set(float64(n))
+}
+
+// Gatherer returns the registry of the metrics, to serve them
+func (p *Prometheus) Gatherer() prometheus.Gatherer {
+	return p.registry
+}
+
+// Close does nothing, the metrics are kept in memory
+func (p *Prometheus) Close() error {
+	return nil
+}
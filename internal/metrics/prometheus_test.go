@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus()
+
+	p.ObserveRequest(Request{Route: "/products", Method: http.MethodGet, Status: http.StatusOK, Duration: 3 * time.Millisecond})
+	p.ObserveRequest(Request{Route: "/products", Method: http.MethodGet, Status: http.StatusOK, Duration: 200 * time.Millisecond})
+	p.ObserveRequest(Request{Route: "/products/{id}", Method: http.MethodGet, Status: http.StatusNotFound, Duration: 20 * time.Second})
+	p.ObserveCall(Call{Backend: "mongodb", Method: "GetProducts", Duration: 40 * time.Millisecond})
+	p.ObserveCall(Call{Backend: "mongodb", Method: "GetProduct", Duration: time.Millisecond, Err: errors.New("boom")})
+	p.SetProducts(datastore.StatusPublished, 12)
+	p.SetProducts(datastore.StatusDraft, 3)
+	p.SetProducts(datastore.StatusPublished, 11)
+
+	want := `
+# HELP catalog_datastore_call_errors_total Number of calls to the data store that failed, by backend and method.
+# TYPE catalog_datastore_call_errors_total counter
+catalog_datastore_call_errors_total{backend="mongodb",method="GetProduct"} 1
+# HELP catalog_http_request_duration_seconds Time spent handling HTTP requests, by route and method.
+# TYPE catalog_http_request_duration_seconds histogram
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.005"} 1
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.01"} 1
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.025"} 1
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.05"} 1
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.1"} 1
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.25"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="0.5"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="1"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="2.5"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="5"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="10"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products",le="+Inf"} 2
+catalog_http_request_duration_seconds_sum{method="GET",route="/products"} 0.203
+catalog_http_request_duration_seconds_count{method="GET",route="/products"} 2
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.005"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.01"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.025"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.05"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.1"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.25"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="0.5"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="1"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="2.5"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="5"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="10"} 0
+catalog_http_request_duration_seconds_bucket{method="GET",route="/products/{id}",le="+Inf"} 1
+catalog_http_request_duration_seconds_sum{method="GET",route="/products/{id}"} 20
+catalog_http_request_duration_seconds_count{method="GET",route="/products/{id}"} 1
+# HELP catalog_http_requests_total Number of HTTP requests handled, by route, method and status code.
+# TYPE catalog_http_requests_total counter
+catalog_http_requests_total{code="200",method="GET",route="/products"} 2
+catalog_http_requests_total{code="404",method="GET",route="/products/{id}"} 1
+# HELP catalog_products Number of products in the catalog, by status.
+# TYPE catalog_products gauge
+catalog_products{status="draft"} 3
+catalog_products{status="published"} 11
+`
+	err := testutil.GatherAndCompare(p.Gatherer(), strings.NewReader(want),
+		"catalog_http_requests_total",
+		"catalog_http_request_duration_seconds",
+		"catalog_datastore_call_errors_total",
+		"catalog_products",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Every call is added to the histogram, the failed ones too
+	if n, err := testutil.GatherAndCount(p.Gatherer(), "catalog_datastore_call_duration_seconds"); err != nil || n != 2 {
+		t.Errorf("catalog_datastore_call_duration_seconds has %d series, %v, want 2", n, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	p := NewPrometheus()
+	p.ObserveRequest(Request{Route: "/products", Method: http.MethodGet, Status: http.StatusOK, Duration: time.Millisecond})
+
+	url := serve(t, Handler(p))
+	res, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", ct)
+	}
+	for _, line := range []string{
+		`catalog_http_requests_total{code="200",method="GET",route="/products"} 1`,
+		`# TYPE go_goroutines gauge`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("the metrics have no line %q", line)
+		}
+	}
+}
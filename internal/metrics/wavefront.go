@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	wavefront "github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+// WavefrontDebugServer is the server name that writes the metrics to the debug log instead
+// of sending them to Wavefront
+const WavefrontDebugServer = "debug"
+
+// WavefrontConfig configures the direct ingestion sender to Wavefront.
+type WavefrontConfig struct {
+	// Server is the Wavefront URL of the form https://<INSTANCE>.wavefront.com, or
+	// WavefrontDebugServer to print the metrics to the log
+	Server string
+
+	// Token is the Wavefront API token with direct data ingestion permission
+	Token string
+
+	// FlushInterval is the interval, in seconds, at which data is sent to Wavefront
+	FlushInterval int
+
+	// Source is the name of the app that emits metrics
+	Source string
+
+	// MetricPrefix is added to the name of all metrics
+	MetricPrefix string
+}
+
+// Wavefront sends the metrics to Wavefront. The metrics of the requests have the same
+// names and tags as the ones sent by gcr-wavefront, so existing dashboards keep working.
+type Wavefront struct {
+	cfg    WavefrontConfig
+	sender wavefront.Sender
+}
+
+// NewWavefront creates a recorder that sends the metrics to Wavefront.
+func NewWavefront(cfg WavefrontConfig) (*Wavefront, error) {
+	w := &Wavefront{cfg: cfg}
+
+	if cfg.Server == WavefrontDebugServer {
+		return w, nil
+	}
+
+	sender, err := wavefront.NewDirectSender(&wavefront.DirectConfiguration{
+		Server:               cfg.Server,
+		Token:                cfg.Token,
+		BatchSize:            10000,
+		MaxBufferSize:        50000,
+		FlushIntervalSeconds: cfg.FlushInterval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating wavefront sender: %s", err.Error())
+	}
+	w.sender = sender
+
+	return w, nil
+}
+
+// ObserveRequest sends the latency, the size of the request and response and a counter
+// for the class of the status code
+func (w *Wavefront) ObserveRequest(r Request) {
+	tags := map[string]string{
+		"path":   r.Route,
+		"method": r.Method,
+	}
+	now := time.Now().Unix()
+
+	w.metric(".latency", float64(r.Duration.Microseconds()), now, tags)
+	w.metric(".bytes.in", float64(r.BytesIn), now, tags)
+	w.metric(".bytes.out", float64(r.BytesOut), now, tags)
+
+	switch {
+	case r.Status > 199 && r.Status < 300:
+		w.counter(".status.success", tags)
+	case r.Status > 299 && r.Status < 400:
+		w.counter(".status.redirection", tags)
+	case r.Status > 399 && r.Status < 500:
+		w.counter(".status.error.client", tags)
+	case r.Status > 499 && r.Status < 600:
+		w.counter(".status.error.server", tags)
+	}
+}
+
+// ObserveCall sends the latency of the call, and a counter when it failed
+func (w *Wavefront) ObserveCall(c Call) {
+	tags := map[string]string{
+		"backend": c.Backend,
+		"method":  c.Method,
+	}
+
+	w.metric(".datastore.latency", float64(c.Duration.Microseconds()), time.Now().Unix(), tags)
+	if c.Err != nil {
+		w.counter(".datastore.errors", tags)
+	}
+}
+
+// SetProducts sends the number of products with the status
+func (w *Wavefront) SetProducts(status datastore.Status, n int) {
+	w.metric(".products", float64(n), time.Now().Unix(), map[string]string{"status": string(status)})
+}
+
+// Close sends the buffered metrics to Wavefront and stops the sender
+func (w *Wavefront) Close() error {
+	if w.sender == nil {
+		return nil
+	}
+
+	err := w.sender.Flush()
+	w.sender.Close()
+	return err
+}
+
+// metric sends a single value
+func (w *Wavefront) metric(name string, value float64, ts int64, tags map[string]string) {
+	if w.sender == nil {
+		logging.Default().Debug("metric", "name", w.cfg.MetricPrefix+name, "value", value, "tags", tags)
+		return
+	}
+	w.sender.SendMetric(w.cfg.MetricPrefix+name, value, ts, w.cfg.Source, tags)
+}
+
+// counter adds one to a delta counter
+func (w *Wavefront) counter(name string, tags map[string]string) {
+	if w.sender == nil {
+		logging.Default().Debug("counter", "name", w.cfg.MetricPrefix+name, "value", 1, "tags", tags)
+		return
+	}
+	w.sender.SendDeltaCounter(w.cfg.MetricPrefix+name, 1, w.cfg.Source, tags)
+}
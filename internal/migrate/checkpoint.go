@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Checkpoint records the IDs of the products that have been copied in a file, one ID
+// per line. Since IDs are only ever appended, a migration that crashes halfway leaves
+// a valid checkpoint behind.
+type Checkpoint struct {
+	mu   sync.Mutex
+	file *os.File
+	done map[string]bool
+}
+
+// OpenCheckpoint opens the checkpoint file at path, or creates it if it doesn't exist.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checkpoint{
+		file: f,
+		done: make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			c.done[id] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading checkpoint %s: %s", path, err.Error())
+	}
+
+	return c, nil
+}
+
+// Done returns true if the product has been copied by an earlier run.
+func (c *Checkpoint) Done(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[id]
+}
+
+// Len returns the number of products in the checkpoint.
+func (c *Checkpoint) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.done)
+}
+
+// Add records that the product has been copied.
+func (c *Checkpoint) Add(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintln(c.file, id); err != nil {
+		return err
+	}
+
+	c.done[id] = true
+	return nil
+}
+
+// Close closes the checkpoint file.
+func (c *Checkpoint) Close() error {
+	return c.file.Close()
+}
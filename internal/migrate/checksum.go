@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Summary identifies the contents of a catalog.
+type Summary struct {
+	// Count is the number of products in the catalog
+	Count int `json:"count"`
+
+	// Checksum is the SHA-256 checksum of all products in the catalog
+	Checksum string `json:"checksum"`
+}
+
+// Checksum calculates a checksum over all products, including their status and window, in the data
+// store. The checksum doesn't depend on the order in which the data store returns the
+// products, so two data stores with the same products have the same checksum.
+func Checksum(m datastore.Manager) (Summary, error) {
+	sums := make([][]byte, 0)
+
+	err := m.ForEachProduct(func(p datastore.Product) error {
+		// Products without tags can be stored as null or as an empty array,
+		// both mean the same thing
+		if p.Item.Tags == nil {
+			p.Item.Tags = []string{}
+		}
+
+		// Data stores keep the window in their own time zone and precision,
+		// MongoDB for example only stores milliseconds
+		p.Window.PublishAt = normalizeTime(p.Window.PublishAt)
+		p.Window.UnpublishAt = normalizeTime(p.Window.UnpublishAt)
+
+		payload, err := p.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(payload)
+		sums = append(sums, sum[:])
+		return nil
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+
+	sort.Slice(sums, func(i, j int) bool {
+		return bytes.Compare(sums[i], sums[j]) < 0
+	})
+
+	h := sha256.New()
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+
+	return Summary{
+		Count:    len(sums),
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// normalizeTime returns t in UTC with millisecond precision.
+func normalizeTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	n := t.UTC().Truncate(time.Millisecond)
+	return &n
+}
@@ -0,0 +1,118 @@
+// Package migrate copies the catalog of the ACME Serverless Fitness Shop from one
+// datastore.Manager to another, for example to keep the DynamoDB and MongoDB flavors
+// of the service in sync.
+package migrate
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Options configure a migration.
+type Options struct {
+	// Concurrency is the number of products that are written to the target at the
+	// same time. Values lower than 1 are treated as 1.
+	Concurrency int
+
+	// DryRun reads all products from the source, but doesn't write them to the target
+	// and doesn't update the checkpoint.
+	DryRun bool
+
+	// Checkpoint keeps track of the products that have been copied, so an interrupted
+	// migration can be resumed. Nil disables checkpoints.
+	Checkpoint *Checkpoint
+}
+
+// Result describes the outcome of a migration.
+type Result struct {
+	// Copied is the number of products written to the target (or that would be
+	// written, in case of a dry run)
+	Copied int `json:"copied"`
+
+	// Skipped is the number of products that were already copied by an earlier run
+	Skipped int `json:"skipped"`
+
+	// Failed maps the IDs of the products that couldn't be copied to the error
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Run copies all products from source to target. Products that fail to copy don't stop
+// the migration, they're reported in the Result and an error is returned at the end.
+// Since failed products aren't added to the checkpoint, running the migration again
+// retries only those products.
+func Run(source datastore.Manager, target datastore.Manager, opts Options) (Result, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	res := Result{
+		Failed: make(map[string]string),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	products := make(chan datastore.Product)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range products {
+				err := copyProduct(target, p, opts)
+
+				mu.Lock()
+				if err != nil {
+					res.Failed[p.Item.ID] = err.Error()
+				} else {
+					res.Copied++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := source.ForEachProduct(func(p datastore.Product) error {
+		if opts.Checkpoint != nil && opts.Checkpoint.Done(p.Item.ID) {
+			mu.Lock()
+			res.Skipped++
+			mu.Unlock()
+			return nil
+		}
+
+		products <- p
+		return nil
+	})
+
+	close(products)
+	wg.Wait()
+
+	if err != nil {
+		return res, fmt.Errorf("error reading products from the source: %s", err.Error())
+	}
+
+	if len(res.Failed) > 0 {
+		return res, fmt.Errorf("%d products could not be copied", len(res.Failed))
+	}
+
+	return res, nil
+}
+
+// copyProduct writes a single product to the target and records it in the checkpoint.
+func copyProduct(target datastore.Manager, p datastore.Product, opts Options) error {
+	if opts.DryRun {
+		return nil
+	}
+
+	if _, err := target.AddProduct(p); err != nil {
+		return err
+	}
+
+	if opts.Checkpoint != nil {
+		return opts.Checkpoint.Add(p.Item.ID)
+	}
+
+	return nil
+}
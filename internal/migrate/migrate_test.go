@@ -0,0 +1,196 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	filestore "github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+)
+
+// testDir returns a directory that is removed when the test ends.
+func testDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// testStore returns a data store in a snapshot file in dir with the products.
+func testStore(t *testing.T, dir string, name string, products ...datastore.Product) datastore.Manager {
+	db, err := filestore.New(filepath.Join(dir, name+".ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range products {
+		if _, err := db.AddProduct(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// testProducts returns products in every status, with and without a window.
+func testProducts() []datastore.Product {
+	publishAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []datastore.Product{
+		{Item: acmeserverless.CatalogItem{ID: "p1", Name: "Bottle", Price: 10, Tags: []string{"bottle"}}, Status: datastore.StatusPublished},
+		{Item: acmeserverless.CatalogItem{ID: "p2", Name: "Shoes", Price: 80}, Status: datastore.StatusDraft},
+		{Item: acmeserverless.CatalogItem{ID: "p3", Name: "Mat", Price: 25, Tags: []string{"mat"}}, Status: datastore.StatusPublished, Window: datastore.Window{PublishAt: &publishAt}},
+		{Item: acmeserverless.CatalogItem{ID: "p4", Name: "Band", Price: 5}, Status: datastore.StatusArchived},
+	}
+}
+
+// failing is a data store that can't store one of the products.
+type failing struct {
+	datastore.Manager
+	id string
+}
+
+func (f failing) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	if p.Item.ID == f.id {
+		return datastore.Revision{}, errors.New("throttled")
+	}
+	return f.Manager.AddProduct(p)
+}
+
+// reversed is a data store that returns its products in the reverse order.
+type reversed struct {
+	datastore.Manager
+}
+
+func (r reversed) ForEachProduct(fn func(p datastore.Product) error) error {
+	var products []datastore.Product
+	if err := r.Manager.ForEachProduct(func(p datastore.Product) error {
+		products = append(products, p)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for i := len(products) - 1; i >= 0; i-- {
+		if err := fn(products[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestRunResume checks that a migration that failed halfway only copies the products it
+// didn't copy yet when it's run again with the same checkpoint.
+func TestRunResume(t *testing.T) {
+	dir := testDir(t)
+	source := testStore(t, dir, "source", testProducts()...)
+	target := testStore(t, dir, "target")
+	path := filepath.Join(dir, "checkpoint")
+
+	cp, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(source, failing{Manager: target, id: "p3"}, Options{Concurrency: 2, Checkpoint: cp})
+	cp.Close()
+	if err == nil || res.Copied != 3 || len(res.Failed) != 1 || res.Failed["p3"] == "" {
+		t.Fatalf("first Run() = %+v, %v, want 3 copied and p3 failed", res, err)
+	}
+
+	// The checkpoint is read from the file again, like after a crash
+	cp, err = OpenCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if cp.Len() != 3 || cp.Done("p3") {
+		t.Fatalf("the checkpoint has %d products, p3 %t, want 3 without p3", cp.Len(), cp.Done("p3"))
+	}
+
+	res, err = Run(source, target, Options{Concurrency: 2, Checkpoint: cp})
+	if err != nil || res.Copied != 1 || res.Skipped != 3 || len(res.Failed) != 0 {
+		t.Fatalf("second Run() = %+v, %v, want 1 copied and 3 skipped", res, err)
+	}
+
+	want, err := Checksum(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Checksum(target); err != nil || got != want {
+		t.Errorf("Checksum(target) = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+// TestRunDryRun checks that a dry run reads every product, but doesn't write to the target
+// or the checkpoint.
+func TestRunDryRun(t *testing.T) {
+	dir := testDir(t)
+	source := testStore(t, dir, "source", testProducts()...)
+	target := testStore(t, dir, "target")
+
+	cp, err := OpenCheckpoint(filepath.Join(dir, "checkpoint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	res, err := Run(source, target, Options{DryRun: true, Checkpoint: cp})
+	if err != nil || res.Copied != len(testProducts()) || res.Skipped != 0 {
+		t.Fatalf("Run() = %+v, %v, want %d copied", res, err, len(testProducts()))
+	}
+
+	if got, err := Checksum(target); err != nil || got.Count != 0 {
+		t.Errorf("the target has %d products, %v, want none", got.Count, err)
+	}
+	if cp.Len() != 0 {
+		t.Errorf("the checkpoint has %d products, want none", cp.Len())
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	dir := testDir(t)
+	products := testProducts()
+	want, err := Checksum(testStore(t, dir, "want", products...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Count != len(products) {
+		t.Fatalf("Checksum() counts %d products, want %d", want.Count, len(products))
+	}
+
+	// The same products, with the window in another time zone and with more precision than
+	// a millisecond, and a product without tags stored as empty rather than null
+	local := products[2].Window.PublishAt.In(time.FixedZone("CEST", 2*60*60)).Add(time.Microsecond)
+	same := testProducts()
+	same[2].Window.PublishAt = &local
+	same[1].Item.Tags = []string{}
+
+	changed := testProducts()
+	changed[3].Status = datastore.StatusDraft
+
+	tests := []struct {
+		name      string
+		db        datastore.Manager
+		wantEqual bool
+	}{
+		{name: "same store", db: testStore(t, dir, "same", products...), wantEqual: true},
+		{name: "other order", db: reversed{testStore(t, dir, "reversed", products...)}, wantEqual: true},
+		{name: "same products stored differently", db: testStore(t, dir, "normalized", same...), wantEqual: true},
+		{name: "other status", db: testStore(t, dir, "changed", changed...)},
+		{name: "missing product", db: testStore(t, dir, "missing", products[:3]...)},
+	}
+
+	for _, tt := range tests {
+		got, err := Checksum(tt.db)
+		if err != nil {
+			t.Fatalf("%s: Checksum() = %v", tt.name, err)
+		}
+		if (got.Checksum == want.Checksum) != tt.wantEqual {
+			t.Errorf("%s: Checksum() = %s, want equal %t to %s", tt.name, got.Checksum, tt.wantEqual, want.Checksum)
+		}
+	}
+}
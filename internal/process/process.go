@@ -0,0 +1,142 @@
+// Package process has the background jobs and the shutdown that the Cloud Run services of
+// the catalog share, like publishing the events in the outbox and flushing what is
+// buffered before the process exits.
+package process
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/internal/metrics"
+	"github.com/retgits/acme-serverless-catalog/internal/tracing"
+)
+
+// RunRelay publishes the events in the outbox every interval, until stop is closed, and
+// once more before it returns so the events of the last requests aren't left behind. A
+// nil relay, when the events are published directly, disables it.
+func RunRelay(interval time.Duration, relay *bus.Relay, stop <-chan struct{}) {
+	if relay == nil {
+		return
+	}
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			drainOutbox(relay)
+			return
+		case <-ticker.C:
+			drainOutbox(relay)
+		}
+	}
+}
+
+// drainOutbox publishes the events in the outbox. Events that can't be published stay
+// in the outbox and are published on the next run.
+func drainOutbox(relay *bus.Relay) {
+	n, err := relay.Drain(context.Background())
+	if n > 0 {
+		logging.Default().Info("published events from the outbox", "count", n)
+	}
+	if err != nil {
+		logging.Default().Error("error draining the outbox", "function", "RunRelay", "error", err)
+		sentry.CaptureException(fmt.Errorf("error in RunRelay::Drain %s", err.Error()))
+	}
+}
+
+// RunFeed sends the events in the log of the live feed, which are published by every
+// instance, to the clients of feed until stop is closed. When the log can't be read, it's
+// read again after retry.
+func RunFeed(feed *bus.Broadcaster, log bus.FeedLog, retry time.Duration, stop <-chan struct{}) {
+	if retry <= 0 {
+		retry = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		err := feed.Follow(ctx, log)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.Default().Error("error reading the feed log", "function", "RunFeed", "error", err)
+		sentry.CaptureException(fmt.Errorf("error in RunFeed::Follow %s", err.Error()))
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// StopGrace is how long Cloud Run waits after SIGTERM before it stops the container.
+const StopGrace = 10 * time.Second
+
+// FlushTimeout returns the time that is left to flush when the requests in flight got the
+// shutdown timeout to finish, which is at least a second.
+func FlushTimeout(shutdownTimeout time.Duration) time.Duration {
+	if d := StopGrace - shutdownTimeout; d > time.Second {
+		return d
+	}
+	return time.Second
+}
+
+// Flush sends the errors, metrics and traces that are still buffered and closes the
+// connections to the message bus and the data store. It returns an error when that takes
+// longer than the timeout, so the process can exit before it's killed. A nil recorder, for
+// a service without metrics, is skipped.
+func Flush(timeout time.Duration, recorder metrics.Recorder, publisher bus.Publisher) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		flush(recorder, publisher)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("still flushing after %s", timeout)
+	}
+}
+
+// flush sends and closes everything in turn
+func flush(recorder metrics.Recorder, publisher bus.Publisher) {
+	if !sentry.Flush(2 * time.Second) {
+		logging.Default().Warn("not all events could be sent to sentry")
+	}
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			logging.Default().Error("error sending metrics", "error", err)
+		}
+	}
+
+	if err := tracing.Shutdown(); err != nil {
+		logging.Default().Error("error exporting traces", "error", err)
+	}
+
+	if err := publisher.Close(); err != nil {
+		logging.Default().Error("error disconnecting from the message bus", "error", err)
+	}
+
+	if err := mongodb.Close(); err != nil {
+		logging.Default().Error("error disconnecting from MongoDB", "error", err)
+	}
+}
@@ -0,0 +1,80 @@
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// closer is a publisher that takes the delay to close.
+type closer struct {
+	delay  time.Duration
+	closed chan struct{}
+}
+
+func (c closer) Publish(ctx context.Context, e bus.Event) error {
+	return nil
+}
+
+func (c closer) Close() error {
+	time.Sleep(c.delay)
+	close(c.closed)
+	return nil
+}
+
+func TestFlushTimeout(t *testing.T) {
+	tests := []struct {
+		shutdownTimeout time.Duration
+		want            time.Duration
+	}{
+		{shutdownTimeout: 5 * time.Second, want: 5 * time.Second},
+		{shutdownTimeout: time.Second, want: 9 * time.Second},
+		{shutdownTimeout: 9500 * time.Millisecond, want: time.Second},
+		{shutdownTimeout: time.Minute, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := FlushTimeout(tt.shutdownTimeout); got != tt.want {
+			t.Errorf("FlushTimeout(%s) = %s, want %s", tt.shutdownTimeout, got, tt.want)
+		}
+	}
+}
+
+// TestFlush checks that the publisher is closed, and that a flush that takes longer than
+// the timeout doesn't hold up the shutdown.
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		wantErr bool
+	}{
+		{name: "within the timeout", delay: 10 * time.Millisecond},
+		{name: "after the timeout", delay: time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		publisher := closer{delay: tt.delay, closed: make(chan struct{})}
+
+		start := time.Now()
+		err := Flush(200*time.Millisecond, nil, publisher)
+		if elapsed := time.Since(start); elapsed > 700*time.Millisecond {
+			t.Errorf("%s: Flush() took %s, want within the timeout of 200ms", tt.name, elapsed)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Flush() = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+
+		select {
+		case <-publisher.closed:
+			if tt.wantErr {
+				t.Errorf("%s: the publisher was closed before Flush() returned", tt.name)
+			}
+		default:
+			if !tt.wantErr {
+				t.Errorf("%s: the publisher wasn't closed", tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/pkg/catalogpb"
+)
+
+// statuses maps the statuses of the API to the statuses of the data store. A new product
+// with an unspecified status is a draft, like one without a status in the JSON API.
+var statuses = map[catalogpb.ProductStatus]datastore.Status{
+	catalogpb.ProductStatus_PRODUCT_STATUS_UNSPECIFIED: datastore.StatusDraft,
+	catalogpb.ProductStatus_PRODUCT_STATUS_DRAFT:       datastore.StatusDraft,
+	catalogpb.ProductStatus_PRODUCT_STATUS_PUBLISHED:   datastore.StatusPublished,
+	catalogpb.ProductStatus_PRODUCT_STATUS_ARCHIVED:    datastore.StatusArchived,
+}
+
+// toItem returns the message of a catalog item.
+func toItem(item acmeserverless.CatalogItem) *catalogpb.CatalogItem {
+	return &catalogpb.CatalogItem{
+		Id:               item.ID,
+		Name:             item.Name,
+		ShortDescription: item.ShortDescription,
+		Description:      item.Description,
+		ImageUrl1:        item.ImageURL1,
+		ImageUrl2:        item.ImageURL2,
+		ImageUrl3:        item.ImageURL3,
+		Price:            item.Price,
+		Tags:             item.Tags,
+	}
+}
+
+// fromItem returns the catalog item of a message. Items always have a list of tags, so
+// they're written to JSON as an empty list rather than null.
+func fromItem(item *catalogpb.CatalogItem) acmeserverless.CatalogItem {
+	tags := item.GetTags()
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return acmeserverless.CatalogItem{
+		ID:               item.GetId(),
+		Name:             item.GetName(),
+		ShortDescription: item.GetShortDescription(),
+		Description:      item.GetDescription(),
+		ImageURL1:        item.GetImageUrl1(),
+		ImageURL2:        item.GetImageUrl2(),
+		ImageURL3:        item.GetImageUrl3(),
+		Price:            item.GetPrice(),
+		Tags:             tags,
+	}
+}
+
+// fromStatus returns the status of the data store for a status of the API.
+func fromStatus(s catalogpb.ProductStatus) (datastore.Status, error) {
+	status, ok := statuses[s]
+	if !ok {
+		return "", fmt.Errorf("unknown status %d", s)
+	}
+	return status, nil
+}
+
+// fromWindow returns the availability window between the timestamps, which are open
+// when they're nil.
+func fromWindow(publishAt *timestamp.Timestamp, unpublishAt *timestamp.Timestamp) (datastore.Window, error) {
+	var window datastore.Window
+
+	if publishAt != nil {
+		t, err := ptypes.Timestamp(publishAt)
+		if err != nil {
+			return window, fmt.Errorf("invalid publish_at: %s", err.Error())
+		}
+		window.PublishAt = &t
+	}
+
+	if unpublishAt != nil {
+		t, err := ptypes.Timestamp(unpublishAt)
+		if err != nil {
+			return window, fmt.Errorf("invalid unpublish_at: %s", err.Error())
+		}
+		window.UnpublishAt = &t
+	}
+
+	return window, window.Validate()
+}
+
+// hasTags returns true if the tags contain all wanted tags, ignoring case.
+func hasTags(tags []string, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if strings.EqualFold(t, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,321 @@
+// Package rpc serves the CatalogService of the ACME Serverless Fitness Shop over gRPC, on
+// top of the same data store as the JSON API, so internal services can call the catalog
+// with typed messages instead of unmarshalling JSON.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gofrs/uuid"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/catalog"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/pkg/catalogpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the CatalogService. Reading the storefront is public, the calls that
+// change the catalog need the write role and are recorded in the audit trail.
+type Server struct {
+	db            datastore.Manager
+	sink          audit.Sink
+	authenticator auth.Authenticator
+	feed          *bus.Broadcaster
+}
+
+// Server implements the CatalogService
+var _ catalogpb.CatalogServiceServer = (*Server)(nil)
+
+// NewServer creates a server that reads and changes the products in db, records who
+// changed them in sink, checks the callers that change them with authenticator and
+// streams the changes published to feed to the clients that watch them.
+func NewServer(db datastore.Manager, sink audit.Sink, authenticator auth.Authenticator, feed *bus.Broadcaster) *Server {
+	return &Server{
+		db:            db,
+		sink:          sink,
+		authenticator: authenticator,
+		feed:          feed,
+	}
+}
+
+// Get returns a product that can be looked up by the public. Drafts are treated as if they
+// don't exist yet.
+func (s *Server) Get(ctx context.Context, req *catalogpb.GetProductRequest) (*catalogpb.CatalogItem, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	prod, ok, err := lookup(s.db.WithContext(ctx), req.GetId())
+	if err != nil {
+		return nil, internal(ctx, "Get", "GetProductsByIDs", err)
+	}
+	if !ok || !prod.Visible(time.Now()) {
+		return nil, status.Errorf(codes.NotFound, "Unable to find product with id %s", req.GetId())
+	}
+
+	return toItem(prod.Item), nil
+}
+
+// List streams the products listed on the storefront, one at a time, so the catalog
+// doesn't have to fit in a single message.
+func (s *Server) List(req *catalogpb.ListProductsRequest, stream catalogpb.CatalogService_ListServer) error {
+	ctx := stream.Context()
+	now := time.Now()
+
+	err := s.db.WithContext(ctx).ForEachProduct(func(p datastore.Product) error {
+		if !p.Listed(now) || !hasTags(p.Item.Tags, req.GetTags()) {
+			return nil
+		}
+		return stream.Send(toItem(p.Item))
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.Error(codes.Canceled, ctx.Err().Error())
+		}
+		return internal(ctx, "List", "ForEachProduct", err)
+	}
+
+	return nil
+}
+
+// BatchGet returns several products at once. Products that aren't visible to the public
+// are reported as missing.
+func (s *Server) BatchGet(ctx context.Context, req *catalogpb.BatchGetProductsRequest) (*catalogpb.BatchGetProductsResponse, error) {
+	ids, err := catalog.CleanIDs(req.GetIds())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	products, err := s.db.WithContext(ctx).GetProductsByIDs(ids)
+	if err != nil {
+		return nil, internal(ctx, "BatchGet", "GetProductsByIDs", err)
+	}
+
+	res := catalog.NewBatchGetResponse(ids, products, time.Now())
+
+	items := make([]*catalogpb.CatalogItem, 0, len(res.Data))
+	for _, item := range res.Data {
+		items = append(items, toItem(item))
+	}
+
+	return &catalogpb.BatchGetProductsResponse{
+		Items:   items,
+		Missing: res.Missing,
+	}, nil
+}
+
+// Create adds a product to the catalog with a new ID.
+func (s *Server) Create(ctx context.Context, req *catalogpb.CreateProductRequest) (*catalogpb.CatalogItem, error) {
+	db, err := s.writer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetItem() == nil {
+		return nil, status.Error(codes.InvalidArgument, "item is required")
+	}
+
+	prodStatus, err := fromStatus(req.GetStatus())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	window, err := fromWindow(req.GetPublishAt(), req.GetUnpublishAt())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	prod := datastore.Product{
+		Item:   fromItem(req.GetItem()),
+		Status: prodStatus,
+		Window: window,
+	}
+	prod.Item.ID = uuid.Must(uuid.NewV4()).String()
+
+	if _, err := db.AddProduct(prod); err != nil {
+		return nil, internal(ctx, "Create", "AddProduct", err)
+	}
+
+	return toItem(prod.Item), nil
+}
+
+// Update replaces the fields of an existing product, keeping its status and availability
+// window.
+func (s *Server) Update(ctx context.Context, req *catalogpb.UpdateProductRequest) (*catalogpb.CatalogItem, error) {
+	db, err := s.writer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetItem().GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "item with an id is required")
+	}
+
+	_, ok, err := lookup(s.db.WithContext(ctx), req.GetItem().GetId())
+	if err != nil {
+		return nil, internal(ctx, "Update", "GetProductsByIDs", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Unable to find product with id %s", req.GetItem().GetId())
+	}
+
+	// Only the item is replaced, inside the versioned write, so status and window changes
+	// made since the lookup are kept
+	item := fromItem(req.GetItem())
+	if _, err := db.UpdateProductItem(item); err != nil {
+		return nil, internal(ctx, "Update", "UpdateProductItem", err)
+	}
+
+	return toItem(item), nil
+}
+
+// Delete archives a product, if the lifecycle allows that, so it's removed from the
+// storefront but kept for the order history.
+func (s *Server) Delete(ctx context.Context, req *catalogpb.DeleteProductRequest) (*empty.Empty, error) {
+	db, err := s.writer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	_, ok, err := lookup(s.db.WithContext(ctx), req.GetId())
+	if err != nil {
+		return nil, internal(ctx, "Delete", "GetProductsByIDs", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Unable to find product with id %s", req.GetId())
+	}
+
+	// The data store checks that the lifecycle allows archiving the product, against the
+	// product as it is when it's written
+	_, err = db.SetProductStatus(req.GetId(), datastore.StatusArchived)
+	var transition *datastore.TransitionError
+	if errors.As(err, &transition) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, internal(ctx, "Delete", "SetProductStatus", err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+// Watch streams the changes to the products on the storefront, until the client cancels
+// the call or the server stops. A client that watches again after the last change it
+// received first gets the changes it missed, or a reset when those aren't known anymore.
+func (s *Server) Watch(req *catalogpb.WatchProductsRequest, stream catalogpb.CatalogService_WatchServer) error {
+	ctx := stream.Context()
+
+	listener, missed, ok := s.feed.Subscribe(ctx, req.GetAfterEventId())
+	defer listener.Cancel()
+
+	if !ok {
+		if err := stream.Send(&catalogpb.ProductChange{Type: catalogpb.ChangeType_CHANGE_TYPE_RESET}); err != nil {
+			return err
+		}
+	}
+	for _, e := range missed {
+		if err := sendChange(stream, e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case e, open := <-listener.Events():
+			if !open {
+				return nil
+			}
+			if err := sendChange(stream, e); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, ctx.Err().Error())
+		}
+	}
+}
+
+// sendChange sends the change of a change event to the client. Events that can't be read
+// are logged and skipped.
+func sendChange(stream catalogpb.CatalogService_WatchServer, e bus.Event) error {
+	event, productID, item, err := catalog.FeedChange(e, time.Now())
+	if err != nil {
+		logging.FromContext(stream.Context()).Error("error sending change to the watcher", "method", "Watch", "event_id", e.ID, "error", err)
+		return nil
+	}
+
+	change := &catalogpb.ProductChange{
+		EventId:   e.ID,
+		Type:      catalogpb.ChangeType_CHANGE_TYPE_REMOVE,
+		ProductId: productID,
+	}
+	if event == catalog.FeedProduct {
+		change.Type = catalogpb.ChangeType_CHANGE_TYPE_PRODUCT
+		change.Item = toItem(item)
+	}
+
+	return stream.Send(change)
+}
+
+// writer checks that the caller holds the write role and returns the datastore manager
+// that records the changes the caller makes in the audit trail.
+func (s *Server) writer(ctx context.Context) (datastore.Manager, error) {
+	p, err := auth.AuthorizeGRPC(ctx, s.authenticator, auth.RoleWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Wrap(s.db.WithContext(ctx), s.sink, p.Name, peerIP(ctx)), nil
+}
+
+// lookup returns a single product, and false if it doesn't exist.
+func lookup(db datastore.Manager, productID string) (datastore.Product, bool, error) {
+	products, err := db.GetProductsByIDs([]string{productID})
+	if err != nil || len(products) == 0 {
+		return datastore.Product{}, false, err
+	}
+	return products[0], true, nil
+}
+
+// peerIP returns the IP address of the client of the call.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return ip
+}
+
+// internal logs an error of the data store, sends it to sentry, tagged with the request ID
+// of the call, and returns it as an Internal error.
+func internal(ctx context.Context, method string, operation string, err error) error {
+	logging.FromContext(ctx).Error("error handling call", "method", method, "operation", operation, "error", err)
+
+	sentry.CurrentHub().WithScope(func(scope *sentry.Scope) {
+		if id := logging.RequestIDFromContext(ctx); id != "" {
+			scope.SetTag("request_id", id)
+		}
+		sentry.CaptureException(fmt.Errorf("error in %s::%s %s", method, operation, err.Error()))
+	})
+
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,398 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/audit"
+	"github.com/retgits/acme-serverless-catalog/internal/auth"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	filestore "github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"github.com/retgits/acme-serverless-catalog/pkg/catalogpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testAPIKey is the API key that holds the write role on the test server
+const testAPIKey = "s3cr3t"
+
+// testServer serves the CatalogService in memory until the test ends, on top of a data
+// store with a published bottle and mat, a draft and an archived product. It returns a
+// client of the server and the data store.
+func testServer(t *testing.T) (catalogpb.CatalogServiceClient, datastore.Manager) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	store, err := filestore.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []datastore.Product{
+		{Item: acmeserverless.CatalogItem{ID: "p1", Name: "Bottle", Price: 10, Tags: []string{"bottle"}}, Status: datastore.StatusPublished},
+		{Item: acmeserverless.CatalogItem{ID: "p2", Name: "Shoes", Price: 80, Tags: []string{"running"}}, Status: datastore.StatusDraft},
+		{Item: acmeserverless.CatalogItem{ID: "p3", Name: "Mat", Price: 25, Tags: []string{"mat"}}, Status: datastore.StatusPublished},
+		{Item: acmeserverless.CatalogItem{ID: "p4", Name: "Band", Price: 5, Tags: []string{"mat"}}, Status: datastore.StatusArchived},
+	} {
+		if _, err := store.AddProduct(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := auth.ParseAPIKeys("ops:" + testAPIKey + ":" + auth.RoleWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	feedLog := bus.NewMemoryLog(100)
+	feed := bus.NewBroadcaster(100)
+	go feed.Follow(ctx, feedLog)
+
+	db := bus.Wrap(store, feedLog, bus.DefaultSource)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor()),
+	)
+	catalogpb.RegisterCatalogServiceServer(server, NewServer(db, audit.NewWriter(ioutil.Discard), keys, feed))
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		feed.Close()
+		server.Stop()
+		cancel()
+	})
+
+	return catalogpb.NewCatalogServiceClient(conn), store
+}
+
+// writer returns a context with the API key that holds the write role
+func writer() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", testAPIKey)
+}
+
+func TestGet(t *testing.T) {
+	client, _ := testServer(t)
+
+	tests := []struct {
+		id       string
+		wantName string
+		wantCode codes.Code
+	}{
+		{id: "p1", wantName: "Bottle", wantCode: codes.OK},
+		{id: "p2", wantCode: codes.NotFound},
+		{id: "p4", wantName: "Band", wantCode: codes.OK},
+		{id: "unknown", wantCode: codes.NotFound},
+		{id: "", wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		got, err := client.Get(context.Background(), &catalogpb.GetProductRequest{Id: tt.id})
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("Get(%q) = %v, want %s", tt.id, err, tt.wantCode)
+			continue
+		}
+		if err == nil && (got.GetId() != tt.id || got.GetName() != tt.wantName) {
+			t.Errorf("Get(%q) = %v, want %s", tt.id, got, tt.wantName)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	client, _ := testServer(t)
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{want: []string{"p1", "p3"}},
+		{tags: []string{"mat"}, want: []string{"p3"}},
+		{tags: []string{"running"}},
+	}
+
+	for _, tt := range tests {
+		stream, err := client.List(context.Background(), &catalogpb.ListProductsRequest{Tags: tt.tags})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for {
+			item, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("List(%v) = %v", tt.tags, err)
+			}
+			got = append(got, item.GetId())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestBatchGet(t *testing.T) {
+	client, _ := testServer(t)
+
+	tests := []struct {
+		ids         []string
+		wantItems   []string
+		wantMissing []string
+		wantCode    codes.Code
+	}{
+		{ids: []string{"p3", "p1"}, wantItems: []string{"p3", "p1"}, wantCode: codes.OK},
+		{ids: []string{"p1", "p2", "unknown", "p1"}, wantItems: []string{"p1"}, wantMissing: []string{"p2", "unknown"}, wantCode: codes.OK},
+		{ids: []string{" "}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		res, err := client.BatchGet(context.Background(), &catalogpb.BatchGetProductsRequest{Ids: tt.ids})
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("BatchGet(%v) = %v, want %s", tt.ids, err, tt.wantCode)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var items []string
+		for _, item := range res.GetItems() {
+			items = append(items, item.GetId())
+		}
+		if !reflect.DeepEqual(items, tt.wantItems) || len(res.GetMissing()) != len(tt.wantMissing) {
+			t.Errorf("BatchGet(%v) = %v, missing %v, want %v, missing %v", tt.ids, items, res.GetMissing(), tt.wantItems, tt.wantMissing)
+		}
+		for i := range tt.wantMissing {
+			if res.GetMissing()[i] != tt.wantMissing[i] {
+				t.Errorf("BatchGet(%v) misses %v, want %v", tt.ids, res.GetMissing(), tt.wantMissing)
+				break
+			}
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	client, store := testServer(t)
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		req        *catalogpb.CreateProductRequest
+		wantStatus datastore.Status
+		wantCode   codes.Code
+	}{
+		{
+			name:       "draft by default",
+			ctx:        writer(),
+			req:        &catalogpb.CreateProductRequest{Item: &catalogpb.CatalogItem{Name: "Roller", Price: 20}},
+			wantStatus: datastore.StatusDraft,
+			wantCode:   codes.OK,
+		},
+		{
+			name:       "published",
+			ctx:        writer(),
+			req:        &catalogpb.CreateProductRequest{Item: &catalogpb.CatalogItem{Name: "Towel", Price: 8}, Status: catalogpb.ProductStatus_PRODUCT_STATUS_PUBLISHED},
+			wantStatus: datastore.StatusPublished,
+			wantCode:   codes.OK,
+		},
+		{
+			name:     "without credentials",
+			ctx:      context.Background(),
+			req:      &catalogpb.CreateProductRequest{Item: &catalogpb.CatalogItem{Name: "Roller"}},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "without an item",
+			ctx:      writer(),
+			req:      &catalogpb.CreateProductRequest{},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "unknown status",
+			ctx:      writer(),
+			req:      &catalogpb.CreateProductRequest{Item: &catalogpb.CatalogItem{Name: "Roller"}, Status: catalogpb.ProductStatus(42)},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		item, err := client.Create(tt.ctx, tt.req)
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("%s: Create() = %v, want %s", tt.name, err, tt.wantCode)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		p, err := store.GetProduct(item.GetId())
+		if err != nil || p.Item.Name != tt.req.GetItem().GetName() || p.Status != tt.wantStatus {
+			t.Errorf("%s: GetProduct(%q) = %+v, %v, want a %s product", tt.name, item.GetId(), p, err, tt.wantStatus)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	client, store := testServer(t)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		item     *catalogpb.CatalogItem
+		wantCode codes.Code
+	}{
+		{name: "published product", ctx: writer(), item: &catalogpb.CatalogItem{Id: "p1", Name: "Water bottle", Price: 12}, wantCode: codes.OK},
+		{name: "draft", ctx: writer(), item: &catalogpb.CatalogItem{Id: "p2", Name: "Trail shoes", Price: 90}, wantCode: codes.OK},
+		{name: "unknown product", ctx: writer(), item: &catalogpb.CatalogItem{Id: "unknown", Name: "Kettlebell"}, wantCode: codes.NotFound},
+		{name: "without an id", ctx: writer(), item: &catalogpb.CatalogItem{Name: "Kettlebell"}, wantCode: codes.InvalidArgument},
+		{name: "without credentials", ctx: context.Background(), item: &catalogpb.CatalogItem{Id: "p1", Name: "Bottle"}, wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		before, _ := store.GetProduct(tt.item.GetId())
+
+		_, err := client.Update(tt.ctx, &catalogpb.UpdateProductRequest{Item: tt.item})
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("%s: Update() = %v, want %s", tt.name, err, tt.wantCode)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// The status is kept
+		p, err := store.GetProduct(tt.item.GetId())
+		if err != nil || p.Item.Name != tt.item.GetName() || p.Status != before.Status {
+			t.Errorf("%s: GetProduct() = %+v, %v, want %s as a %s product", tt.name, p, err, tt.item.GetName(), before.Status)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	client, store := testServer(t)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		id       string
+		wantCode codes.Code
+	}{
+		{name: "published product", ctx: writer(), id: "p1", wantCode: codes.OK},
+		{name: "archived product", ctx: writer(), id: "p1", wantCode: codes.FailedPrecondition},
+		{name: "unknown product", ctx: writer(), id: "unknown", wantCode: codes.NotFound},
+		{name: "without an id", ctx: writer(), wantCode: codes.InvalidArgument},
+		{name: "without credentials", ctx: context.Background(), id: "p3", wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		if _, err := client.Delete(tt.ctx, &catalogpb.DeleteProductRequest{Id: tt.id}); status.Code(err) != tt.wantCode {
+			t.Errorf("%s: Delete() = %v, want %s", tt.name, err, tt.wantCode)
+		}
+	}
+
+	// Deleted products are archived, not removed
+	if p, err := store.GetProduct("p1"); err != nil || p.Status != datastore.StatusArchived {
+		t.Errorf("GetProduct() = %+v, %v, want an archived product", p, err)
+	}
+	if p, err := store.GetProduct("p3"); err != nil || p.Status != datastore.StatusPublished {
+		t.Errorf("GetProduct() = %+v, %v, want a published product", p, err)
+	}
+}
+
+// recv returns the next change of the stream, or fails the test if it doesn't arrive.
+func recv(t *testing.T, stream catalogpb.CatalogService_WatchClient) *catalogpb.ProductChange {
+	t.Helper()
+
+	changes := make(chan *catalogpb.ProductChange, 1)
+	errs := make(chan error, 1)
+	go func() {
+		c, err := stream.Recv()
+		if err != nil {
+			errs <- err
+			return
+		}
+		changes <- c
+	}()
+
+	select {
+	case c := <-changes:
+		return c
+	case err := <-errs:
+		t.Fatalf("Recv() = %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no change for the watcher")
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	client, _ := testServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A client that watches after a change that isn't known gets a reset, which also tells
+	// the test the server is sending the changes
+	stream, err := client.Watch(ctx, &catalogpb.WatchProductsRequest{AfterEventId: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := recv(t, stream); c.GetType() != catalogpb.ChangeType_CHANGE_TYPE_RESET {
+		t.Errorf("Watch() after an unknown change sends %v, want a reset", c)
+	}
+
+	// A product that is listed is sent, and removed when it's archived
+	if _, err := client.Update(writer(), &catalogpb.UpdateProductRequest{Item: &catalogpb.CatalogItem{Id: "p1", Name: "Water bottle"}}); err != nil {
+		t.Fatal(err)
+	}
+	first := recv(t, stream)
+	if first.GetType() != catalogpb.ChangeType_CHANGE_TYPE_PRODUCT || first.GetProductId() != "p1" || first.GetItem().GetName() != "Water bottle" || first.GetEventId() == "" {
+		t.Errorf("first change = %v, want the water bottle", first)
+	}
+
+	if _, err := client.Delete(writer(), &catalogpb.DeleteProductRequest{Id: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	removed := recv(t, stream)
+	if removed.GetType() != catalogpb.ChangeType_CHANGE_TYPE_REMOVE || removed.GetProductId() != "p1" {
+		t.Errorf("second change = %v, want a remove of p1", removed)
+	}
+
+	// A client that watches again gets the changes it missed
+	again, err := client.Watch(ctx, &catalogpb.WatchProductsRequest{AfterEventId: first.GetEventId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := recv(t, again); c.GetType() != catalogpb.ChangeType_CHANGE_TYPE_REMOVE || c.GetEventId() != removed.GetEventId() {
+		t.Errorf("Watch() after the first change sends %v, want %v", c, removed)
+	}
+}
@@ -0,0 +1,154 @@
+// Package schedule finds the products whose availability window starts or ends in a
+// period of time, so the Catalog service can announce that they went live or were
+// taken off the storefront. The Google Cloud Run flavor checks the catalog on a ticker
+// and the AWS Lambda flavor on a scheduled event, and both publish the announcements
+// like the changes to the products, so they reach the message bus, the webhook
+// subscriptions and the live feed.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// EventType is the kind of boundary of an availability window that was crossed.
+type EventType string
+
+const (
+	// EventPublished is emitted when the window of a product starts
+	EventPublished EventType = "published"
+
+	// EventUnpublished is emitted when the window of a product ends
+	EventUnpublished EventType = "unpublished"
+)
+
+// Event describes a product that went live or was taken off the storefront.
+type Event struct {
+	// Type is the kind of boundary that was crossed
+	Type EventType `json:"type"`
+
+	// ProductID is the unique identifier of the product
+	ProductID string `json:"productId"`
+
+	// At is the moment the boundary was crossed
+	At time.Time `json:"at"`
+
+	// Product is the product as it was when the boundary was checked
+	Product datastore.Product `json:"-"`
+}
+
+// Emitter sends events to whoever is interested in them.
+type Emitter interface {
+	Emit(e Event) error
+}
+
+// busEmitter publishes events as change events.
+type busEmitter struct {
+	ctx       context.Context
+	publisher bus.Publisher
+	source    string
+}
+
+// NewBusEmitter returns an emitter that publishes the events with ctx to publisher, as
+// change events from source of the type ProductPublished or ProductUnpublished. The ID of
+// the change event is derived from the boundary, so a boundary that is emitted again,
+// after a run failed halfway, has the same ID and consumers can drop the duplicate.
+func NewBusEmitter(ctx context.Context, publisher bus.Publisher, source string) Emitter {
+	return busEmitter{ctx: ctx, publisher: publisher, source: source}
+}
+
+// Emit publishes a single event and writes it to the log
+func (b busEmitter) Emit(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(b.ctx).Info("schedule event", "event", json.RawMessage(payload))
+
+	event, err := ChangeEvent(b.source, e)
+	if err != nil {
+		return err
+	}
+	return b.publisher.Publish(b.ctx, event)
+}
+
+// ChangeEvent returns the change event that announces the boundary e, from source, at the
+// moment the boundary was crossed.
+func ChangeEvent(source string, e Event) (bus.Event, error) {
+	eventType := bus.TypeProductPublished
+	if e.Type == EventUnpublished {
+		eventType = bus.TypeProductUnpublished
+	}
+
+	event, err := bus.NewEvent(source, eventType, e.ProductID, bus.ProductData{
+		ProductID: e.ProductID,
+		Product:   e.Product,
+	})
+	if err != nil {
+		return bus.Event{}, err
+	}
+
+	event.ID = uuid.NewV5(uuid.NamespaceURL, fmt.Sprintf("%s/%s/%s/%s", source, e.Type, e.ProductID, e.At.UTC().Format(time.RFC3339Nano))).String()
+	event.Time = e.At.UTC()
+	return event, nil
+}
+
+// Run emits the events for all products in the data store whose windows start or end
+// after from and up to and including to, ordered by time, and returns the number of
+// emitted events. Only published products have their windows announced, drafts and
+// archived products aren't on the storefront anyway.
+func Run(db datastore.Manager, emitter Emitter, from time.Time, to time.Time) (int, error) {
+	events := make([]Event, 0)
+
+	err := db.ForEachProduct(func(p datastore.Product) error {
+		events = append(events, productEvents(p, from, to)...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sortEvents(events)
+
+	for idx, e := range events {
+		if err := emitter.Emit(e); err != nil {
+			return idx, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// sortEvents orders the events by the moment the boundary was crossed.
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+}
+
+// productEvents returns the boundaries of the window of a single product in (from, to].
+func productEvents(p datastore.Product, from time.Time, to time.Time) []Event {
+	if p.Status != datastore.StatusPublished {
+		return nil
+	}
+
+	events := make([]Event, 0, 2)
+
+	if t := p.Window.PublishAt; t != nil && t.After(from) && !t.After(to) {
+		events = append(events, Event{Type: EventPublished, ProductID: p.Item.ID, At: *t, Product: p})
+	}
+
+	if t := p.Window.UnpublishAt; t != nil && t.After(from) && !t.After(to) {
+		events = append(events, Event{Type: EventUnpublished, ProductID: p.Item.ID, At: *t, Product: p})
+	}
+
+	return events
+}
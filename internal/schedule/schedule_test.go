@@ -0,0 +1,232 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore/file"
+)
+
+func TestProductEvents(t *testing.T) {
+	from := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	before := from.Add(-time.Second)
+	inside := from.Add(30 * time.Second)
+	after := to.Add(time.Second)
+
+	product := func(status datastore.Status, publishAt *time.Time, unpublishAt *time.Time) datastore.Product {
+		return datastore.Product{
+			Item:   acmeserverless.CatalogItem{ID: "1"},
+			Status: status,
+			Window: datastore.Window{PublishAt: publishAt, UnpublishAt: unpublishAt},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		product datastore.Product
+		want    []EventType
+	}{
+		{name: "no window", product: product(datastore.StatusPublished, nil, nil)},
+		{name: "starts inside", product: product(datastore.StatusPublished, &inside, nil), want: []EventType{EventPublished}},
+		{name: "ends inside", product: product(datastore.StatusPublished, nil, &inside), want: []EventType{EventUnpublished}},
+		{name: "starts at from", product: product(datastore.StatusPublished, &from, nil)},
+		{name: "starts at to", product: product(datastore.StatusPublished, &to, nil), want: []EventType{EventPublished}},
+		{name: "outside", product: product(datastore.StatusPublished, &before, &after)},
+		{name: "draft", product: product(datastore.StatusDraft, &inside, nil)},
+		{name: "archived", product: product(datastore.StatusArchived, nil, &inside)},
+	}
+
+	for _, tt := range tests {
+		var got []EventType
+		for _, e := range productEvents(tt.product, from, to) {
+			got = append(got, e.Type)
+			if e.ProductID != "1" || !reflect.DeepEqual(e.Product, tt.product) {
+				t.Errorf("%s: got event %+v for another product", tt.name, e)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChangeEvent(t *testing.T) {
+	at := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	p := datastore.Product{
+		Item:   acmeserverless.CatalogItem{ID: "1", Name: "Bottle"},
+		Status: datastore.StatusPublished,
+		Window: datastore.Window{PublishAt: &at},
+	}
+
+	tests := []struct {
+		eventType EventType
+		want      string
+	}{
+		{EventPublished, bus.TypeProductPublished},
+		{EventUnpublished, bus.TypeProductUnpublished},
+	}
+
+	for _, tt := range tests {
+		e := Event{Type: tt.eventType, ProductID: "1", At: at, Product: p}
+
+		got, err := ChangeEvent("/acme/catalog", e)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.eventType, err)
+		}
+		if got.Type != tt.want || got.Subject != "1" || !got.Time.Equal(at) || got.Source != "/acme/catalog" {
+			t.Errorf("%s: got %+v", tt.eventType, got)
+		}
+
+		var data bus.ProductData
+		if err := json.Unmarshal(got.Data, &data); err != nil {
+			t.Fatalf("%s: invalid data: %v", tt.eventType, err)
+		}
+		if data.ProductID != "1" || data.Operation != "" || data.Product.Item.Name != "Bottle" {
+			t.Errorf("%s: got data %+v", tt.eventType, data)
+		}
+
+		// A boundary that is emitted again gets the same ID
+		again, err := ChangeEvent("/acme/catalog", e)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.eventType, err)
+		}
+		if again.ID != got.ID {
+			t.Errorf("%s: got IDs %s and %s for the same boundary", tt.eventType, got.ID, again.ID)
+		}
+	}
+
+	published, _ := ChangeEvent("/acme/catalog", Event{Type: EventPublished, ProductID: "1", At: at})
+	unpublished, _ := ChangeEvent("/acme/catalog", Event{Type: EventUnpublished, ProductID: "1", At: at})
+	if published.ID == unpublished.ID {
+		t.Errorf("got the same ID for different boundaries")
+	}
+}
+
+func TestRun(t *testing.T) {
+	from := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	early := from.Add(10 * time.Second)
+	late := from.Add(50 * time.Second)
+
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := file.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := []datastore.Product{
+		{Item: acmeserverless.CatalogItem{ID: "launch"}, Status: datastore.StatusPublished, Window: datastore.Window{PublishAt: &late}},
+		{Item: acmeserverless.CatalogItem{ID: "limited"}, Status: datastore.StatusPublished, Window: datastore.Window{UnpublishAt: &early}},
+		{Item: acmeserverless.CatalogItem{ID: "draft"}, Status: datastore.StatusDraft, Window: datastore.Window{PublishAt: &early}},
+	}
+	for _, p := range products {
+		if _, err := db.AddProduct(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publisher := bus.NewMemory()
+	n, err := Run(db, NewBusEmitter(context.Background(), publisher, "/acme/catalog"), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d events, want 2", n)
+	}
+
+	// The events are ordered by the moment the boundary was crossed
+	var got []string
+	for _, e := range publisher.Events() {
+		got = append(got, e.Type+" "+e.Subject)
+	}
+	want := []string{
+		bus.TypeProductUnpublished + " limited",
+		bus.TypeProductPublished + " launch",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// failing is an emitter that can't emit anything
+type failing struct{}
+
+func (failing) Emit(e Event) error {
+	return errors.New("bus down")
+}
+
+// TestCheck checks that a scheduler that was down catches up with the boundaries it
+// missed, from the stored watermark, and doesn't emit them again on the next check.
+func TestCheck(t *testing.T) {
+	start := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	missed := start.Add(30 * time.Minute)
+	restart := start.Add(time.Hour)
+
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := file.New(filepath.Join(dir, "catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := datastore.Product{Item: acmeserverless.CatalogItem{ID: "launch"}, Status: datastore.StatusPublished, Window: datastore.Window{PublishAt: &missed}}
+	if _, err := db.AddProduct(p); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	publisher := bus.NewMemory()
+	emitter := NewBusEmitter(ctx, publisher, "/acme/catalog")
+	watermark := NewMemoryWatermark()
+
+	tests := []struct {
+		name string
+		to   time.Time
+		want int
+	}{
+		{name: "first check", to: start},
+		{name: "after the restart", to: restart, want: 1},
+		{name: "next check", to: restart.Add(time.Minute)},
+	}
+
+	for _, tt := range tests {
+		n, err := Check(ctx, db, emitter, watermark, tt.to)
+		if err != nil || n != tt.want {
+			t.Errorf("%s: got %d, %v, want %d events", tt.name, n, err, tt.want)
+		}
+		if got, _ := watermark.Load(ctx); !got.Equal(tt.to) {
+			t.Errorf("%s: the watermark is %s, want %s", tt.name, got, tt.to)
+		}
+	}
+
+	if events := publisher.Events(); len(events) != 1 || events[0].Subject != "launch" {
+		t.Errorf("got events %+v, want the launch once", events)
+	}
+
+	// A check that fails leaves the watermark, so the period is checked again
+	watermark.Save(ctx, start)
+	if _, err := Check(ctx, db, failing{}, watermark, restart); err == nil {
+		t.Fatal("expected an error from the emitter")
+	}
+	if got, _ := watermark.Load(ctx); !got.Equal(start) {
+		t.Errorf("the watermark moved to %s after a failed check", got)
+	}
+}
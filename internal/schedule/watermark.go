@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Watermark keeps the moment up to which the windows have been checked, so a scheduler
+// that is restarted, or another instance that takes over, continues where the previous
+// one left off instead of skipping the boundaries in between.
+type Watermark interface {
+	// Load returns the stored moment, or the zero time if there isn't one
+	Load(ctx context.Context) (time.Time, error)
+
+	// Save stores the moment
+	Save(ctx context.Context, t time.Time) error
+}
+
+// memoryWatermark keeps the moment in memory.
+type memoryWatermark struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewMemoryWatermark returns a watermark that is kept in memory, for tests and local
+// development.
+func NewMemoryWatermark() Watermark {
+	return &memoryWatermark{}
+}
+
+// Load returns the moment that was saved last
+func (m *memoryWatermark) Load(ctx context.Context) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.t, nil
+}
+
+// Save keeps the moment
+func (m *memoryWatermark) Save(ctx context.Context, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.t = t
+	return nil
+}
+
+// Check emits the events for the windows that start or end after the watermark and up to
+// and including to, and moves the watermark to to once all of them were emitted. When the
+// run fails the watermark stays, so the period is checked again by the next run. A
+// watermark that was never saved is set to to, so a new scheduler doesn't announce the
+// windows of the past.
+func Check(ctx context.Context, db datastore.Manager, emitter Emitter, watermark Watermark, to time.Time) (int, error) {
+	from, err := watermark.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	if !from.IsZero() {
+		if n, err = Run(db, emitter, from, to); err != nil {
+			return n, err
+		}
+	}
+
+	return n, watermark.Save(ctx, to)
+}
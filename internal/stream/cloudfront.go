@@ -0,0 +1,49 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+)
+
+// cloudFront invalidates the cached responses of a CloudFront distribution.
+type cloudFront struct {
+	client         *cloudfront.CloudFront
+	distributionID string
+}
+
+// NewCloudFront creates a target that invalidates the list of products and the changed
+// product in the CloudFront distribution with the given ID.
+func NewCloudFront(sess *session.Session, distributionID string) Target {
+	return &cloudFront{
+		client:         cloudfront.New(sess),
+		distributionID: distributionID,
+	}
+}
+
+// Name returns cloudfront
+func (t *cloudFront) Name() string {
+	return "cloudfront"
+}
+
+// Apply creates an invalidation for the change. The caller reference is the ID of the
+// change, so CloudFront doesn't create a second invalidation when the record is retried.
+func (t *cloudFront) Apply(ctx context.Context, c Change) error {
+	_, err := t.client.CreateInvalidationWithContext(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(t.distributionID),
+		InvalidationBatch: &cloudfront.InvalidationBatch{
+			CallerReference: aws.String(c.ID),
+			Paths: &cloudfront.Paths{
+				Quantity: aws.Int64(2),
+				Items: aws.StringSlice([]string{
+					"/products",
+					fmt.Sprintf("/products/%s", c.ProductID),
+				}),
+			},
+		},
+	})
+	return err
+}
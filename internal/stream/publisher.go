@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"context"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// publisher publishes the change events to a message bus.
+type publisher struct {
+	publisher bus.Publisher
+	source    string
+}
+
+// NewPublisher creates a target that publishes the change events from source with p.
+// Unlike the events published by the data store, the events of the stream include the
+// changes made by other writers, but not who made the change.
+func NewPublisher(p bus.Publisher, source string) Target {
+	return &publisher{
+		publisher: p,
+		source:    source,
+	}
+}
+
+// Name returns publisher
+func (t *publisher) Name() string {
+	return "publisher"
+}
+
+// Apply publishes the event of the change. The ID of the event is the ID of the change,
+// so consumers can drop the event when the change is read again.
+func (t *publisher) Apply(ctx context.Context, c Change) error {
+	e, err := c.Event(t.source)
+	if err != nil {
+		return err
+	}
+	return t.publisher.Publish(ctx, e)
+}
@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// searchTimeout is how long a request to the search index can take
+const searchTimeout = 10 * time.Second
+
+// search keeps the products in an Elasticsearch index.
+type search struct {
+	client *http.Client
+	index  string
+	signer *v4.Signer
+	region string
+}
+
+// NewSearch creates a target that indexes the products in the Elasticsearch index at
+// indexURL. Requests to an Amazon Elasticsearch Service domain are signed with the
+// credentials of the session.
+func NewSearch(sess *session.Session, indexURL string) (Target, error) {
+	u, err := url.Parse(indexURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("search URL %q must be http or https", indexURL)
+	}
+
+	t := &search{
+		client: &http.Client{Timeout: searchTimeout},
+		index:  strings.TrimSuffix(indexURL, "/"),
+	}
+	if strings.HasSuffix(u.Hostname(), ".es.amazonaws.com") {
+		t.signer = v4.NewSigner(sess.Config.Credentials)
+		t.region = aws.StringValue(sess.Config.Region)
+	}
+
+	return t, nil
+}
+
+// Name returns search
+func (t *search) Name() string {
+	return "search"
+}
+
+// Apply indexes the product, or removes it from the index when it was deleted. The
+// version of the change is used as an external version, so a change that arrives after a
+// newer one is rejected by the index instead of overwriting it. Products that were
+// written without a version, by another writer or before revisions existed, overwrite
+// the document.
+func (t *search) Apply(ctx context.Context, c Change) error {
+	doc := fmt.Sprintf("%s/_doc/%s", t.index, url.PathEscape(c.ProductID))
+	if c.Version > 0 {
+		doc = fmt.Sprintf("%s?version=%d&version_type=external", doc, c.Version)
+	}
+
+	if c.New == nil {
+		res, err := t.do(ctx, http.MethodDelete, doc, nil)
+		if err != nil {
+			return err
+		}
+		// The product isn't in the index, or a newer version is
+		if res == http.StatusNotFound || res == http.StatusConflict {
+			return nil
+		}
+		return searchStatus(res)
+	}
+
+	// The document is the product as the API returns it, including its status and
+	// window, so searches can leave out products that aren't listed
+	body, err := json.Marshal(c.New)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.do(ctx, http.MethodPut, doc, body)
+	if err != nil {
+		return err
+	}
+	// The index already has this version or a newer one
+	if res == http.StatusConflict {
+		return nil
+	}
+	return searchStatus(res)
+}
+
+// do sends a request to the index and returns the status code of the response
+func (t *search) do(ctx context.Context, method string, u string, body []byte) (int, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if t.signer != nil {
+		if _, err := t.signer.Sign(req, bytes.NewReader(body), "es", t.region, time.Now()); err != nil {
+			return 0, fmt.Errorf("error signing request: %s", err.Error())
+		}
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	return res.StatusCode, nil
+}
+
+// searchStatus returns an error if the index didn't accept the request
+func searchStatus(code int) error {
+	if code >= 200 && code < 300 {
+		return nil
+	}
+	return fmt.Errorf("search index returned %d %s", code, http.StatusText(code))
+}
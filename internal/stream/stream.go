@@ -0,0 +1,115 @@
+// Package stream reacts to the changes in the data store, as they're read from the
+// DynamoDB stream of the catalog table or the change stream of the MongoDB collection.
+// Every change to a product is decoded into a Change, which is applied to the targets
+// that keep copies of the catalog up to date, like caches, a search index and the
+// endpoints of webhooks.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// Change is a change to a product, decoded from a stream.
+type Change struct {
+	// ID identifies the change in the stream it was read from, and stays the same when
+	// the change is read again, so targets can use it to drop duplicates
+	ID string
+
+	// ProductID is the ID of the changed product
+	ProductID string
+
+	// Operation is OperationCreate, OperationUpdate or OperationDelete
+	Operation datastore.Operation
+
+	// Old is the product before the change, or nil if it was created
+	Old *datastore.Product
+
+	// New is the product after the change, or nil if it was deleted
+	New *datastore.Product
+
+	// Version is the version of the product after the change. The version only goes up,
+	// so targets can use it to ignore changes that arrive out of order.
+	Version int
+
+	// Time is when the change was made, to the second
+	Time time.Time
+}
+
+// Product returns the product after the change, or the product as it was before it was
+// deleted
+func (c Change) Product() datastore.Product {
+	if c.New != nil {
+		return *c.New
+	}
+	return *c.Old
+}
+
+// Event creates the change event for the change. The ID of the event is the ID of the
+// change, so a change that is read again always sends the same event.
+func (c Change) Event(source string) (bus.Event, error) {
+	e, err := bus.ProductEvent(source, "", c.Operation, c.Old != nil, c.Product())
+	if err != nil {
+		return bus.Event{}, err
+	}
+
+	e.ID = c.ID
+	if !c.Time.IsZero() {
+		e.Time = c.Time.UTC()
+	}
+	return e, nil
+}
+
+// Target is a copy of the catalog that is kept up to date with the changes. Changes are
+// read again when a target fails, and every target sees the change again, so Apply must
+// be idempotent.
+type Target interface {
+	// Name identifies the target in logs
+	Name() string
+
+	// Apply updates the target with a single change
+	Apply(ctx context.Context, c Change) error
+}
+
+// Fanout applies the change to every target, also when one of them fails, and returns
+// the errors of all targets that failed.
+func Fanout(ctx context.Context, c Change, targets []Target) error {
+	var errs []string
+	for _, t := range targets {
+		if err := t.Apply(ctx, c); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", t.Name(), err.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error applying change %s of product %s: %s", c.ID, c.ProductID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// BatchResponse is the response of a function that reports the records of a batch that
+// failed, so Lambda only retries those records and the ones after them in the shard. The
+// event source mapping must have ReportBatchItemFailures in its FunctionResponseTypes,
+// otherwise Lambda ignores the response and treats the batch as processed.
+type BatchResponse struct {
+	BatchItemFailures []BatchItemFailure `json:"batchItemFailures"`
+}
+
+// BatchItemFailure is a record that failed
+type BatchItemFailure struct {
+	// ItemIdentifier is the sequence number of the record
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// Fail adds a record to the failures
+func (r *BatchResponse) Fail(record events.DynamoDBEventRecord) {
+	r.BatchItemFailures = append(r.BatchItemFailures, BatchItemFailure{
+		ItemIdentifier: record.Change.SequenceNumber,
+	})
+}
@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// FromEnv creates the targets configured in the environment:
+//
+//   - STREAM_CLOUDFRONT_DISTRIBUTION invalidates the cached responses of the products in
+//     a CloudFront distribution
+//   - STREAM_SEARCH_URL indexes the products in an Elasticsearch index, like
+//     https://search-acme.us-west-2.es.amazonaws.com/products
+//   - STREAM_WEBHOOK_URLS sends the change events to a comma separated list of URLs
+//   - STREAM_EVENTS_PUBLISHER publishes the change events to a message bus, which takes
+//     the same values as EVENTS_PUBLISHER
+//
+// Targets that aren't configured are left out.
+func FromEnv() ([]Target, error) {
+	var targets []Target
+
+	if id := os.Getenv("STREAM_CLOUDFRONT_DISTRIBUTION"); id != "" {
+		targets = append(targets, NewCloudFront(awsSession(), id))
+	}
+
+	if u := os.Getenv("STREAM_SEARCH_URL"); u != "" {
+		t, err := NewSearch(awsSession(), u)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring search index: %s", err.Error())
+		}
+		targets = append(targets, t)
+	}
+
+	if urls := os.Getenv("STREAM_WEBHOOK_URLS"); urls != "" {
+		for _, u := range strings.Split(urls, ",") {
+			if u = strings.TrimSpace(u); u == "" {
+				continue
+			}
+			t, err := NewWebhook(u, bus.SourceFromEnv())
+			if err != nil {
+				return nil, fmt.Errorf("error configuring webhook: %s", err.Error())
+			}
+			targets = append(targets, t)
+		}
+	}
+
+	if spec := os.Getenv("STREAM_EVENTS_PUBLISHER"); spec != "" {
+		p, err := bus.Open(spec)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring events publisher: %s", err.Error())
+		}
+		targets = append(targets, NewPublisher(p, bus.SourceFromEnv()))
+	}
+
+	return targets, nil
+}
+
+// awsSession creates the session of the AWS targets, in the region in REGION
+func awsSession() *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REGION")),
+	}))
+}
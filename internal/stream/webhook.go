@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// webhookTimeout is how long the endpoint of a webhook can take to accept an event
+const webhookTimeout = 10 * time.Second
+
+// webhook sends the change events to an HTTP endpoint.
+type webhook struct {
+	client *http.Client
+	url    string
+	source string
+}
+
+// NewWebhook creates a target that sends the change events from source to endpoint, in
+// the structured JSON format of CloudEvents.
+func NewWebhook(endpoint string, source string) (Target, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook URL %q must be http or https", endpoint)
+	}
+
+	return &webhook{
+		client: &http.Client{Timeout: webhookTimeout},
+		url:    endpoint,
+		source: source,
+	}, nil
+}
+
+// Name returns the host of the endpoint, so the URL, which can contain a secret, isn't
+// logged
+func (t *webhook) Name() string {
+	u, _ := url.Parse(t.url)
+	return fmt.Sprintf("webhook %s", u.Host)
+}
+
+// Apply posts the event of the change to the endpoint. The ID of the event is the same
+// when the record is retried, so the endpoint can drop duplicates.
+func (t *webhook) Apply(ctx context.Context, c Change) error {
+	e, err := c.Event(t.source)
+	if err != nil {
+		return err
+	}
+
+	payload, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", bus.ContentType)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"context"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+)
+
+// manager records a span for every call to the Manager it wraps.
+type manager struct {
+	db      datastore.Manager
+	backend string
+	ctx     context.Context
+}
+
+// Instrument returns a Manager that records a span for every call to db, which is the
+// named backend. The spans are children of the span in the context set with WithContext,
+// and the operations db runs against its backend are children of those spans.
+func Instrument(db datastore.Manager, backend string) datastore.Manager {
+	return manager{
+		db:      db,
+		backend: backend,
+		ctx:     context.Background(),
+	}
+}
+
+// start starts the span of a call to method and returns the Manager to make the call with
+func (m manager) start(method string) (datastore.Manager, *Span) {
+	ctx, span := StartChild(m.ctx, "datastore."+method, KindInternal)
+	span.SetAttribute("db.system", m.backend)
+	span.SetAttribute("code.function", method)
+	return m.db.WithContext(ctx), span
+}
+
+// AddProduct stores a product and records the call
+func (m manager) AddProduct(p datastore.Product) (datastore.Revision, error) {
+	db, span := m.start("AddProduct")
+	span.SetAttribute("catalog.product_id", p.Item.ID)
+	rev, err := db.AddProduct(p)
+	span.End(err)
+	return rev, err
+}
+
+// GetProduct retrieves a product and records the call
+func (m manager) GetProduct(productID string) (datastore.Product, error) {
+	db, span := m.start("GetProduct")
+	span.SetAttribute("catalog.product_id", productID)
+	p, err := db.GetProduct(productID)
+	span.End(err)
+	return p, err
+}
+
+// GetProducts retrieves all products and records the call
+func (m manager) GetProducts() ([]datastore.Product, error) {
+	db, span := m.start("GetProducts")
+	p, err := db.GetProducts()
+	span.SetAttribute("catalog.products", len(p))
+	span.End(err)
+	return p, err
+}
+
+// GetProductsByIDs retrieves the products with the IDs and records the call
+func (m manager) GetProductsByIDs(productIDs []string) ([]datastore.Product, error) {
+	db, span := m.start("GetProductsByIDs")
+	span.SetAttribute("catalog.requested", len(productIDs))
+	p, err := db.GetProductsByIDs(productIDs)
+	span.SetAttribute("catalog.products", len(p))
+	span.End(err)
+	return p, err
+}
+
+// SetProductStatus moves a product to a new status and records the call
+func (m manager) SetProductStatus(productID string, status datastore.Status) (datastore.Revision, error) {
+	db, span := m.start("SetProductStatus")
+	span.SetAttribute("catalog.product_id", productID)
+	span.SetAttribute("catalog.status", string(status))
+	rev, err := db.SetProductStatus(productID, status)
+	span.End(err)
+	return rev, err
+}
+
+// UpdateProductItem replaces the catalog item of a product and records the call
+func (m manager) UpdateProductItem(item acmeserverless.CatalogItem) (datastore.Revision, error) {
+	db, span := m.start("UpdateProductItem")
+	span.SetAttribute("catalog.product_id", item.ID)
+	rev, err := db.UpdateProductItem(item)
+	span.End(err)
+	return rev, err
+}
+
+// SetProductWindow changes the availability window of a product and records the call
+func (m manager) SetProductWindow(productID string, window datastore.Window) (datastore.Revision, error) {
+	db, span := m.start("SetProductWindow")
+	span.SetAttribute("catalog.product_id", productID)
+	rev, err := db.SetProductWindow(productID, window)
+	span.End(err)
+	return rev, err
+}
+
+// DeleteProduct removes a product and records the call
+func (m manager) DeleteProduct(productID string) (datastore.Revision, error) {
+	db, span := m.start("DeleteProduct")
+	span.SetAttribute("catalog.product_id", productID)
+	rev, err := db.DeleteProduct(productID)
+	span.End(err)
+	return rev, err
+}
+
+// ForEachProduct calls fn for every product and records the call, including the time
+// spent in fn
+func (m manager) ForEachProduct(fn func(p datastore.Product) error) error {
+	db, span := m.start("ForEachProduct")
+	err := db.ForEachProduct(fn)
+	span.End(err)
+	return err
+}
+
+// GetRevisions retrieves the revisions of a product and records the call
+func (m manager) GetRevisions(productID string) ([]datastore.Revision, error) {
+	db, span := m.start("GetRevisions")
+	span.SetAttribute("catalog.product_id", productID)
+	revs, err := db.GetRevisions(productID)
+	span.End(err)
+	return revs, err
+}
+
+// RollbackProduct restores a version of a product and records the call
+func (m manager) RollbackProduct(productID string, version int) (datastore.Revision, error) {
+	db, span := m.start("RollbackProduct")
+	span.SetAttribute("catalog.product_id", productID)
+	span.SetAttribute("catalog.version", version)
+	rev, err := db.RollbackProduct(productID, version)
+	span.End(err)
+	return rev, err
+}
+
+// Ping checks the data store and records the call
+func (m manager) Ping() error {
+	db, span := m.start("Ping")
+	err := db.Ping()
+	span.End(err)
+	return err
+}
+
+// WithActor returns a Manager that records actor in the revisions and keeps recording
+// the calls
+func (m manager) WithActor(actor string) datastore.Manager {
+	return manager{
+		db:      m.db.WithActor(actor),
+		backend: m.backend,
+		ctx:     m.ctx,
+	}
+}
+
+// WithContext returns a Manager that records the calls as children of the span in ctx
+func (m manager) WithContext(ctx context.Context) datastore.Manager {
+	return manager{
+		db:      m.db.WithContext(ctx),
+		backend: m.backend,
+		ctx:     ctx,
+	}
+}
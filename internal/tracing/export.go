@@ -0,0 +1,242 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+const (
+	// ExporterOTLP sends the spans to an OpenTelemetry collector with OTLP over HTTP
+	ExporterOTLP = "otlp"
+
+	// ExporterStdout prints the spans to stdout as JSON
+	ExporterStdout = "stdout"
+
+	// ExporterNone disables tracing
+	ExporterNone = "none"
+
+	// maxQueueSize is the number of ended spans kept in memory, spans that end while
+	// the queue is full are dropped
+	maxQueueSize = 2048
+
+	// maxBatchSize is the number of spans that triggers an export before the interval
+	maxBatchSize = 512
+
+	// exportTimeout is how long flushing the spans can take
+	exportTimeout = 10 * time.Second
+)
+
+// Config configures how spans are exported.
+type Config struct {
+	// Exporter is one of ExporterOTLP, ExporterStdout or ExporterNone
+	Exporter string
+
+	// Endpoint is the URL the OTLP exporter sends the spans to
+	Endpoint string
+
+	// Headers are added to the requests of the OTLP exporter, for authentication
+	Headers map[string]string
+
+	// ServiceName identifies the service in the traces
+	ServiceName string
+
+	// FlushInterval is the interval at which spans are exported
+	FlushInterval time.Duration
+}
+
+// FromEnv creates the configuration with the standard OpenTelemetry environment variables.
+// OTEL_TRACES_EXPORTER is otlp, stdout or none, which is the default.
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is the URL the spans are sent to, or else /v1/traces
+// on OTEL_EXPORTER_OTLP_ENDPOINT, which defaults to http://localhost:4318.
+// OTEL_EXPORTER_OTLP_HEADERS is a comma separated list of key=value pairs and
+// OTEL_SERVICE_NAME the name of the service, which defaults to catalog.
+func FromEnv() (Config, error) {
+	cfg := Config{
+		Exporter:      strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")),
+		Endpoint:      os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		Headers:       make(map[string]string),
+		ServiceName:   os.Getenv("OTEL_SERVICE_NAME"),
+		FlushInterval: 5 * time.Second,
+	}
+
+	if cfg.Exporter == "" {
+		cfg.Exporter = ExporterNone
+	}
+
+	if cfg.Endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			base = "http://localhost:4318"
+		}
+		cfg.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "catalog"
+	}
+
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return Config{}, fmt.Errorf("error parsing OTEL_EXPORTER_OTLP_HEADERS: %q isn't a key=value pair", pair)
+		}
+		cfg.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	switch cfg.Exporter {
+	case ExporterOTLP, ExporterStdout, ExporterNone:
+	default:
+		return Config{}, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, use otlp, stdout or none", cfg.Exporter)
+	}
+
+	return cfg, nil
+}
+
+// propagator reads and writes the W3C traceparent header.
+var propagator = propagation.TraceContext{}
+
+// provider is the tracer provider of the process, it's nil when tracing is disabled
+var (
+	providerMu sync.Mutex
+	provider   *sdktrace.TracerProvider
+)
+
+// Init starts exporting spans as configured. Spans started before Init, or when the
+// exporter is ExporterNone, aren't recorded.
+func Init(cfg Config) error {
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		e, err := newOTLP(cfg.Endpoint, cfg.Headers)
+		if err != nil {
+			return err
+		}
+		exporter = e
+	case ExporterStdout:
+		e, err := stdout.NewExporter(stdout.WithWriter(os.Stdout), stdout.WithoutMetricExport())
+		if err != nil {
+			return err
+		}
+		exporter = e
+	case ExporterNone, "":
+		return nil
+	default:
+		return fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return install(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithBatchTimeout(interval),
+			sdktrace.WithMaxQueueSize(maxQueueSize),
+			sdktrace.WithMaxExportBatchSize(maxBatchSize),
+		),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	))
+}
+
+// newOTLP creates the exporter that sends the spans to the collector at endpoint with
+// OTLP over HTTP
+func newOTLP(endpoint string, headers map[string]string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+
+	opts := []otlphttp.Option{
+		otlphttp.WithEndpoint(u.Host),
+		otlphttp.WithTracesURLPath(u.Path),
+		otlphttp.WithHeaders(headers),
+	}
+	switch u.Scheme {
+	case "http":
+		opts = append(opts, otlphttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, use http or https", endpoint)
+	}
+
+	return otlp.NewExporter(context.Background(), otlphttp.NewDriver(opts...))
+}
+
+// install makes p the tracer provider of the process and shuts the previous one down
+func install(p *sdktrace.TracerProvider) error {
+	providerMu.Lock()
+	old := provider
+	provider = p
+	providerMu.Unlock()
+
+	otel.SetTracerProvider(p)
+	otel.SetTextMapPropagator(propagator)
+	otel.SetErrorHandler(errorHandler{})
+
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		return old.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Flush exports the spans that have ended so far. Lambda functions call it at the end of
+// every invocation, because the container can be frozen before the next interval.
+func Flush() error {
+	providerMu.Lock()
+	p := provider
+	providerMu.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	return p.ForceFlush(ctx)
+}
+
+// Shutdown exports the remaining spans and stops the exporter
+func Shutdown() error {
+	providerMu.Lock()
+	p := provider
+	provider = nil
+	providerMu.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// errorHandler logs the errors of the SDK, like spans that couldn't be exported.
+type errorHandler struct{}
+
+// Handle logs err
+func (errorHandler) Handle(err error) {
+	logging.Default().Error("error exporting spans", "error", err)
+}
@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setenv sets the environment variables for the rest of the test, the empty ones are
+// unset
+func setenv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: Config{Exporter: ExporterNone, Endpoint: "http://localhost:4318/v1/traces", Headers: map[string]string{}, ServiceName: "catalog"},
+		},
+		{
+			name: "otlp",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":        "OTLP",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector.example.com/",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "api-key = s3cr3t, x-tenant=acme,",
+				"OTEL_SERVICE_NAME":           "catalog-http",
+			},
+			want: Config{
+				Exporter:    ExporterOTLP,
+				Endpoint:    "https://collector.example.com/v1/traces",
+				Headers:     map[string]string{"api-key": "s3cr3t", "x-tenant": "acme"},
+				ServiceName: "catalog-http",
+			},
+		},
+		{
+			name: "traces endpoint",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":               "otlp",
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://collector.example.com",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces.example.com/api/traces",
+			},
+			want: Config{Exporter: ExporterOTLP, Endpoint: "https://traces.example.com/api/traces", Headers: map[string]string{}, ServiceName: "catalog"},
+		},
+		{
+			name:    "unknown exporter",
+			env:     map[string]string{"OTEL_TRACES_EXPORTER": "jaeger"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid headers",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "api-key"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		env := map[string]string{
+			"OTEL_TRACES_EXPORTER":               "",
+			"OTEL_EXPORTER_OTLP_ENDPOINT":        "",
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "",
+			"OTEL_EXPORTER_OTLP_HEADERS":         "",
+			"OTEL_SERVICE_NAME":                  "",
+		}
+		for k, v := range tt.env {
+			env[k] = v
+		}
+		setenv(t, env)
+
+		got, err := FromEnv()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: FromEnv() = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		tt.want.FlushInterval = 5 * time.Second
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FromEnv() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// collector is an OTLP collector that keeps the requests it receives.
+type collector struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+}
+
+func TestInitOTLP(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	err := Init(Config{
+		Exporter:      ExporterOTLP,
+		Endpoint:      server.URL + "/v1/traces",
+		Headers:       map[string]string{"api-key": "s3cr3t"},
+		ServiceName:   "catalog-test",
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Init() = %v", err)
+	}
+
+	ctx, span := Start(context.Background(), "GET /products", KindServer)
+	_, child := StartChild(ctx, "datastore.GetProducts", KindInternal)
+	child.End(nil)
+	span.End(nil)
+
+	// The spans are only exported at the interval, or when they're flushed
+	c.mu.Lock()
+	n := len(c.requests)
+	c.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d requests before the flush, want 0", n)
+	}
+
+	if err := Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if err := Shutdown(); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(c.requests))
+	}
+
+	r := c.requests[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		t.Errorf("request is %s %s, want POST /v1/traces", r.Method, r.URL.Path)
+	}
+	if got := r.Header.Get("api-key"); got != "s3cr3t" {
+		t.Errorf("api-key = %q, want the configured header", got)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q, want application/x-protobuf", got)
+	}
+
+	// The request is a protobuf message, which contains the names as they are
+	body := string(c.bodies[0])
+	for _, s := range []string{"catalog-test", "GET /products", "datastore.GetProducts"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("the request doesn't contain %q", s)
+		}
+	}
+}
+
+func TestInitError(t *testing.T) {
+	tests := []Config{
+		{Exporter: "jaeger"},
+		{Exporter: ExporterOTLP, Endpoint: "localhost:4318"},
+		{Exporter: ExporterOTLP, Endpoint: "ftp://localhost:4318/v1/traces"},
+	}
+
+	for _, cfg := range tests {
+		if err := Init(cfg); err == nil {
+			t.Errorf("Init(%+v) = nil, want an error", cfg)
+			Shutdown()
+		}
+	}
+}
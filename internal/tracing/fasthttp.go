@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// contextValue is the user value of a fasthttp request that holds the context of its span
+const contextValue = "tracing.context"
+
+// Middleware returns a fasthttp handler that records a server span for every request
+// handled by next. The span continues the trace of the traceparent header of the request
+// and its context is available to next through ContextFromRequest. The route is the
+// pattern next is registered with, so the spans are named after the route rather than
+// the product.
+func Middleware(route string, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Method())
+
+		parent := extractRequest(context.Background(), &ctx.Request.Header)
+		spanCtx, span := Start(parent, method+" "+route, KindServer)
+		span.SetAttribute("http.method", method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", string(ctx.RequestURI()))
+		span.SetAttribute("http.user_agent", string(ctx.UserAgent()))
+		span.SetAttribute("net.peer.ip", ctx.RemoteIP().String())
+
+		ctx.SetUserValue(contextValue, spanCtx)
+
+		// The span is ended even when next panics, so the trace shows the failed request
+		status := 0
+		defer func() {
+			var err error
+			switch {
+			case status == 0:
+				err = fmt.Errorf("the handler panicked")
+			case status >= http.StatusInternalServerError:
+				err = fmt.Errorf("%d %s", status, http.StatusText(status))
+			}
+			span.End(err)
+		}()
+
+		next(ctx)
+
+		status = ctx.Response.StatusCode()
+		span.SetAttribute("http.status_code", status)
+	}
+}
+
+// ContextFromRequest returns the context that holds the span of the request, or an empty
+// context when the request isn't traced. Calls made with the context, like the calls to
+// the data store, are recorded as children of the request.
+func ContextFromRequest(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(contextValue).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
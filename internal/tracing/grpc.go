@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that records a server span for every
+// call. The span continues the trace of the traceparent metadata of the call and is passed
+// to the handler in the context.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		spanCtx, span := startCall(ctx, info.FullMethod)
+		defer func() {
+			endCall(span, err, recover())
+		}()
+
+		return handler(spanCtx, req)
+	}
+}
+
+// StreamServerInterceptor returns the streaming counterpart of UnaryServerInterceptor. The
+// span lasts until the stream ends.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		spanCtx, span := startCall(ss.Context(), info.FullMethod)
+		defer func() {
+			endCall(span, err, recover())
+		}()
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: spanCtx})
+	}
+}
+
+// startCall starts the server span of a call to the method, which is the full name of
+// the method, like /acme.catalog.v1.CatalogService/Get.
+func startCall(ctx context.Context, method string) (context.Context, *Span) {
+	spanCtx, span := Start(extractMetadata(ctx), strings.TrimPrefix(method, "/"), KindServer)
+	span.SetAttribute("rpc.system", "grpc")
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		span.SetAttribute("rpc.service", method[1:i])
+		span.SetAttribute("rpc.method", method[i+1:])
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		ip, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			ip = p.Addr.String()
+		}
+		span.SetAttribute("net.peer.ip", ip)
+	}
+
+	return spanCtx, span
+}
+
+// endCall ends the span of a call with its error. A panic of the handler is recorded and
+// passed on. Only the codes that point at a problem of the server mark the span as failed,
+// like the 5xx status codes of HTTP requests.
+func endCall(span *Span, err error, panicked interface{}) {
+	if panicked != nil {
+		span.End(fmt.Errorf("the handler panicked"))
+		panic(panicked)
+	}
+
+	code := status.Code(err)
+	span.SetAttribute("rpc.grpc.status_code", int(code))
+
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		span.End(err)
+	default:
+		span.End(nil)
+	}
+}
+
+// serverStream is a server stream with the context of the call replaced.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the call
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/retgits/acme-serverless-catalog/internal/logging"
+)
+
+// LambdaHandler handles an API Gateway event.
+type LambdaHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WrapLambda returns a Lambda handler that records a server span for every event handled
+// by next. The span continues the trace of the traceparent header of the request and is
+// passed to next in the context. The spans are exported before the handler returns,
+// because the container can be frozen until the next event.
+func WrapLambda(next LambdaHandler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		parent := extractEvent(ctx, request)
+		spanCtx, span := Start(parent, request.HTTPMethod+" "+request.Resource, KindServer)
+		span.SetAttribute("http.method", request.HTTPMethod)
+		span.SetAttribute("http.route", request.Resource)
+		span.SetAttribute("http.target", request.Path)
+		span.SetAttribute("http.user_agent", request.RequestContext.Identity.UserAgent)
+		span.SetAttribute("net.peer.ip", request.RequestContext.Identity.SourceIP)
+		span.SetAttribute("faas.trigger", "http")
+		span.SetAttribute("faas.name", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			span.SetAttribute("faas.execution", lc.AwsRequestID)
+		}
+
+		res, err := next(spanCtx, request)
+
+		span.SetAttribute("http.status_code", res.StatusCode)
+		switch {
+		case err != nil:
+			span.End(err)
+		case res.StatusCode >= http.StatusInternalServerError:
+			span.End(fmt.Errorf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)))
+		default:
+			span.End(nil)
+		}
+
+		if ferr := Flush(); ferr != nil {
+			logging.FromContext(ctx).Error("error exporting spans", "error", ferr)
+		}
+
+		return res, err
+	}
+}
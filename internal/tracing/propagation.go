@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/apigateway"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestCarrier reads the trace context from the headers of a fasthttp request.
+type requestCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+// Get returns the value of the header
+func (c requestCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+// Set sets the header
+func (c requestCarrier) Set(key string, value string) {
+	c.header.Set(key, value)
+}
+
+// Keys returns the names of the headers
+func (c requestCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
+
+// metadataCarrier reads the trace context from the metadata of a gRPC call.
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the key
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set sets the key
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the keys of the metadata
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// eventCarrier reads the trace context from the headers of an API Gateway request.
+type eventCarrier events.APIGatewayProxyRequest
+
+// Get returns the value of the header
+func (c eventCarrier) Get(key string) string {
+	return apigateway.Header(events.APIGatewayProxyRequest(c), key)
+}
+
+// Set does nothing, the request is only read
+func (c eventCarrier) Set(key string, value string) {}
+
+// Keys returns the names of the headers
+func (c eventCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.Headers))
+	for k := range c.Headers {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// extractRequest returns a copy of ctx that holds the span context of the traceparent
+// header of the request, so the next span is started as its child. An invalid header
+// starts a new trace, as the specification requires.
+func extractRequest(ctx context.Context, header *fasthttp.RequestHeader) context.Context {
+	return propagator.Extract(ctx, requestCarrier{header: header})
+}
+
+// extractMetadata returns a copy of ctx that holds the span context of the traceparent
+// metadata of the incoming call in ctx.
+func extractMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return propagator.Extract(ctx, metadataCarrier(md))
+}
+
+// extractEvent returns a copy of ctx that holds the span context of the traceparent
+// header of the API Gateway request.
+func extractEvent(ctx context.Context, request events.APIGatewayProxyRequest) context.Context {
+	return propagator.Extract(ctx, eventCarrier(request))
+}
@@ -0,0 +1,267 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-catalog/internal/datastore"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	callerTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpan  = "00f067aa0ba902b7"
+)
+
+// propagationTests are the traceparent headers of the callers, and whether the span of
+// the request continues the trace of the caller and is recorded
+var propagationTests = []struct {
+	name        string
+	traceparent string
+	continued   bool
+	recorded    bool
+}{
+	{name: "no header", recorded: true},
+	{name: "sampled", traceparent: "00-" + callerTrace + "-" + callerSpan + "-01", continued: true, recorded: true},
+	{name: "not sampled", traceparent: "00-" + callerTrace + "-" + callerSpan + "-00", continued: true},
+	{name: "uppercase", traceparent: "00-" + "4BF92F3577B34DA6A3CE929D0E0E4736" + "-" + callerSpan + "-01", recorded: true},
+	{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-" + callerSpan + "-01", recorded: true},
+	{name: "zero span id", traceparent: "00-" + callerTrace + "-0000000000000000-01", recorded: true},
+	{name: "version ff", traceparent: "ff-" + callerTrace + "-" + callerSpan + "-01", recorded: true},
+	{name: "too short", traceparent: "00-" + callerTrace + "-01", recorded: true},
+	{name: "garbage", traceparent: "not a traceparent", recorded: true},
+}
+
+// checkPropagation checks the span context of a request against the test
+func checkPropagation(t *testing.T, name string, sc trace.SpanContext, continued bool, recorded bool) {
+	t.Helper()
+
+	if !sc.IsValid() {
+		t.Errorf("%s: the request has no span", name)
+		return
+	}
+	if got := sc.TraceID().String() == callerTrace; got != continued {
+		t.Errorf("%s: trace %s continues the caller's trace: %t, want %t", name, sc.TraceID(), got, continued)
+	}
+	if sc.IsSampled() != recorded {
+		t.Errorf("%s: sampled = %t, want %t", name, sc.IsSampled(), recorded)
+	}
+}
+
+// checkParent checks the parent of an exported span against the test
+func checkParent(t *testing.T, name string, s *sdktrace.SpanSnapshot, continued bool) {
+	t.Helper()
+
+	if continued {
+		if s.Parent.SpanID().String() != callerSpan || !s.Parent.IsRemote() {
+			t.Errorf("%s: parent = %s, want the remote span %s", name, s.Parent.SpanID(), callerSpan)
+		}
+	} else if s.Parent.IsValid() {
+		t.Errorf("%s: parent = %s, want a new trace", name, s.Parent.SpanID())
+	}
+}
+
+// fakeDB is a data store that only knows GetProduct and keeps the context it's used with.
+type fakeDB struct {
+	datastore.Manager
+	ctx context.Context
+}
+
+// WithContext returns a fakeDB that keeps ctx
+func (db fakeDB) WithContext(ctx context.Context) datastore.Manager {
+	return fakeDB{ctx: ctx}
+}
+
+// GetProduct starts a span for the query, like the backends do, and returns not found
+func (db fakeDB) GetProduct(productID string) (datastore.Product, error) {
+	_, span := StartChild(db.ctx, "query", KindClient)
+	span.End(nil)
+	return datastore.Product{}, errors.New("product not found")
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, tt := range propagationTests {
+		exporter := record(t)
+		db := Instrument(fakeDB{}, "fake")
+
+		handler := Middleware("/products/{id}", func(ctx *fasthttp.RequestCtx) {
+			reqCtx := ContextFromRequest(ctx)
+			checkPropagation(t, tt.name, trace.SpanContextFromContext(reqCtx), tt.continued, tt.recorded)
+
+			if _, err := db.WithContext(reqCtx).GetProduct("p-1"); err == nil {
+				t.Errorf("%s: GetProduct() = nil, want not found", tt.name)
+			}
+			ctx.SetStatusCode(http.StatusNotFound)
+		})
+
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(http.MethodGet)
+		ctx.Request.SetRequestURI("/products/p-1")
+		if tt.traceparent != "" {
+			ctx.Request.Header.Set("Traceparent", tt.traceparent)
+		}
+		handler(&ctx)
+
+		spans := exporter.GetSpans()
+		if !tt.recorded {
+			if len(spans) != 0 {
+				t.Errorf("%s: %d spans exported, want 0", tt.name, len(spans))
+			}
+			Shutdown()
+			continue
+		}
+		if len(spans) != 3 {
+			t.Errorf("%s: %d spans exported, want the request, the call and the query", tt.name, len(spans))
+			Shutdown()
+			continue
+		}
+
+		server := spanNamed(t, exporter, "GET /products/{id}")
+		call := spanNamed(t, exporter, "datastore.GetProduct")
+		query := spanNamed(t, exporter, "query")
+
+		checkParent(t, tt.name, server, tt.continued)
+		if call.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("%s: the data store call isn't a child of the request", tt.name)
+		}
+		if query.Parent.SpanID() != call.SpanContext.SpanID() {
+			t.Errorf("%s: the query isn't a child of the data store call", tt.name)
+		}
+		if got := attr(server, "http.route"); got != "/products/{id}" {
+			t.Errorf("%s: http.route = %v", tt.name, got)
+		}
+		if got := attr(server, "http.status_code"); got != int64(http.StatusNotFound) {
+			t.Errorf("%s: http.status_code = %v, want 404", tt.name, got)
+		}
+		if server.StatusCode != codes.Unset {
+			t.Errorf("%s: a 404 marked the request as failed", tt.name)
+		}
+		if call.StatusCode != codes.Error {
+			t.Errorf("%s: the failed call isn't marked as failed", tt.name)
+		}
+		Shutdown()
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	exporter := record(t)
+
+	handler := Middleware("/products", func(ctx *fasthttp.RequestCtx) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("the panic wasn't passed on")
+			}
+		}()
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(http.MethodPost)
+		handler(&ctx)
+	}()
+
+	s := spanNamed(t, exporter, "POST /products")
+	if s.StatusCode != codes.Error {
+		t.Errorf("status = %s, want the panic recorded as an error", s.StatusCode)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/acme.catalog.v1.CatalogService/Get"}
+
+	for _, tt := range propagationTests {
+		exporter := record(t)
+
+		ctx := context.Background()
+		if tt.traceparent != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("traceparent", tt.traceparent))
+		}
+		interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			checkPropagation(t, tt.name, trace.SpanContextFromContext(ctx), tt.continued, tt.recorded)
+			return nil, nil
+		})
+
+		if tt.recorded {
+			s := spanNamed(t, exporter, "acme.catalog.v1.CatalogService/Get")
+			checkParent(t, tt.name, s, tt.continued)
+			if got := attr(s, "rpc.method"); got != "Get" {
+				t.Errorf("%s: rpc.method = %v, want Get", tt.name, got)
+			}
+		}
+		Shutdown()
+	}
+}
+
+func TestUnaryServerInterceptorStatus(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/acme.catalog.v1.CatalogService/Get"}
+
+	tests := []struct {
+		err    error
+		failed bool
+	}{
+		{err: nil},
+		{err: status.Error(grpccodes.NotFound, "product not found")},
+		{err: status.Error(grpccodes.InvalidArgument, "invalid product")},
+		{err: status.Error(grpccodes.Internal, "boom"), failed: true},
+		{err: status.Error(grpccodes.Unavailable, "boom"), failed: true},
+		{err: errors.New("boom"), failed: true},
+	}
+
+	for _, tt := range tests {
+		exporter := record(t)
+
+		interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, tt.err
+		})
+
+		s := spanNamed(t, exporter, "acme.catalog.v1.CatalogService/Get")
+		if got := s.StatusCode == codes.Error; got != tt.failed {
+			t.Errorf("%v: failed = %t, want %t", tt.err, got, tt.failed)
+		}
+		Shutdown()
+	}
+}
+
+func TestWrapLambda(t *testing.T) {
+	for _, tt := range propagationTests {
+		exporter := record(t)
+
+		handler := WrapLambda(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			checkPropagation(t, tt.name, trace.SpanContextFromContext(ctx), tt.continued, tt.recorded)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+		})
+
+		// API Gateway keeps the case of the header names the client sent
+		request := events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodGet,
+			Resource:   "/products",
+			Headers:    map[string]string{},
+		}
+		if tt.traceparent != "" {
+			request.Headers["TraceParent"] = tt.traceparent
+		}
+		handler(context.Background(), request)
+
+		if tt.recorded {
+			s := spanNamed(t, exporter, "GET /products")
+			checkParent(t, tt.name, s, tt.continued)
+			if s.StatusCode != codes.Error {
+				t.Errorf("%s: a 500 isn't marked as failed", tt.name)
+			}
+		}
+		Shutdown()
+	}
+}
@@ -0,0 +1,89 @@
+// Package tracing records OpenTelemetry traces of the Catalog service of the ACME
+// Serverless Fitness Shop with the OpenTelemetry SDK: a span for every request handled by
+// the API, with child spans for the calls to the data store and the operations they run
+// against DynamoDB or MongoDB. The trace context is propagated with the W3C traceparent
+// header and the spans are sent to an OTLP collector, or printed to stdout for local runs.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans of the catalog to the SDK
+const instrumentationName = "github.com/retgits/acme-serverless-catalog/internal/tracing"
+
+// Kind describes the relationship between the span and its parent.
+type Kind = trace.SpanKind
+
+const (
+	// KindInternal is an operation within the service
+	KindInternal = trace.SpanKindInternal
+
+	// KindServer is a request handled by the service
+	KindServer = trace.SpanKindServer
+
+	// KindClient is a request to a remote service, like a database
+	KindClient = trace.SpanKindClient
+)
+
+// Span is a span of the OpenTelemetry SDK. All methods can be called on nil, which is
+// the span StartChild returns outside of a traced request.
+type Span struct {
+	span trace.Span
+}
+
+// SetAttribute adds an attribute to the span, unless it has ended
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attribute.Any(key, value))
+}
+
+// End ends the span, marking it as failed when err isn't nil. Calls after the first one
+// are ignored.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// SpanContext returns the IDs of the span and the sampling decision, which are
+// propagated to its children
+func (s *Span) SpanContext() trace.SpanContext {
+	if s == nil {
+		return trace.SpanContext{}
+	}
+	return s.span.SpanContext()
+}
+
+// Start starts a span as a child of the span in ctx, or of the span context extracted
+// from the request of another service, or as the root of a new trace when ctx doesn't
+// have either, and returns a copy of ctx that holds the new span. Spans are only
+// recorded once Init configured an exporter, children follow the sampling decision of
+// their parent.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &Span{span: span}
+}
+
+// StartChild starts a span like Start does, but only when ctx already holds a span. It
+// returns nil otherwise, so operations outside of a request don't start traces of their
+// own.
+func StartChild(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind)
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record installs a tracer provider that exports every span to the returned exporter as
+// soon as it ends, and disables tracing again when the test ends
+func record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	p := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	if err := install(p); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := Shutdown(); err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	return exporter
+}
+
+// spanNamed returns the exported span with the name
+func spanNamed(t *testing.T, exporter *tracetest.InMemoryExporter, name string) *sdktrace.SpanSnapshot {
+	t.Helper()
+
+	for _, s := range exporter.GetSpans() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %q in %d exported spans", name, len(exporter.GetSpans()))
+	return nil
+}
+
+// attr returns the value of the attribute of the span, or nil if it's not set
+func attr(s *sdktrace.SpanSnapshot, key string) interface{} {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func TestStart(t *testing.T) {
+	exporter := record(t)
+
+	ctx, root := Start(context.Background(), "root", KindServer)
+	childCtx, child := StartChild(ctx, "child", KindClient)
+	child.SetAttribute("catalog.product_id", "p-1")
+	child.SetAttribute("catalog.products", 3)
+	child.End(errors.New("boom"))
+	root.End(nil)
+
+	if trace.SpanFromContext(childCtx).SpanContext().SpanID() != child.SpanContext().SpanID() {
+		t.Errorf("StartChild() didn't return a context that holds the child")
+	}
+
+	rs := spanNamed(t, exporter, "root")
+	cs := spanNamed(t, exporter, "child")
+
+	if rs.Parent.IsValid() {
+		t.Errorf("root has parent %s, want none", rs.Parent.SpanID())
+	}
+	if rs.SpanKind != trace.SpanKindServer || cs.SpanKind != trace.SpanKindClient {
+		t.Errorf("kinds are %s and %s, want server and client", rs.SpanKind, cs.SpanKind)
+	}
+	if cs.SpanContext.TraceID() != rs.SpanContext.TraceID() {
+		t.Errorf("child has trace %s, want %s", cs.SpanContext.TraceID(), rs.SpanContext.TraceID())
+	}
+	if cs.Parent.SpanID() != rs.SpanContext.SpanID() {
+		t.Errorf("child has parent %s, want %s", cs.Parent.SpanID(), rs.SpanContext.SpanID())
+	}
+
+	if got := attr(cs, "catalog.product_id"); got != "p-1" {
+		t.Errorf("catalog.product_id = %v, want p-1", got)
+	}
+	if got := attr(cs, "catalog.products"); got != int64(3) {
+		t.Errorf("catalog.products = %v, want 3", got)
+	}
+	if cs.StatusCode != codes.Error || cs.StatusMessage != "boom" {
+		t.Errorf("child has status %s %q, want an error", cs.StatusCode, cs.StatusMessage)
+	}
+	if len(cs.MessageEvents) != 1 || cs.MessageEvents[0].Name != "exception" {
+		t.Errorf("child has events %v, want the exception", cs.MessageEvents)
+	}
+	if rs.StatusCode != codes.Unset {
+		t.Errorf("root has status %s, want unset", rs.StatusCode)
+	}
+}
+
+func TestStartChildWithoutParent(t *testing.T) {
+	exporter := record(t)
+
+	ctx := context.Background()
+	got, span := StartChild(ctx, "orphan", KindClient)
+	if span != nil || got != ctx {
+		t.Errorf("StartChild() without a parent = %v, want no span", span)
+	}
+
+	// All methods can be called on the span StartChild returns
+	span.SetAttribute("db.system", "mongodb")
+	span.End(errors.New("boom"))
+	if span.SpanContext().IsValid() {
+		t.Errorf("SpanContext() of nil is valid")
+	}
+
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Errorf("%d spans exported, want 0", n)
+	}
+}
+
+func TestStartWithoutInit(t *testing.T) {
+	ctx, span := Start(context.Background(), "request", KindServer)
+	defer span.End(nil)
+
+	if span.SpanContext().IsValid() {
+		t.Errorf("Start() before Init recorded a span")
+	}
+	if _, child := StartChild(ctx, "datastore.GetProduct", KindInternal); child != nil {
+		t.Errorf("StartChild() before Init started a span")
+	}
+	if err := Flush(); err != nil {
+		t.Errorf("Flush() before Init = %v", err)
+	}
+}
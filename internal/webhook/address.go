@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// dialTimeout is how long connecting to an endpoint can take
+const dialTimeout = 5 * time.Second
+
+// privateNetworks are the networks, next to the loopback and link-local ones, that
+// endpoints can't be in, so subscriptions can't be used to reach the services that run
+// next to the catalog or the metadata servers of the cloud providers
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"fc00::/7",
+)
+
+// parseNetworks parses the CIDR notation of networks
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = n
+	}
+	return networks
+}
+
+// checkIP returns an error if ip isn't a public unicast address.
+func checkIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || !ip.IsGlobalUnicast() {
+		return fmt.Errorf("address %s isn't a public address", ip)
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return fmt.Errorf("address %s is in private network %s", ip, n)
+		}
+	}
+	return nil
+}
+
+// checkHost returns an error if the host of an endpoint is an address that isn't public
+// or a name that always points to the machine itself. Other names are checked when the
+// dispatcher connects to them, because what they resolve to can change.
+func checkHost(host string) error {
+	h := strings.ToLower(strings.TrimSuffix(host, "."))
+	if h == "localhost" || strings.HasSuffix(h, ".localhost") {
+		return fmt.Errorf("host %s is the local machine", host)
+	}
+
+	if ip := net.ParseIP(strings.Trim(h, "[]")); ip != nil {
+		return checkIP(ip)
+	}
+	return nil
+}
+
+// checkDial is the Control function of the dialer of the dispatcher. It runs after the
+// name of the endpoint is resolved, right before every connection, so an endpoint whose
+// name starts pointing to a private address after the subscription was validated is
+// refused as well.
+func checkDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("address %s isn't an IP address", host)
+	}
+	return checkIP(ip)
+}
+
+// newClient returns the HTTP client that sends the deliveries. It only connects to public
+// addresses, doesn't use a proxy and checks every redirect the same way.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: checkDial,
+	}
+
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: dialTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
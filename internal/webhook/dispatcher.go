@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+const (
+	// MaxAttempts is the number of times a delivery is attempted before it's given up on
+	MaxAttempts = 8
+
+	// deliveryTimeout is how long an endpoint can take to accept a delivery
+	deliveryTimeout = 10 * time.Second
+
+	// deliveryBatchSize is the number of due deliveries read at a time
+	deliveryBatchSize = 25
+
+	// minBackoff is the time before the second attempt, which doubles after every attempt
+	minBackoff = time.Minute
+
+	// maxBackoff is the longest time between two attempts
+	maxBackoff = time.Hour
+
+	// claimTimeout is how long a claimed delivery is left to the instance that claimed
+	// it, before another instance attempts it when its outcome wasn't saved
+	claimTimeout = 3 * deliveryTimeout
+)
+
+// ErrNotFound is returned by a Store when a subscription doesn't exist.
+var ErrNotFound = errors.New("subscription not found")
+
+// Dispatcher delivers the change events to the subscriptions. It's a bus.Publisher, so it
+// can be combined with the message bus using bus.Multi: publishing an event queues a
+// delivery for every matching subscription in the store, and Deliver sends the deliveries
+// that are due.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+
+	// mu makes sure the deliveries are sent by one caller of the process at a time
+	mu sync.Mutex
+}
+
+// NewDispatcher creates a dispatcher that keeps the subscriptions and deliveries in store.
+// It only connects to endpoints at public addresses.
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: newClient(),
+	}
+}
+
+// Publish queues a delivery of the event for every subscription that matches its type.
+// The deliveries are sent by the next call to Deliver.
+func (d *Dispatcher) Publish(ctx context.Context, e bus.Event) error {
+	subs, err := d.store.Subscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading webhook subscriptions: %s", err.Error())
+	}
+
+	for _, s := range subs {
+		if !s.Match(e.Type) {
+			continue
+		}
+
+		del, err := NewDelivery(s.ID, e)
+		if err != nil {
+			return err
+		}
+
+		if err := d.store.AddDelivery(ctx, del); err != nil {
+			return fmt.Errorf("error queueing webhook delivery for subscription %s: %s", s.ID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Close is a no-op, the dispatcher has no connections to close
+func (d *Dispatcher) Close() error {
+	return nil
+}
+
+// Deliver sends the deliveries that are due and records the outcome of every attempt.
+// A delivery that fails is attempted again after Backoff, and is marked dead after
+// MaxAttempts attempts or when its subscription was removed. Every delivery is claimed
+// before it's sent, so instances that deliver at the same time don't send it twice, and a
+// delivery whose outcome wasn't saved is attempted again once the claim times out. Only
+// errors of the store are returned, together with the number of attempts that were made.
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subs := make(map[string]*Subscription)
+	n := 0
+	for {
+		due, err := d.store.DueDeliveries(ctx, time.Now().UTC(), deliveryBatchSize)
+		if err != nil {
+			return n, fmt.Errorf("error reading due webhook deliveries: %s", err.Error())
+		}
+
+		for _, del := range due {
+			claimed, err := d.store.ClaimDelivery(ctx, del, time.Now().UTC().Add(claimTimeout))
+			if err != nil {
+				return n, fmt.Errorf("error claiming webhook delivery %s: %s", del.ID, err.Error())
+			}
+			if !claimed {
+				continue
+			}
+
+			s, ok := subs[del.SubscriptionID]
+			if !ok {
+				sub, err := d.store.GetSubscription(ctx, del.SubscriptionID)
+				switch {
+				case err == ErrNotFound:
+					s = nil
+				case err != nil:
+					return n, fmt.Errorf("error reading webhook subscription %s: %s", del.SubscriptionID, err.Error())
+				default:
+					s = &sub
+				}
+				subs[del.SubscriptionID] = s
+			}
+
+			if s == nil {
+				now := time.Now().UTC()
+				del.Status = StatusDead
+				del.Error = "the subscription was removed"
+				del.NextAttemptAt = nil
+				del.CompletedAt = &now
+			} else {
+				d.attempt(ctx, *s, &del)
+				n++
+			}
+
+			if err := d.store.SaveDelivery(ctx, del); err != nil {
+				return n, fmt.Errorf("error saving webhook delivery %s: %s", del.ID, err.Error())
+			}
+		}
+
+		if len(due) < deliveryBatchSize {
+			return n, nil
+		}
+	}
+}
+
+// attempt sends the delivery to the endpoint of the subscription once and updates the
+// delivery with the outcome
+func (d *Dispatcher) attempt(ctx context.Context, s Subscription, del *Delivery) {
+	del.Attempts++
+	del.StatusCode, del.Error = 0, ""
+
+	code, err := d.send(ctx, s, *del)
+	del.StatusCode = code
+
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		del.Status = StatusSucceeded
+		del.NextAttemptAt = nil
+		del.CompletedAt = &now
+	case del.Attempts >= MaxAttempts:
+		del.Status = StatusDead
+		del.Error = err.Error()
+		del.NextAttemptAt = nil
+		del.CompletedAt = &now
+	default:
+		next := now.Add(Backoff(del.Attempts))
+		del.Error = err.Error()
+		del.NextAttemptAt = &next
+	}
+}
+
+// send posts the payload of the delivery, signed with the secret of the subscription, and
+// returns the status code of the response
+func (d *Dispatcher) send(ctx context.Context, s Subscription, del Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", bus.ContentType)
+	req.Header.Set(SignatureHeader, Sign(s.Secret, time.Now(), del.Payload))
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(EventHeader, del.EventType)
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("endpoint returned %d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	return res.StatusCode, nil
+}
+
+// Backoff returns the time between the given attempt and the next one, which starts at a
+// minute and doubles after every attempt, up to an hour.
+func Backoff(attempts int) time.Duration {
+	b := minBackoff
+	for i := 1; i < attempts && b < maxBackoff; i++ {
+		b *= 2
+	}
+	if b > maxBackoff {
+		b = maxBackoff
+	}
+	return b
+}
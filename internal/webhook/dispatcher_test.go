@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{MaxAttempts, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// subscribe stores a subscription of url, without validating it so the tests can use
+// local servers, and publishes an event to it
+func subscribe(t *testing.T, d *Dispatcher, store Store, url string) Subscription {
+	t.Helper()
+
+	s := Subscription{ID: "sub-1", URL: url, Secret: "0123456789abcdef"}
+	if err := store.AddSubscription(context.Background(), s); err != nil {
+		t.Fatalf("AddSubscription() = %v", err)
+	}
+
+	e := bus.Event{ID: "evt-1", Type: bus.TypeProductCreated, Source: "/acme/catalog/test", Time: time.Now().UTC()}
+	if err := d.Publish(context.Background(), e); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	return s
+}
+
+func TestDeliverPrivateAddress(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	store := NewMemory()
+	d := NewDispatcher(store)
+	s := subscribe(t, d, store, server.URL)
+
+	n, err := d.Deliver(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Deliver() = %d, %v, want 1 attempt", n, err)
+	}
+	if calls != 0 {
+		t.Errorf("endpoint on a loopback address was called %d times, want 0", calls)
+	}
+
+	dels, err := store.Deliveries(context.Background(), s.ID, 10)
+	if err != nil || len(dels) != 1 {
+		t.Fatalf("Deliveries() = %v, %v, want 1 delivery", dels, err)
+	}
+	if dels[0].Status != StatusPending || !strings.Contains(dels[0].Error, "127.0.0.1") {
+		t.Errorf("delivery = %s %q, want a pending delivery that failed on the address", dels[0].Status, dels[0].Error)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	store := NewMemory()
+	d := NewDispatcher(store)
+	d.client = server.Client()
+	s := subscribe(t, d, store, server.URL)
+
+	// The first attempt fails and is retried after the backoff
+	if n, err := d.Deliver(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Deliver() = %d, %v, want 1 attempt", n, err)
+	}
+	dels, _ := store.Deliveries(context.Background(), s.ID, 10)
+	if len(dels) != 1 || dels[0].Status != StatusPending || dels[0].StatusCode != status || dels[0].NextAttemptAt == nil {
+		t.Fatalf("delivery after a failed attempt = %+v, want it pending with the status code", dels)
+	}
+	if d := time.Until(*dels[0].NextAttemptAt); d < 50*time.Second || d > time.Minute {
+		t.Errorf("next attempt in %s, want a minute", d)
+	}
+
+	if err := Verify(s.Secret, got.Header.Get(SignatureHeader), body, time.Now(), DefaultTolerance); err != nil {
+		t.Errorf("Verify() of the delivery = %v", err)
+	}
+	if got.Header.Get(DeliveryHeader) != dels[0].ID || got.Header.Get(EventHeader) != bus.TypeProductCreated {
+		t.Errorf("delivery headers = %v, want the ID of the delivery and the type of the event", got.Header)
+	}
+
+	// Deliveries aren't sent again before they're due
+	if n, err := d.Deliver(context.Background()); err != nil || n != 0 {
+		t.Fatalf("Deliver() before the backoff = %d, %v, want 0 attempts", n, err)
+	}
+
+	status = http.StatusNoContent
+	del := dels[0]
+	now := time.Now().UTC()
+	del.NextAttemptAt = &now
+	store.SaveDelivery(context.Background(), del)
+
+	if n, err := d.Deliver(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Deliver() = %d, %v, want 1 attempt", n, err)
+	}
+	dels, _ = store.Deliveries(context.Background(), s.ID, 10)
+	if len(dels) != 1 || dels[0].Status != StatusSucceeded || dels[0].Attempts != 2 || dels[0].CompletedAt == nil {
+		t.Errorf("delivery after a successful attempt = %+v, want it succeeded after 2 attempts", dels)
+	}
+}
+
+func TestDeliverRemovedSubscription(t *testing.T) {
+	store := NewMemory()
+	d := NewDispatcher(store)
+	s := subscribe(t, d, store, "https://partner.example.com/hooks")
+
+	if err := store.RemoveSubscription(context.Background(), s.ID); err != nil {
+		t.Fatalf("RemoveSubscription() = %v", err)
+	}
+
+	if n, err := d.Deliver(context.Background()); err != nil || n != 0 {
+		t.Fatalf("Deliver() = %d, %v, want 0 attempts", n, err)
+	}
+	dels, _ := store.Deliveries(context.Background(), s.ID, 10)
+	if len(dels) != 1 || dels[0].Status != StatusDead {
+		t.Errorf("delivery of a removed subscription = %+v, want it dead", dels)
+	}
+}
+
+// TestPublishTwice checks that an event that is published again, like by a relay that is
+// retried, is delivered once.
+func TestPublishTwice(t *testing.T) {
+	store := NewMemory()
+	d := NewDispatcher(store)
+	s := subscribe(t, d, store, "https://partner.example.com/hooks")
+
+	e := bus.Event{ID: "evt-1", Type: bus.TypeProductCreated, Source: "/acme/catalog/test", Time: time.Now().UTC()}
+	if err := d.Publish(context.Background(), e); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	dels, _ := store.Deliveries(context.Background(), s.ID, 10)
+	if len(dels) != 1 {
+		t.Errorf("got %d deliveries of the same event, want 1", len(dels))
+	}
+}
+
+// TestDeliverClaimed checks that a delivery that another instance claimed isn't sent,
+// until the claim times out.
+func TestDeliverClaimed(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	store := NewMemory()
+	d := NewDispatcher(store)
+	d.client = server.Client()
+	s := subscribe(t, d, store, server.URL)
+
+	// Another instance read the delivery at the same time and claimed it first
+	dels, _ := store.DueDeliveries(context.Background(), time.Now().UTC(), 10)
+	if len(dels) != 1 {
+		t.Fatalf("got %d due deliveries, want 1", len(dels))
+	}
+	claimed, err := store.ClaimDelivery(context.Background(), dels[0], time.Now().UTC().Add(time.Minute))
+	if err != nil || !claimed {
+		t.Fatalf("ClaimDelivery() = %t, %v, want the claim", claimed, err)
+	}
+	if claimed, _ := store.ClaimDelivery(context.Background(), dels[0], time.Now().UTC().Add(time.Minute)); claimed {
+		t.Errorf("the delivery was claimed twice")
+	}
+
+	if n, err := d.Deliver(context.Background()); err != nil || n != 0 || calls != 0 {
+		t.Fatalf("Deliver() of a claimed delivery = %d, %v and %d calls, want none", n, err, calls)
+	}
+
+	// The instance stopped before it saved the outcome, so the claim times out
+	del, _ := store.Deliveries(context.Background(), s.ID, 1)
+	now := time.Now().UTC()
+	del[0].NextAttemptAt = &now
+	store.SaveDelivery(context.Background(), del[0])
+
+	if n, err := d.Deliver(context.Background()); err != nil || n != 1 || calls != 1 {
+		t.Fatalf("Deliver() after the claim timed out = %d, %v and %d calls, want 1", n, err, calls)
+	}
+}
@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a store that keeps the subscriptions and deliveries in memory, for tests and
+// local development.
+type Memory struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+	}
+}
+
+// AddSubscription keeps a subscription
+func (m *Memory) AddSubscription(ctx context.Context, s Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscriptions[s.ID] = s
+	return nil
+}
+
+// GetSubscription returns a single subscription
+func (m *Memory) GetSubscription(ctx context.Context, id string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.subscriptions[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return s, nil
+}
+
+// Subscriptions returns all subscriptions, oldest first
+func (m *Memory) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(m.subscriptions))
+	for _, s := range m.subscriptions {
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs, nil
+}
+
+// RemoveSubscription removes a single subscription
+func (m *Memory) RemoveSubscription(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.subscriptions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.subscriptions, id)
+	return nil
+}
+
+// AddDelivery keeps a delivery, unless it's kept already
+func (m *Memory) AddDelivery(ctx context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.deliveries[d.ID]; !ok {
+		m.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+// SaveDelivery keeps a delivery, replacing its previous state
+func (m *Memory) SaveDelivery(ctx context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries[d.ID] = d
+	return nil
+}
+
+// ClaimDelivery moves the next attempt of a pending delivery to until, if it didn't change
+func (m *Memory) ClaimDelivery(ctx context.Context, d Delivery, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.deliveries[d.ID]
+	if !ok || cur.Status != StatusPending || cur.NextAttemptAt == nil || d.NextAttemptAt == nil || !cur.NextAttemptAt.Equal(*d.NextAttemptAt) {
+		return false, nil
+	}
+
+	cur.NextAttemptAt = &until
+	m.deliveries[d.ID] = cur
+	return true, nil
+}
+
+// DueDeliveries returns the pending deliveries that are due at now, the ones that are due
+// the longest first
+func (m *Memory) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return m.find(limit, func(d Delivery) bool {
+		return d.Status == StatusPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now)
+	}, func(a, b Delivery) bool {
+		return a.NextAttemptAt.Before(*b.NextAttemptAt)
+	})
+}
+
+// Deliveries returns the newest deliveries of a subscription
+func (m *Memory) Deliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error) {
+	return m.find(limit, func(d Delivery) bool {
+		return d.SubscriptionID == subscriptionID
+	}, func(a, b Delivery) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+}
+
+// find returns up to limit deliveries that match, in the given order.
+func (m *Memory) find(limit int, match func(d Delivery) bool, less func(a, b Delivery) bool) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := make([]Delivery, 0)
+	for _, d := range m.deliveries {
+		if match(d) {
+			found = append(found, d)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return less(found[i], found[j])
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header of a delivery that contains its signature
+	SignatureHeader = "X-Acme-Signature"
+
+	// DeliveryHeader is the header of a delivery that contains its ID, which is the same
+	// for every attempt
+	DeliveryHeader = "X-Acme-Delivery"
+
+	// EventHeader is the header of a delivery that contains the type of the event
+	EventHeader = "X-Acme-Event"
+
+	// DefaultTolerance is how old the signature of a delivery can be before Verify rejects
+	// it as a replay
+	DefaultTolerance = 5 * time.Minute
+)
+
+// Sign returns the signature of a payload sent at t, in the form t=<unix time>,v1=<hex>.
+// The signature is the HMAC-SHA256, with the secret of the subscription as key, of the
+// unix time, a dot and the payload, so a receiver can check both that the payload was
+// sent by the Catalog service and that it wasn't sent again later.
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac(secret, ts, payload)))
+}
+
+// Verify returns an error if header isn't a signature of payload with secret, or if the
+// signature is older than tolerance at now. Receivers written in Go can use it to check
+// the deliveries.
+func Verify(secret string, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var sigs [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("signature has no valid timestamp")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside the tolerance of %s", tolerance)
+	}
+
+	expected := mac(secret, ts, payload)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature doesn't match the payload")
+}
+
+// mac returns the HMAC-SHA256 of the timestamp and the payload
+func mac(secret string, ts string, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return h.Sum(nil)
+}
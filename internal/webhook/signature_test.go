@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1590000000, 0)
+	got := Sign("whsec_test", at, []byte(`{"id":"1"}`))
+
+	// HMAC-SHA256 of 1590000000.{"id":"1"} with key whsec_test
+	want := "t=1590000000,v1=fbd9b12b5eb38de393f456b4d57b1fb0ab12768a4bd4b66fa2f6d980b0daba05"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"id":"1"}`)
+	at := time.Unix(1590000000, 0)
+	sig := Sign(secret, at, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "valid", secret: secret, header: sig, payload: payload, now: at},
+		{name: "within tolerance", secret: secret, header: sig, payload: payload, now: at.Add(DefaultTolerance)},
+		{name: "clock skew", secret: secret, header: sig, payload: payload, now: at.Add(-DefaultTolerance)},
+		{name: "spaces", secret: secret, header: "t=1590000000, " + sig[len("t=1590000000,"):], payload: payload, now: at},
+		{name: "rotated secret", secret: secret, header: sig + ",v1=" + Sign("whsec_old", at, payload)[len("t=1590000000,v1="):], payload: payload, now: at},
+		{name: "replay", secret: secret, header: sig, payload: payload, now: at.Add(DefaultTolerance + time.Second), wantErr: true},
+		{name: "future", secret: secret, header: sig, payload: payload, now: at.Add(-DefaultTolerance - time.Second), wantErr: true},
+		{name: "other secret", secret: "whsec_other", header: sig, payload: payload, now: at, wantErr: true},
+		{name: "other payload", secret: secret, header: sig, payload: []byte(`{"id":"2"}`), now: at, wantErr: true},
+		{name: "other timestamp", secret: secret, header: "t=1590000001" + sig[len("t=1590000000"):], payload: payload, now: at, wantErr: true},
+		{name: "no timestamp", secret: secret, header: sig[len("t=1590000000,"):], payload: payload, now: at, wantErr: true},
+		{name: "no signature", secret: secret, header: "t=1590000000", payload: payload, now: at, wantErr: true},
+		{name: "invalid hex", secret: secret, header: "t=1590000000,v1=zz", payload: payload, now: at, wantErr: true},
+		{name: "empty", secret: secret, header: "", payload: payload, now: at, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.header, tt.payload, tt.now, DefaultTolerance)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Verify() %s = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
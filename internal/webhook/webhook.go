@@ -0,0 +1,250 @@
+// Package webhook notifies the endpoints of partners of the changes to the catalog of the
+// ACME Serverless Fitness Shop. Partners subscribe an endpoint to the types of change
+// events they're interested in, and every matching event is delivered to it as a signed
+// HTTP request. Deliveries that fail are retried with an exponential backoff, until they
+// succeed or are given up on, and every delivery is kept in a log per subscription.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+// eventTypes are the types of the events a subscription can select
+var eventTypes = []string{
+	bus.TypeProductCreated,
+	bus.TypeProductUpdated,
+	bus.TypeProductDeleted,
+	bus.TypeProductPublished,
+	bus.TypeProductUnpublished,
+}
+
+// Subscription is an endpoint that receives the change events of the catalog.
+type Subscription struct {
+	// ID is the unique identifier of the subscription
+	ID string `json:"id"`
+
+	// URL is the endpoint the events are delivered to
+	URL string `json:"url"`
+
+	// Events are the types of the events that are delivered, or every event if it's empty
+	Events []string `json:"events"`
+
+	// Secret is the key the deliveries are signed with. It's only shown when the
+	// subscription is created.
+	Secret string `json:"-"`
+
+	// CreatedAt is the moment the subscription was created
+	CreatedAt time.Time `json:"createdAt"`
+
+	// CreatedBy is who created the subscription
+	CreatedBy string `json:"createdBy,omitempty"`
+}
+
+// NewSubscription creates a subscription of endpoint to the events of the given types,
+// which can be the full type, like com.acme.catalog.ProductCreated, or the name after the
+// last dot, like ProductCreated. The deliveries are signed with secret, or with a random
+// secret if it's empty.
+func NewSubscription(endpoint string, events []string, secret string, actor string) (Subscription, error) {
+	s := Subscription{
+		ID:        uuid.Must(uuid.NewV4()).String(),
+		URL:       endpoint,
+		Events:    make([]string, 0, len(events)),
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: actor,
+	}
+
+	for _, e := range events {
+		t, err := eventType(e)
+		if err != nil {
+			return Subscription{}, err
+		}
+		s.Events = append(s.Events, t)
+	}
+
+	if s.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Subscription{}, fmt.Errorf("error generating secret: %s", err.Error())
+		}
+		s.Secret = "whsec_" + hex.EncodeToString(b)
+	}
+
+	return s, s.Validate()
+}
+
+// Validate returns an error if the subscription can't receive events. Endpoints on the
+// local machine or at an address that isn't public are rejected.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url %q is invalid", s.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %q must be http or https", s.URL)
+	}
+	if err := checkHost(u.Hostname()); err != nil {
+		return fmt.Errorf("url %q can't receive events: %s", s.URL, err.Error())
+	}
+
+	if len(s.Secret) < 16 {
+		return fmt.Errorf("secret must be at least 16 characters")
+	}
+
+	return nil
+}
+
+// Match returns true if events of the given type are delivered to the subscription.
+func (s Subscription) Match(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// eventType returns the full type of an event
+func eventType(name string) (string, error) {
+	for _, t := range eventTypes {
+		if name == t || "."+name == t[strings.LastIndex(t, "."):] {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event %q, must be one of %s", name, strings.Join(eventTypes, ", "))
+}
+
+// Status is the state of a delivery.
+type Status string
+
+const (
+	// StatusPending is a delivery that hasn't succeeded yet, and will be attempted again
+	StatusPending Status = "pending"
+
+	// StatusSucceeded is a delivery the endpoint accepted
+	StatusSucceeded Status = "succeeded"
+
+	// StatusDead is a delivery that was given up on, after it failed too often or the
+	// subscription was removed
+	StatusDead Status = "dead"
+)
+
+// ParseStatus returns the status with the given name.
+func ParseStatus(name string) (Status, error) {
+	switch s := Status(name); s {
+	case StatusPending, StatusSucceeded, StatusDead:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown status %q, must be pending, succeeded or dead", name)
+	}
+}
+
+// Delivery is a single event sent to a subscription, with the outcome of the last
+// attempt.
+type Delivery struct {
+	// ID is the unique identifier of the delivery
+	ID string `json:"id"`
+
+	// SubscriptionID is the subscription the event is delivered to
+	SubscriptionID string `json:"subscriptionId"`
+
+	// EventID is the ID of the event, which receivers can use to drop events they
+	// received twice
+	EventID string `json:"eventId"`
+
+	// EventType is the type of the event
+	EventType string `json:"eventType"`
+
+	// Payload is the event as it's sent, so every attempt sends the same body
+	Payload []byte `json:"-"`
+
+	// Status is the state of the delivery
+	Status Status `json:"status"`
+
+	// Attempts is the number of times the event was sent
+	Attempts int `json:"attempts"`
+
+	// StatusCode is the HTTP status code of the response to the last attempt, if there
+	// was one
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Error describes why the last attempt failed
+	Error string `json:"error,omitempty"`
+
+	// CreatedAt is the moment the event was queued for the subscription
+	CreatedAt time.Time `json:"createdAt"`
+
+	// NextAttemptAt is when the event is sent next, for pending deliveries
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+
+	// CompletedAt is when the delivery succeeded or was given up on
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// NewDelivery creates a pending delivery of the event to a subscription, which is
+// attempted right away. The ID of the delivery is derived from the event and the
+// subscription, so an event that is published again, like by a relay or a change stream
+// that is retried, is queued once.
+func NewDelivery(subscriptionID string, e bus.Event) (Delivery, error) {
+	payload, err := e.Marshal()
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	now := time.Now().UTC()
+	return Delivery{
+		ID:             uuid.NewV5(uuid.NamespaceURL, fmt.Sprintf("%s/%s", subscriptionID, e.ID)).String(),
+		SubscriptionID: subscriptionID,
+		EventID:        e.ID,
+		EventType:      e.Type,
+		Payload:        payload,
+		Status:         StatusPending,
+		CreatedAt:      now,
+		NextAttemptAt:  &now,
+	}, nil
+}
+
+// Store keeps the subscriptions and their deliveries.
+type Store interface {
+	// AddSubscription stores a new subscription
+	AddSubscription(ctx context.Context, s Subscription) error
+
+	// GetSubscription returns a single subscription, including its secret
+	GetSubscription(ctx context.Context, id string) (Subscription, error)
+
+	// Subscriptions returns all subscriptions, including their secrets
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+
+	// RemoveSubscription removes a subscription, its deliveries stay in the log
+	RemoveSubscription(ctx context.Context, id string) error
+
+	// AddDelivery stores a new delivery, unless a delivery with its ID is stored already
+	AddDelivery(ctx context.Context, d Delivery) error
+
+	// SaveDelivery replaces a delivery with its new state
+	SaveDelivery(ctx context.Context, d Delivery) error
+
+	// ClaimDelivery moves the next attempt of the pending delivery d to until, if it's
+	// still due at the moment it was read with. It returns false when the delivery
+	// changed since, like when another instance claimed it first.
+	ClaimDelivery(ctx context.Context, d Delivery, until time.Time) (bool, error)
+
+	// DueDeliveries returns up to limit pending deliveries that are due at now
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+
+	// Deliveries returns up to limit deliveries of a subscription, newest first
+	Deliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error)
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/retgits/acme-serverless-catalog/internal/bus"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		url     string
+		secret  string
+		wantErr bool
+	}{
+		{url: "https://partner.example.com/hooks", secret: "0123456789abcdef"},
+		{url: "http://203.0.113.10:8080/hooks", secret: "0123456789abcdef"},
+		{url: "https://[2001:db8::1]/hooks", secret: "0123456789abcdef"},
+		{url: "https://partner.example.com/hooks", secret: "short", wantErr: true},
+		{url: "ftp://partner.example.com/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "partner.example.com/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://localhost:8080/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://LOCALHOST./hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://api.localhost/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://127.0.0.1/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://127.1.2.3/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://[::1]/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://0.0.0.0/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://[::]/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://169.254.169.254/latest/meta-data", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://[fe80::1]/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://10.1.2.3/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://172.16.0.1/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://192.168.1.1/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://100.64.0.1/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://[fd00:ec2::254]/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://[::ffff:127.0.0.1]/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://224.0.0.1/hooks", secret: "0123456789abcdef", wantErr: true},
+		{url: "http://255.255.255.255/hooks", secret: "0123456789abcdef", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := Subscription{URL: tt.url, Secret: tt.secret}.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate() of %s = %v, want error %t", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewSubscription(t *testing.T) {
+	s, err := NewSubscription("https://partner.example.com/hooks", []string{"ProductCreated", bus.TypeProductDeleted}, "", "ops")
+	if err != nil {
+		t.Fatalf("NewSubscription() = %v", err)
+	}
+	if s.ID == "" || len(s.Secret) < 16 || s.CreatedBy != "ops" {
+		t.Errorf("NewSubscription() = %+v, want an ID, a generated secret and the actor", s)
+	}
+	if len(s.Events) != 2 || s.Events[0] != bus.TypeProductCreated || s.Events[1] != bus.TypeProductDeleted {
+		t.Errorf("NewSubscription() events = %v, want the full types", s.Events)
+	}
+
+	if _, err := NewSubscription("https://partner.example.com/hooks", []string{"ProductRenamed"}, "", "ops"); err == nil {
+		t.Errorf("NewSubscription() with an unknown event = nil, want an error")
+	}
+	if _, err := NewSubscription("http://127.0.0.1/hooks", nil, "", "ops"); err == nil {
+		t.Errorf("NewSubscription() with a loopback endpoint = nil, want an error")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		events    []string
+		eventType string
+		want      bool
+	}{
+		{nil, bus.TypeProductCreated, true},
+		{[]string{bus.TypeProductCreated}, bus.TypeProductCreated, true},
+		{[]string{bus.TypeProductCreated}, bus.TypeProductDeleted, false},
+		{[]string{bus.TypeProductDeleted, bus.TypeProductPublished}, bus.TypeProductPublished, true},
+	}
+
+	for _, tt := range tests {
+		if got := (Subscription{Events: tt.events}).Match(tt.eventType); got != tt.want {
+			t.Errorf("Match(%s) of %v = %t, want %t", tt.eventType, tt.events, got, tt.want)
+		}
+	}
+}
+
+func TestNewDelivery(t *testing.T) {
+	e := bus.Event{ID: "evt-1", Type: bus.TypeProductCreated}
+
+	tests := []struct {
+		name           string
+		subscriptionID string
+		eventID        string
+		same           bool
+	}{
+		{name: "same event and subscription", subscriptionID: "sub-1", eventID: "evt-1", same: true},
+		{name: "other subscription", subscriptionID: "sub-2", eventID: "evt-1"},
+		{name: "other event", subscriptionID: "sub-1", eventID: "evt-2"},
+	}
+
+	first, err := NewDelivery("sub-1", e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != StatusPending || first.NextAttemptAt == nil || first.EventID != "evt-1" {
+		t.Errorf("NewDelivery() = %+v, want a pending delivery that is due", first)
+	}
+
+	for _, tt := range tests {
+		other := e
+		other.ID = tt.eventID
+		d, err := NewDelivery(tt.subscriptionID, other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (d.ID == first.ID) != tt.same {
+			t.Errorf("%s: got IDs %s and %s, want the same ID %t", tt.name, first.ID, d.ID, tt.same)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Package catalogclient is a typed client for the HTTP API of the Catalog service of the
+// ACME Serverless Fitness Shop, so the services that call the catalog don't have to build
+// the requests and read the responses themselves.
+//
+// The client retries requests that fail for reasons that are expected to pass, with an
+// exponential backoff, adds the credentials to every request and stops as soon as the
+// context of the call is done. Responses that aren't successful are returned as an *Error.
+package catalogclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRetries is the number of times a request is retried when MaxRetries isn't set
+	defaultRetries = 3
+
+	// defaultMinBackoff is the wait before the first retry when MinBackoff isn't set
+	defaultMinBackoff = 100 * time.Millisecond
+
+	// defaultMaxBackoff is the longest wait between retries when MaxBackoff isn't set
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Config configures a Client.
+type Config struct {
+	// BaseURL is the URL the service runs at, like https://catalog-abc123-uc.a.run.app
+	BaseURL string
+
+	// HTTPClient sends the requests, or http.DefaultClient if it's nil
+	HTTPClient *http.Client
+
+	// Token is sent as the bearer token of every request
+	Token string
+
+	// TokenSource returns the bearer token of a request, for tokens that expire. It's
+	// called for every attempt and takes precedence over Token.
+	TokenSource func(ctx context.Context) (string, error)
+
+	// APIKey is sent in the X-API-Key header of every request
+	APIKey string
+
+	// MaxRetries is the number of times a failed request is retried, or 3 if it's zero.
+	// A negative number disables the retries.
+	MaxRetries int
+
+	// MinBackoff is the wait before the first retry, or 100ms if it's zero. The wait
+	// doubles with every retry.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the longest wait between retries, or 5s if it's zero
+	MaxBackoff time.Duration
+
+	// UserAgent is sent in the User-Agent header of every request
+	UserAgent string
+}
+
+// Client calls the HTTP API of the Catalog service. It's safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      func(ctx context.Context) (string, error)
+	apiKey     string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	userAgent  string
+}
+
+// New creates a client for the service at cfg.BaseURL.
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %s", err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL: scheme must be http or https")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid base URL: it can't have a query or fragment")
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(u.String(), "/"),
+		httpClient: cfg.HTTPClient,
+		token:      cfg.TokenSource,
+		apiKey:     cfg.APIKey,
+		maxRetries: cfg.MaxRetries,
+		minBackoff: cfg.MinBackoff,
+		maxBackoff: cfg.MaxBackoff,
+		userAgent:  cfg.UserAgent,
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.token == nil && cfg.Token != "" {
+		token := cfg.Token
+		c.token = func(context.Context) (string, error) { return token, nil }
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultRetries
+	}
+	if c.minBackoff <= 0 {
+		c.minBackoff = defaultMinBackoff
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = defaultMaxBackoff
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = c.minBackoff
+	}
+
+	return c, nil
+}
+
+// request describes a call to the API.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	accept string
+	header http.Header
+
+	// idempotent requests can be sent again when the outcome of an attempt isn't known
+	idempotent bool
+}
+
+// do sends the request and decodes the JSON response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, r request, out interface{}) error {
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, err := io.Copy(ioutil.Discard, res.Body)
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response of %s %s: %s", r.method, r.path, err.Error())
+	}
+	return nil
+}
+
+// send sends the request, retrying it as long as that's allowed, and returns the first
+// successful response. The caller has to close its body.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var payload []byte
+	if r.body != nil {
+		var err error
+		payload, err = json.Marshal(r.body)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request of %s %s: %s", r.method, r.path, err.Error())
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.attempt(ctx, r, payload)
+		if err == nil && res.StatusCode < 300 {
+			return res, nil
+		}
+
+		// Stop as soon as the caller gives up, rather than reporting the error the
+		// cancelled attempt ended with
+		if ctx.Err() != nil {
+			if res != nil {
+				res.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		var wait time.Duration
+		if err == nil {
+			wait = retryAfter(res)
+			err = newError(res)
+			res.Body.Close()
+		}
+
+		if attempt >= c.maxRetries || !retryable(r, err) {
+			return nil, err
+		}
+
+		if d := c.backoff(attempt); d > wait {
+			wait = d
+		}
+		if wait > c.maxBackoff {
+			wait = c.maxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the request once.
+func (c *Client) attempt(ctx context.Context, r request, payload []byte) (*http.Response, error) {
+	// The path is escaped already, so it's added to the base URL as it is
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	accept := r.accept
+	if accept == "" {
+		accept = "application/json"
+	}
+	req.Header.Set("Accept", accept)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, &tokenError{err: err}
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// backoff returns the wait before the retry after the given attempt, which doubles with
+// every attempt and is spread out randomly so clients don't retry all at once.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff
+	for i := 0; i < attempt && d < c.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryable returns true if the request can be sent again after it failed with err.
+// Requests that were rejected before they were handled can always be retried, the other
+// failures only for idempotent requests.
+func retryable(r request, err error) bool {
+	if _, ok := err.(*tokenError); ok {
+		return false
+	}
+
+	if e, ok := err.(*Error); ok {
+		switch e.StatusCode {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return r.idempotent
+		default:
+			return false
+		}
+	}
+
+	// Connections that were refused never reached the service
+	if e, ok := err.(*url.Error); ok {
+		if op, ok := e.Err.(*net.OpError); ok && op.Op == "dial" {
+			return true
+		}
+	}
+
+	return r.idempotent
+}
+
+// retryAfter returns the wait the service asked for in the Retry-After header of res.
+func retryAfter(res *http.Response) time.Duration {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// tokenError is returned when the TokenSource fails, which isn't retried.
+type tokenError struct {
+	err error
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("error getting token: %s", e.err.Error())
+}
+
+// Unwrap returns the error of the TokenSource
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+// productPath returns the path of a product, or of a resource under it.
+func productPath(id string, resource ...string) string {
+	return "/products/" + url.PathEscape(id) + suffix(resource)
+}
+
+// suffix joins the segments of a path after a resource.
+func suffix(segments []string) string {
+	if len(segments) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(segments, "/")
+}
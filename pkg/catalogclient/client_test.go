@@ -0,0 +1,470 @@
+package catalogclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client of the server at url that retries without waiting long
+func testClient(t *testing.T, url string, cfg Config) *Client {
+	t.Helper()
+
+	cfg.BaseURL = url
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = time.Millisecond
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Millisecond
+	}
+
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return c
+}
+
+// failing returns a handler that responds with status to the first n requests and with
+// the product p1 to the others, and counts the requests in calls
+func failing(n int32, status int, calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= n {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"p1","name":"Bottle","price":10}`)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "https://catalog.example.com", want: "https://catalog.example.com"},
+		{baseURL: "https://catalog.example.com/", want: "https://catalog.example.com"},
+		{baseURL: "http://localhost:8080/api/", want: "http://localhost:8080/api"},
+		{baseURL: "", wantErr: true},
+		{baseURL: "catalog.example.com", wantErr: true},
+		{baseURL: "ftp://catalog.example.com", wantErr: true},
+		{baseURL: "https://catalog.example.com?debug=true", wantErr: true},
+		{baseURL: "https://catalog.example.com#top", wantErr: true},
+		{baseURL: "https://%zz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c, err := New(Config{BaseURL: tt.baseURL})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q) = %v, want error %t", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c.baseURL != tt.want {
+			t.Errorf("New(%q) base URL = %q, want %q", tt.baseURL, c.baseURL, tt.want)
+		}
+	}
+
+	c, _ := New(Config{BaseURL: "https://catalog.example.com", MinBackoff: time.Second, MaxBackoff: time.Millisecond})
+	if c.maxRetries != defaultRetries || c.httpClient != http.DefaultClient || c.maxBackoff != time.Second {
+		t.Errorf("New() = %+v, want the defaults and MaxBackoff raised to MinBackoff", c)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		failures   int32
+		maxRetries int
+		call       func(c *Client) error
+		wantCalls  int32
+		wantErr    bool
+	}{
+		{
+			name: "idempotent request after 503", status: http.StatusServiceUnavailable, failures: 2,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: 3,
+		},
+		{
+			name: "idempotent request after 502 and 504", status: http.StatusGatewayTimeout, failures: 1,
+			call:      func(c *Client) error { _, err := c.ArchiveProduct(context.Background(), "p1"); return err },
+			wantCalls: 2,
+		},
+		{
+			name: "retries used up", status: http.StatusServiceUnavailable, failures: 10,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: defaultRetries + 1, wantErr: true,
+		},
+		{
+			name: "retries disabled", status: http.StatusServiceUnavailable, failures: 10, maxRetries: -1,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: 1, wantErr: true,
+		},
+		{
+			name: "more retries", status: http.StatusServiceUnavailable, failures: 5, maxRetries: 5,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: 6,
+		},
+		{
+			name: "non-idempotent request after 503", status: http.StatusServiceUnavailable, failures: 1,
+			call:      func(c *Client) error { _, err := c.CreateProduct(context.Background(), Product{}); return err },
+			wantCalls: 1, wantErr: true,
+		},
+		{
+			name: "non-idempotent request after 429", status: http.StatusTooManyRequests, failures: 2,
+			call:      func(c *Client) error { _, err := c.CreateProduct(context.Background(), Product{}); return err },
+			wantCalls: 3,
+		},
+		{
+			name: "graphql mutation after 503", status: http.StatusServiceUnavailable, failures: 1,
+			call:      func(c *Client) error { return c.GraphQL(context.Background(), "mutation { x }", nil, nil) },
+			wantCalls: 1, wantErr: true,
+		},
+		{
+			name: "client error", status: http.StatusBadRequest, failures: 1,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: 1, wantErr: true,
+		},
+		{
+			name: "server error", status: http.StatusInternalServerError, failures: 1,
+			call:      func(c *Client) error { _, err := c.GetProduct(context.Background(), "p1"); return err },
+			wantCalls: 1, wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		var calls int32
+		server := httptest.NewServer(failing(tt.failures, tt.status, &calls))
+		c := testClient(t, server.URL, Config{MaxRetries: tt.maxRetries})
+
+		err := tt.call(c)
+		server.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: %d calls, want %d", tt.name, calls, tt.wantCalls)
+		}
+
+		var e *Error
+		if tt.wantErr && (!errors.As(err, &e) || e.StatusCode != tt.status) {
+			t.Errorf("%s: error = %v, want an *Error with status %d", tt.name, err, tt.status)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	// A server that is closed refuses the connection
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	_, refused := http.Get(url)
+
+	get := request{method: http.MethodGet, idempotent: true}
+	post := request{method: http.MethodPost}
+
+	tests := []struct {
+		name string
+		r    request
+		err  error
+		want bool
+	}{
+		{name: "refused idempotent", r: get, err: refused, want: true},
+		{name: "refused non-idempotent", r: post, err: refused, want: true},
+		{name: "broken connection idempotent", r: get, err: errors.New("connection reset"), want: true},
+		{name: "broken connection non-idempotent", r: post, err: errors.New("connection reset"), want: false},
+		{name: "429 non-idempotent", r: post, err: &Error{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "502 idempotent", r: get, err: &Error{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "503 non-idempotent", r: post, err: &Error{StatusCode: http.StatusServiceUnavailable}, want: false},
+		{name: "500 idempotent", r: get, err: &Error{StatusCode: http.StatusInternalServerError}, want: false},
+		{name: "404 idempotent", r: get, err: &Error{StatusCode: http.StatusNotFound}, want: false},
+		{name: "token error", r: get, err: &tokenError{err: errors.New("expired")}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := retryable(tt.r, tt.err); got != tt.want {
+			t.Errorf("%s: retryable() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{header: "", min: 0, max: 0},
+		{header: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{header: "0", min: 0, max: 0},
+		{header: "-1", min: 0, max: 0},
+		{header: "soon", min: 0, max: 0},
+		{header: now.Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{header: now.Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		res := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			res.Header.Set("Retry-After", tt.header)
+		}
+		if got := retryAfter(res); got < tt.min || got > tt.max {
+			t.Errorf("retryAfter(%q) = %s, want between %s and %s", tt.header, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryAfterWait(t *testing.T) {
+	var calls int32
+	var first, second time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		second = time.Now()
+		fmt.Fprint(w, `{"id":"p1"}`)
+	}))
+	defer server.Close()
+
+	// The wait the service asks for is used when it's longer than the backoff, up to
+	// MaxBackoff
+	c := testClient(t, server.URL, Config{MaxBackoff: 300 * time.Millisecond})
+	if _, err := c.GetProduct(context.Background(), "p1"); err != nil {
+		t.Fatalf("GetProduct() = %v", err)
+	}
+	if d := second.Sub(first); d < 250*time.Millisecond || d > 900*time.Millisecond {
+		t.Errorf("retried after %s, want MaxBackoff", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{minBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{10, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := c.backoff(tt.attempt); got < tt.max/2 || got > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.max/2, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(failing(100, http.StatusServiceUnavailable, &calls))
+	defer server.Close()
+
+	// The client stops waiting for the next retry as soon as the context is done
+	c := testClient(t, server.URL, Config{MaxRetries: 100, MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetProduct(ctx, "p1")
+	if err != context.DeadlineExceeded {
+		t.Errorf("GetProduct() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("GetProduct() returned after %s, want it to stop when the context is done", d)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls, want 1", calls)
+	}
+
+	// A request that is in flight is cancelled too
+	block := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(block)
+
+	c = testClient(t, slow.URL, Config{})
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetProduct(ctx, "p1"); err != context.DeadlineExceeded {
+		t.Errorf("GetProduct() of a slow server = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, `{"id":"p1"}`)
+	}))
+	defer server.Close()
+
+	var tokens int32
+	source := func(ctx context.Context) (string, error) {
+		return fmt.Sprintf("token-%d", atomic.AddInt32(&tokens, 1)), nil
+	}
+
+	tests := []struct {
+		name          string
+		cfg           Config
+		authorization string
+		apiKey        string
+		userAgent     string
+	}{
+		{name: "none", cfg: Config{}},
+		{name: "token", cfg: Config{Token: "t0k3n"}, authorization: "Bearer t0k3n"},
+		{name: "token source", cfg: Config{Token: "t0k3n", TokenSource: source}, authorization: "Bearer token-1"},
+		{name: "api key", cfg: Config{APIKey: "s3cr3t", UserAgent: "orders/1.0"}, apiKey: "s3cr3t", userAgent: "orders/1.0"},
+	}
+
+	for _, tt := range tests {
+		c := testClient(t, server.URL, tt.cfg)
+		if _, err := c.GetProduct(context.Background(), "p1"); err != nil {
+			t.Fatalf("%s: GetProduct() = %v", tt.name, err)
+		}
+
+		if got.Get("Authorization") != tt.authorization || got.Get("X-API-Key") != tt.apiKey {
+			t.Errorf("%s: Authorization %q X-API-Key %q, want %q and %q", tt.name, got.Get("Authorization"), got.Get("X-API-Key"), tt.authorization, tt.apiKey)
+		}
+		if tt.userAgent != "" && got.Get("User-Agent") != tt.userAgent {
+			t.Errorf("%s: User-Agent %q, want %q", tt.name, got.Get("User-Agent"), tt.userAgent)
+		}
+		if got.Get("Accept") != "application/json" {
+			t.Errorf("%s: Accept %q, want application/json", tt.name, got.Get("Accept"))
+		}
+	}
+}
+
+func TestTokenSourcePerAttempt(t *testing.T) {
+	var seen []string
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		failing(1, http.StatusServiceUnavailable, &calls)(w, r)
+	}))
+	defer server.Close()
+
+	var tokens int32
+	c := testClient(t, server.URL, Config{TokenSource: func(ctx context.Context) (string, error) {
+		return fmt.Sprintf("token-%d", atomic.AddInt32(&tokens, 1)), nil
+	}})
+	if _, err := c.GetProduct(context.Background(), "p1"); err != nil {
+		t.Fatalf("GetProduct() = %v", err)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer token-1" || seen[1] != "Bearer token-2" {
+		t.Errorf("Authorization headers = %v, want a new token for every attempt", seen)
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(failing(0, http.StatusOK, &calls))
+	defer server.Close()
+
+	errExpired := errors.New("refresh token expired")
+	var tokens int32
+	c := testClient(t, server.URL, Config{TokenSource: func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&tokens, 1)
+		return "", errExpired
+	}})
+
+	_, err := c.GetProduct(context.Background(), "p1")
+	if !errors.Is(err, errExpired) {
+		t.Errorf("GetProduct() = %v, want the error of the token source", err)
+	}
+	if calls != 0 || tokens != 1 {
+		t.Errorf("%d calls and %d tokens, want no request and no retries", calls, tokens)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	c := testClient(t, server.URL+"/api", Config{})
+	ctx := context.Background()
+	c.GetProduct(ctx, "a/b c")
+	c.SetProductStatus(ctx, "p1", StatusDraft)
+	c.AdminProducts(ctx, StatusArchived)
+	c.AuditTrail(ctx, AuditQuery{ProductID: "p1", Actor: "ops"})
+	c.RollbackProduct(ctx, "p1", 2)
+
+	want := []string{
+		"GET /api/products/a%2Fb%20c?",
+		"PUT /api/products/p1/status?",
+		"GET /api/admin/products?status=archived",
+		"GET /api/audit?actor=ops&productId=p1",
+		"POST /api/products/p1/rollback?",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("requests = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBatchGetProducts(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid batch request: %v", err)
+		}
+		batches = append(batches, len(req.IDs))
+		fmt.Fprintf(w, `{"data":[{"id":%q}],"missing":[%q]}`, req.IDs[0], req.IDs[1])
+	}))
+	defer server.Close()
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", i)
+	}
+
+	c := testClient(t, server.URL, Config{})
+	res, err := c.BatchGetProducts(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("BatchGetProducts() = %v", err)
+	}
+	if len(batches) != 3 || batches[0] != 100 || batches[1] != 100 || batches[2] != 50 {
+		t.Errorf("batches = %v, want 100, 100 and 50 IDs", batches)
+	}
+	if len(res.Items) != 3 || res.Items[2].ID != "p200" || len(res.Missing) != 3 || res.Missing[1] != "p101" {
+		t.Errorf("BatchGetProducts() = %+v, want the results of all batches in order", res)
+	}
+}
@@ -0,0 +1,138 @@
+package catalogclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is the number of bytes of an error response that are read
+const maxErrorBody = 64 << 10
+
+// notFoundMessages are the messages of the errors the service returns, with a 400 Bad
+// Request, for products and webhook subscriptions that don't exist
+var notFoundMessages = []string{
+	"Unable to find product with id",
+	"subscription not found",
+}
+
+// Error is a response of the service that isn't successful. Responses in the problem
+// details format of RFC 7807, application/problem+json, are decoded into its fields.
+// For other responses, like the plain text errors of the service, the body is the
+// Detail and the status text the Title.
+type Error struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"-"`
+
+	// Type is a URI that identifies the kind of problem
+	Type string `json:"type,omitempty"`
+
+	// Title is a short summary of the kind of problem
+	Title string `json:"title,omitempty"`
+
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is a URI that identifies this occurrence of the problem
+	Instance string `json:"instance,omitempty"`
+
+	// RequestID is the ID the service logged the request with, to look it up
+	RequestID string `json:"-"`
+}
+
+// Error returns the status code and the detail of the problem
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("catalog: %d %s", e.StatusCode, msg)
+}
+
+// newError reads the error response res.
+func newError(res *http.Response) *Error {
+	e := &Error{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-ID"),
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" && json.Unmarshal(body, e) == nil {
+		return e
+	}
+
+	e.Title = http.StatusText(res.StatusCode)
+	e.Detail = strings.TrimSpace(string(body))
+	return e
+}
+
+// IsNotFound returns true if err reports that the product or webhook subscription doesn't
+// exist. The service reports those with a 400 Bad Request, so they're recognized by their
+// message.
+func IsNotFound(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.StatusCode == http.StatusNotFound {
+		return true
+	}
+	if e.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, msg := range notFoundMessages {
+		if strings.Contains(e.Detail, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnauthorized returns true if err reports that the request had no valid credentials.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden returns true if err reports that the credentials don't allow the request.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// hasStatus returns true if err is an *Error with the given status code.
+func hasStatus(err error, code int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == code
+}
+
+// GraphQLError is an error the GraphQL API returned next to, or instead of, the data.
+type GraphQLError struct {
+	// Message describes the error
+	Message string `json:"message"`
+
+	// Path is the path of the field the error belongs to
+	Path []interface{} `json:"path,omitempty"`
+}
+
+// GraphQLErrors are the errors of a GraphQL response.
+type GraphQLErrors []GraphQLError
+
+// Error returns the first error and the number of other errors
+func (e GraphQLErrors) Error() string {
+	if len(e) == 0 {
+		return "catalog: graphql: no errors"
+	}
+	if len(e) == 1 {
+		return fmt.Sprintf("catalog: graphql: %s", e[0].Message)
+	}
+	return fmt.Sprintf("catalog: graphql: %s (and %d more errors)", e[0].Message, len(e)-1)
+}
@@ -0,0 +1,137 @@
+package catalogclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		body        string
+		want        Error
+		message     string
+	}{
+		{
+			name:        "problem details",
+			contentType: "application/problem+json; charset=utf-8",
+			status:      http.StatusConflict,
+			body:        `{"type":"https://acme.example.com/problems/conflict","title":"Conflict","detail":"product p1 was changed","instance":"/products/p1"}`,
+			want:        Error{StatusCode: http.StatusConflict, Type: "https://acme.example.com/problems/conflict", Title: "Conflict", Detail: "product p1 was changed", Instance: "/products/p1", RequestID: "req-1"},
+			message:     "catalog: 409 product p1 was changed",
+		},
+		{
+			name:        "problem details without detail",
+			contentType: "application/problem+json",
+			status:      http.StatusForbidden,
+			body:        `{"title":"Missing role"}`,
+			want:        Error{StatusCode: http.StatusForbidden, Title: "Missing role", RequestID: "req-1"},
+			message:     "catalog: 403 Missing role",
+		},
+		{
+			name:        "invalid problem details",
+			contentType: "application/problem+json",
+			status:      http.StatusBadGateway,
+			body:        `<html>bad gateway</html>`,
+			want:        Error{StatusCode: http.StatusBadGateway, Title: "Bad Gateway", Detail: "<html>bad gateway</html>", RequestID: "req-1"},
+			message:     "catalog: 502 <html>bad gateway</html>",
+		},
+		{
+			name:        "plain text",
+			contentType: "text/plain; charset=utf-8",
+			status:      http.StatusBadRequest,
+			body:        "Unable to find product with id p1\n",
+			want:        Error{StatusCode: http.StatusBadRequest, Title: "Bad Request", Detail: "Unable to find product with id p1", RequestID: "req-1"},
+			message:     "catalog: 400 Unable to find product with id p1",
+		},
+		{
+			name:    "empty",
+			status:  http.StatusUnauthorized,
+			want:    Error{StatusCode: http.StatusUnauthorized, Title: "Unauthorized", RequestID: "req-1"},
+			message: "catalog: 401 Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-1")
+			if tt.contentType != "" {
+				w.Header().Set("Content-Type", tt.contentType)
+			}
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+
+		c := testClient(t, server.URL, Config{MaxRetries: -1})
+		_, err := c.GetProduct(context.Background(), "p1")
+		server.Close()
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: GetProduct() = %v, want an *Error", tt.name, err)
+			continue
+		}
+		if *e != tt.want {
+			t.Errorf("%s: error = %+v, want %+v", tt.name, *e, tt.want)
+		}
+		if err.Error() != tt.message {
+			t.Errorf("%s: message = %q, want %q", tt.name, err.Error(), tt.message)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		notFound     bool
+		unauthorized bool
+		forbidden    bool
+	}{
+		{name: "nil", err: nil},
+		{name: "other error", err: errors.New("catalog: 404")},
+		{name: "404", err: &Error{StatusCode: http.StatusNotFound}, notFound: true},
+		{name: "unknown product", err: &Error{StatusCode: http.StatusBadRequest, Detail: "Unable to find product with id p1"}, notFound: true},
+		{name: "unknown subscription", err: &Error{StatusCode: http.StatusBadRequest, Detail: "subscription not found"}, notFound: true},
+		{name: "wrapped", err: fmt.Errorf("loading order: %w", &Error{StatusCode: http.StatusNotFound}), notFound: true},
+		{name: "other bad request", err: &Error{StatusCode: http.StatusBadRequest, Detail: "name is required"}},
+		{name: "not found message with other status", err: &Error{StatusCode: http.StatusInternalServerError, Detail: "subscription not found"}},
+		{name: "401", err: &Error{StatusCode: http.StatusUnauthorized}, unauthorized: true},
+		{name: "403", err: &Error{StatusCode: http.StatusForbidden}, forbidden: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsNotFound(tt.err); got != tt.notFound {
+			t.Errorf("%s: IsNotFound() = %t, want %t", tt.name, got, tt.notFound)
+		}
+		if got := IsUnauthorized(tt.err); got != tt.unauthorized {
+			t.Errorf("%s: IsUnauthorized() = %t, want %t", tt.name, got, tt.unauthorized)
+		}
+		if got := IsForbidden(tt.err); got != tt.forbidden {
+			t.Errorf("%s: IsForbidden() = %t, want %t", tt.name, got, tt.forbidden)
+		}
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		errs GraphQLErrors
+		want string
+	}{
+		{nil, "catalog: graphql: no errors"},
+		{GraphQLErrors{{Message: "unknown field"}}, "catalog: graphql: unknown field"},
+		{GraphQLErrors{{Message: "unknown field"}, {Message: "missing variable"}, {Message: "x"}}, "catalog: graphql: unknown field (and 2 more errors)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.errs.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
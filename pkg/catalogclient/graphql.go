@@ -0,0 +1,60 @@
+package catalogclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// graphQLRequest is the body of a GraphQL request
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLResponse is the body of a GraphQL response
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQL sends a query or mutation to the GraphQL API and decodes the data of the
+// response into out, unless out is nil. Errors the API returned are reported as
+// GraphQLErrors, after the data that could be resolved is decoded. Because a mutation
+// can't safely be sent twice, GraphQL requests are only retried when they weren't
+// handled at all.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	return c.graphQL(ctx, graphQLRequest{Query: query, Variables: variables}, false, out)
+}
+
+// graphQL sends a GraphQL request. Queries are idempotent and can be retried.
+func (c *Client) graphQL(ctx context.Context, r graphQLRequest, idempotent bool, out interface{}) error {
+	var res graphQLResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/graphql", body: r, idempotent: idempotent}, &res)
+
+	// Requests that can't be executed are rejected with a 400 Bad Request, which still
+	// holds the errors
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusBadRequest {
+		if json.Unmarshal([]byte(e.Detail), &res) == nil && len(res.Errors) > 0 {
+			return res.Errors
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(res.Data) > 0 && string(res.Data) != "null" {
+		if err := json.Unmarshal(res.Data, out); err != nil {
+			return fmt.Errorf("error decoding graphql data: %s", err.Error())
+		}
+	}
+
+	if len(res.Errors) > 0 {
+		return res.Errors
+	}
+	return nil
+}
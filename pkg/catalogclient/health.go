@@ -0,0 +1,37 @@
+package catalogclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Live returns the state of the service itself, which is up as long as it handles
+// requests.
+func (c *Client) Live(ctx context.Context) (Health, error) {
+	return c.health(ctx, "/healthz")
+}
+
+// Ready returns the state of the service and of the components it depends on. A service
+// that isn't ready isn't an error, its Status is down.
+func (c *Client) Ready(ctx context.Context) (Health, error) {
+	return c.health(ctx, "/readyz")
+}
+
+// health gets a health report. The probes respond with 503 Service Unavailable when the
+// service is down, which is returned as the report rather than retried.
+func (c *Client) health(ctx context.Context, path string) (Health, error) {
+	var h Health
+
+	err := c.do(ctx, request{method: http.MethodGet, path: path}, &h)
+
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusServiceUnavailable {
+		if json.Unmarshal([]byte(e.Detail), &h) == nil && h.Status != "" {
+			return h, nil
+		}
+	}
+
+	return h, err
+}
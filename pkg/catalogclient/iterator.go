@@ -0,0 +1,164 @@
+package catalogclient
+
+import (
+	"context"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// maxPageSize is the number of products the GraphQL API returns on a single page
+const maxPageSize = 100
+
+// pageFields are the fields of a page of products
+const pageFields = `{
+    totalCount
+    pageInfo { hasNextPage endCursor }
+    nodes { id name shortDescription description imageUrl1 imageUrl2 imageUrl3 price tags }
+  }`
+
+// productsQuery gets a page of the products listed on the storefront
+const productsQuery = `query Products($first: Int, $after: String, $filter: ProductFilter) {
+  page: products(first: $first, after: $after, filter: $filter) ` + pageFields + `
+}`
+
+// searchQuery gets a page of the products that match a search
+const searchQuery = `query Search($query: String!, $first: Int, $after: String, $filter: ProductFilter) {
+  page: search(query: $query, first: $first, after: $after, filter: $filter) ` + pageFields + `
+}`
+
+// ProductQuery selects the products listed on the storefront that a ProductIterator goes
+// through. The zero value selects all of them.
+type ProductQuery struct {
+	// Search keeps the products whose name, descriptions or tags contain all of its
+	// words, ignoring case
+	Search string
+
+	// Tags keeps the products that have all of these tags
+	Tags []string
+
+	// MinPrice keeps the products that cost at least this much
+	MinPrice *float64
+
+	// MaxPrice keeps the products that cost at most this much
+	MaxPrice *float64
+
+	// PageSize is the number of products that is requested at once, up to 100, or 20 if
+	// it's zero
+	PageSize int
+}
+
+// ProductIterator goes through the products that match a ProductQuery, ordered by their
+// ID, requesting the next page when the products of the previous one are used up.
+//
+//	it := client.Products(ctx, catalogclient.ProductQuery{Tags: []string{"bottle"}})
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ProductIterator struct {
+	c   *Client
+	ctx context.Context
+	req graphQLRequest
+
+	page    []acmeserverless.CatalogItem
+	item    acmeserverless.CatalogItem
+	total   int
+	fetched bool
+	more    bool
+	err     error
+}
+
+// productPage is a page of products of the GraphQL API
+type productPage struct {
+	Page struct {
+		TotalCount int `json:"totalCount"`
+		PageInfo   struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Nodes []acmeserverless.CatalogItem `json:"nodes"`
+	} `json:"page"`
+}
+
+// Products returns an iterator over the products listed on the storefront that match q.
+// The products are read through the paginated connections of the GraphQL API.
+func (c *Client) Products(ctx context.Context, q ProductQuery) *ProductIterator {
+	variables := map[string]interface{}{}
+
+	if q.PageSize > 0 {
+		if q.PageSize > maxPageSize {
+			q.PageSize = maxPageSize
+		}
+		variables["first"] = q.PageSize
+	}
+
+	filter := map[string]interface{}{}
+	if len(q.Tags) > 0 {
+		filter["tags"] = q.Tags
+	}
+	if q.MinPrice != nil {
+		filter["minPrice"] = *q.MinPrice
+	}
+	if q.MaxPrice != nil {
+		filter["maxPrice"] = *q.MaxPrice
+	}
+	if len(filter) > 0 {
+		variables["filter"] = filter
+	}
+
+	req := graphQLRequest{Query: productsQuery, OperationName: "Products", Variables: variables}
+	if q.Search != "" {
+		variables["query"] = q.Search
+		req = graphQLRequest{Query: searchQuery, OperationName: "Search", Variables: variables}
+	}
+
+	return &ProductIterator{c: c, ctx: ctx, req: req}
+}
+
+// Next moves to the next product, and returns false when there are no more products or
+// a page couldn't be read, which is reported by Err.
+func (it *ProductIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.err != nil || (it.fetched && !it.more) {
+			return false
+		}
+		it.fetch()
+	}
+
+	it.item, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Item returns the current product.
+func (it *ProductIterator) Item() acmeserverless.CatalogItem {
+	return it.item
+}
+
+// TotalCount returns the number of products that match the query, on all pages, as
+// reported with the last page that was read.
+func (it *ProductIterator) TotalCount() int {
+	return it.total
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *ProductIterator) Err() error {
+	return it.err
+}
+
+// fetch reads the next page.
+func (it *ProductIterator) fetch() {
+	var res productPage
+	if err := it.c.graphQL(it.ctx, it.req, true, &res); err != nil {
+		it.err = err
+		return
+	}
+
+	it.fetched = true
+	it.page = res.Page.Nodes
+	it.total = res.Page.TotalCount
+	it.more = res.Page.PageInfo.HasNextPage && len(res.Page.Nodes) > 0
+	it.req.Variables["after"] = res.Page.PageInfo.EndCursor
+}
@@ -0,0 +1,199 @@
+package catalogclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pages returns a GraphQL handler that serves total products, size per page, with the
+// index of the last product as the cursor, and records the requests it got
+func pages(t *testing.T, total int, requests *[]graphQLRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid graphql request: %v", err)
+		}
+		*requests = append(*requests, req)
+
+		size := 20
+		if first, ok := req.Variables["first"].(float64); ok {
+			size = int(first)
+		}
+		start := 0
+		if after, ok := req.Variables["after"].(string); ok {
+			start, _ = strconv.Atoi(after)
+		}
+		end := start + size
+		if end > total {
+			end = total
+		}
+
+		nodes := []map[string]interface{}{}
+		for i := start; i < end; i++ {
+			nodes = append(nodes, map[string]interface{}{"id": fmt.Sprintf("p%d", i), "name": "Bottle"})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"page": map[string]interface{}{
+					"totalCount": total,
+					"pageInfo":   map[string]interface{}{"hasNextPage": end < total, "endCursor": strconv.Itoa(end)},
+					"nodes":      nodes,
+				},
+			},
+		})
+	}
+}
+
+func TestProducts(t *testing.T) {
+	min, max := 5.0, 20.0
+	tests := []struct {
+		name      string
+		total     int
+		q         ProductQuery
+		requests  int
+		operation string
+		first     interface{}
+	}{
+		{name: "empty", total: 0, requests: 1, operation: "Products"},
+		{name: "single page", total: 7, requests: 1, operation: "Products"},
+		{name: "default page size", total: 45, requests: 3, operation: "Products"},
+		{name: "page size", total: 45, q: ProductQuery{PageSize: 10}, requests: 5, operation: "Products", first: 10.0},
+		{name: "exact pages", total: 20, q: ProductQuery{PageSize: 10}, requests: 2, operation: "Products", first: 10.0},
+		{name: "page size limit", total: 150, q: ProductQuery{PageSize: 500}, requests: 2, operation: "Products", first: 100.0},
+		{name: "search", total: 3, q: ProductQuery{Search: "red bottle", Tags: []string{"bottle"}, MinPrice: &min, MaxPrice: &max}, requests: 1, operation: "Search"},
+	}
+
+	for _, tt := range tests {
+		var requests []graphQLRequest
+		server := httptest.NewServer(pages(t, tt.total, &requests))
+		c := testClient(t, server.URL, Config{})
+
+		it := c.Products(context.Background(), tt.q)
+		var ids []string
+		for it.Next() {
+			ids = append(ids, it.Item().ID)
+		}
+		server.Close()
+
+		if err := it.Err(); err != nil {
+			t.Errorf("%s: Err() = %v", tt.name, err)
+		}
+		if len(ids) != tt.total || it.TotalCount() != tt.total {
+			t.Errorf("%s: got %d products, total %d, want %d", tt.name, len(ids), it.TotalCount(), tt.total)
+		}
+		for i, id := range ids {
+			if id != fmt.Sprintf("p%d", i) {
+				t.Errorf("%s: product %d = %s, want them in order", tt.name, i, id)
+				break
+			}
+		}
+		if len(requests) != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, len(requests), tt.requests)
+			continue
+		}
+
+		first := requests[0]
+		if first.OperationName != tt.operation || first.Variables["first"] != tt.first || first.Variables["after"] != nil {
+			t.Errorf("%s: first request = %s %v, want %s with first %v", tt.name, first.OperationName, first.Variables, tt.operation, tt.first)
+		}
+		if tt.q.Search != "" {
+			filter, _ := first.Variables["filter"].(map[string]interface{})
+			if first.Variables["query"] != tt.q.Search || filter["minPrice"] != min || filter["maxPrice"] != max || len(filter["tags"].([]interface{})) != 1 {
+				t.Errorf("%s: variables = %v, want the search and the filter", tt.name, first.Variables)
+			}
+		}
+		for i := 1; i < len(requests); i++ {
+			if requests[i].Variables["after"] != requests[i-1].Variables["after"] && requests[i].Variables["after"] == nil {
+				t.Errorf("%s: request %d has no cursor", tt.name, i)
+			}
+		}
+	}
+}
+
+func TestProductsError(t *testing.T) {
+	var requests []graphQLRequest
+	serve := pages(t, 30, &requests)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(requests) == 1 {
+			requests = append(requests, graphQLRequest{})
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		serve(w, r)
+	}))
+	defer server.Close()
+
+	it := testClient(t, server.URL, Config{}).Products(context.Background(), ProductQuery{PageSize: 10})
+	n := 0
+	for it.Next() {
+		n++
+	}
+
+	// The products of the first page are used, then the iterator stops with the error of
+	// the second page
+	if n != 10 || !hasStatus(it.Err(), http.StatusInternalServerError) {
+		t.Errorf("got %d products and %v, want 10 products and the error", n, it.Err())
+	}
+	if it.Next() {
+		t.Errorf("Next() after an error = true, want false")
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantName string
+		wantErrs int
+		wantErr  bool
+	}{
+		{name: "data", status: http.StatusOK, body: `{"data":{"product":{"name":"Bottle"}}}`, wantName: "Bottle"},
+		{name: "partial data", status: http.StatusOK, body: `{"data":{"product":{"name":"Bottle"}},"errors":[{"message":"price unavailable","path":["product","price"]}]}`, wantName: "Bottle", wantErrs: 1},
+		{name: "null data", status: http.StatusOK, body: `{"data":null,"errors":[{"message":"a"},{"message":"b"}]}`, wantErrs: 2},
+		{name: "rejected", status: http.StatusBadRequest, body: `{"errors":[{"message":"unknown field \"color\""}]}`, wantErrs: 1},
+		{name: "rejected without errors", status: http.StatusBadRequest, body: `query is required`, wantErr: true},
+		{name: "invalid data", status: http.StatusOK, body: `{"data":{"product":{"name":1}}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+		c := testClient(t, server.URL, Config{})
+
+		var out struct {
+			Product struct {
+				Name string `json:"name"`
+			} `json:"product"`
+		}
+		err := c.GraphQL(context.Background(), `query { product(id: "p1") { name } }`, nil, &out)
+		server.Close()
+
+		errs, isGraphQL := err.(GraphQLErrors)
+		switch {
+		case tt.wantErrs > 0:
+			if !isGraphQL || len(errs) != tt.wantErrs {
+				t.Errorf("%s: GraphQL() = %v, want %d GraphQL errors", tt.name, err, tt.wantErrs)
+			}
+		case tt.wantErr:
+			if err == nil || isGraphQL {
+				t.Errorf("%s: GraphQL() = %v, want an error that isn't a GraphQL error", tt.name, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("%s: GraphQL() = %v", tt.name, err)
+			}
+		}
+		if out.Product.Name != tt.wantName {
+			t.Errorf("%s: name = %q, want %q", tt.name, out.Product.Name, tt.wantName)
+		}
+	}
+}
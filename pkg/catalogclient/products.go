@@ -0,0 +1,182 @@
+package catalogclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// maxBatchSize is the number of products the service returns in a single batch
+const maxBatchSize = 100
+
+// ExportFormat is the file format of an export of the catalog.
+type ExportFormat string
+
+const (
+	// ExportJSON exports the catalog as a single JSON array
+	ExportJSON ExportFormat = "json"
+
+	// ExportNDJSON exports the catalog as one JSON object per line
+	ExportNDJSON ExportFormat = "ndjson"
+
+	// ExportCSV exports the catalog as CSV with a header row
+	ExportCSV ExportFormat = "csv"
+)
+
+// CreateProduct adds a product to the catalog and returns it with its new ID. The ID of
+// p is ignored. Products without a status are drafts.
+func (c *Client) CreateProduct(ctx context.Context, p Product) (acmeserverless.CatalogItem, error) {
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+
+	var res acmeserverless.CreateCatalogItemResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/product", body: p}, &res)
+	return res.ResourceID, err
+}
+
+// GetProduct returns a product that can be looked up by the public.
+func (c *Client) GetProduct(ctx context.Context, id string) (acmeserverless.CatalogItem, error) {
+	var item acmeserverless.CatalogItem
+	err := c.do(ctx, request{method: http.MethodGet, path: productPath(id), idempotent: true}, &item)
+	return item, err
+}
+
+// ListProducts returns all products listed on the storefront in a single response. Use
+// Products to go through them a page at a time.
+func (c *Client) ListProducts(ctx context.Context) ([]acmeserverless.CatalogItem, error) {
+	var res acmeserverless.AllCatalogItemsResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/products", idempotent: true}, &res)
+	return res.Data, err
+}
+
+// BatchGetProducts returns several products at once. Products that aren't visible to the
+// public are reported as missing. The IDs are requested in batches of 100, the results
+// are combined in the order of ids.
+func (c *Client) BatchGetProducts(ctx context.Context, ids []string) (BatchResult, error) {
+	result := BatchResult{
+		Items:   []acmeserverless.CatalogItem{},
+		Missing: []string{},
+	}
+
+	for start := 0; start < len(ids); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var res BatchResult
+		body := map[string][]string{"ids": ids[start:end]}
+		if err := c.do(ctx, request{method: http.MethodPost, path: "/products:batchGet", body: body, idempotent: true}, &res); err != nil {
+			return result, err
+		}
+
+		result.Items = append(result.Items, res.Items...)
+		result.Missing = append(result.Missing, res.Missing...)
+	}
+
+	return result, nil
+}
+
+// SetProductStatus moves a product to a new lifecycle status.
+func (c *Client) SetProductStatus(ctx context.Context, id string, status Status) (StatusChange, error) {
+	var res StatusChange
+	body := map[string]Status{"status": status}
+	err := c.do(ctx, request{method: http.MethodPut, path: productPath(id, "status"), body: body, idempotent: true}, &res)
+	return res, err
+}
+
+// SetProductAvailability replaces the availability window of a product and returns the
+// product after the change.
+func (c *Client) SetProductAvailability(ctx context.Context, id string, w Window) (Product, error) {
+	var res Product
+	err := c.do(ctx, request{method: http.MethodPut, path: productPath(id, "availability"), body: w, idempotent: true}, &res)
+	return res, err
+}
+
+// ArchiveProduct removes a product from the storefront by archiving it, so orders that
+// contain it can still show its details.
+func (c *Client) ArchiveProduct(ctx context.Context, id string) (StatusChange, error) {
+	var res StatusChange
+	err := c.do(ctx, request{method: http.MethodDelete, path: productPath(id), idempotent: true}, &res)
+	return res, err
+}
+
+// ProductRevisions returns the versions of a product, oldest first.
+func (c *Client) ProductRevisions(ctx context.Context, id string) ([]Revision, error) {
+	var res struct {
+		Data []Revision `json:"data"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: productPath(id, "revisions"), idempotent: true}, &res)
+	return res.Data, err
+}
+
+// RollbackProduct restores a product to an earlier version, which is recorded as a new
+// revision.
+func (c *Client) RollbackProduct(ctx context.Context, id string, version int) (Revision, error) {
+	var res Revision
+	body := map[string]int{"version": version}
+	err := c.do(ctx, request{method: http.MethodPost, path: productPath(id, "rollback"), body: body}, &res)
+	return res, err
+}
+
+// AdminProducts returns all products in the catalog, in any status, or the products in
+// status if it isn't empty.
+func (c *Client) AdminProducts(ctx context.Context, status Status) ([]Product, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+
+	var res struct {
+		Data []Product `json:"data"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/products", query: query, idempotent: true}, &res)
+	return res.Data, err
+}
+
+// AdminProduct returns a product in any status, together with its status and
+// availability window.
+func (c *Client) AdminProduct(ctx context.Context, id string) (Product, error) {
+	var res Product
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/products/" + url.PathEscape(id), idempotent: true}, &res)
+	return res, err
+}
+
+// AuditTrail returns the entries of the audit trail that match q, oldest first.
+func (c *Client) AuditTrail(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	query := url.Values{}
+	if q.ProductID != "" {
+		query.Set("productId", q.ProductID)
+	}
+	if q.Actor != "" {
+		query.Set("actor", q.Actor)
+	}
+
+	var res struct {
+		Data []AuditEntry `json:"data"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/audit", query: query, idempotent: true}, &res)
+	return res.Data, err
+}
+
+// Export streams every product in the catalog, in any status, in the given format. The
+// caller has to close the reader.
+func (c *Client) Export(ctx context.Context, format ExportFormat) (io.ReadCloser, error) {
+	switch format {
+	case ExportJSON, ExportNDJSON, ExportCSV:
+	default:
+		return nil, fmt.Errorf("unknown export format %q, use one of json, ndjson or csv", format)
+	}
+
+	query := url.Values{"format": []string{string(format)}}
+	res, err := c.send(ctx, request{method: http.MethodGet, path: "/products:export", query: query, accept: "*/*", idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,241 @@
+package catalogclient
+
+import (
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// Status is the lifecycle state of a product.
+type Status string
+
+const (
+	// StatusDraft is the status of a product that is being prepared and isn't visible
+	// on the storefront
+	StatusDraft Status = "draft"
+
+	// StatusPublished is the status of a product that is visible on the storefront,
+	// within its availability window
+	StatusPublished Status = "published"
+
+	// StatusArchived is the status of a product that is no longer sold, but is kept for
+	// the order history
+	StatusArchived Status = "archived"
+)
+
+// Product is a catalog item together with its lifecycle status and availability window,
+// as it's shown to the callers that can change the catalog.
+type Product struct {
+	acmeserverless.CatalogItem
+
+	// Status is the lifecycle state of the product, products without a status are
+	// drafts when they're created
+	Status Status `json:"status,omitempty"`
+
+	// PublishAt is the moment the product goes live, if it's scheduled
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+
+	// UnpublishAt is the moment the product is taken off the storefront, if it's scheduled
+	UnpublishAt *time.Time `json:"unpublishAt,omitempty"`
+}
+
+// Window is the period in which a published product is available on the storefront. A
+// nil start or end means the window is open on that side.
+type Window struct {
+	// PublishAt is the moment the product goes live
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+
+	// UnpublishAt is the moment the product is taken off the storefront
+	UnpublishAt *time.Time `json:"unpublishAt,omitempty"`
+}
+
+// StatusChange is the result of moving a product to a new lifecycle status.
+type StatusChange struct {
+	// ID is the unique identifier of the product
+	ID string `json:"id"`
+
+	// Previous is the status of the product before the change
+	Previous Status `json:"previous"`
+
+	// Status is the current status of the product
+	Status Status `json:"status"`
+}
+
+// BatchResult holds the products of a batch lookup.
+type BatchResult struct {
+	// Items are the products that were found, in the order in which they were requested
+	Items []acmeserverless.CatalogItem `json:"data"`
+
+	// Missing are the IDs of the requested products that don't exist
+	Missing []string `json:"missing"`
+}
+
+// Revision is a version of a product, recorded every time it changed.
+type Revision struct {
+	// ProductID is the unique identifier of the product
+	ProductID string `json:"productId"`
+
+	// Version is the number of the revision, starting at 1 for each product
+	Version int `json:"version"`
+
+	// Operation is the kind of change that created the revision
+	Operation string `json:"operation"`
+
+	// Actor is the caller that made the change, if it's known
+	Actor string `json:"actor,omitempty"`
+
+	// CreatedAt is the moment of the change
+	CreatedAt time.Time `json:"createdAt"`
+
+	// RestoredFrom is the version a rollback restored, or 0
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+
+	// Product is the product as it was after the change
+	Product Product `json:"product"`
+}
+
+// AuditQuery limits the entries of the audit trail.
+type AuditQuery struct {
+	// ProductID limits the entries to the changes of a single product
+	ProductID string
+
+	// Actor limits the entries to the changes made by a single caller
+	Actor string
+}
+
+// AuditEntry records who changed a product, when and from where.
+type AuditEntry struct {
+	// ID is the unique identifier of the entry
+	ID string `json:"id"`
+
+	// Time is the moment of the change
+	Time time.Time `json:"time"`
+
+	// Actor is the caller that made the change
+	Actor string `json:"actor"`
+
+	// SourceIP is the IP address the change was made from
+	SourceIP string `json:"sourceIp,omitempty"`
+
+	// Operation is the kind of change
+	Operation string `json:"operation"`
+
+	// ProductID is the unique identifier of the product
+	ProductID string `json:"productId"`
+
+	// Changes are the fields that changed
+	Changes []Change `json:"changes"`
+}
+
+// Change is a field of a product that changed.
+type Change struct {
+	// Field is the name of the field
+	Field string `json:"field"`
+
+	// Before is the value before the change, or nil if the field was empty
+	Before interface{} `json:"before,omitempty"`
+
+	// After is the value after the change, or nil if the field is empty
+	After interface{} `json:"after,omitempty"`
+}
+
+// WebhookRequest subscribes an endpoint to the changes of the catalog.
+type WebhookRequest struct {
+	// URL is the endpoint the events are delivered to
+	URL string `json:"url"`
+
+	// Events are the types of the events that are delivered, or every event if it's empty
+	Events []string `json:"events,omitempty"`
+
+	// Secret is the key the deliveries are signed with, a random secret is generated when
+	// it's empty
+	Secret string `json:"secret,omitempty"`
+}
+
+// Webhook is a subscription of an endpoint to the changes of the catalog.
+type Webhook struct {
+	// ID is the unique identifier of the subscription
+	ID string `json:"id"`
+
+	// URL is the endpoint the events are delivered to
+	URL string `json:"url"`
+
+	// Events are the types of the events that are delivered, or every event if it's empty
+	Events []string `json:"events"`
+
+	// CreatedAt is the moment the subscription was created
+	CreatedAt time.Time `json:"createdAt"`
+
+	// CreatedBy is the caller that created the subscription
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	// Secret is the key the deliveries are signed with. It's only returned when the
+	// subscription is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// DeliveryQuery limits the deliveries of a webhook.
+type DeliveryQuery struct {
+	// Status limits the deliveries to the ones that are pending, succeeded or dead
+	Status string
+
+	// Limit is the number of deliveries, between 1 and 500, or 50 if it's zero
+	Limit int
+}
+
+// Delivery is an attempt to deliver an event to a webhook.
+type Delivery struct {
+	// ID is the unique identifier of the delivery
+	ID string `json:"id"`
+
+	// SubscriptionID is the unique identifier of the webhook
+	SubscriptionID string `json:"subscriptionId"`
+
+	// EventID is the ID of the event that is delivered
+	EventID string `json:"eventId"`
+
+	// EventType is the type of the event that is delivered
+	EventType string `json:"eventType"`
+
+	// Status is pending, succeeded or dead
+	Status string `json:"status"`
+
+	// Attempts is the number of times the delivery was sent
+	Attempts int `json:"attempts"`
+
+	// StatusCode is the status code of the last attempt, if the endpoint responded
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Error is the reason the last attempt failed
+	Error string `json:"error,omitempty"`
+
+	// CreatedAt is the moment the event was queued for the webhook
+	CreatedAt time.Time `json:"createdAt"`
+
+	// NextAttemptAt is the moment of the next attempt, while the delivery is pending
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+
+	// CompletedAt is the moment the delivery succeeded or was given up
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// Health is the state of the service and of the components it depends on.
+type Health struct {
+	// Status is up or down
+	Status string `json:"status"`
+
+	// Components are the states of the dependencies, by name
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+// Component is the state of a dependency of the service.
+type Component struct {
+	// Status is up or down
+	Status string `json:"status"`
+
+	// Latency is the time the check took
+	Latency string `json:"latency"`
+
+	// Error is the reason the check failed
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,159 @@
+package catalogclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// ChangeProduct is the type of a change after which the product is listed on the
+	// storefront
+	ChangeProduct = "product"
+
+	// ChangeRemove is the type of a change after which the product isn't listed on the
+	// storefront anymore, or never was
+	ChangeRemove = "remove"
+
+	// ChangeReset is the type of a change that tells the client it may have missed
+	// changes and should list the products again
+	ChangeReset = "reset"
+)
+
+// ProductChange is a change to a product on the storefront.
+type ProductChange struct {
+	// EventID is the ID of the change, to resume watching after it, or empty for resets
+	EventID string
+
+	// Type is ChangeProduct, ChangeRemove or ChangeReset
+	Type string
+
+	// ProductID is the unique identifier of the product that changed, for changes of
+	// type ChangeProduct and ChangeRemove
+	ProductID string
+
+	// Item is the product as it's listed after the change, for changes of type
+	// ChangeProduct
+	Item acmeserverless.CatalogItem
+}
+
+// Watcher reads the live feed of the changes to the products on the storefront.
+type Watcher struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	lastID string
+}
+
+// Watch opens the live feed of the changes to the products on the storefront. A client
+// that watches again with the EventID of the last change it received first gets the
+// changes it missed, or a change of type ChangeReset when those aren't known anymore.
+// The feed stays open until the context is done, the watcher is closed or the service
+// stops, so the HTTP client of the Config shouldn't have a Timeout.
+func (c *Client) Watch(ctx context.Context, lastEventID string) (*Watcher, error) {
+	r := request{method: http.MethodGet, path: "/products/stream", accept: "text/event-stream", idempotent: true}
+	if lastEventID != "" {
+		r.header = http.Header{"Last-Event-ID": []string{lastEventID}}
+	}
+
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		body:   res.Body,
+		reader: bufio.NewReader(res.Body),
+		lastID: lastEventID,
+	}, nil
+}
+
+// Next blocks until the next change arrives and returns it. It returns io.EOF when the
+// service ended the feed, after which the client can watch again from LastEventID.
+func (w *Watcher) Next() (ProductChange, error) {
+	var id, event string
+	var data []string
+	hasID := false
+
+	for {
+		line, err := w.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" {
+				return ProductChange{}, io.EOF
+			}
+			if err != io.EOF {
+				return ProductChange{}, err
+			}
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event, if one was read. Resets don't have an ID, the
+		// client resumes after the last change it received.
+		if line == "" {
+			if hasID {
+				w.lastID = id
+			}
+			if event == "" && len(data) == 0 {
+				continue
+			}
+			return newProductChange(id, event, strings.Join(data, "\n"))
+		}
+
+		// Lines that start with a colon are comments, like the keepalives
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			id, hasID = value, true
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+// LastEventID returns the ID of the last change that was read, to resume watching after it.
+func (w *Watcher) LastEventID() string {
+	return w.lastID
+}
+
+// Close closes the feed.
+func (w *Watcher) Close() error {
+	return w.body.Close()
+}
+
+// newProductChange reads the data of a change event of the feed.
+func newProductChange(id string, event string, data string) (ProductChange, error) {
+	change := ProductChange{EventID: id, Type: event}
+
+	switch event {
+	case ChangeProduct:
+		if err := json.Unmarshal([]byte(data), &change.Item); err != nil {
+			return change, fmt.Errorf("error decoding product change %s: %s", id, err.Error())
+		}
+		change.ProductID = change.Item.ID
+	case ChangeRemove:
+		var r struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return change, fmt.Errorf("error decoding remove change %s: %s", id, err.Error())
+		}
+		change.ProductID = r.ID
+	}
+
+	return change, nil
+}
@@ -0,0 +1,84 @@
+package catalogclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	var lastEventID, accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventID, accept = r.Header.Get("Last-Event-ID"), r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		fmt.Fprint(w, "id: 1\r\nevent: product\r\ndata: {\"id\":\"p1\",\r\ndata: \"name\":\"Bottle\"}\r\n\r\n")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "id: 2\nevent:remove\ndata:{\"id\":\"p2\"}\nretry: 1000\n\n")
+		fmt.Fprint(w, "id: 3\n\n")
+		fmt.Fprint(w, "id: 4\nevent: product\ndata: not json\n\n")
+		fmt.Fprint(w, "id: 5\nevent: remove\ndata: {\"id\":\"p5\"}")
+	}))
+	defer server.Close()
+
+	c := testClient(t, server.URL, Config{})
+	w, err := c.Watch(context.Background(), "41")
+	if err != nil {
+		t.Fatalf("Watch() = %v", err)
+	}
+	defer w.Close()
+
+	if lastEventID != "41" || accept != "text/event-stream" {
+		t.Errorf("Last-Event-ID %q Accept %q, want 41 and text/event-stream", lastEventID, accept)
+	}
+	if w.LastEventID() != "41" {
+		t.Errorf("LastEventID() = %q, want 41 before the first change", w.LastEventID())
+	}
+
+	tests := []struct {
+		change      ProductChange
+		lastEventID string
+		wantErr     bool
+	}{
+		{change: ProductChange{Type: ChangeReset}, lastEventID: "41"},
+		{change: ProductChange{EventID: "1", Type: ChangeProduct, ProductID: "p1"}, lastEventID: "1"},
+		{change: ProductChange{EventID: "2", Type: ChangeRemove, ProductID: "p2"}, lastEventID: "2"},
+		{change: ProductChange{EventID: "4", Type: ChangeProduct}, lastEventID: "4", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		got, err := w.Next()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Next() %d = %+v, %v, want error %t", i, got, err, tt.wantErr)
+		}
+		if got.EventID != tt.change.EventID || got.Type != tt.change.Type || got.ProductID != tt.change.ProductID {
+			t.Errorf("Next() %d = %+v, want %+v", i, got, tt.change)
+		}
+		if w.LastEventID() != tt.lastEventID {
+			t.Errorf("LastEventID() after change %d = %q, want %q", i, w.LastEventID(), tt.lastEventID)
+		}
+		if got.Type == ChangeProduct && !tt.wantErr && got.Item.Name != "Bottle" {
+			t.Errorf("Next() %d item = %+v, want the data lines joined", i, got.Item)
+		}
+	}
+
+	// An event that isn't complete when the feed ends is dropped
+	if _, err := w.Next(); err != io.EOF || w.LastEventID() != "4" {
+		t.Errorf("Next() at the end of the feed = %v, last event %q, want io.EOF and 4", err, w.LastEventID())
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing role", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := testClient(t, server.URL, Config{}).Watch(context.Background(), ""); !IsForbidden(err) {
+		t.Errorf("Watch() = %v, want a 403", err)
+	}
+}
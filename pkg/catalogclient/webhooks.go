@@ -0,0 +1,60 @@
+package catalogclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateWebhook subscribes an endpoint to the changes of the catalog. The returned
+// webhook is the only one that contains the secret the deliveries are signed with.
+func (c *Client) CreateWebhook(ctx context.Context, r WebhookRequest) (Webhook, error) {
+	var res Webhook
+	err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks", body: r}, &res)
+	return res, err
+}
+
+// Webhooks returns all webhook subscriptions, without their secrets.
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var res struct {
+		Data []Webhook `json:"data"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks", idempotent: true}, &res)
+	return res.Data, err
+}
+
+// Webhook returns a single webhook subscription, without its secret.
+func (c *Client) Webhook(ctx context.Context, id string) (Webhook, error) {
+	var res Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id), idempotent: true}, &res)
+	return res, err
+}
+
+// DeleteWebhook removes a webhook subscription, after which no more events are delivered
+// to it.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: webhookPath(id), idempotent: true}, nil)
+}
+
+// WebhookDeliveries returns the delivery log of a webhook subscription, newest first.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string, q DeliveryQuery) ([]Delivery, error) {
+	query := url.Values{}
+	if q.Status != "" {
+		query.Set("status", q.Status)
+	}
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var res struct {
+		Data []Delivery `json:"data"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id, "deliveries"), query: query, idempotent: true}, &res)
+	return res.Data, err
+}
+
+// webhookPath returns the path of a webhook subscription, or of a resource under it.
+func webhookPath(id string, resource ...string) string {
+	return "/webhooks/" + url.PathEscape(id) + suffix(resource)
+}
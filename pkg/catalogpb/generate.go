@@ -0,0 +1,3 @@
+package catalogpb
+
+//go:generate protoc -I ../../api --go_out=plugins=grpc,paths=source_relative:. catalog.proto
@@ -5,8 +5,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/apigateway"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/cloudwatch"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
@@ -48,6 +51,55 @@ type GenericConfig struct {
 
 	// WavefrontToken is your Wavefront API token
 	WavefrontToken string `json:"wavefronttoken"`
+
+	// AuthJWKS is the path or URL of the JSON Web Key Set that signs bearer tokens
+	AuthJWKS string `json:"authjwks"`
+
+	// AuthIssuer is the expected issuer of bearer tokens
+	AuthIssuer string `json:"authissuer"`
+
+	// AuthAudience is the expected audience of bearer tokens
+	AuthAudience string `json:"authaudience"`
+
+	// AuthAPIKeys are the static API keys that can change the catalog
+	AuthAPIKeys string `json:"authapikeys"`
+
+	// CORSAllowedOrigins are the origins that can call the API from a browser
+	CORSAllowedOrigins string `json:"corsallowedorigins"`
+
+	// TracesExporter is where the traces are sent, otlp, stdout or none
+	TracesExporter string `json:"tracesexporter"`
+
+	// OTLPEndpoint is the URL of the OpenTelemetry collector that receives the traces
+	OTLPEndpoint string `json:"otlpendpoint"`
+
+	// OTLPHeaders are the headers sent to the OpenTelemetry collector, for authentication
+	OTLPHeaders string `json:"otlpheaders"`
+
+	// EventsPublisher is where the changes to the catalog are published, like
+	// sns:<topic arn> or eventbridge:<bus name>
+	EventsPublisher string `json:"eventspublisher"`
+
+	// EventsDelivery is how the changes are delivered, direct or outbox
+	EventsDelivery string `json:"eventsdelivery"`
+
+	// StreamCloudFrontDistribution is the ID of the CloudFront distribution whose cached
+	// products are invalidated when they change
+	StreamCloudFrontDistribution string `json:"streamcloudfrontdistribution"`
+
+	// StreamSearchURL is the URL of the Elasticsearch index the products are kept in
+	StreamSearchURL string `json:"streamsearchurl"`
+
+	// StreamSearchDomain is the name of the Amazon Elasticsearch Service domain of
+	// StreamSearchURL, if it is one
+	StreamSearchDomain string `json:"streamsearchdomain"`
+
+	// StreamWebhookURLs are the comma separated URLs the change events are sent to
+	StreamWebhookURLs string `json:"streamwebhookurls"`
+
+	// StreamReportBatchItemFailures is true when ReportBatchItemFailures was added to the
+	// function response types of the event source mapping of the Stream function
+	StreamReportBatchItemFailures bool `json:"streamreportbatchitemfailures"`
 }
 
 func main() {
@@ -85,7 +137,10 @@ func main() {
 		functions := []string{
 			"lambda-catalog-all",
 			"lambda-catalog-get",
+			"lambda-catalog-graphql",
 			"lambda-catalog-newproduct",
+			"lambda-catalog-scheduler",
+			"lambda-catalog-stream",
 		}
 
 		// Compile and zip the AWS Lambda functions
@@ -120,6 +175,16 @@ func main() {
 		// to connect to and execute command on Amazon DynamoDB
 		iamFactory.ClearPolicies()
 		iamFactory.AddDynamoDBCrudPolicy(dynamoTable.Name)
+
+		// Allow the functions to publish the changes to the catalog
+		switch {
+		case strings.HasPrefix(genericConfig.EventsPublisher, "sns:"):
+			arn := strings.Split(genericConfig.EventsPublisher, ":")
+			iamFactory.AddSNSPublishMessagePolicy(arn[len(arn)-1])
+		case strings.HasPrefix(genericConfig.EventsPublisher, "eventbridge:"):
+			iamFactory.AddEventBridgePutEventsPolicy(strings.TrimPrefix(genericConfig.EventsPublisher, "eventbridge:"))
+		}
+
 		dynamoPolicy, err := iamFactory.GetPolicyStatement()
 		if err != nil {
 			return err
@@ -174,6 +239,16 @@ func main() {
 		variables["TABLE"] = pulumi.String(dynamoTable.Name)
 		variables["WAVEFRONT_URL"] = pulumi.String(genericConfig.WavefrontURL)
 		variables["WAVEFRONT_API_TOKEN"] = pulumi.String(genericConfig.WavefrontToken)
+		variables["AUTH_JWKS"] = pulumi.String(genericConfig.AuthJWKS)
+		variables["AUTH_ISSUER"] = pulumi.String(genericConfig.AuthIssuer)
+		variables["AUTH_AUDIENCE"] = pulumi.String(genericConfig.AuthAudience)
+		variables["AUTH_API_KEYS"] = pulumi.String(genericConfig.AuthAPIKeys)
+		variables["CORS_ALLOWED_ORIGINS"] = pulumi.String(genericConfig.CORSAllowedOrigins)
+		variables["OTEL_TRACES_EXPORTER"] = pulumi.String(genericConfig.TracesExporter)
+		variables["OTEL_EXPORTER_OTLP_ENDPOINT"] = pulumi.String(genericConfig.OTLPEndpoint)
+		variables["OTEL_EXPORTER_OTLP_HEADERS"] = pulumi.String(genericConfig.OTLPHeaders)
+		variables["EVENTS_PUBLISHER"] = pulumi.String(genericConfig.EventsPublisher)
+		variables["EVENTS_DELIVERY"] = pulumi.String(genericConfig.EventsDelivery)
 
 		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-catalog-all", ctx.Stack()))
 		environment := lambda.FunctionEnvironmentArgs{
@@ -254,6 +329,175 @@ func main() {
 
 		ctx.Export("lambda-catalog-newproduct::Arn", catalogNewProductFunction.Arn)
 
+		// Create the GraphQL function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-catalog-graphql", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to query and change the catalog with GraphQL"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-catalog-graphql", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-catalog-graphql"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-catalog-graphql/lambda-catalog-graphql.zip"),
+			Role:        roles["lambda-catalog-graphql"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		catalogGraphQLFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-catalog-graphql", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-catalog-graphql::Arn", catalogGraphQLFunction.Arn)
+
+		// Create the Scheduler function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-catalog-scheduler", ctx.Stack()))
+		variables["SCHEDULE_INTERVAL"] = pulumi.String("1m")
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to announce products that go live or are taken off the storefront and to send the webhooks"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-catalog-scheduler", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(300),
+			Handler:     pulumi.String("lambda-catalog-scheduler"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-catalog-scheduler/lambda-catalog-scheduler.zip"),
+			Role:        roles["lambda-catalog-scheduler"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		catalogSchedulerFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-catalog-scheduler", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-catalog-scheduler::Arn", catalogSchedulerFunction.Arn)
+
+		// Trigger the Scheduler function every minute, matching its SCHEDULE_INTERVAL
+		scheduleRule, err := cloudwatch.NewEventRule(ctx, fmt.Sprintf("%s-catalog-scheduler", ctx.Stack()), &cloudwatch.EventRuleArgs{
+			Description:        pulumi.String("Checks the availability windows of the products in the catalog"),
+			Name:               pulumi.String(fmt.Sprintf("%s-catalog-scheduler", ctx.Stack())),
+			ScheduleExpression: pulumi.String("rate(1 minute)"),
+			Tags:               pulumi.Map(tagMap),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = cloudwatch.NewEventTarget(ctx, fmt.Sprintf("%s-catalog-scheduler", ctx.Stack()), &cloudwatch.EventTargetArgs{
+			Arn:  catalogSchedulerFunction.Arn,
+			Rule: scheduleRule.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = lambda.NewPermission(ctx, "CatalogSchedulerPermission", &lambda.PermissionArgs{
+			Action:    pulumi.String("lambda:InvokeFunction"),
+			Function:  catalogSchedulerFunction.Name,
+			Principal: pulumi.String("events.amazonaws.com"),
+			SourceArn: scheduleRule.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Create the Stream function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-catalog-stream", ctx.Stack()))
+		variables["STREAM_CLOUDFRONT_DISTRIBUTION"] = pulumi.String(genericConfig.StreamCloudFrontDistribution)
+		variables["STREAM_SEARCH_URL"] = pulumi.String(genericConfig.StreamSearchURL)
+		variables["STREAM_WEBHOOK_URLS"] = pulumi.String(genericConfig.StreamWebhookURLs)
+		variables["STREAM_REPORT_BATCH_ITEM_FAILURES"] = pulumi.String(strconv.FormatBool(genericConfig.StreamReportBatchItemFailures))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to apply the changes in the DynamoDB stream to caches, the search index and webhooks"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-catalog-stream", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(60),
+			Handler:     pulumi.String("lambda-catalog-stream"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-catalog-stream/lambda-catalog-stream.zip"),
+			Role:        roles["lambda-catalog-stream"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		catalogStreamFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-catalog-stream", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-catalog-stream::Arn", catalogStreamFunction.Arn)
+
+		// Attach the AWSLambdaDynamoDBExecutionRole so the function can read the stream
+		_, err = iam.NewRolePolicyAttachment(ctx, "AWSLambdaDynamoDBExecutionRole-lambda-catalog-stream", &iam.RolePolicyAttachmentArgs{
+			PolicyArn: pulumi.String("arn:aws:iam::aws:policy/service-role/AWSLambdaDynamoDBExecutionRole"),
+			Role:      roles["lambda-catalog-stream"].Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Allow the function to invalidate the cache and to update the search index, the
+		// policy factory has no templates for these actions
+		var streamStatements []string
+		if genericConfig.StreamCloudFrontDistribution != "" {
+			streamStatements = append(streamStatements, fmt.Sprintf(`{ "Action": ["cloudfront:CreateInvalidation"], "Effect": "Allow", "Resource": "arn:aws:cloudfront::%s:distribution/%s" }`, genericConfig.AccountID, genericConfig.StreamCloudFrontDistribution))
+		}
+		if genericConfig.StreamSearchDomain != "" {
+			streamStatements = append(streamStatements, fmt.Sprintf(`{ "Action": ["es:ESHttpPut","es:ESHttpDelete"], "Effect": "Allow", "Resource": "arn:aws:es:%s:%s:domain/%s/*" }`, genericConfig.Region, genericConfig.AccountID, genericConfig.StreamSearchDomain))
+		}
+
+		if len(streamStatements) > 0 {
+			_, err = iam.NewRolePolicy(ctx, "ACMEServerlessCatalogStreamPolicy", &iam.RolePolicyArgs{
+				Name:   pulumi.String("ACMEServerlessCatalogStreamPolicy"),
+				Role:   roles["lambda-catalog-stream"].Name,
+				Policy: pulumi.String(fmt.Sprintf(`{ "Version": "2012-10-17", "Statement": [ %s ] }`, strings.Join(streamStatements, ","))),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Trigger the Stream function with the changes to the table, which needs a stream
+		// with new and old images. Lambda only retries the records the function reports as
+		// failed once ReportBatchItemFailures is added to the function response types of
+		// the mapping, which this provider can't set yet:
+		//
+		//   aws lambda update-event-source-mapping --uuid <uuid> --function-response-types ReportBatchItemFailures
+		//
+		// Until streamreportbatchitemfailures is set to true after that, the function fails
+		// the invocation when a record fails, so the whole batch is retried.
+		if !dynamoTable.StreamEnabled {
+			ctx.Log.Warn(fmt.Sprintf("dynamodb table %s has no stream, enable it with the NEW_AND_OLD_IMAGES view type to trigger the Stream function", dynamoTable.Name), nil)
+		} else {
+			streamMapping, err := lambda.NewEventSourceMapping(ctx, fmt.Sprintf("%s-catalog-stream", ctx.Stack()), &lambda.EventSourceMappingArgs{
+				EventSourceArn:             pulumi.String(dynamoTable.StreamArn),
+				FunctionName:               catalogStreamFunction.Arn,
+				StartingPosition:           pulumi.String("LATEST"),
+				BatchSize:                  pulumi.Int(100),
+				MaximumRetryAttempts:       pulumi.Int(10),
+				BisectBatchOnFunctionError: pulumi.Bool(true),
+			})
+			if err != nil {
+				return err
+			}
+
+			ctx.Export("catalog-stream::Uuid", streamMapping.Uuid)
+		}
+
 		// Create the API Gateway Policy
 		iamFactory.ClearPolicies()
 		iamFactory.AddAssumeRoleLambda()
@@ -354,13 +598,49 @@ func main() {
 				fmt.Println(err)
 			}
 
+			resource = gw.MustGetGatewayResource(ctx, id, "/graphql")
+
+			i4, err := apigateway.NewIntegration(ctx, "GraphQLGetAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   catalogGraphQLFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			i5, err := apigateway.NewIntegration(ctx, "GraphQLPostAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   catalogGraphQLFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "GraphQLAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  catalogGraphQLFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/*/graphql", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
 			// Create a new deployment in API Gateway
 			_, err = apigateway.NewDeployment(ctx, "prod", &apigateway.DeploymentArgs{
 				Description:      pulumi.String("deployment to the prod stage"),
 				RestApi:          gateway.ID(),
 				StageDescription: pulumi.String("Prod Stage"),
 				StageName:        pulumi.String("Prod"),
-			}, pulumi.DependsOn([]pulumi.Resource{i1, i2, i3}))
+			}, pulumi.DependsOn([]pulumi.Resource{i1, i2, i3, i4, i5}))
 			if err != nil {
 				fmt.Println(err)
 			}